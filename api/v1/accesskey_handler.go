@@ -1,11 +1,13 @@
 package v1
 
 import (
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/madhouselabs/anybase/internal/accesskey"
+	"github.com/madhouselabs/anybase/internal/governance"
 	"github.com/madhouselabs/anybase/pkg/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -26,6 +28,15 @@ type CreateAccessKeyRequest struct {
 	Description string   `json:"description"`
 	Permissions []string `json:"permissions" binding:"required"`
 	ExpiresIn   int      `json:"expires_in,omitempty"` // Hours until expiration, 0 = never expires
+
+	AllowedCIDRs   []string `json:"allowed_cidrs,omitempty"`
+	AllowedOrigins []string `json:"allowed_origins,omitempty"`
+	RateLimit      int      `json:"rate_limit,omitempty" binding:"min=0"` // Requests per minute, 0 = unlimited
+}
+
+// RegenerateAccessKeyRequest represents the optional body of a key rotation
+type RegenerateAccessKeyRequest struct {
+	GracePeriod int `json:"grace_period,omitempty" binding:"min=0"` // Hours the previous key keeps working, 0 = revoke immediately
 }
 
 // AccessKeyResponse represents the response for access key operations
@@ -33,6 +44,7 @@ type AccessKeyResponse struct {
 	ID          string    `json:"id"`
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
+	PublicID    string    `json:"public_id,omitempty"` // Identifies the key without revealing its secret
 	Key         string    `json:"key,omitempty"` // Only included on creation
 	Permissions []string  `json:"permissions"`
 	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
@@ -40,6 +52,46 @@ type AccessKeyResponse struct {
 	Active      bool      `json:"active"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+
+	AllowedCIDRs         []string   `json:"allowed_cidrs"`
+	AllowedOrigins       []string   `json:"allowed_origins"`
+	RateLimit            int        `json:"rate_limit"`
+	PreviousKeyExpiresAt *time.Time `json:"previous_key_expires_at,omitempty"`
+	RequestCount         int64      `json:"request_count"`
+	LastIP               string     `json:"last_ip,omitempty"`
+	LastEndpoint         string     `json:"last_endpoint,omitempty"`
+}
+
+func newAccessKeyResponse(key *models.AccessKey) *AccessKeyResponse {
+	response := &AccessKeyResponse{
+		ID:             key.ID.Hex(),
+		Name:           key.Name,
+		Description:    key.Description,
+		PublicID:       key.PublicID,
+		Permissions:    key.Permissions,
+		ExpiresAt:      key.ExpiresAt,
+		LastUsed:       key.LastUsed,
+		Active:         key.Active,
+		CreatedAt:      key.CreatedAt,
+		UpdatedAt:      key.UpdatedAt,
+		AllowedCIDRs:   key.AllowedCIDRs,
+		AllowedOrigins: key.AllowedOrigins,
+		RateLimit:      key.RateLimit,
+		RequestCount:   key.RequestCount,
+		LastIP:         key.LastIP,
+		LastEndpoint:   key.LastEndpoint,
+	}
+	if response.AllowedCIDRs == nil {
+		response.AllowedCIDRs = []string{}
+	}
+	if response.AllowedOrigins == nil {
+		response.AllowedOrigins = []string{}
+	}
+	// Only report a grace period that is still running
+	if key.PreviousKeyExpiresAt != nil && key.PreviousKeyExpiresAt.After(time.Now()) {
+		response.PreviousKeyExpiresAt = key.PreviousKeyExpiresAt
+	}
+	return response
 }
 
 // CreateAccessKey creates a new access key
@@ -50,6 +102,25 @@ func (h *AccessKeyHandler) CreateAccessKey(c *gin.Context) {
 		return
 	}
 
+	// The key is limited to these patterns, so reject any that could never match
+	for _, perm := range req.Permissions {
+		if err := governance.ValidatePermission(perm); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	allowedCIDRs, err := accesskey.NormalizeCIDRs(req.AllowedCIDRs)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	allowedOrigins, err := accesskey.NormalizeOrigins(req.AllowedOrigins)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Get current user ID from context
 	userID, _ := c.Get("user_id")
 	creatorID, err := primitive.ObjectIDFromHex(userID.(string))
@@ -60,10 +131,13 @@ func (h *AccessKeyHandler) CreateAccessKey(c *gin.Context) {
 
 	// Create access key model
 	ak := &models.AccessKey{
-		Name:        req.Name,
-		Description: req.Description,
-		Permissions: req.Permissions,
-		CreatedBy:   creatorID,
+		Name:           req.Name,
+		Description:    req.Description,
+		Permissions:    req.Permissions,
+		CreatedBy:      creatorID,
+		AllowedCIDRs:   allowedCIDRs,
+		AllowedOrigins: allowedOrigins,
+		RateLimit:      req.RateLimit,
 	}
 
 	// Set expiration if provided
@@ -80,17 +154,8 @@ func (h *AccessKeyHandler) CreateAccessKey(c *gin.Context) {
 	}
 
 	// Return response with the generated key
-	response := &AccessKeyResponse{
-		ID:          ak.ID.Hex(),
-		Name:        ak.Name,
-		Description: ak.Description,
-		Key:         key, // Include the key only on creation
-		Permissions: ak.Permissions,
-		ExpiresAt:   ak.ExpiresAt,
-		Active:      ak.Active,
-		CreatedAt:   ak.CreatedAt,
-		UpdatedAt:   ak.UpdatedAt,
-	}
+	response := newAccessKeyResponse(ak)
+	response.Key = key // Include the key only on creation
 
 	c.JSON(http.StatusCreated, response)
 }
@@ -106,7 +171,7 @@ func (h *AccessKeyHandler) ListAccessKeys(c *gin.Context) {
 	}
 
 	// List access keys created by this user
-	filter := bson.M{"created_by": creatorID}
+	filter := bson.M{"_created_by": creatorID.Hex()}
 	opts := options.Find().SetSort(bson.M{"created_at": -1})
 	
 	keys, err := h.repo.List(c.Request.Context(), filter, opts)
@@ -118,17 +183,7 @@ func (h *AccessKeyHandler) ListAccessKeys(c *gin.Context) {
 	// Convert to response format
 	responses := make([]*AccessKeyResponse, len(keys))
 	for i, key := range keys {
-		responses[i] = &AccessKeyResponse{
-			ID:          key.ID.Hex(),
-			Name:        key.Name,
-			Description: key.Description,
-			Permissions: key.Permissions,
-			ExpiresAt:   key.ExpiresAt,
-			LastUsed:    key.LastUsed,
-			Active:      key.Active,
-			CreatedAt:   key.CreatedAt,
-			UpdatedAt:   key.UpdatedAt,
-		}
+		responses[i] = newAccessKeyResponse(key)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"access_keys": responses})
@@ -161,19 +216,7 @@ func (h *AccessKeyHandler) GetAccessKey(c *gin.Context) {
 		return
 	}
 
-	response := &AccessKeyResponse{
-		ID:          key.ID.Hex(),
-		Name:        key.Name,
-		Description: key.Description,
-		Permissions: key.Permissions,
-		ExpiresAt:   key.ExpiresAt,
-		LastUsed:    key.LastUsed,
-		Active:      key.Active,
-		CreatedAt:   key.CreatedAt,
-		UpdatedAt:   key.UpdatedAt,
-	}
-
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusOK, newAccessKeyResponse(key))
 }
 
 // UpdateAccessKey updates an access key
@@ -221,6 +264,29 @@ func (h *AccessKeyHandler) UpdateAccessKey(c *gin.Context) {
 	if active, ok := updates["active"].(bool); ok {
 		updateDoc["active"] = active
 	}
+	if raw, ok := updates["allowed_cidrs"]; ok {
+		allowedCIDRs, err := accesskey.NormalizeCIDRs(stringList(raw))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updateDoc["allowed_cidrs"] = allowedCIDRs
+	}
+	if raw, ok := updates["allowed_origins"]; ok {
+		allowedOrigins, err := accesskey.NormalizeOrigins(stringList(raw))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updateDoc["allowed_origins"] = allowedOrigins
+	}
+	if rateLimit, ok := updates["rate_limit"].(float64); ok {
+		if rateLimit < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "rate_limit must not be negative"})
+			return
+		}
+		updateDoc["rate_limit"] = int(rateLimit)
+	}
 
 	if len(updateDoc) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "no valid updates provided"})
@@ -265,17 +331,35 @@ func (h *AccessKeyHandler) RegenerateAccessKey(c *gin.Context) {
 		return
 	}
 
+	// The body is optional; without one the old key stops working immediately
+	var req RegenerateAccessKeyRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.GracePeriod > accesskey.MaxGracePeriodHours {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("grace_period cannot exceed %d hours", accesskey.MaxGracePeriodHours)})
+		return
+	}
+
 	// Regenerate the key
-	newKey, err := h.repo.RegenerateKey(c.Request.Context(), objID)
+	gracePeriod := time.Duration(req.GracePeriod) * time.Hour
+	newKey, err := h.repo.RegenerateKey(c.Request.Context(), objID, gracePeriod)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"message": "access key regenerated successfully",
 		"key":     newKey,
-	})
+	}
+	if gracePeriod > 0 {
+		response["grace_period"] = req.GracePeriod
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // DeleteAccessKey deletes an access key
@@ -314,4 +398,16 @@ func (h *AccessKeyHandler) DeleteAccessKey(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "access key deleted successfully"})
-}
\ No newline at end of file
+}
+
+// stringList converts a decoded JSON array into strings, skipping other values
+func stringList(raw interface{}) []string {
+	items, _ := raw.([]interface{})
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		if str, ok := item.(string); ok {
+			list = append(list, str)
+		}
+	}
+	return list
+}
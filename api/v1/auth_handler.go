@@ -1,10 +1,17 @@
 package v1
 
 import (
+	"errors"
 	"net/http"
+	"net/url"
 
 	"github.com/gin-gonic/gin"
 	"github.com/madhouselabs/anybase/internal/auth"
+	"github.com/madhouselabs/anybase/internal/oidc"
+	"github.com/madhouselabs/anybase/internal/organization"
+	"github.com/madhouselabs/anybase/internal/serviceaccount"
+	"github.com/madhouselabs/anybase/internal/session"
+	"github.com/madhouselabs/anybase/internal/user"
 	"github.com/madhouselabs/anybase/pkg/models"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -29,7 +36,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 
 	user, err := h.authService.Register(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
 
@@ -46,6 +53,8 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	req.IPAddress = c.ClientIP()
+	req.UserAgent = c.Request.UserAgent()
 
 	response, err := h.authService.Login(c.Request.Context(), &req)
 	if err != nil {
@@ -61,6 +70,21 @@ func (h *AuthHandler) Login(c *gin.Context) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Account is inactive"})
 			return
 		}
+		if err == auth.ErrEmailNotVerified {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Email address is not verified"})
+			return
+		}
+		if err == auth.ErrPasswordExpired {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":            "Password has expired; set a new one with /auth/rotate-password",
+				"password_expired": true,
+			})
+			return
+		}
+		if err == organization.ErrNotMember {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to login"})
 		return
 	}
@@ -81,6 +105,10 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 
 	response, err := h.authService.RefreshToken(c.Request.Context(), req.RefreshToken)
 	if err != nil {
+		if errors.Is(err, session.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has already been used; the session has been revoked"})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
 		return
 	}
@@ -88,6 +116,46 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// SwitchOrganization issues a token pair for another organization the caller belongs to.
+// An empty org switches back to the platform.
+func (h *AuthHandler) SwitchOrganization(c *gin.Context) {
+	type SwitchRequest struct {
+		Org string `json:"org"`
+	}
+
+	var req SwitchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Access keys are bound to one organization for good
+	if !isUserToken(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only user tokens can switch organization"})
+		return
+	}
+
+	response, err := h.authService.SwitchOrganization(c.Request.Context(), getUserID(c), getSessionID(c), req.Org)
+	if err != nil {
+		if err == organization.ErrNotMember {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if err == auth.ErrAccountInactive {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is inactive"})
+			return
+		}
+		if err == auth.ErrImpersonating {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not available while impersonating a user"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to switch organization"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
 func (h *AuthHandler) Logout(c *gin.Context) {
 	// Get user ID from context (set by auth middleware)
 	userIDStr, exists := c.Get("userID")
@@ -102,7 +170,11 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 		return
 	}
 
-	if err := h.authService.Logout(c.Request.Context(), userID); err != nil {
+	if err := h.authService.Logout(c.Request.Context(), userID, getSessionID(c)); err != nil {
+		if errors.Is(err, session.ErrSessionNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only user tokens can log out"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
 		return
 	}
@@ -110,6 +182,42 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
 
+// ListSessions lists the caller's active sessions and marks the one making the request
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	sessions, err := h.authService.ListSessions(c.Request.Context(), getUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list sessions"})
+		return
+	}
+
+	current := getSessionID(c)
+	for _, s := range sessions {
+		s.Current = s.ID == current
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// RevokeSession logs out one of the caller's sessions
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	sessionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	if err := h.authService.RevokeSession(c.Request.Context(), getUserID(c), sessionID); err != nil {
+		if errors.Is(err, session.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
 func (h *AuthHandler) VerifyEmail(c *gin.Context) {
 	token := c.Query("token")
 	if token == "" {
@@ -125,6 +233,25 @@ func (h *AuthHandler) VerifyEmail(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
 }
 
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	type ResendRequest struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+
+	var req ResendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.authService.ResendVerification(c.Request.Context(), req.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the account exists and is unverified, a verification link has been sent"})
+}
+
 func (h *AuthHandler) RequestPasswordReset(c *gin.Context) {
 	type ResetRequest struct {
 		Email string `json:"email" binding:"required,email"`
@@ -148,7 +275,7 @@ func (h *AuthHandler) RequestPasswordReset(c *gin.Context) {
 func (h *AuthHandler) ResetPassword(c *gin.Context) {
 	type ResetPasswordRequest struct {
 		Token       string `json:"token" binding:"required"`
-		NewPassword string `json:"new_password" binding:"required,min=8"`
+		NewPassword string `json:"new_password" binding:"required"` // Length and strength come from the password policy
 	}
 
 	var req ResetPasswordRequest
@@ -158,6 +285,10 @@ func (h *AuthHandler) ResetPassword(c *gin.Context) {
 	}
 
 	if err := h.authService.ResetPassword(c.Request.Context(), req.Token, req.NewPassword); err != nil {
+		if isPolicyError(err) {
+			c.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
 		return
 	}
@@ -168,7 +299,7 @@ func (h *AuthHandler) ResetPassword(c *gin.Context) {
 func (h *AuthHandler) ChangePassword(c *gin.Context) {
 	type ChangePasswordRequest struct {
 		OldPassword string `json:"old_password" binding:"required"`
-		NewPassword string `json:"new_password" binding:"required,min=8"`
+		NewPassword string `json:"new_password" binding:"required"` // Length and strength come from the password policy
 	}
 
 	// Get user ID from context
@@ -195,9 +326,483 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
 			return
 		}
+		if isPolicyError(err) {
+			c.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully. Please sign in again."})
+}
+
+// RotatePassword sets a new password for a user whose password expired, without a session
+func (h *AuthHandler) RotatePassword(c *gin.Context) {
+	type RotatePasswordRequest struct {
+		Email       string `json:"email" binding:"required,email"`
+		OldPassword string `json:"old_password" binding:"required"`
+		NewPassword string `json:"new_password" binding:"required"`
+		Code        string `json:"code"` // Authentication code, when MFA is enabled
+	}
+
+	var req RotatePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.authService.RotatePassword(c.Request.Context(), req.Email, req.OldPassword, req.NewPassword, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		case errors.Is(err, auth.ErrInvalidMFACode):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+		case errors.Is(err, auth.ErrAccountLocked):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is locked due to too many failed attempts"})
+		case errors.Is(err, auth.ErrAccountInactive):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is inactive"})
+		case isPolicyError(err):
+			c.JSON(http.StatusBadRequest, errorResponse(err))
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully. Please sign in again."})
+}
+
+// GetPasswordPolicy returns the password requirements, so forms can show them up front
+func (h *AuthHandler) GetPasswordPolicy(c *gin.Context) {
+	policy, err := h.authService.PasswordPolicy(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load password policy"})
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// RequestPasswordlessLogin emails a sign-in link and code. It responds the same whether or
+// not the address has an account.
+func (h *AuthHandler) RequestPasswordlessLogin(c *gin.Context) {
+	var req models.PasswordlessRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.authService.RequestPasswordlessLogin(c.Request.Context(), &req); err != nil {
+		switch {
+		case errors.Is(err, auth.ErrPasswordlessDisabled):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Passwordless login is disabled"})
+		case errors.Is(err, auth.ErrTooManyLoginRequests):
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send sign-in email"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the address has an account, a sign-in link and code have been sent"})
+}
+
+// CompletePasswordlessLogin signs in with the token from the emailed link, or the email
+// address and code. Like Login, it may answer with an MFA challenge instead of tokens.
+func (h *AuthHandler) CompletePasswordlessLogin(c *gin.Context) {
+	var req models.PasswordlessLogin
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.IPAddress = c.ClientIP()
+	req.UserAgent = c.Request.UserAgent()
+
+	response, err := h.authService.CompletePasswordlessLogin(c.Request.Context(), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrPasswordlessDisabled):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Passwordless login is disabled"})
+		case errors.Is(err, auth.ErrInvalidLoginCode):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired sign-in link or code"})
+		case errors.Is(err, auth.ErrAccountLocked):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is locked due to too many failed attempts"})
+		case errors.Is(err, auth.ErrAccountInactive):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is inactive"})
+		case errors.Is(err, organization.ErrNotMember):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to login"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Token implements the OAuth 2.0 client credentials grant for service accounts. Clients
+// authenticate with HTTP Basic or with client_id and client_secret in the body, as a form
+// or JSON. Errors follow RFC 6749 so standard OAuth clients understand them.
+func (h *AuthHandler) Token(c *gin.Context) {
+	type TokenRequest struct {
+		GrantType    string `form:"grant_type" json:"grant_type"`
+		ClientID     string `form:"client_id" json:"client_id"`
+		ClientSecret string `form:"client_secret" json:"client_secret"`
+		Org          string `form:"org" json:"org"`
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	var req TokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		oauthError(c, http.StatusBadRequest, "invalid_request", "Malformed token request")
+		return
+	}
+	if req.GrantType != "client_credentials" {
+		oauthError(c, http.StatusBadRequest, "unsupported_grant_type", "Only the client_credentials grant is supported")
+		return
+	}
+	if id, secret, ok := c.Request.BasicAuth(); ok {
+		// RFC 6749 form-encodes the parts before Basic encoding them
+		if decoded, err := url.QueryUnescape(id); err == nil {
+			id = decoded
+		}
+		if decoded, err := url.QueryUnescape(secret); err == nil {
+			secret = decoded
+		}
+		req.ClientID, req.ClientSecret = id, secret
+	}
+	if req.ClientID == "" || req.ClientSecret == "" {
+		oauthError(c, http.StatusBadRequest, "invalid_request", "client_id and client_secret are required")
+		return
+	}
+
+	resp, err := h.authService.ExchangeClientCredentials(c.Request.Context(), req.ClientID, req.ClientSecret, req.Org)
+	if err != nil {
+		switch {
+		case errors.Is(err, serviceaccount.ErrInvalidClient):
+			c.Header("WWW-Authenticate", `Basic realm="anybase"`)
+			oauthError(c, http.StatusUnauthorized, "invalid_client", "Invalid client credentials")
+		case errors.Is(err, organization.ErrNotMember), errors.Is(err, organization.ErrOrganizationNotFound):
+			oauthError(c, http.StatusForbidden, "invalid_scope", "Service account is not a member of this organization")
+		default:
+			oauthError(c, http.StatusInternalServerError, "server_error", "Failed to issue token")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": resp.AccessToken,
+		"token_type":   "Bearer",
+		"expires_in":   resp.ExpiresIn,
+	})
+}
+
+// oauthError writes an RFC 6749 error response
+func oauthError(c *gin.Context, status int, code, description string) {
+	c.JSON(status, gin.H{"error": code, "error_description": description})
+}
+
+// ListOIDCProviders lists the identity providers users can sign in with
+func (h *AuthHandler) ListOIDCProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": h.authService.OIDCProviders()})
+}
+
+// OIDCLogin redirects the user to an identity provider to sign in. The optional org query
+// parameter picks the organization to sign in to.
+func (h *AuthHandler) OIDCLogin(c *gin.Context) {
+	authURL, err := h.authService.BeginOIDCLogin(c.Request.Context(), c.Param("provider"), c.Query("org"))
+	if err != nil {
+		if errors.Is(err, oidc.ErrProviderNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Identity provider not found"})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider is unavailable"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback completes a sign-in when the identity provider redirects back
+func (h *AuthHandler) OIDCCallback(c *gin.Context) {
+	if providerError := c.Query("error"); providerError != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":          "Sign-in was refused by the identity provider",
+			"provider_error": providerError,
+		})
+		return
+	}
+	if c.Query("code") == "" || c.Query("state") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing code or state"})
+		return
+	}
+
+	response, err := h.authService.CompleteOIDCLogin(c.Request.Context(), &models.OIDCCallback{
+		Provider:  c.Param("provider"),
+		Code:      c.Query("code"),
+		State:     c.Query("state"),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, oidc.ErrProviderNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Identity provider not found"})
+		case errors.Is(err, auth.ErrInvalidOIDCState):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Sign-in request is invalid or has expired; start again"})
+		case errors.Is(err, oidc.ErrInvalidIDToken):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Identity provider returned an invalid token"})
+		case errors.Is(err, auth.ErrOIDCEmailRequired), errors.Is(err, auth.ErrOIDCEmailConflict),
+			errors.Is(err, auth.ErrOIDCSignupDisabled):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, auth.ErrAccountLocked):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is locked due to too many failed attempts"})
+		case errors.Is(err, auth.ErrAccountInactive):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is inactive"})
+		case errors.Is(err, auth.ErrEmailNotVerified):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Email address is not verified"})
+		case errors.Is(err, organization.ErrNotMember):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Sign-in with the identity provider failed"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// VerifyMFA completes a login that returned an MFA challenge
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var req models.MFAVerification
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.IPAddress = c.ClientIP()
+	req.UserAgent = c.Request.UserAgent()
+
+	response, err := h.authService.VerifyMFA(c.Request.Context(), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidMFAToken):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token; sign in again"})
+		case errors.Is(err, auth.ErrInvalidMFACode):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+		case errors.Is(err, auth.ErrMFANotEnrolling):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Set up an authenticator with /auth/mfa/enroll first"})
+		case errors.Is(err, auth.ErrAccountLocked):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is locked due to too many failed attempts"})
+		case errors.Is(err, auth.ErrAccountInactive):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is inactive"})
+		case errors.Is(err, organization.ErrNotMember):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify authentication code"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// EnrollMFA starts setting up an authenticator. Signed-in users call it with their token;
+// users who must enroll before they can sign in pass the login's MFA token instead.
+func (h *AuthHandler) EnrollMFA(c *gin.Context) {
+	type EnrollRequest struct {
+		MFAToken string `json:"mfa_token"`
+	}
+
+	var req EnrollRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var userID primitive.ObjectID
+	if req.MFAToken != "" {
+		id, err := h.authService.ChallengeUser(c.Request.Context(), req.MFAToken)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token; sign in again"})
+			return
+		}
+		userID = id
+	} else if isUserToken(c) {
+		userID = getUserID(c)
+	} else {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	enrollment, err := h.authService.BeginMFAEnrollment(c.Request.Context(), userID)
+	if err != nil {
+		h.mfaError(c, err, "Failed to start MFA enrollment")
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmMFA turns MFA on with a code from the newly enrolled authenticator
+func (h *AuthHandler) ConfirmMFA(c *gin.Context) {
+	code, ok := bindMFACode(c)
+	if !ok {
+		return
+	}
+
+	recoveryCodes, err := h.authService.ConfirmMFAEnrollment(c.Request.Context(), getUserID(c), code)
+	if err != nil {
+		h.mfaError(c, err, "Failed to enable MFA")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "MFA enabled. Store the recovery codes somewhere safe; they are not shown again.",
+		"recovery_codes": recoveryCodes,
+	})
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	code, ok := bindMFACode(c)
+	if !ok {
+		return
+	}
+
+	recoveryCodes, err := h.authService.RegenerateRecoveryCodes(c.Request.Context(), getUserID(c), code)
+	if err != nil {
+		h.mfaError(c, err, "Failed to regenerate recovery codes")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": recoveryCodes})
+}
+
+// DisableMFA turns MFA off for the caller
+func (h *AuthHandler) DisableMFA(c *gin.Context) {
+	code, ok := bindMFACode(c)
+	if !ok {
+		return
+	}
+
+	if err := h.authService.DisableMFA(c.Request.Context(), getUserID(c), code); err != nil {
+		h.mfaError(c, err, "Failed to disable MFA")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "MFA disabled"})
+}
+
+// ResetUserMFA removes another user's authenticator, for users who lost it along with
+// their recovery codes
+func (h *AuthHandler) ResetUserMFA(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.authService.ResetMFA(c.Request.Context(), userID); err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset MFA"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "MFA reset successfully"})
+}
+
+// Impersonate lets an admin act as another user with a short-lived token. Everything done
+// with the token is checked against the user's roles and audited under both names.
+func (h *AuthHandler) Impersonate(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req models.ImpersonationRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	req.IPAddress = c.ClientIP()
+	req.UserAgent = c.Request.UserAgent()
+
+	response, err := h.authService.Impersonate(c.Request.Context(), getUserID(c), userID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		case errors.Is(err, auth.ErrImpersonationNotAllowed):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admins, service accounts and yourself cannot be impersonated"})
+		case errors.Is(err, auth.ErrAccountInactive):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is inactive"})
+		case errors.Is(err, organization.ErrNotMember):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to impersonate user"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// mfaError responds to an error from the MFA management calls
+func (h *AuthHandler) mfaError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidMFACode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid authentication code"})
+	case errors.Is(err, auth.ErrMFAAlreadyEnabled), errors.Is(err, auth.ErrMFANotEnabled),
+		errors.Is(err, auth.ErrMFANotEnrolling), errors.Is(err, auth.ErrMFAEnforced):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrAccountLocked):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is locked due to too many failed attempts"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// bindMFACode reads the code of an MFA management request, which must come with a user token
+func bindMFACode(c *gin.Context) (string, bool) {
+	type CodeRequest struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	if !isUserToken(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "MFA can only be managed with a user token"})
+		return "", false
+	}
+	var req CodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	return req.Code, true
+}
+
+// isUserToken reports whether the request was authenticated with a user's JWT rather than
+// an access key
+func isUserToken(c *gin.Context) bool {
+	authType, _ := c.Get("auth_type")
+	return authType == "jwt"
+}
+
+// getSessionID returns the session of the token that authenticated the request
+func getSessionID(c *gin.Context) primitive.ObjectID {
+	sessionID, err := primitive.ObjectIDFromHex(c.GetString("session_id"))
+	if err != nil {
+		return primitive.NilObjectID
+	}
+	return sessionID
 }
\ No newline at end of file
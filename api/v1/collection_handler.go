@@ -3,6 +3,7 @@ package v1
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -10,6 +11,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/madhouselabs/anybase/internal/collection"
+	"github.com/madhouselabs/anybase/internal/governance"
+	"github.com/madhouselabs/anybase/internal/password"
+	"github.com/madhouselabs/anybase/internal/validator"
 	"github.com/madhouselabs/anybase/pkg/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -126,37 +130,26 @@ func (h *CollectionHandler) ListCollections(c *gin.Context) {
 	// Check if authenticated via access key
 	authType, _ := c.Get("auth_type")
 	if authType == "access_key" {
-		// For access keys, check permissions and return all collections they have access to
-		permissions, _ := c.Get("permissions")
-		perms := permissions.([]string)
+		// For access keys, return only the collections the key can read
+		collections, err := h.collectionService.ListCollections(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 		
-		// Check if has collection read permission
-		hasPermission := false
-		for _, perm := range perms {
-			if perm == "collection:*:read" || perm == "*:*:*" {
-				hasPermission = true
-				break
-			}
-			// Check for specific collection permissions
-			if strings.HasPrefix(perm, "collection:") && strings.HasSuffix(perm, ":read") {
-				hasPermission = true
-				break
+		readable := []*models.Collection{}
+		for _, col := range collections {
+			if keyHasPermission(c, "collection:"+col.Name+":read") {
+				readable = append(readable, col)
 			}
 		}
 		
-		if !hasPermission {
+		if len(readable) == 0 {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions to list collections"})
 			return
 		}
 		
-		// Return all collections (permissions are checked at operation level)
-		collections, err := h.collectionService.ListCollections(c.Request.Context())
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		
-		c.JSON(http.StatusOK, gin.H{"collections": collections})
+		c.JSON(http.StatusOK, gin.H{"collections": readable})
 		return
 	}
 	
@@ -237,18 +230,9 @@ func (h *CollectionHandler) QueryView(c *gin.Context) {
 	authType, _ := c.Get("auth_type")
 	if authType == "access_key" {
 		// For access keys, check specific view execute permission
-		permissions, _ := c.Get("permissions")
-		perms := permissions.([]string)
-		
 		// Check for specific view execute permission or wildcard
-		hasPermission := false
 		requiredPerm := "view:" + name + ":execute"
-		for _, perm := range perms {
-			if perm == requiredPerm || perm == "view:*:execute" || perm == "*:*:*" {
-				hasPermission = true
-				break
-			}
-		}
+		hasPermission := keyHasPermission(c, requiredPerm)
 		
 		if !hasPermission {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: missing permission " + requiredPerm})
@@ -286,8 +270,7 @@ func (h *CollectionHandler) QueryView(c *gin.Context) {
 			}
 		}
 		
-		// Use special context to bypass user checks for access keys
-		ctx := context.WithValue(c.Request.Context(), "access_key_validated", true)
+		ctx := c.Request.Context()
 		
 		// Execute view with NilObjectID for access keys
 		results, err := h.collectionService.QueryView(ctx, primitive.NilObjectID, name, opts)
@@ -310,7 +293,7 @@ func (h *CollectionHandler) QueryView(c *gin.Context) {
 
 	// JWT auth - use userID
 	userID := getUserID(c)
-	if userID == primitive.NilObjectID {
+	if userID == primitive.NilObjectID && !isAnonymous(c) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
@@ -446,26 +429,16 @@ func (h *CollectionHandler) InsertDocument(c *gin.Context) {
 	authType, _ := c.Get("auth_type")
 	if authType == "access_key" {
 		// For access keys, check permissions
-		permissions, _ := c.Get("permissions")
-		perms := permissions.([]string)
-		
 		// Check if has permission to write to this specific collection
-		hasPermission := false
 		requiredPerm := "collection:" + collectionName + ":write"
-		for _, perm := range perms {
-			if perm == requiredPerm || perm == "collection:*:write" || perm == "collection:*:*" || perm == "*:*:*" {
-				hasPermission = true
-				break
-			}
-		}
+		hasPermission := keyHasPermission(c, requiredPerm)
 		
 		if !hasPermission {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions to write to collection"})
 			return
 		}
 		
-		// Use special context to bypass user checks
-		ctx := context.WithValue(c.Request.Context(), "access_key_validated", true)
+		ctx := c.Request.Context()
 		
 		// Get access key ID to track who created the document
 		accessKeyID := primitive.NilObjectID
@@ -487,7 +460,7 @@ func (h *CollectionHandler) InsertDocument(c *gin.Context) {
 		
 		doc, err := h.collectionService.InsertDocument(ctx, mutation)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			c.JSON(http.StatusBadRequest, errorResponse(err))
 			return
 		}
 		
@@ -512,7 +485,7 @@ func (h *CollectionHandler) InsertDocument(c *gin.Context) {
 
 	doc, err := h.collectionService.InsertDocument(c.Request.Context(), mutation)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
 
@@ -534,31 +507,27 @@ func (h *CollectionHandler) GetDocument(c *gin.Context) {
 	authType, _ := c.Get("auth_type")
 	if authType == "access_key" {
 		// For access keys, check permissions
-		permissions, _ := c.Get("permissions")
-		perms := permissions.([]string)
-		
 		// Check if has permission to read this specific collection
-		hasPermission := false
 		requiredPerm := "collection:" + collectionName + ":read"
-		for _, perm := range perms {
-			if perm == requiredPerm || perm == "collection:*:read" || perm == "collection:*:*" || perm == "*:*:*" {
-				hasPermission = true
-				break
-			}
-		}
+		hasPermission := keyHasPermission(c, requiredPerm)
 		
 		if !hasPermission {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions to read collection"})
 			return
 		}
 		
-		// Use special context to bypass user checks
-		ctx := context.WithValue(c.Request.Context(), "access_key_validated", true)
+		ctx := c.Request.Context()
 		doc, err := h.collectionService.GetDocument(ctx, primitive.NilObjectID, collectionName, objectID)
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
 		}
+
+		// Inline referenced documents
+		if err := h.expandDocument(ctx, primitive.NilObjectID, collectionName, doc, parseExpand(c)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		
 		c.JSON(http.StatusOK, doc)
 		return
@@ -566,7 +535,7 @@ func (h *CollectionHandler) GetDocument(c *gin.Context) {
 
 	// JWT auth - use userID
 	userID := getUserID(c)
-	if userID == primitive.NilObjectID {
+	if userID == primitive.NilObjectID && !isAnonymous(c) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
@@ -577,6 +546,12 @@ func (h *CollectionHandler) GetDocument(c *gin.Context) {
 		return
 	}
 
+	// Inline referenced documents
+	if err := h.expandDocument(c.Request.Context(), userID, collectionName, doc, parseExpand(c)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, doc)
 }
 
@@ -601,26 +576,16 @@ func (h *CollectionHandler) UpdateDocument(c *gin.Context) {
 	authType, _ := c.Get("auth_type")
 	if authType == "access_key" {
 		// For access keys, check permissions
-		permissions, _ := c.Get("permissions")
-		perms := permissions.([]string)
-		
 		// Check if has permission to update this specific collection
-		hasPermission := false
 		requiredPerm := "collection:" + collectionName + ":update"
-		for _, perm := range perms {
-			if perm == requiredPerm || perm == "collection:*:update" || perm == "collection:*:*" || perm == "*:*:*" {
-				hasPermission = true
-				break
-			}
-		}
+		hasPermission := keyHasPermission(c, requiredPerm)
 		
 		if !hasPermission {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions to update collection"})
 			return
 		}
 		
-		// Use special context to bypass user checks
-		ctx := context.WithValue(c.Request.Context(), "access_key_validated", true)
+		ctx := c.Request.Context()
 		
 		// Get access key ID to track who updated the document
 		accessKeyID := primitive.NilObjectID
@@ -642,7 +607,7 @@ func (h *CollectionHandler) UpdateDocument(c *gin.Context) {
 		}
 		
 		if err := h.collectionService.UpdateDocument(ctx, mutation); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			c.JSON(http.StatusBadRequest, errorResponse(err))
 			return
 		}
 		
@@ -667,7 +632,7 @@ func (h *CollectionHandler) UpdateDocument(c *gin.Context) {
 	}
 
 	if err := h.collectionService.UpdateDocument(c.Request.Context(), mutation); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
 
@@ -689,26 +654,16 @@ func (h *CollectionHandler) DeleteDocument(c *gin.Context) {
 	authType, _ := c.Get("auth_type")
 	if authType == "access_key" {
 		// For access keys, check permissions
-		permissions, _ := c.Get("permissions")
-		perms := permissions.([]string)
-		
 		// Check if has permission to delete from this specific collection
-		hasPermission := false
 		requiredPerm := "collection:" + collectionName + ":delete"
-		for _, perm := range perms {
-			if perm == requiredPerm || perm == "collection:*:delete" || perm == "collection:*:*" || perm == "*:*:*" {
-				hasPermission = true
-				break
-			}
-		}
+		hasPermission := keyHasPermission(c, requiredPerm)
 		
 		if !hasPermission {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions to delete from collection"})
 			return
 		}
 		
-		// Use special context to bypass user checks
-		ctx := context.WithValue(c.Request.Context(), "access_key_validated", true)
+		ctx := c.Request.Context()
 		
 		// Get access key ID to track who deleted the document
 		accessKeyID := primitive.NilObjectID
@@ -768,27 +723,16 @@ func (h *CollectionHandler) QueryDocuments(c *gin.Context) {
 	authType, _ := c.Get("auth_type")
 	if authType == "access_key" {
 		// For access keys, check permissions
-		permissions, _ := c.Get("permissions")
-		perms := permissions.([]string)
-		
 		// Check if has permission to read this specific collection
-		hasPermission := false
 		requiredPerm := "collection:" + collectionName + ":read"
-		for _, perm := range perms {
-			if perm == requiredPerm || perm == "collection:*:read" || perm == "collection:*:*" || perm == "*:*:*" {
-				hasPermission = true
-				break
-			}
-		}
+		hasPermission := keyHasPermission(c, requiredPerm)
 		
 		if !hasPermission {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions to read collection"})
 			return
 		}
 		
-		// For access keys, we bypass the service's CanRead check by using a special context
-		// The service should check for this and allow access
-		ctx := context.WithValue(c.Request.Context(), "access_key_validated", true)
+		ctx := c.Request.Context()
 		
 		// Query without userID for access keys
 		query := &models.DataQuery{
@@ -818,6 +762,7 @@ func (h *CollectionHandler) QueryDocuments(c *gin.Context) {
 		if fields := c.QueryArray("fields"); len(fields) > 0 {
 			query.Fields = fields
 		}
+		query.Expand = parseExpand(c)
 		
 		// Parse pagination parameters
 		page := 1
@@ -843,35 +788,41 @@ func (h *CollectionHandler) QueryDocuments(c *gin.Context) {
 			return
 		}
 		
+		facets, err := h.queryFacets(ctx, primitive.NilObjectID, collectionName, query.Filter, c.Query("facets"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		
 		
 		// Get total count for pagination (for access keys, pass nil userID)
 		totalCount, err := h.collectionService.CountDocuments(ctx, primitive.NilObjectID, collectionName, query.Filter)
 		if err != nil {
 			// If count fails, just return the data without total
-			c.JSON(http.StatusOK, gin.H{
+			c.JSON(http.StatusOK, withFacets(gin.H{
 				"data":  docs,
 				"count": len(docs),
 				"page":  page,
 				"limit": limit,
-			})
+			}, facets))
 			return
 		}
 		
 		totalPages := (totalCount + limit - 1) / limit
 		
-		c.JSON(http.StatusOK, gin.H{
+		c.JSON(http.StatusOK, withFacets(gin.H{
 			"data":       docs,
 			"total":      totalCount,
 			"page":       page,
 			"limit":      limit,
 			"totalPages": totalPages,
-		})
+		}, facets))
 		return
 	}
 	
 	// JWT auth - use userID as before
 	userID := getUserID(c)
-	if userID == primitive.NilObjectID {
+	if userID == primitive.NilObjectID && !isAnonymous(c) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
@@ -903,6 +854,7 @@ func (h *CollectionHandler) QueryDocuments(c *gin.Context) {
 	if fields := c.QueryArray("fields"); len(fields) > 0 {
 		query.Fields = fields
 	}
+	query.Expand = parseExpand(c)
 
 	// Parse pagination parameters
 	page := 1
@@ -927,32 +879,143 @@ func (h *CollectionHandler) QueryDocuments(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+
+	facets, err := h.queryFacets(c.Request.Context(), userID, collectionName, query.Filter, c.Query("facets"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	
 	
 	// Get total count for pagination
 	totalCount, err := h.collectionService.CountDocuments(c.Request.Context(), userID, collectionName, query.Filter)
 	if err != nil {
 		// If count fails, just return the data without total
-		c.JSON(http.StatusOK, gin.H{
+		c.JSON(http.StatusOK, withFacets(gin.H{
 			"data":  docs,
 			"count": len(docs),
 			"page":  page,
 			"limit": limit,
-		})
+		}, facets))
 		return
 	}
 	
 	totalPages := (totalCount + limit - 1) / limit
 
-	c.JSON(http.StatusOK, gin.H{
+	c.JSON(http.StatusOK, withFacets(gin.H{
 		"data":       docs,
 		"total":      totalCount,
 		"page":       page,
 		"limit":      limit,
 		"totalPages": totalPages,
+	}, facets))
+}
+
+// DistinctValues returns the distinct values of a field with their document counts
+func (h *CollectionHandler) DistinctValues(c *gin.Context) {
+	collectionName := c.Param("collection")
+	field := c.Param("field")
+
+	var filter map[string]interface{}
+	if filterStr := c.Query("filter"); filterStr != "" {
+		if err := json.Unmarshal([]byte(filterStr), &filter); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid filter"})
+			return
+		}
+	}
+
+	limit := 0
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+
+	ctx := c.Request.Context()
+	userID := getUserID(c)
+
+	// Check if authenticated via access key
+	if authType, _ := c.Get("auth_type"); authType == "access_key" {
+		requiredPerm := "collection:" + collectionName + ":read"
+		hasPermission := keyHasPermission(c, requiredPerm)
+
+		if !hasPermission {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions to read collection"})
+			return
+		}
+
+		userID = primitive.NilObjectID
+	} else if userID == primitive.NilObjectID && !isAnonymous(c) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	values, err := h.collectionService.DistinctValues(ctx, userID, collectionName, field, filter, limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"field":  field,
+		"values": values,
+		"count":  len(values),
 	})
 }
 
+// parseExpand reads the comma-separated expand query parameter, e.g. ?expand=author,comments.user
+func parseExpand(c *gin.Context) []string {
+	var paths []string
+	for _, value := range c.QueryArray("expand") {
+		for _, path := range strings.Split(value, ",") {
+			if path = strings.TrimSpace(path); path != "" {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+// queryFacets computes the aggregates requested by the facets query parameter, a JSON object
+// of named facets, e.g. ?facets={"by_status":{"type":"terms","field":"status"}}
+func (h *CollectionHandler) queryFacets(ctx context.Context, userID primitive.ObjectID, collectionName string, filter map[string]interface{}, raw string) (map[string]models.FacetResult, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var facets map[string]models.FacetRequest
+	if err := json.Unmarshal([]byte(raw), &facets); err != nil {
+		return nil, fmt.Errorf("invalid facets: %w", err)
+	}
+	if len(facets) == 0 {
+		return nil, nil
+	}
+	return h.collectionService.ComputeFacets(ctx, userID, collectionName, collection.FacetOptions{
+		Filter: filter,
+		Facets: facets,
+	})
+}
+
+// withFacets adds facet results to a response when any were requested
+func withFacets(response gin.H, facets map[string]models.FacetResult) gin.H {
+	if facets != nil {
+		response["facets"] = facets
+	}
+	return response
+}
+
+// expandDocument inlines the referenced documents of a single document
+func (h *CollectionHandler) expandDocument(ctx context.Context, userID primitive.ObjectID, collectionName string, doc *models.Document, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	docs := []models.Document{*doc}
+	if err := h.collectionService.ExpandReferences(ctx, userID, collectionName, docs, paths); err != nil {
+		return err
+	}
+	*doc = docs[0]
+	return nil
+}
+
 // Helper functions
 // ListIndexes lists all indexes for a collection
 func (h *CollectionHandler) ListIndexes(c *gin.Context) {
@@ -1040,6 +1103,26 @@ func getUserID(c *gin.Context) primitive.ObjectID {
 	return userID
 }
 
+// keyHasPermission reports whether the access key that authenticated the request grants
+// the required type:name:action permission
+func keyHasPermission(c *gin.Context, required string) bool {
+	permissions, _ := c.Get("permissions")
+	perms, _ := permissions.([]string)
+	for _, perm := range perms {
+		if governance.MatchPermission(perm, required) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAnonymous reports whether an optional-auth route is serving a caller without credentials;
+// the service then only grants access through public permission rules
+func isAnonymous(c *gin.Context) bool {
+	authType, _ := c.Get("auth_type")
+	return authType == "anonymous"
+}
+
 func getUserRoles(c *gin.Context) []string {
 	roles, exists := c.Get("roles")
 	if !exists {
@@ -1051,4 +1134,25 @@ func getUserRoles(c *gin.Context) []string {
 	}
 
 	return []string{}
-}
\ No newline at end of file
+}
+
+// errorResponse builds an error body, attaching structured schema or password policy
+// violations when present
+func errorResponse(err error) gin.H {
+	body := gin.H{"error": err.Error()}
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		body["details"] = validationErrs
+	}
+	var policyErr *password.PolicyError
+	if errors.As(err, &policyErr) {
+		body["details"] = policyErr.Violations
+	}
+	return body
+}
+
+// isPolicyError reports whether a password was refused by the password policy
+func isPolicyError(err error) bool {
+	var policyErr *password.PolicyError
+	return errors.As(err, &policyErr)
+}
@@ -0,0 +1,31 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/madhouselabs/anybase/internal/signing"
+)
+
+type JWKSHandler struct {
+	keys *signing.KeyStore
+}
+
+// NewJWKSHandler publishes the store's public keys. A nil store, as with HS256 tokens,
+// publishes an empty set.
+func NewJWKSHandler(keys *signing.KeyStore) *JWKSHandler {
+	return &JWKSHandler{keys: keys}
+}
+
+// GetJWKS returns the public keys that verify AnyBase tokens, for other services to check
+// tokens without sharing a secret
+func (h *JWKSHandler) GetJWKS(c *gin.Context) {
+	keys := []signing.JWK{}
+	if h.keys != nil {
+		keys = h.keys.JWKS()
+	}
+
+	// New keys are published well before they sign, so a short cache is safe
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
@@ -117,112 +117,47 @@ func (h *MCPHandler) handleInitialize(c *gin.Context, req MCPRequest) {
 func (h *MCPHandler) handleResourcesList(c *gin.Context, req MCPRequest) {
 	// Check authentication type and permissions
 	authType, _ := c.Get("auth_type")
-	permissions, _ := c.Get("permissions")
 	
 	resources := []map[string]interface{}{}
 	
 	if authType == "access_key" {
 		// For access keys, only show resources they have permission to access
-		perms := permissions.([]string)
-		
-		// Parse permissions to find accessible collections and views
-		collectionPermMap := make(map[string]bool)
-		viewPermMap := make(map[string]bool)
-		hasAllCollections := false
-		hasAllViews := false
-		
-		for _, perm := range perms {
-			parts := strings.Split(perm, ":")
-			if len(parts) >= 3 {
-				resource := parts[0]
-				name := parts[1]
-				action := parts[2]
-				
-				if resource == "collection" {
-					if name == "*" {
-						hasAllCollections = true
-					} else if action == "read" || action == "*" {
-						collectionPermMap[name] = true
-					}
-				} else if resource == "view" {
-					if name == "*" {
-						hasAllViews = true
-					} else if action == "execute" || action == "*" {
-						viewPermMap[name] = true
-					}
-				}
-			}
-		}
-		
-		// Only fetch collections the access key has permission for
-		if hasAllCollections {
-			// Has wildcard permission - can list all collections
-			collections, err := h.collectionService.ListCollections(c.Request.Context())
-			if err == nil {
-				for _, col := range collections {
-					// Include schema information in the resource
-					schemaInfo := "No schema defined"
-					if col.Schema != nil {
-						schemaBytes, _ := json.Marshal(col.Schema)
-						schemaInfo = string(schemaBytes)
-					}
-					
-					resources = append(resources, map[string]interface{}{
-						"uri":         "anybase://collection/" + col.Name,
-						"name":        "Collection: " + col.Name,
-						"description": fmt.Sprintf("%s\nSchema: %s", col.Description, schemaInfo),
-						"mimeType":    "application/json",
-					})
+		collections, err := h.collectionService.ListCollections(c.Request.Context())
+		if err == nil {
+			for _, col := range collections {
+				if !keyHasPermission(c, "collection:"+col.Name+":read") {
+					continue
 				}
-			}
-		} else if len(collectionPermMap) > 0 {
-			// Only fetch specific collections
-			for collName := range collectionPermMap {
-				col, err := h.collectionService.GetCollection(c.Request.Context(), primitive.NilObjectID, collName)
-				if err == nil {
-					// Include schema information in the resource
-					schemaInfo := "No schema defined"
-					if col.Schema != nil {
-						schemaBytes, _ := json.Marshal(col.Schema)
-						schemaInfo = string(schemaBytes)
-					}
-					
-					resources = append(resources, map[string]interface{}{
-						"uri":         "anybase://collection/" + collName,
-						"name":        "Collection: " + collName,
-						"description": fmt.Sprintf("%s\nSchema: %s", col.Description, schemaInfo),
-						"mimeType":    "application/json",
-					})
+				
+				// Include schema information in the resource
+				schemaInfo := "No schema defined"
+				if col.Schema != nil {
+					schemaBytes, _ := json.Marshal(col.Schema)
+					schemaInfo = string(schemaBytes)
 				}
+				
+				resources = append(resources, map[string]interface{}{
+					"uri":         "anybase://collection/" + col.Name,
+					"name":        "Collection: " + col.Name,
+					"description": fmt.Sprintf("%s\nSchema: %s", col.Description, schemaInfo),
+					"mimeType":    "application/json",
+				})
 			}
 		}
 		
-		// Only fetch views the access key has permission for
-		if hasAllViews {
-			// Has wildcard permission - can list all views
-			views, err := h.collectionService.ListViews(c.Request.Context())
-			if err == nil {
-				for _, view := range views {
-					resources = append(resources, map[string]interface{}{
-						"uri":         "anybase://view/" + view.Name,
-						"name":        "View: " + view.Name,
-						"description": fmt.Sprintf("%s\nCollection: %s\nPipeline: %s", view.Description, view.Collection, view.Pipeline),
-						"mimeType":    "application/json",
-					})
-				}
-			}
-		} else if len(viewPermMap) > 0 {
-			// Only fetch specific views
-			for viewName := range viewPermMap {
-				view, err := h.collectionService.GetView(c.Request.Context(), viewName)
-				if err == nil {
-					resources = append(resources, map[string]interface{}{
-						"uri":         "anybase://view/" + viewName,
-						"name":        "View: " + viewName,
-						"description": fmt.Sprintf("%s\nCollection: %s\nPipeline: %s", view.Description, view.Collection, view.Pipeline),
-						"mimeType":    "application/json",
-					})
+		views, err := h.collectionService.ListViews(c.Request.Context())
+		if err == nil {
+			for _, view := range views {
+				if !keyHasPermission(c, "view:"+view.Name+":execute") {
+					continue
 				}
+				
+				resources = append(resources, map[string]interface{}{
+					"uri":         "anybase://view/" + view.Name,
+					"name":        "View: " + view.Name,
+					"description": fmt.Sprintf("%s\nCollection: %s\nPipeline: %s", view.Description, view.Collection, view.Pipeline),
+					"mimeType":    "application/json",
+				})
 			}
 		}
 	} else {
@@ -397,68 +332,19 @@ func (h *MCPHandler) handleToolsList(c *gin.Context, req MCPRequest) {
 	
 	// Check authentication type and permissions
 	authType, _ := c.Get("auth_type")
-	permissions, _ := c.Get("permissions")
 	
 	if authType == "access_key" {
 		// For access keys, only show tools they have permission to use
-		perms := permissions.([]string)
-		
-		// Parse permissions to find accessible collections and views
-		collectionPerms := make(map[string][]string) // collection name -> actions
-		viewPerms := make(map[string][]string)       // view name -> actions
-		
-		for _, perm := range perms {
-			parts := strings.Split(perm, ":")
-			if len(parts) >= 3 {
-				resource := parts[0]
-				name := parts[1]
-				action := parts[2]
-				
-				if resource == "collection" {
-					if name == "*" {
-						// Has wildcard permission - fetch all collections
-						collections, err := h.collectionService.ListCollections(c.Request.Context())
-						if err == nil {
-							for _, col := range collections {
-								if collectionPerms[col.Name] == nil {
-									collectionPerms[col.Name] = []string{}
-								}
-								collectionPerms[col.Name] = append(collectionPerms[col.Name], action)
-							}
-						}
-					} else {
-						if collectionPerms[name] == nil {
-							collectionPerms[name] = []string{}
-						}
-						collectionPerms[name] = append(collectionPerms[name], action)
-					}
-				} else if resource == "view" {
-					if name == "*" {
-						// Has wildcard permission - fetch all views
-						views, err := h.collectionService.ListViews(c.Request.Context())
-						if err == nil {
-							for _, view := range views {
-								if viewPerms[view.Name] == nil {
-									viewPerms[view.Name] = []string{}
-								}
-								viewPerms[view.Name] = append(viewPerms[view.Name], action)
-							}
-						}
-					} else {
-						if viewPerms[name] == nil {
-							viewPerms[name] = []string{}
-						}
-						viewPerms[name] = append(viewPerms[name], action)
-					}
-				}
-			}
-		}
+		collections, _ := h.collectionService.ListCollections(c.Request.Context())
 		
 		// Create specific tools for each collection
-		for collName, actions := range collectionPerms {
-			// Get collection details for schema
-			col, err := h.collectionService.GetCollection(c.Request.Context(), primitive.NilObjectID, collName)
-			if err != nil {
+		for _, col := range collections {
+			collName := col.Name
+			hasRead := keyHasPermission(c, "collection:"+collName+":read")
+			hasWrite := keyHasPermission(c, "collection:"+collName+":write")
+			hasUpdate := keyHasPermission(c, "collection:"+collName+":update")
+			hasDelete := keyHasPermission(c, "collection:"+collName+":delete")
+			if !hasRead && !hasWrite && !hasUpdate && !hasDelete {
 				continue
 			}
 			
@@ -476,23 +362,6 @@ func (h *MCPHandler) handleToolsList(c *gin.Context, req MCPRequest) {
 				requiredFields = col.Schema.Required
 			}
 			
-			// Check permissions and add appropriate tools
-			hasRead := false
-			hasWrite := false
-			hasDelete := false
-			
-			for _, action := range actions {
-				if action == "read" || action == "*" {
-					hasRead = true
-				}
-				if action == "write" || action == "create" || action == "*" {
-					hasWrite = true
-				}
-				if action == "delete" || action == "*" {
-					hasDelete = true
-				}
-			}
-			
 			if hasRead {
 				// Build schema description for the query tool
 				schemaDesc := ""
@@ -582,7 +451,9 @@ func (h *MCPHandler) handleToolsList(c *gin.Context, req MCPRequest) {
 						"required": []string{"document"},
 					},
 				})
-				
+			}
+			
+			if hasUpdate {
 				tools = append(tools, map[string]interface{}{
 					"name":        "update_" + collName,
 					"description": fmt.Sprintf("Update documents in %s collection", collName),
@@ -622,53 +493,48 @@ func (h *MCPHandler) handleToolsList(c *gin.Context, req MCPRequest) {
 		}
 		
 		// Create specific tools for each view
-		for viewName, actions := range viewPerms {
-			// Get view details
-			view, err := h.collectionService.GetView(c.Request.Context(), viewName)
-			if err != nil {
+		views, _ := h.collectionService.ListViews(c.Request.Context())
+		for _, view := range views {
+			viewName := view.Name
+			if !keyHasPermission(c, "view:"+viewName+":execute") {
 				continue
 			}
 			
-			for _, action := range actions {
-				if action == "execute" || action == "*" {
-					// Build more descriptive view information
-					viewDesc := fmt.Sprintf("Execute %s view. %s", viewName, view.Description)
-					if view.Collection != "" {
-						viewDesc += fmt.Sprintf("\nBase collection: %s", view.Collection)
-					}
-					if view.Filter != nil && len(view.Filter) > 0 {
-						filterJSON, _ := json.Marshal(view.Filter)
-						viewDesc += fmt.Sprintf("\nBase filter: %s", string(filterJSON))
-					}
-					if len(view.Fields) > 0 {
-						viewDesc += fmt.Sprintf("\nProjected fields: %v", view.Fields)
-					}
-					if len(view.Pipeline) > 0 {
-						pipelineJSON, _ := json.Marshal(view.Pipeline)
-						viewDesc += fmt.Sprintf("\nAggregation pipeline: %s", string(pipelineJSON))
-					}
-					
-					tools = append(tools, map[string]interface{}{
-						"name":        "execute_view_" + viewName,
-						"description": viewDesc,
-						"inputSchema": map[string]interface{}{
-							"type": "object",
-							"properties": map[string]interface{}{
-								"limit": map[string]interface{}{
-									"type":        "number",
-									"description": "Maximum number of results to return",
-									"default":     10,
-								},
-								"filter": map[string]interface{}{
-									"type":        "object",
-									"description": "Additional filter to apply on view results",
-								},
-							},
-						},
-					})
-					break
-				}
+			// Build more descriptive view information
+			viewDesc := fmt.Sprintf("Execute %s view. %s", viewName, view.Description)
+			if view.Collection != "" {
+				viewDesc += fmt.Sprintf("\nBase collection: %s", view.Collection)
 			}
+			if view.Filter != nil && len(view.Filter) > 0 {
+				filterJSON, _ := json.Marshal(view.Filter)
+				viewDesc += fmt.Sprintf("\nBase filter: %s", string(filterJSON))
+			}
+			if len(view.Fields) > 0 {
+				viewDesc += fmt.Sprintf("\nProjected fields: %v", view.Fields)
+			}
+			if len(view.Pipeline) > 0 {
+				pipelineJSON, _ := json.Marshal(view.Pipeline)
+				viewDesc += fmt.Sprintf("\nAggregation pipeline: %s", string(pipelineJSON))
+			}
+			
+			tools = append(tools, map[string]interface{}{
+				"name":        "execute_view_" + viewName,
+				"description": viewDesc,
+				"inputSchema": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"limit": map[string]interface{}{
+							"type":        "number",
+							"description": "Maximum number of results to return",
+							"default":     10,
+						},
+						"filter": map[string]interface{}{
+							"type":        "object",
+							"description": "Additional filter to apply on view results",
+						},
+					},
+				},
+			})
 		}
 	} else {
 		// JWT auth - show all available collections and views as specific tools
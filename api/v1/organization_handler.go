@@ -0,0 +1,167 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/madhouselabs/anybase/internal/organization"
+	"github.com/madhouselabs/anybase/internal/user"
+	"github.com/madhouselabs/anybase/pkg/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type OrganizationHandler struct {
+	orgService organization.Service
+	userRepo   user.Repository
+}
+
+func NewOrganizationHandler(orgService organization.Service, userRepo user.Repository) *OrganizationHandler {
+	return &OrganizationHandler{
+		orgService: orgService,
+		userRepo:   userRepo,
+	}
+}
+
+// CreateOrganization creates an organization with its own namespace
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	var req models.CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	org, err := h.orgService.CreateOrganization(c.Request.Context(), getUserID(c), &req)
+	if err != nil {
+		c.JSON(organizationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, org)
+}
+
+// ListOrganizations lists every organization
+func (h *OrganizationHandler) ListOrganizations(c *gin.Context) {
+	orgs, err := h.orgService.ListOrganizations(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organizations": orgs})
+}
+
+// ListMyOrganizations lists the organizations the caller belongs to
+func (h *OrganizationHandler) ListMyOrganizations(c *gin.Context) {
+	orgs, err := h.orgService.ListUserOrganizations(c.Request.Context(), getUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organizations": orgs})
+}
+
+// ListMembers lists the members of the caller's organization
+func (h *OrganizationHandler) ListMembers(c *gin.Context) {
+	members, err := h.orgService.ListMembers(c.Request.Context())
+	if err != nil {
+		c.JSON(organizationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"members": members})
+}
+
+// AddMember adds a user, by ID or email, to the caller's organization
+func (h *OrganizationHandler) AddMember(c *gin.Context) {
+	var req models.OrgMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var userID primitive.ObjectID
+	switch {
+	case req.UserID != "":
+		id, err := primitive.ObjectIDFromHex(req.UserID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		if _, err := h.userRepo.GetByID(c.Request.Context(), id); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		userID = id
+	case req.Email != "":
+		u, err := h.userRepo.GetByEmail(c.Request.Context(), req.Email)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		userID = u.ID
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id or email is required"})
+		return
+	}
+
+	member, err := h.orgService.AddMember(c.Request.Context(), getUserID(c), userID, req.Roles)
+	if err != nil {
+		c.JSON(organizationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, member)
+}
+
+// SetMemberRoles replaces the roles a member holds in the caller's organization
+func (h *OrganizationHandler) SetMemberRoles(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req SetUserRolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.orgService.SetMemberRoles(c.Request.Context(), getUserID(c), userID, req.Roles); err != nil {
+		c.JSON(organizationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Member roles updated successfully",
+		"roles":   req.Roles,
+	})
+}
+
+// RemoveMember removes a user from the caller's organization
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.orgService.RemoveMember(c.Request.Context(), getUserID(c), userID); err != nil {
+		c.JSON(organizationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+func organizationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, organization.ErrOrganizationNotFound), errors.Is(err, organization.ErrNotMember):
+		return http.StatusNotFound
+	case errors.Is(err, organization.ErrOrganizationExists), errors.Is(err, organization.ErrMemberExists):
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,164 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/madhouselabs/anybase/internal/governance"
+	"github.com/madhouselabs/anybase/pkg/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type RoleHandler struct {
+	rbacService governance.RBACService
+}
+
+func NewRoleHandler(rbacService governance.RBACService) *RoleHandler {
+	return &RoleHandler{rbacService: rbacService}
+}
+
+// CreateRoleRequest represents the request to create a role
+type CreateRoleRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+// SetUserRolesRequest represents the request to replace a user's roles
+type SetUserRolesRequest struct {
+	Roles []string `json:"roles" binding:"required,min=1"`
+}
+
+// ListRoles lists built-in and custom roles
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	roles, err := h.rbacService.ListRoles(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+// GetRole gets a role by name
+func (h *RoleHandler) GetRole(c *gin.Context) {
+	role, err := h.rbacService.GetRole(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		c.JSON(roleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// CreateRole creates a custom role
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var req CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role := &models.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: req.Permissions,
+	}
+	if err := h.rbacService.CreateRole(c.Request.Context(), getUserID(c), role); err != nil {
+		c.JSON(roleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, role)
+}
+
+// UpdateRole updates the description or permissions of a role
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	var req models.RoleUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role, err := h.rbacService.UpdateRole(c.Request.Context(), getUserID(c), c.Param("name"), &req)
+	if err != nil {
+		c.JSON(roleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole deletes a custom role that is not assigned to anyone
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	if err := h.rbacService.DeleteRole(c.Request.Context(), getUserID(c), c.Param("name")); err != nil {
+		c.JSON(roleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
+
+// GetUserRoles returns a user's roles and the permissions they add up to
+func (h *RoleHandler) GetUserRoles(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	roles, err := h.rbacService.GetUserRoles(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	permissions, err := h.rbacService.GetEffectivePermissions(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"roles":       roles,
+		"permissions": permissions,
+	})
+}
+
+// SetUserRoles replaces a user's roles
+func (h *RoleHandler) SetUserRoles(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req SetUserRolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.rbacService.SetUserRoles(c.Request.Context(), getUserID(c), userID, req.Roles); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	roles, _ := h.rbacService.GetUserRoles(c.Request.Context(), userID)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User roles updated successfully",
+		"roles":   roles,
+	})
+}
+
+func roleErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, governance.ErrRoleNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, governance.ErrRoleExists), errors.Is(err, governance.ErrRoleInUse):
+		return http.StatusConflict
+	case errors.Is(err, governance.ErrSystemRole):
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
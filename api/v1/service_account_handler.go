@@ -0,0 +1,148 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/madhouselabs/anybase/internal/governance"
+	"github.com/madhouselabs/anybase/internal/serviceaccount"
+	"github.com/madhouselabs/anybase/internal/user"
+	"github.com/madhouselabs/anybase/pkg/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ServiceAccountHandler struct {
+	service serviceaccount.Service
+}
+
+func NewServiceAccountHandler(service serviceaccount.Service) *ServiceAccountHandler {
+	return &ServiceAccountHandler{service: service}
+}
+
+// CreateServiceAccount creates a service account with the given roles
+func (h *ServiceAccountHandler) CreateServiceAccount(c *gin.Context) {
+	var req models.CreateServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	account, err := h.service.Create(c.Request.Context(), getUserID(c), &req)
+	if err != nil {
+		c.JSON(serviceAccountErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, account)
+}
+
+// ListServiceAccounts lists every service account
+func (h *ServiceAccountHandler) ListServiceAccounts(c *gin.Context) {
+	accounts, err := h.service.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"service_accounts": accounts})
+}
+
+// GetServiceAccount returns a service account
+func (h *ServiceAccountHandler) GetServiceAccount(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid service account ID"})
+		return
+	}
+
+	account, err := h.service.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(serviceAccountErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, account)
+}
+
+// IssueCredential creates a client ID and secret for a service account. The secret is
+// only returned here.
+func (h *ServiceAccountHandler) IssueCredential(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid service account ID"})
+		return
+	}
+
+	var req models.CreateCredentialRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	cred, secret, err := h.service.IssueCredential(c.Request.Context(), getUserID(c), id, &req)
+	if err != nil {
+		c.JSON(serviceAccountErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"credential":    cred,
+		"client_id":     cred.ClientID,
+		"client_secret": secret,
+		"message":       "Store the client secret now, it will not be shown again",
+	})
+}
+
+// ListCredentials lists a service account's credentials, without their secrets
+func (h *ServiceAccountHandler) ListCredentials(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid service account ID"})
+		return
+	}
+
+	creds, err := h.service.ListCredentials(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(serviceAccountErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"credentials": creds})
+}
+
+// RevokeCredential revokes a credential and the tokens issued with it
+func (h *ServiceAccountHandler) RevokeCredential(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid service account ID"})
+		return
+	}
+	credentialID, err := primitive.ObjectIDFromHex(c.Param("credentialId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid credential ID"})
+		return
+	}
+
+	if err := h.service.RevokeCredential(c.Request.Context(), id, credentialID); err != nil {
+		c.JSON(serviceAccountErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Credential revoked"})
+}
+
+func serviceAccountErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, serviceaccount.ErrNotFound), errors.Is(err, serviceaccount.ErrCredentialNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, user.ErrUserAlreadyExists):
+		return http.StatusConflict
+	case errors.Is(err, serviceaccount.ErrInvalidName), errors.Is(err, serviceaccount.ErrInvalidExpiration),
+		errors.Is(err, governance.ErrRoleNotFound):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,135 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/madhouselabs/anybase/internal/collection"
+	"github.com/madhouselabs/anybase/pkg/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TextSearchHandler handles full-text search configuration and queries
+type TextSearchHandler struct {
+	collectionService collection.Service
+}
+
+// NewTextSearchHandler creates a new text search handler
+func NewTextSearchHandler(collectionService collection.Service) *TextSearchHandler {
+	return &TextSearchHandler{
+		collectionService: collectionService,
+	}
+}
+
+// ConfigureTextSearch sets the fields, weights and language used for full-text search
+func (h *TextSearchHandler) ConfigureTextSearch(c *gin.Context) {
+	collectionName := c.Param("name")
+
+	userID := getUserID(c)
+	if userID == primitive.NilObjectID {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var config models.TextSearchConfig
+	if err := c.ShouldBindJSON(&config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(config.Fields) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one field is required"})
+		return
+	}
+
+	if err := h.collectionService.ConfigureTextSearch(c.Request.Context(), userID, collectionName, &config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "text search configured successfully",
+		"text_search": config,
+	})
+}
+
+// DisableTextSearch removes full-text search from a collection
+func (h *TextSearchHandler) DisableTextSearch(c *gin.Context) {
+	collectionName := c.Param("name")
+
+	userID := getUserID(c)
+	if userID == primitive.NilObjectID {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	if err := h.collectionService.ConfigureTextSearch(c.Request.Context(), userID, collectionName, nil); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "text search disabled successfully"})
+}
+
+// TextSearch performs a ranked full-text search
+func (h *TextSearchHandler) TextSearch(c *gin.Context) {
+	collectionName := c.Param("collection")
+
+	// Parse request body
+	var opts collection.TextSearchOptions
+	if err := c.ShouldBindJSON(&opts); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if opts.Query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query is required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	userID := getUserID(c)
+
+	// Check if authenticated via access key
+	if authType, _ := c.Get("auth_type"); authType == "access_key" {
+		// Check if has permission to read this specific collection
+		requiredPerm := "collection:" + collectionName + ":read"
+		hasPermission := keyHasPermission(c, requiredPerm)
+
+		if !hasPermission {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions to read collection"})
+			return
+		}
+
+		userID = primitive.NilObjectID
+	} else if userID == primitive.NilObjectID {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	results, err := h.collectionService.TextSearch(ctx, userID, collectionName, opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{
+		"results": results,
+		"count":   len(results),
+	}
+
+	// Facets cover every match of the query, not just the returned page
+	if len(opts.Facets) > 0 {
+		facets, err := h.collectionService.ComputeFacets(ctx, userID, collectionName, collection.FacetOptions{
+			Filter:    opts.Filter,
+			Facets:    opts.Facets,
+			TextQuery: opts.Query,
+			TextMode:  opts.Mode,
+		})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		response["facets"] = facets
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -4,6 +4,9 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/madhouselabs/anybase/internal/governance"
+	"github.com/madhouselabs/anybase/internal/password"
+	"github.com/madhouselabs/anybase/internal/session"
 	"github.com/madhouselabs/anybase/internal/user"
 	"github.com/madhouselabs/anybase/pkg/models"
 	"go.mongodb.org/mongo-driver/bson"
@@ -12,12 +15,18 @@ import (
 )
 
 type UserHandler struct {
-	userRepo user.Repository
+	userRepo    user.Repository
+	rbacService governance.RBACService
+	sessionRepo session.Repository
+	passwords   *password.Checker
 }
 
-func NewUserHandler(userRepo user.Repository) *UserHandler {
+func NewUserHandler(userRepo user.Repository, rbacService governance.RBACService, sessionRepo session.Repository, passwords *password.Checker) *UserHandler {
 	return &UserHandler{
-		userRepo: userRepo,
+		userRepo:    userRepo,
+		rbacService: rbacService,
+		sessionRepo: sessionRepo,
+		passwords:   passwords,
 	}
 }
 
@@ -36,8 +45,15 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 	// Calculate skip
 	skip := (page - 1) * limit
 	
+	// People and service accounts are listed separately: ?type=service_account
+	userType := models.UserTypeRegular
+	if c.Query("type") == string(models.UserTypeServiceAccount) {
+		userType = models.UserTypeServiceAccount
+	}
+	filter := bson.M{"user_type": string(userType)}
+	
 	// Find users using the List method
-	users, err := h.userRepo.List(ctx, bson.M{}, &options.FindOptions{
+	users, err := h.userRepo.List(ctx, filter, &options.FindOptions{
 		Skip:  int64Ptr(int64(skip)),
 		Limit: int64Ptr(int64(limit)),
 	})
@@ -54,9 +70,10 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 	}
 	
 	// Get total count
-	total, _ := h.userRepo.Count(ctx, bson.M{})
+	total, _ := h.userRepo.Count(ctx, filter)
 	
 	c.JSON(http.StatusOK, gin.H{
+		"type":  userType,
 		"users": users,
 		"total": total,
 		"page":  page,
@@ -101,9 +118,10 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		FirstName string `json:"first_name"`
 		LastName  string `json:"last_name"`
 		Email     string `json:"email" binding:"required,email"`
-		Password  string `json:"password" binding:"required,min=6"`
-		Role      string `json:"role" binding:"required,oneof=admin developer"`
-		Active    bool   `json:"active"`
+		Password  string `json:"password" binding:"required"`
+		Role      string   `json:"role" binding:"required"`
+		Roles     []string `json:"roles"`
+		Active    bool     `json:"active"`
 	}
 	
 	if err := c.ShouldBindJSON(&createData); err != nil {
@@ -113,6 +131,23 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		return
 	}
 	
+	// Every assigned role must exist
+	for _, role := range append([]string{createData.Role}, createData.Roles...) {
+		if _, err := h.rbacService.GetRole(ctx, role); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+	
+	if models.IsServiceAccountEmail(createData.Email) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Service accounts are created through /admin/service-accounts",
+		})
+		return
+	}
+	
 	// Check if user with email already exists
 	existingUser, _ := h.userRepo.GetByEmail(ctx, createData.Email)
 	if existingUser != nil {
@@ -122,6 +157,18 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		return
 	}
 	
+	// Admins are held to the same password policy as everyone else
+	if err := h.passwords.Check(ctx, createData.Password, &models.User{Email: createData.Email}); err != nil {
+		if isPolicyError(err) {
+			c.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to check password",
+		})
+		return
+	}
+	
 	// Hash password
 	hashedPassword, err := user.HashPassword(createData.Password)
 	if err != nil {
@@ -138,8 +185,11 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		Email:     createData.Email,
 		Password:  hashedPassword,
 		Role:      createData.Role,
+		Roles:     createData.Roles,
 		Active:    createData.Active,
 		UserType:  "regular",
+		// Accounts created by an admin don't go through email verification
+		EmailVerified: true,
 	}
 	
 	// Create user
@@ -176,8 +226,9 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		FirstName string `json:"first_name"`
 		LastName  string `json:"last_name"`
 		Email     string `json:"email"`
-		Role      string `json:"role"`
-		Active    bool   `json:"active"`
+		Role      string   `json:"role"`
+		Roles     []string `json:"roles"`
+		Active    bool     `json:"active"`
 	}
 	
 	if err := c.ShouldBindJSON(&updateData); err != nil {
@@ -196,11 +247,22 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		updateMap["last_name"] = updateData.LastName
 	}
 	if updateData.Email != "" {
+		// A service account's address is derived from its name, and the domain is reserved
+		target, err := h.userRepo.GetByID(ctx, objID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "User not found",
+			})
+			return
+		}
+		if target.IsServiceAccount() != models.IsServiceAccountEmail(updateData.Email) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Service account addresses cannot be changed or given to users",
+			})
+			return
+		}
 		updateMap["email"] = updateData.Email
 	}
-	if updateData.Role != "" {
-		updateMap["role"] = updateData.Role
-	}
 	updateMap["active"] = updateData.Active
 	
 	// Update user directly with map
@@ -211,6 +273,37 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 	
+	// A deactivated user is logged out everywhere
+	if !updateData.Active {
+		if _, err := h.sessionRepo.RevokeAll(ctx, objID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to revoke user sessions",
+			})
+			return
+		}
+	}
+	
+	// Role changes go through RBAC so they are validated and audited
+	if updateData.Role != "" || len(updateData.Roles) > 0 {
+		roles := updateData.Roles
+		if len(roles) == 0 {
+			// A primary role alone replaces the current primary and keeps the rest
+			current, _ := h.rbacService.GetUserRoles(ctx, objID)
+			if len(current) > 0 {
+				roles = current[1:]
+			}
+		}
+		if updateData.Role != "" {
+			roles = append([]string{updateData.Role}, roles...)
+		}
+		if err := h.rbacService.SetUserRoles(ctx, getUserID(c), objID, roles); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+	
 	// Get updated user
 	updatedUser, err := h.userRepo.GetByID(ctx, objID)
 	if err != nil {
@@ -264,11 +357,50 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 	
+	if _, err := h.sessionRepo.RevokeAll(ctx, objID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "User deleted but their sessions could not be revoked",
+		})
+		return
+	}
+	
 	c.JSON(http.StatusOK, gin.H{
 		"message": "User deleted successfully",
 	})
 }
 
+// RevokeUserSessions logs a user out of every session (admin only)
+func (h *UserHandler) RevokeUserSessions(c *gin.Context) {
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID",
+		})
+		return
+	}
+	
+	ctx := c.Request.Context()
+	if _, err := h.userRepo.GetByID(ctx, objID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "User not found",
+		})
+		return
+	}
+	
+	revoked, err := h.sessionRepo.RevokeAll(ctx, objID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to revoke sessions",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Sessions revoked successfully",
+		"revoked": revoked,
+	})
+}
+
 // GetUserProfile returns the current user's profile
 func (h *UserHandler) GetUserProfile(c *gin.Context) {
 	userID := c.GetString("userID")
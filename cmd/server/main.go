@@ -19,8 +19,15 @@ import (
 	"github.com/madhouselabs/anybase/internal/config"
 	"github.com/madhouselabs/anybase/internal/database"
 	"github.com/madhouselabs/anybase/internal/governance"
+	"github.com/madhouselabs/anybase/internal/mailer"
+	"github.com/madhouselabs/anybase/internal/oidc"
+	"github.com/madhouselabs/anybase/internal/password"
 	"github.com/madhouselabs/anybase/internal/middleware"
+	"github.com/madhouselabs/anybase/internal/organization"
+	"github.com/madhouselabs/anybase/internal/serviceaccount"
+	"github.com/madhouselabs/anybase/internal/session"
 	"github.com/madhouselabs/anybase/internal/settings"
+	"github.com/madhouselabs/anybase/internal/signing"
 	"github.com/madhouselabs/anybase/internal/user"
 	"github.com/madhouselabs/anybase/pkg/models"
 )
@@ -53,29 +60,96 @@ func main() {
 
 	// Initialize repositories and services
 	userRepo := user.NewRepository(dbAdapter)
-	authService := auth.NewService(userRepo, &cfg.Auth)
+	orgRepo := organization.NewRepository(dbAdapter)
+	sessionRepo := session.NewRepository(dbAdapter)
+	settingsService := settings.NewAdapterService(dbAdapter)
+
+	// Outgoing email for verification and password reset
+	mailDriver, err := mailer.New(&cfg.Mail)
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+	mailTemplates, err := mailer.LoadTemplates(cfg.Mail.TemplatesDir)
+	if err != nil {
+		log.Fatalf("Failed to load mail templates: %v", err)
+	}
+	mailSender := mailer.NewSender(mailDriver, mailTemplates, cfg.Mail.From)
+
+	if cfg.Auth.BreachedPasswordsFile != "" {
+		if err := password.LoadBreachedList(cfg.Auth.BreachedPasswordsFile); err != nil {
+			log.Fatalf("Failed to load breached passwords: %v", err)
+		}
+	}
+	passwordChecker := password.NewChecker(settingsService, cfg.Auth.PasswordMinLength)
+
+	identityProviders, err := oidc.NewRegistry(cfg.Auth.OIDCProviders, nil)
+	if err != nil {
+		log.Fatalf("Invalid identity provider configuration: %v", err)
+	}
+
+	// RS256 and ES256 tokens are signed with rotating key pairs that other services verify
+	// through the JWKS endpoint; HS256 keeps using the shared secret
+	var signingKeys *signing.KeyStore
+	if cfg.Auth.JWTAlgorithm != "" && cfg.Auth.JWTAlgorithm != "HS256" {
+		keySecret := cfg.Auth.SigningKeySecret
+		if keySecret == "" {
+			keySecret = cfg.Auth.JWTSecret
+		}
+		// A retired key keeps verifying until the last token it signed has expired
+		retention := cfg.Auth.RefreshTokenExpiration
+		if cfg.Auth.JWTExpiration > retention {
+			retention = cfg.Auth.JWTExpiration
+		}
+		signingKeys, err = signing.NewKeyStore(dbAdapter, signing.Config{
+			Algorithm: cfg.Auth.JWTAlgorithm,
+			Rotation:  cfg.Auth.SigningKeyRotation,
+			Retention: retention,
+			Secret:    keySecret,
+		})
+		if err != nil {
+			log.Fatalf("Invalid signing key configuration: %v", err)
+		}
+		keysCtx, stopKeys := context.WithCancel(ctx)
+		defer stopKeys()
+		if err := signingKeys.Start(keysCtx); err != nil {
+			log.Fatalf("Failed to initialize signing keys: %v", err)
+		}
+	}
+	tokenService := auth.NewTokenService(&cfg.Auth, signingKeys)
+
+	rbacService := governance.NewRBACService(dbAdapter)
+	serviceAccountService := serviceaccount.NewService(userRepo, sessionRepo, serviceaccount.NewCredentialRepository(dbAdapter), rbacService)
+
+	authService := auth.NewService(dbAdapter, userRepo, orgRepo, sessionRepo, settingsService, passwordChecker, mailSender, identityProviders, serviceAccountService, tokenService, &cfg.Auth)
 	
 	// Initialize admin user if needed
 	if err := initializeAdminUser(ctx, userRepo, authService); err != nil {
 		log.Printf("Warning: Failed to initialize admin user: %v", err)
 	}
-	rbacService := governance.NewRBACService(dbAdapter)
+	if err := rbacService.EnsureSystemRoles(ctx); err != nil {
+		log.Printf("Warning: Failed to initialize system roles: %v", err)
+	}
+	
+	orgService := organization.NewService(dbAdapter, orgRepo, rbacService)
 	
 	// Use adapter-based services
 	collectionService := collection.NewAdapterService(dbAdapter, rbacService)
-	accessKeyRepo := accesskey.NewRepository(dbAdapter)
-	settingsService := settings.NewAdapterService(dbAdapter)
+	accessKeySecret := cfg.Auth.AccessKeySecret
+	if accessKeySecret == "" {
+		accessKeySecret = cfg.Auth.JWTSecret
+	}
+	accessKeyRepo := accesskey.NewRepository(dbAdapter, accessKeySecret)
 	
 	// Initialize AI service
 	aiService := ai.NewService(dbAdapter, collectionService)
 	// Start the job processor for background embedding generation
-	jobProcessor := ai.NewJobProcessor(aiService)
+	jobProcessor := ai.NewJobProcessor(aiService).WithOrganizations(orgRepo.List)
 	jobProcessor.Start()
 	defer jobProcessor.Stop()
 
 	// Initialize middleware
-	authMiddleware := middleware.NewAuthMiddleware(&cfg.Auth, rbacService)
-	accessKeyMiddleware := middleware.NewAccessKeyAuthMiddleware(accessKeyRepo)
+	authMiddleware := middleware.NewAuthMiddleware(tokenService, rbacService, orgRepo, sessionRepo)
+	accessKeyMiddleware := middleware.NewAccessKeyAuthMiddleware(accessKeyRepo, orgRepo)
 	rateLimiter := middleware.NewPerIPRateLimiter(100, 10) // 100 requests per second, burst of 10
 
 	// Setup Gin router
@@ -90,6 +164,8 @@ func main() {
 	router.Use(gin.Logger())
 	router.Use(middleware.CORS())
 	router.Use(rateLimiter.Limit())
+	// Ahead of every authentication middleware, so it sees the requests they mark as impersonated
+	router.Use(middleware.NewImpersonationAudit(dbAdapter).Record())
 
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
@@ -106,12 +182,16 @@ func main() {
 		})
 	})
 
+	// Public keys for verifying tokens
+	jwksHandler := v1.NewJWKSHandler(signingKeys)
+	router.GET("/.well-known/jwks.json", jwksHandler.GetJWKS)
+
 	// MCP endpoint
 	mcpHandler := v1.NewMCPHandler(collectionService)
 	router.POST("/mcp", accessKeyMiddleware.Authenticate(), authMiddleware.RequireAuth(), mcpHandler.HandleMCPRequest)
 
 	// API routes
-	setupAPIRoutes(router, authService, authMiddleware, accessKeyMiddleware, rbacService, collectionService, userRepo, accessKeyRepo, settingsService, aiService)
+	setupAPIRoutes(router, authService, authMiddleware, accessKeyMiddleware, rbacService, collectionService, userRepo, accessKeyRepo, settingsService, aiService, orgService, sessionRepo, passwordChecker, serviceAccountService)
 
 	// Start server
 	srv := &http.Server{
@@ -146,7 +226,7 @@ func main() {
 	log.Println("Server exited")
 }
 
-func setupAPIRoutes(router *gin.Engine, authService auth.Service, authMiddleware *middleware.AuthMiddleware, accessKeyMiddleware *middleware.AccessKeyAuthMiddleware, rbacService governance.RBACService, collectionService collection.Service, userRepo user.Repository, accessKeyRepo accesskey.Repository, settingsService settings.Service, aiService ai.Service) {
+func setupAPIRoutes(router *gin.Engine, authService auth.Service, authMiddleware *middleware.AuthMiddleware, accessKeyMiddleware *middleware.AccessKeyAuthMiddleware, rbacService governance.RBACService, collectionService collection.Service, userRepo user.Repository, accessKeyRepo accesskey.Repository, settingsService settings.Service, aiService ai.Service, orgService organization.Service, sessionRepo session.Repository, passwordChecker *password.Checker, serviceAccountService serviceaccount.Service) {
 	// API v1 group
 	api := router.Group("/api/v1")
 
@@ -158,8 +238,20 @@ func setupAPIRoutes(router *gin.Engine, authService auth.Service, authMiddleware
 		authGroup.POST("/login", authHandler.Login)
 		authGroup.POST("/refresh", authHandler.RefreshToken)
 		authGroup.GET("/verify-email", authHandler.VerifyEmail)
+		authGroup.POST("/resend-verification", authHandler.ResendVerification)
 		authGroup.POST("/request-password-reset", authHandler.RequestPasswordReset)
 		authGroup.POST("/reset-password", authHandler.ResetPassword)
+		authGroup.POST("/rotate-password", authHandler.RotatePassword)
+		authGroup.GET("/password-policy", authHandler.GetPasswordPolicy)
+		authGroup.POST("/token", authHandler.Token)
+		authGroup.POST("/passwordless/start", authHandler.RequestPasswordlessLogin)
+		authGroup.POST("/passwordless/verify", authHandler.CompletePasswordlessLogin)
+		authGroup.GET("/oidc/providers", authHandler.ListOIDCProviders)
+		authGroup.GET("/oidc/:provider/login", authHandler.OIDCLogin)
+		authGroup.GET("/oidc/:provider/callback", authHandler.OIDCCallback)
+		authGroup.POST("/mfa/verify", authHandler.VerifyMFA)
+		// Also reachable mid-login with an MFA token, for users who must enroll to sign in
+		authGroup.POST("/mfa/enroll", authMiddleware.OptionalAuth(), authMiddleware.DenyImpersonation(), authHandler.EnrollMFA)
 	}
 
 	// Protected auth endpoints
@@ -167,11 +259,24 @@ func setupAPIRoutes(router *gin.Engine, authService auth.Service, authMiddleware
 	authProtected.Use(authMiddleware.RequireAuth())
 	{
 		authProtected.POST("/logout", authHandler.Logout)
-		authProtected.POST("/change-password", authHandler.ChangePassword)
+		authProtected.GET("/sessions", authHandler.ListSessions)
+	}
+
+	// Account security is for the user alone, never for an admin impersonating them
+	authSensitive := api.Group("/auth")
+	authSensitive.Use(authMiddleware.RequireAuth(), authMiddleware.DenyImpersonation())
+	{
+		authSensitive.POST("/change-password", authHandler.ChangePassword)
+		authSensitive.POST("/switch-org", authHandler.SwitchOrganization)
+		authSensitive.DELETE("/sessions/:id", authHandler.RevokeSession)
+		authSensitive.POST("/mfa/confirm", authHandler.ConfirmMFA)
+		authSensitive.POST("/mfa/recovery-codes", authHandler.RegenerateRecoveryCodes)
+		authSensitive.POST("/mfa/disable", authHandler.DisableMFA)
 	}
 
 	// User endpoints (protected)
-	userHandler := v1.NewUserHandler(userRepo)
+	userHandler := v1.NewUserHandler(userRepo, rbacService, sessionRepo, passwordChecker)
+	roleHandler := v1.NewRoleHandler(rbacService)
 	userGroup := api.Group("/users")
 	userGroup.Use(authMiddleware.RequireAuth())
 	{
@@ -182,6 +287,7 @@ func setupAPIRoutes(router *gin.Engine, authService auth.Service, authMiddleware
 	// Collection & View endpoints (support both JWT and Access Key auth)
 	collectionHandler := v1.NewCollectionHandler(collectionService)
 	vectorHandler := v1.NewVectorHandler(collectionService)
+	textSearchHandler := v1.NewTextSearchHandler(collectionService)
 	
 	collectionsGroup := api.Group("/collections")
 	collectionsGroup.Use(accessKeyMiddleware.Authenticate()) // Try access key first
@@ -202,6 +308,10 @@ func setupAPIRoutes(router *gin.Engine, authService auth.Service, authMiddleware
 		collectionsGroup.GET("/:name/vector-fields", vectorHandler.ListVectorFields)
 		collectionsGroup.POST("/:name/vector-fields", vectorHandler.AddVectorField)
 		collectionsGroup.DELETE("/:name/vector-fields/:field", vectorHandler.RemoveVectorField)
+		
+		// Full-text search configuration
+		collectionsGroup.PUT("/:name/text-search", textSearchHandler.ConfigureTextSearch)
+		collectionsGroup.DELETE("/:name/text-search", textSearchHandler.DisableTextSearch)
 	}
 
 	// View endpoints (support both JWT and Access Key auth)
@@ -224,6 +334,7 @@ func setupAPIRoutes(router *gin.Engine, authService auth.Service, authMiddleware
 	{
 		dataGroup.GET("/:collection", collectionHandler.QueryDocuments)
 		dataGroup.POST("/:collection", collectionHandler.InsertDocument)
+		dataGroup.GET("/:collection/distinct/:field", collectionHandler.DistinctValues)
 		dataGroup.GET("/:collection/:id", collectionHandler.GetDocument)
 		dataGroup.PUT("/:collection/:id", collectionHandler.UpdateDocument)
 		dataGroup.DELETE("/:collection/:id", collectionHandler.DeleteDocument)
@@ -231,13 +342,29 @@ func setupAPIRoutes(router *gin.Engine, authService auth.Service, authMiddleware
 		// Vector search endpoints
 		dataGroup.POST("/:collection/search", vectorHandler.VectorSearch)
 		dataGroup.POST("/:collection/hybrid-search", vectorHandler.HybridSearch)
+		dataGroup.POST("/:collection/text-search", textSearchHandler.TextSearch)
+	}
+
+	// Public read endpoints: anonymous callers only reach collections and views whose
+	// permissions are marked public, under a stricter per-IP rate limit
+	publicLimiter := middleware.NewPerIPRateLimiter(5, 20) // 5 requests per second, burst of 20
+	publicGroup := api.Group("/public")
+	publicGroup.Use(publicLimiter.Limit())
+	publicGroup.Use(accessKeyMiddleware.Authenticate())
+	publicGroup.Use(authMiddleware.OptionalAuth())
+	{
+		publicGroup.GET("/collections/:name", collectionHandler.GetCollection)
+		publicGroup.GET("/data/:collection", collectionHandler.QueryDocuments)
+		publicGroup.GET("/data/:collection/distinct/:field", collectionHandler.DistinctValues)
+		publicGroup.GET("/data/:collection/:id", collectionHandler.GetDocument)
+		publicGroup.GET("/views/:name/query", collectionHandler.QueryView)
 	}
 
 
 	// Access key management (accessible by admin and developer)
 	accessKeyHandler := v1.NewAccessKeyHandler(accessKeyRepo)
 	accessKeysGroup := api.Group("/access-keys")
-	accessKeysGroup.Use(authMiddleware.RequireRole("admin", "developer"))
+	accessKeysGroup.Use(authMiddleware.RequireRole("admin", "developer"), authMiddleware.DenyImpersonation())
 	{
 		accessKeysGroup.POST("", accessKeyHandler.CreateAccessKey)
 		accessKeysGroup.GET("", accessKeyHandler.ListAccessKeys)
@@ -247,22 +374,86 @@ func setupAPIRoutes(router *gin.Engine, authService auth.Service, authMiddleware
 		accessKeysGroup.POST("/:id/regenerate", accessKeyHandler.RegenerateAccessKey)
 	}
 
-	// User management endpoints
+	// Organizations: any user can list their own, platform admins create them
+	orgHandler := v1.NewOrganizationHandler(orgService, userRepo)
+	orgsGroup := api.Group("/orgs")
+	orgsGroup.Use(authMiddleware.RequireAuth())
+	{
+		orgsGroup.GET("", orgHandler.ListMyOrganizations)
+	}
+	
+	orgsAdminGroup := api.Group("")
+	orgsAdminGroup.Use(authMiddleware.RequirePlatformRole("admin"))
+	{
+		orgsAdminGroup.POST("/orgs", orgHandler.CreateOrganization)
+		orgsAdminGroup.GET("/admin/orgs", orgHandler.ListOrganizations)
+	}
+	
+	// Members of the organization the caller's token is scoped to
+	membersReadGroup := api.Group("/org/members")
+	membersReadGroup.Use(authMiddleware.RequireRole("admin", "developer"))
+	{
+		membersReadGroup.GET("", orgHandler.ListMembers)
+	}
+	
+	membersWriteGroup := api.Group("/org/members")
+	membersWriteGroup.Use(authMiddleware.RequireRole("admin"))
+	{
+		membersWriteGroup.POST("", orgHandler.AddMember)
+		membersWriteGroup.PUT("/:userId/roles", orgHandler.SetMemberRoles)
+		membersWriteGroup.DELETE("/:userId", orgHandler.RemoveMember)
+	}
+	
+	// User management endpoints are platform-wide, so organization tokens are refused
 	// Read operations - accessible by admin and developer
 	usersReadGroup := api.Group("/admin/users")
-	usersReadGroup.Use(authMiddleware.RequireRole("admin", "developer"))
+	usersReadGroup.Use(authMiddleware.RequirePlatformRole("admin", "developer"))
 	{
 		usersReadGroup.GET("", userHandler.ListUsers)
 		usersReadGroup.GET("/:id", userHandler.GetUser)
+		usersReadGroup.GET("/:id/roles", roleHandler.GetUserRoles)
 	}
 
 	// Write operations - admin only
 	usersWriteGroup := api.Group("/admin/users")
-	usersWriteGroup.Use(authMiddleware.RequireRole("admin"))
+	usersWriteGroup.Use(authMiddleware.RequirePlatformRole("admin"))
 	{
 		usersWriteGroup.POST("", userHandler.CreateUser)
 		usersWriteGroup.PUT("/:id", userHandler.UpdateUser)
 		usersWriteGroup.DELETE("/:id", userHandler.DeleteUser)
+		usersWriteGroup.PUT("/:id/roles", roleHandler.SetUserRoles)
+		usersWriteGroup.DELETE("/:id/sessions", userHandler.RevokeUserSessions)
+		usersWriteGroup.DELETE("/:id/mfa", authHandler.ResetUserMFA)
+		usersWriteGroup.POST("/:id/impersonate", authMiddleware.DenyImpersonation(), authHandler.Impersonate)
+	}
+
+	// Service accounts - admin only
+	serviceAccountHandler := v1.NewServiceAccountHandler(serviceAccountService)
+	serviceAccountsGroup := api.Group("/admin/service-accounts")
+	serviceAccountsGroup.Use(authMiddleware.RequirePlatformRole("admin"))
+	{
+		serviceAccountsGroup.POST("", serviceAccountHandler.CreateServiceAccount)
+		serviceAccountsGroup.GET("", serviceAccountHandler.ListServiceAccounts)
+		serviceAccountsGroup.GET("/:id", serviceAccountHandler.GetServiceAccount)
+		serviceAccountsGroup.GET("/:id/credentials", serviceAccountHandler.ListCredentials)
+		serviceAccountsGroup.POST("/:id/credentials", serviceAccountHandler.IssueCredential)
+		serviceAccountsGroup.DELETE("/:id/credentials/:credentialId", serviceAccountHandler.RevokeCredential)
+	}
+
+	// Role management endpoints
+	rolesReadGroup := api.Group("/admin/roles")
+	rolesReadGroup.Use(authMiddleware.RequireRole("admin", "developer"))
+	{
+		rolesReadGroup.GET("", roleHandler.ListRoles)
+		rolesReadGroup.GET("/:name", roleHandler.GetRole)
+	}
+
+	rolesWriteGroup := api.Group("/admin/roles")
+	rolesWriteGroup.Use(authMiddleware.RequireRole("admin"))
+	{
+		rolesWriteGroup.POST("", roleHandler.CreateRole)
+		rolesWriteGroup.PUT("/:name", roleHandler.UpdateRole)
+		rolesWriteGroup.DELETE("/:name", roleHandler.DeleteRole)
 	}
 
 	// Settings endpoints (only if settings service is available)
@@ -278,7 +469,7 @@ func setupAPIRoutes(router *gin.Engine, authService auth.Service, authMiddleware
 		
 		// System settings update - admin only
 		settingsAdminGroup := api.Group("/settings")
-		settingsAdminGroup.Use(authMiddleware.RequireRole("admin"))
+		settingsAdminGroup.Use(authMiddleware.RequirePlatformRole("admin"))
 		{
 			settingsAdminGroup.PUT("/system", settingsHandler.UpdateSystemSettings)
 		}
@@ -384,7 +575,9 @@ func initializeAdminUser(ctx context.Context, userRepo user.Repository, authServ
 	}
 	updateData := map[string]interface{}{
 		"$set": map[string]interface{}{
-			"role": "admin",
+			"role":           "admin",
+			"roles":          []string{"admin"},
+			"email_verified": true,
 		},
 	}
 	
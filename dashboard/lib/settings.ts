@@ -52,13 +52,31 @@ export interface SystemSettings {
   encryption_enabled: boolean
   session_timeout?: number
   password_policy?: string
+  password_max_age?: number
   mfa_required?: boolean
+  passwordless_login?: boolean
   audit_log_enabled?: boolean
   rate_limit: number
   burst_limit?: number
   cors_enabled: boolean
 }
 
+export interface PasswordPolicy {
+  tier: 'basic' | 'moderate' | 'strong'
+  min_length: number
+  max_length: number
+  min_char_classes: number
+  check_breached: boolean
+  history_size: number
+  max_age_days: number
+}
+
+// Returned in the "details" of a 400 when a password fails the policy
+export interface PasswordViolation {
+  code: 'too_short' | 'too_long' | 'char_classes' | 'similar_to_email' | 'breached' | 'reused'
+  message: string
+}
+
 export const settingsApi = {
   // User settings
   getUserSettings: async () => {
@@ -80,5 +98,10 @@ export const settingsApi = {
   updateSystemSettings: async (settings: Partial<SystemSettings>) => {
     const response = await apiClient.put('/settings/system', settings)
     return response.data
+  },
+
+  getPasswordPolicy: async () => {
+    const response = await apiClient.get<PasswordPolicy>('/auth/password-policy')
+    return response.data
   }
 }
\ No newline at end of file
@@ -0,0 +1,90 @@
+package accesskey
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/madhouselabs/anybase/pkg/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// verifyCacheTTL is how long a verified key is trusted before it is looked up again
+const verifyCacheTTL = 30 * time.Second
+
+// verifyCacheSweepSize is the entry count above which expired entries are pruned on insert
+const verifyCacheSweepSize = 1024
+
+// verifyCache remembers recently verified keys so repeated requests skip the database.
+// Entries are indexed by a digest of the presented key, never the key itself.
+type verifyCache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	ak         *models.AccessKey
+	validUntil time.Time
+}
+
+func newVerifyCache() *verifyCache {
+	return &verifyCache{entries: make(map[string]cacheEntry)}
+}
+
+func cacheDigest(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// get returns a copy of the cached key, if it is still fresh
+func (c *verifyCache) get(key string) (*models.AccessKey, bool) {
+	digest := cacheDigest(key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[digest]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.validUntil) {
+		delete(c.entries, digest)
+		return nil, false
+	}
+	ak := *entry.ak
+	return &ak, true
+}
+
+// put caches a verified key until validUntil, or the cache TTL if that is sooner
+func (c *verifyCache) put(key string, ak *models.AccessKey, validUntil time.Time) {
+	if deadline := time.Now().Add(verifyCacheTTL); validUntil.IsZero() || validUntil.After(deadline) {
+		validUntil = deadline
+	}
+	cached := *ak
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= verifyCacheSweepSize {
+		now := time.Now()
+		for digest, entry := range c.entries {
+			if now.After(entry.validUntil) {
+				delete(c.entries, digest)
+			}
+		}
+	}
+	c.entries[cacheDigest(key)] = cacheEntry{ak: &cached, validUntil: validUntil}
+}
+
+// invalidate drops every cached entry for the key, including ones for a previous secret
+func (c *verifyCache) invalidate(id primitive.ObjectID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for digest, entry := range c.entries {
+		if entry.ak.ID == id {
+			delete(c.entries, digest)
+		}
+	}
+}
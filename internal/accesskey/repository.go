@@ -2,13 +2,18 @@ package accesskey
 
 import (
 	"context"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	types "github.com/madhouselabs/anybase/internal/database/types"
+	"github.com/madhouselabs/anybase/internal/tenant"
 	"github.com/madhouselabs/anybase/pkg/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -31,35 +36,51 @@ type Repository interface {
 	Delete(ctx context.Context, id primitive.ObjectID) error
 	List(ctx context.Context, filter interface{}, opts interface{}) ([]*models.AccessKey, error)
 	Count(ctx context.Context, filter interface{}) (int64, error)
-	RegenerateKey(ctx context.Context, id primitive.ObjectID) (string, error)
-	UpdateLastUsed(ctx context.Context, id primitive.ObjectID) error
+	RegenerateKey(ctx context.Context, id primitive.ObjectID, gracePeriod time.Duration) (string, error)
+	RecordUsage(ctx context.Context, id primitive.ObjectID, ip, endpoint string) error
 	ValidateKey(ctx context.Context, key string) (*models.AccessKey, error)
 }
 
+// Keys are issued as ak_<public_id>_<secret>. The public ID is stored in the clear and
+// indexed, so a key is found with a single lookup; the secret is stored as an HMAC.
+const (
+	keyPrefix     = "ak_"
+	publicIDBytes = 8
+	secretBytes   = 32
+)
+
 type repository struct {
 	db types.DB
 	collection types.Collection
+
+	// HMAC key for stored secrets. Changing it invalidates every prefixed key.
+	secret []byte
+	cache  *verifyCache
+
+	// Set once a scan finds no bcrypt hashes left, after which legacy keys are not looked up
+	noLegacyKeys atomic.Bool
 }
 
-func NewRepository(db types.DB) Repository {
+func NewRepository(db types.DB, secret string) Repository {
 	return &repository{
 		db: db,
 		collection: db.Collection("access_keys"),
+		secret: []byte(secret),
+		cache: newVerifyCache(),
 	}
 }
 
 func (r *repository) Create(ctx context.Context, ak *models.AccessKey) (string, error) {
 	// Generate access key
-	accessKey := generateAccessKey()
-	
-	// Hash the key for storage
-	hashedKey, err := hashKey(accessKey)
+	publicID, secret, err := generateAccessKey()
 	if err != nil {
-		return "", fmt.Errorf("failed to hash access key: %w", err)
+		return "", err
 	}
+	accessKey := formatKey(publicID, secret)
 
 	ak.ID = primitive.NewObjectID()
-	ak.KeyHash = hashedKey
+	ak.PublicID = publicID
+	ak.KeyHash = r.hashSecret(secret)
 	ak.Active = true
 	ak.CreatedAt = time.Now()
 	ak.UpdatedAt = time.Now()
@@ -67,15 +88,24 @@ func (r *repository) Create(ctx context.Context, ak *models.AccessKey) (string,
 	doc := map[string]interface{}{
 		"_id": ak.ID,
 		"name": ak.Name,
+		"public_id": ak.PublicID,
 		"key_hash": ak.KeyHash,
 		"description": ak.Description,
 		"permissions": ak.Permissions,
-		"created_by": ak.CreatedBy,
+		"created_by": ak.CreatedBy.Hex(),
 		"active": ak.Active,
 		"expires_at": ak.ExpiresAt,
+		"allowed_cidrs": ak.AllowedCIDRs,
+		"allowed_origins": ak.AllowedOrigins,
+		"rate_limit": ak.RateLimit,
+		"request_count": 0,
 		"created_at": ak.CreatedAt,
 		"updated_at": ak.UpdatedAt,
 	}
+	if org, ok := tenant.FromContext(ctx); ok {
+		ak.OrgID = org.ID
+		doc["org_id"] = org.ID.Hex()
+	}
 	
 	_, err = r.collection.InsertOne(ctx, doc)
 	if err != nil {
@@ -90,7 +120,7 @@ func (r *repository) Create(ctx context.Context, ak *models.AccessKey) (string,
 
 func (r *repository) GetByID(ctx context.Context, id primitive.ObjectID) (*models.AccessKey, error) {
 	var ak models.AccessKey
-	filter := map[string]interface{}{"_id": id}
+	filter := scoped(ctx, map[string]interface{}{"_id": id})
 
 	err := r.collection.FindOne(ctx, filter, &ak)
 	if err != nil {
@@ -105,7 +135,7 @@ func (r *repository) GetByID(ctx context.Context, id primitive.ObjectID) (*model
 
 func (r *repository) GetByName(ctx context.Context, name string) (*models.AccessKey, error) {
 	var ak models.AccessKey
-	filter := map[string]interface{}{"name": name}
+	filter := scoped(ctx, map[string]interface{}{"name": name})
 
 	err := r.collection.FindOne(ctx, filter, &ak)
 	if err != nil {
@@ -119,38 +149,116 @@ func (r *repository) GetByName(ctx context.Context, name string) (*models.Access
 }
 
 func (r *repository) GetByKey(ctx context.Context, key string) (*models.AccessKey, error) {
-	// Get all active keys and check them one by one (bcrypt doesn't allow direct lookup)
-	cursor, err := r.collection.Find(ctx, map[string]interface{}{"active": true}, nil)
+	if ak, ok := r.cache.get(key); ok {
+		if ak.ExpiresAt != nil && ak.ExpiresAt.Before(time.Now()) {
+			return nil, ErrExpiredAccessKey
+		}
+		return ak, nil
+	}
+
+	var ak *models.AccessKey
+	var validUntil time.Time
+	var err error
+	if publicID, secret, ok := parseKey(key); ok {
+		ak, validUntil, err = r.getByPublicID(ctx, publicID, secret)
+	} else {
+		ak, validUntil, err = r.getByLegacyKey(ctx, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if expired
+	if ak.ExpiresAt != nil {
+		if ak.ExpiresAt.Before(time.Now()) {
+			return nil, ErrExpiredAccessKey
+		}
+		if validUntil.IsZero() || ak.ExpiresAt.Before(validUntil) {
+			validUntil = *ak.ExpiresAt
+		}
+	}
+
+	r.cache.put(key, ak, validUntil)
+	return ak, nil
+}
+
+// getByPublicID finds a prefixed key by its public ID and verifies the secret against the
+// current hash, or the previous one while its grace period lasts. A match on the previous
+// secret is only valid until the grace period ends.
+func (r *repository) getByPublicID(ctx context.Context, publicID, secret string) (*models.AccessKey, time.Time, error) {
+	var ak models.AccessKey
+	err := r.collection.FindOne(ctx, map[string]interface{}{"public_id": publicID}, &ak)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find access keys: %w", err)
+		if errors.Is(err, types.ErrNoDocuments) {
+			return nil, time.Time{}, ErrAccessKeyNotFound
+		}
+		return nil, time.Time{}, fmt.Errorf("failed to get access key: %w", err)
+	}
+
+	if r.verifySecret(ak.KeyHash, secret) {
+		return &ak, time.Time{}, nil
+	}
+	if ak.PreviousKeyExpiresAt != nil && ak.PreviousKeyExpiresAt.After(time.Now()) && r.verifySecret(ak.PreviousKeyHash, secret) {
+		return &ak, *ak.PreviousKeyExpiresAt, nil
+	}
+	return nil, time.Time{}, ErrInvalidAccessKey
+}
+
+// getByLegacyKey checks a key issued before public IDs existed. Those are bcrypt hashes of
+// the whole key, so every active key has to be tried. Keys that are not in the legacy format
+// are turned away before any hash is compared, and once no legacy hashes remain the scan is
+// skipped altogether.
+func (r *repository) getByLegacyKey(ctx context.Context, key string) (*models.AccessKey, time.Time, error) {
+	if !isLegacyKey(key) {
+		return nil, time.Time{}, ErrInvalidAccessKey
+	}
+	if r.noLegacyKeys.Load() {
+		return nil, time.Time{}, ErrAccessKeyNotFound
+	}
+
+	// Inactive keys are read too, since one can be reactivated
+	cursor, err := r.collection.Find(ctx, map[string]interface{}{}, nil)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to find access keys: %w", err)
 	}
 	defer cursor.Close(ctx)
 
+	legacyHashes := false
+	var seen int64
 	for cursor.Next(ctx) {
+		seen++
 		var ak models.AccessKey
 		if err := cursor.Decode(&ak); err != nil {
+			legacyHashes = true // Unknown, so keep scanning next time
 			continue
 		}
-		
-		// Check if this key matches
-		if err := verifyKey(ak.KeyHash, key); err == nil {
-			// Check if expired
-			if ak.ExpiresAt != nil && ak.ExpiresAt.Before(time.Now()) {
-				return nil, ErrExpiredAccessKey
-			}
-
-			// Update last used timestamp
-			go func() {
-				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-				defer cancel()
-				r.UpdateLastUsed(ctx, ak.ID)
-			}()
-
-			return &ak, nil
+
+		// A legacy key that was regenerated keeps its old hash during the grace period
+		current := isLegacyHash(ak.KeyHash)
+		previous := ak.PreviousKeyExpiresAt != nil && ak.PreviousKeyExpiresAt.After(time.Now()) && isLegacyHash(ak.PreviousKeyHash)
+		if !current && !previous {
+			continue
+		}
+		legacyHashes = true
+		if !ak.Active {
+			continue
+		}
+		if current && verifyLegacyKey(ak.KeyHash, key) == nil {
+			return &ak, time.Time{}, nil
+		}
+		if previous && verifyLegacyKey(ak.PreviousKeyHash, key) == nil {
+			return &ak, *ak.PreviousKeyExpiresAt, nil
 		}
 	}
 
-	return nil, ErrAccessKeyNotFound
+	// Legacy keys are no longer issued, so once they are gone they stay gone. The count makes
+	// sure the scan was not cut short by an error.
+	if !legacyHashes {
+		if total, err := r.collection.CountDocuments(ctx, map[string]interface{}{}); err == nil && total == seen {
+			r.noLegacyKeys.Store(true)
+		}
+	}
+	return nil, time.Time{}, ErrAccessKeyNotFound
 }
 
 func (r *repository) ValidateKey(ctx context.Context, key string) (*models.AccessKey, error) {
@@ -158,15 +266,20 @@ func (r *repository) ValidateKey(ctx context.Context, key string) (*models.Acces
 }
 
 func (r *repository) Update(ctx context.Context, id primitive.ObjectID, update bson.M) error {
-	filter := map[string]interface{}{"_id": id}
+	filter := scoped(ctx, map[string]interface{}{"_id": id})
 	
 	updateMap := map[string]interface{}(update)
-	if updateMap["$set"] == nil {
-		updateMap["$set"] = map[string]interface{}{}
+	switch set := updateMap["$set"].(type) {
+	case bson.M:
+		set["updated_at"] = time.Now()
+	case map[string]interface{}:
+		set["updated_at"] = time.Now()
+	default:
+		updateMap["$set"] = map[string]interface{}{"updated_at": time.Now()}
 	}
-	updateMap["$set"].(map[string]interface{})["updated_at"] = time.Now()
 
 	result, err := r.collection.UpdateOne(ctx, filter, updateMap)
+	r.cache.invalidate(id)
 	if err != nil {
 		return fmt.Errorf("failed to update access key: %w", err)
 	}
@@ -179,8 +292,9 @@ func (r *repository) Update(ctx context.Context, id primitive.ObjectID, update b
 }
 
 func (r *repository) Delete(ctx context.Context, id primitive.ObjectID) error {
-	filter := map[string]interface{}{"_id": id}
+	filter := scoped(ctx, map[string]interface{}{"_id": id})
 	result, err := r.collection.DeleteOne(ctx, filter)
+	r.cache.invalidate(id)
 	if err != nil {
 		return fmt.Errorf("failed to delete access key: %w", err)
 	}
@@ -220,7 +334,7 @@ func (r *repository) List(ctx context.Context, filter interface{}, opts interfac
 		}
 	}
 	
-	cursor, err := r.collection.Find(ctx, filterMap, findOpts)
+	cursor, err := r.collection.Find(ctx, scoped(ctx, filterMap), findOpts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list access keys: %w", err)
 	}
@@ -254,7 +368,7 @@ func (r *repository) Count(ctx context.Context, filter interface{}) (int64, erro
 		}
 	}
 
-	count, err := r.collection.CountDocuments(ctx, filterMap)
+	count, err := r.collection.CountDocuments(ctx, scoped(ctx, filterMap))
 	if err != nil {
 		return 0, fmt.Errorf("failed to count access keys: %w", err)
 	}
@@ -262,23 +376,39 @@ func (r *repository) Count(ctx context.Context, filter interface{}) (int64, erro
 	return count, nil
 }
 
-func (r *repository) RegenerateKey(ctx context.Context, id primitive.ObjectID) (string, error) {
+// RegenerateKey replaces the key's secret, keeping its public ID. Legacy keys are given one.
+// With a grace period the old secret keeps working until the period ends; otherwise it stops
+// working immediately.
+func (r *repository) RegenerateKey(ctx context.Context, id primitive.ObjectID, gracePeriod time.Duration) (string, error) {
+	current, err := r.GetByID(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
 	// Generate new access key
-	accessKey := generateAccessKey()
-	
-	// Hash the key for storage
-	hashedKey, err := hashKey(accessKey)
+	publicID, secret, err := generateAccessKey()
 	if err != nil {
-		return "", fmt.Errorf("failed to hash access key: %w", err)
+		return "", err
 	}
+	if current.PublicID != "" {
+		publicID = current.PublicID
+	}
+	accessKey := formatKey(publicID, secret)
 
 	// Update the access key with new hash
-	update := bson.M{
-		"$set": bson.M{
-			"key_hash":   hashedKey,
-			"updated_at": time.Now(),
-		},
+	set := bson.M{
+		"public_id":               publicID,
+		"key_hash":                r.hashSecret(secret),
+		"previous_key_hash":       "",
+		"previous_key_expires_at": nil,
+		"updated_at":              time.Now(),
+	}
+	if gracePeriod > 0 {
+		graceUntil := time.Now().Add(gracePeriod)
+		set["previous_key_hash"] = current.KeyHash
+		set["previous_key_expires_at"] = graceUntil
 	}
+	update := bson.M{"$set": set}
 
 	err = r.Update(ctx, id, update)
 	if err != nil {
@@ -288,11 +418,17 @@ func (r *repository) RegenerateKey(ctx context.Context, id primitive.ObjectID) (
 	return accessKey, nil
 }
 
-func (r *repository) UpdateLastUsed(ctx context.Context, id primitive.ObjectID) error {
+// RecordUsage counts a request made with the key and remembers where it came from
+func (r *repository) RecordUsage(ctx context.Context, id primitive.ObjectID, ip, endpoint string) error {
 	filter := bson.M{"_id": id}
 	update := bson.M{
 		"$set": bson.M{
-			"last_used": time.Now(),
+			"last_used":     time.Now(),
+			"last_ip":       ip,
+			"last_endpoint": endpoint,
+		},
+		"$inc": bson.M{
+			"request_count": 1,
 		},
 	}
 
@@ -302,25 +438,84 @@ func (r *repository) UpdateLastUsed(ctx context.Context, id primitive.ObjectID)
 
 // Helper functions
 
-func generateAccessKey() string {
-	// Generate a 32-byte random key
-	b := make([]byte, 32)
+// scoped limits a filter to the keys of the organization in ctx, or to keys outside every
+// organization. Access keys share one table, so this is what keeps organizations apart.
+// Lookups by the key itself are not scoped: the key determines the organization.
+func scoped(ctx context.Context, filter map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(filter)+1)
+	for k, v := range filter {
+		result[k] = v
+	}
+	if org, ok := tenant.FromContext(ctx); ok {
+		result["org_id"] = org.ID.Hex()
+	} else {
+		result["org_id"] = nil
+	}
+	return result
+}
+
+func generateAccessKey() (publicID, secret string, err error) {
+	id := make([]byte, publicIDBytes)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+	b := make([]byte, secretBytes)
 	if _, err := rand.Read(b); err != nil {
-		panic(fmt.Sprintf("failed to generate random bytes: %v", err))
+		return "", "", fmt.Errorf("failed to generate random bytes: %w", err)
 	}
-	
-	// Encode to hex and add prefix for easy identification
-	return "ak_" + hex.EncodeToString(b)
+	return hex.EncodeToString(id), hex.EncodeToString(b), nil
 }
 
-func hashKey(key string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(key), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
+func formatKey(publicID, secret string) string {
+	return keyPrefix + publicID + "_" + secret
+}
+
+// parseKey splits a prefixed key into its public ID and secret. Legacy keys (ak_<secret>)
+// do not parse.
+func parseKey(key string) (publicID, secret string, ok bool) {
+	rest, found := strings.CutPrefix(key, keyPrefix)
+	if !found {
+		return "", "", false
 	}
-	return string(bytes), nil
+	publicID, secret, found = strings.Cut(rest, "_")
+	if !found || len(publicID) != publicIDBytes*2 || len(secret) != secretBytes*2 {
+		return "", "", false
+	}
+	return publicID, secret, true
+}
+
+func (r *repository) hashSecret(secret string) string {
+	mac := hmac.New(sha256.New, r.secret)
+	mac.Write([]byte(secret))
+	return hex.EncodeToString(mac.Sum(nil))
 }
 
-func verifyKey(hashedKey, key string) error {
+// verifySecret compares in constant time. Legacy bcrypt hashes never match a prefixed key.
+func (r *repository) verifySecret(hashedSecret, secret string) bool {
+	if hashedSecret == "" || isLegacyHash(hashedSecret) {
+		return false
+	}
+	return hmac.Equal([]byte(hashedSecret), []byte(r.hashSecret(secret)))
+}
+
+// isLegacyKey reports whether a key has the legacy form: ak_ and 64 lowercase hex digits
+func isLegacyKey(key string) bool {
+	rest, found := strings.CutPrefix(key, keyPrefix)
+	if !found || len(rest) != secretBytes*2 {
+		return false
+	}
+	for _, c := range rest {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func isLegacyHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2")
+}
+
+func verifyLegacyKey(hashedKey, key string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashedKey), []byte(key))
-}
\ No newline at end of file
+}
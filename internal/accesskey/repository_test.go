@@ -0,0 +1,127 @@
+package accesskey
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	types "github.com/madhouselabs/anybase/internal/database/types"
+	"github.com/madhouselabs/anybase/pkg/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// keyCollection serves a fixed set of keys and counts the scans that could reach bcrypt
+type keyCollection struct {
+	types.Collection
+
+	keys  []*models.AccessKey
+	scans int
+}
+
+func (c *keyCollection) Find(ctx context.Context, filter map[string]interface{}, opts *types.FindOptions) (types.Cursor, error) {
+	c.scans++
+	return &keyCursor{keys: c.keys, pos: -1}, nil
+}
+
+func (c *keyCollection) CountDocuments(ctx context.Context, filter map[string]interface{}) (int64, error) {
+	return int64(len(c.keys)), nil
+}
+
+type keyCursor struct {
+	types.Cursor
+
+	keys []*models.AccessKey
+	pos  int
+}
+
+func (c *keyCursor) Next(ctx context.Context) bool {
+	c.pos++
+	return c.pos < len(c.keys)
+}
+
+func (c *keyCursor) Decode(result interface{}) error {
+	*result.(*models.AccessKey) = *c.keys[c.pos]
+	return nil
+}
+
+func (c *keyCursor) Close(ctx context.Context) error { return nil }
+
+func newTestRepository(keys ...*models.AccessKey) (*repository, *keyCollection) {
+	col := &keyCollection{keys: keys}
+	return &repository{collection: col, secret: []byte("test-secret"), cache: newVerifyCache()}, col
+}
+
+// legacyKey returns a key in the old format along with a stored key holding its bcrypt hash
+func legacyKey(t *testing.T) (string, *models.AccessKey) {
+	t.Helper()
+	key := keyPrefix + strings.Repeat("0123456789abcdef", 4)
+	hash, err := bcrypt.GenerateFromPassword([]byte(key), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, &models.AccessKey{ID: primitive.NewObjectID(), KeyHash: string(hash), Active: true}
+}
+
+func TestGetByKeyRejectsMalformedKeysWithoutScanning(t *testing.T) {
+	_, stored := legacyKey(t)
+	repo, col := newTestRepository(stored)
+
+	for _, key := range []string{
+		"ak_x",
+		"ak_",
+		keyPrefix + strings.Repeat("a", secretBytes*2-1),
+		keyPrefix + strings.Repeat("a", secretBytes*2+1),
+		keyPrefix + strings.Repeat("A", secretBytes*2),
+		keyPrefix + strings.Repeat("g", secretBytes*2),
+		keyPrefix + "abc_def",
+	} {
+		if _, err := repo.GetByKey(context.Background(), key); !errors.Is(err, ErrInvalidAccessKey) {
+			t.Errorf("%q: expected ErrInvalidAccessKey, got %v", key, err)
+		}
+	}
+	if col.scans != 0 {
+		t.Fatalf("malformed keys caused %d scans of the stored hashes", col.scans)
+	}
+}
+
+func TestGetByKeyLegacy(t *testing.T) {
+	key, stored := legacyKey(t)
+	repo, col := newTestRepository(stored)
+
+	ak, err := repo.GetByKey(context.Background(), key)
+	if err != nil || ak.ID != stored.ID {
+		t.Fatalf("GetByKey: %+v, %v", ak, err)
+	}
+
+	// A well-formed key that matches nothing still scans while legacy hashes remain
+	other := keyPrefix + strings.Repeat("f", secretBytes*2)
+	for i := 0; i < 2; i++ {
+		if _, err := repo.GetByKey(context.Background(), other); !errors.Is(err, ErrAccessKeyNotFound) {
+			t.Fatalf("expected ErrAccessKeyNotFound, got %v", err)
+		}
+	}
+	if col.scans != 3 {
+		t.Fatalf("got %d scans, want 3", col.scans)
+	}
+}
+
+func TestGetByKeySkipsScanOnceLegacyHashesAreGone(t *testing.T) {
+	key, _ := legacyKey(t)
+	repo, col := newTestRepository(&models.AccessKey{
+		ID:       primitive.NewObjectID(),
+		PublicID: "0011223344556677",
+		KeyHash:  "not-a-bcrypt-hash",
+		Active:   true,
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, err := repo.GetByKey(context.Background(), key); !errors.Is(err, ErrAccessKeyNotFound) {
+			t.Fatalf("expected ErrAccessKeyNotFound, got %v", err)
+		}
+	}
+	if col.scans != 1 {
+		t.Fatalf("got %d scans, want only the first", col.scans)
+	}
+}
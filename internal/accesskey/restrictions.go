@@ -0,0 +1,98 @@
+package accesskey
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/madhouselabs/anybase/pkg/models"
+)
+
+// MaxGracePeriodHours bounds how long a rotated key keeps working
+const MaxGracePeriodHours = 7 * 24
+
+// NormalizeCIDRs validates an IP allowlist. Bare addresses are turned into single-host ranges.
+func NormalizeCIDRs(cidrs []string) ([]string, error) {
+	normalized := make([]string, 0, len(cidrs))
+	for _, entry := range cidrs {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address or CIDR '%s'", entry)
+			}
+			if ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address or CIDR '%s'", entry)
+		}
+		normalized = append(normalized, network.String())
+	}
+	return normalized, nil
+}
+
+// NormalizeOrigins validates allowed browser origins of the form scheme://host[:port].
+// The host may start with "*." to allow any subdomain.
+func NormalizeOrigins(origins []string) ([]string, error) {
+	normalized := make([]string, 0, len(origins))
+	for _, origin := range origins {
+		origin = strings.TrimRight(strings.ToLower(strings.TrimSpace(origin)), "/")
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+			return nil, fmt.Errorf("invalid origin '%s': expected scheme://host[:port]", origin)
+		}
+		if strings.Contains(strings.TrimPrefix(u.Host, "*."), "*") {
+			return nil, fmt.Errorf("invalid origin '%s': only a leading '*.' wildcard is supported", origin)
+		}
+		normalized = append(normalized, u.Scheme+"://"+u.Host)
+	}
+	return normalized, nil
+}
+
+// AllowsIP reports whether the key may be used from the given client IP
+func AllowsIP(ak *models.AccessKey, clientIP string) bool {
+	if len(ak.AllowedCIDRs) == 0 {
+		return true
+	}
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range ak.AllowedCIDRs {
+		if _, network, err := net.ParseCIDR(cidr); err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsOrigin reports whether the key may be used by a page on the given origin. Keys with
+// allowed origins reject requests that carry neither an Origin nor a Referer header.
+func AllowsOrigin(ak *models.AccessKey, origin string) bool {
+	if len(ak.AllowedOrigins) == 0 {
+		return true
+	}
+	u, err := url.Parse(strings.ToLower(origin))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return false
+	}
+	for _, allowed := range ak.AllowedOrigins {
+		a, err := url.Parse(allowed)
+		if err != nil || a.Scheme != u.Scheme {
+			continue
+		}
+		if a.Host == u.Host {
+			return true
+		}
+		if suffix := strings.TrimPrefix(a.Host, "*"); suffix != a.Host && strings.HasSuffix(u.Host, suffix) {
+			return true
+		}
+	}
+	return false
+}
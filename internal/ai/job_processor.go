@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/madhouselabs/anybase/internal/tenant"
 	"github.com/madhouselabs/anybase/pkg/models"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -13,6 +14,9 @@ import (
 type JobProcessor struct {
 	service Service
 	running bool
+
+	// organizations lists the namespaces whose jobs are polled besides the default one
+	organizations func(ctx context.Context) ([]*models.Organization, error)
 }
 
 // NewJobProcessor creates a new job processor
@@ -23,6 +27,12 @@ func NewJobProcessor(s Service) *JobProcessor {
 	}
 }
 
+// WithOrganizations makes the processor poll the jobs of every listed organization
+func (p *JobProcessor) WithOrganizations(list func(ctx context.Context) ([]*models.Organization, error)) *JobProcessor {
+	p.organizations = list
+	return p
+}
+
 // Start begins processing jobs
 func (p *JobProcessor) Start() {
 	if p.running {
@@ -41,35 +51,56 @@ func (p *JobProcessor) Stop() {
 // processLoop continuously processes pending jobs
 func (p *JobProcessor) processLoop() {
 	for p.running {
-		ctx := context.Background()
-		
-		// Get pending jobs
-		jobs, err := p.service.GetRepository().GetPendingJobs(ctx, 5)
-		if err != nil {
-			fmt.Printf("Error getting pending jobs: %v\n", err)
-			time.Sleep(10 * time.Second)
-			continue
+		processed := 0
+		failed := false
+
+		// Jobs are stored per organization, so each namespace is polled in turn
+		for _, ctx := range p.namespaces() {
+			jobs, err := p.service.GetRepository().GetPendingJobs(ctx, 5)
+			if err != nil {
+				fmt.Printf("Error getting pending jobs: %v\n", err)
+				failed = true
+				continue
+			}
+
+			// Process each job
+			for _, job := range jobs {
+				if !p.running {
+					break
+				}
+				p.ProcessJob(ctx, job.ID)
+				processed++
+			}
 		}
-		
-		if len(jobs) == 0 {
+
+		if failed && processed == 0 {
+			time.Sleep(10 * time.Second)
+		} else if processed == 0 {
 			// No jobs, wait before checking again
 			time.Sleep(5 * time.Second)
-			continue
-		}
-		
-		// Process each job
-		for _, job := range jobs {
-			if !p.running {
-				break
-			}
-			p.ProcessJob(job.ID)
 		}
 	}
 }
 
-// ProcessJob processes a single embedding job
-func (p *JobProcessor) ProcessJob(jobID primitive.ObjectID) {
-	ctx := context.Background()
+// namespaces returns a context for the default namespace and one for each organization
+func (p *JobProcessor) namespaces() []context.Context {
+	contexts := []context.Context{context.Background()}
+	if p.organizations == nil {
+		return contexts
+	}
+	orgs, err := p.organizations(context.Background())
+	if err != nil {
+		fmt.Printf("Error listing organizations: %v\n", err)
+		return contexts
+	}
+	for _, org := range orgs {
+		contexts = append(contexts, tenant.WithOrg(context.Background(), org))
+	}
+	return contexts
+}
+
+// ProcessJob processes a single embedding job of the organization bound to ctx
+func (p *JobProcessor) ProcessJob(ctx context.Context, jobID primitive.ObjectID) {
 	
 	// Get job details
 	job, err := p.service.GetRepository().GetEmbeddingJob(ctx, jobID)
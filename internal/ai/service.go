@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/madhouselabs/anybase/internal/collection"
+	"github.com/madhouselabs/anybase/internal/tenant"
 	"github.com/madhouselabs/anybase/pkg/models"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -588,7 +589,7 @@ func (s *service) GenerateEmbeddings(ctx context.Context, userID primitive.Objec
 	}
 	
 	// Start processing in background
-	go s.jobProcessor.ProcessJob(job.ID)
+	go s.jobProcessor.ProcessJob(tenant.Detach(ctx), job.ID)
 	
 	return job, nil
 }
@@ -626,7 +627,7 @@ func (s *service) GenerateAllEmbeddings(ctx context.Context, userID primitive.Ob
 	}
 	
 	// Start processing in background
-	go s.jobProcessor.ProcessJob(job.ID)
+	go s.jobProcessor.ProcessJob(tenant.Detach(ctx), job.ID)
 	
 	return job, nil
 }
@@ -741,6 +742,9 @@ func (s *service) QueryRAG(ctx context.Context, userID primitive.ObjectID, query
 		ragDocs = append(ragDocs, doc)
 	}
 	
+	// Attach full-text snippets when the collection has text search configured
+	s.attachHighlights(ctx, userID, query, ragDocs)
+	
 	// Build response
 	response := &models.RAGResponse{
 		Query:      query.Query,
@@ -759,6 +763,41 @@ func (s *service) QueryRAG(ctx context.Context, userID primitive.ObjectID, query
 	return response, nil
 }
 
+// attachHighlights fills RAGDocument.Highlights from a full-text search over the returned documents
+func (s *service) attachHighlights(ctx context.Context, userID primitive.ObjectID, query *models.RAGQuery, docs []models.RAGDocument) {
+	if len(docs) == 0 {
+		return
+	}
+	ids := make([]interface{}, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.ID.Hex())
+	}
+	
+	results, err := s.collectionService.TextSearch(ctx, userID, query.CollectionName, collection.TextSearchOptions{
+		Query:     query.Query,
+		Filter:    map[string]interface{}{"_id": map[string]interface{}{"$in": ids}},
+		Limit:     len(ids),
+		Highlight: true,
+	})
+	if err != nil {
+		// Text search is optional; vector results are returned without snippets
+		return
+	}
+	
+	highlights := make(map[string]map[string][]string, len(results))
+	for _, result := range results {
+		id, _ := result["_id"].(string)
+		if h, ok := result["_highlights"].(map[string][]string); ok {
+			highlights[id] = h
+		}
+	}
+	for i := range docs {
+		if h, ok := highlights[docs[i].ID.Hex()]; ok {
+			docs[i].Highlights = h
+		}
+	}
+}
+
 // Document hooks for auto-embedding
 
 func (s *service) OnDocumentCreated(ctx context.Context, collectionName string, document *models.Document) error {
@@ -787,7 +826,7 @@ func (s *service) OnDocumentCreated(ctx context.Context, collectionName string,
 	}
 	
 	// Process immediately for single documents
-	go s.jobProcessor.ProcessJob(job.ID)
+	go s.jobProcessor.ProcessJob(tenant.Detach(ctx), job.ID)
 	
 	return nil
 }
@@ -819,7 +858,7 @@ func (s *service) OnDocumentUpdated(ctx context.Context, collectionName string,
 		return nil
 	}
 	
-	go s.jobProcessor.ProcessJob(job.ID)
+	go s.jobProcessor.ProcessJob(tenant.Detach(ctx), job.ID)
 	
 	return nil
 }
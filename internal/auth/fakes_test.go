@@ -0,0 +1,453 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/madhouselabs/anybase/internal/config"
+	types "github.com/madhouselabs/anybase/internal/database/types"
+	"github.com/madhouselabs/anybase/internal/mailer"
+	"github.com/madhouselabs/anybase/internal/oidc"
+	"github.com/madhouselabs/anybase/internal/session"
+	"github.com/madhouselabs/anybase/internal/settings"
+	"github.com/madhouselabs/anybase/internal/user"
+	"github.com/madhouselabs/anybase/pkg/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The fakes below keep their data in memory. Each embeds the interface it stands in for,
+// so a test that reaches a method the fake does not implement fails loudly.
+
+// testEnv is a service wired to in-memory fakes, with handles on the fakes for assertions
+type testEnv struct {
+	*service
+	users *fakeUsers
+	db    *memDB
+	mail  *outbox
+}
+
+// newTestService returns a service over in-memory storage that signs HS256 tokens. Sessions
+// use the real repository, so rotation goes through its compare-and-swap.
+func newTestService(t *testing.T) *testEnv {
+	t.Helper()
+	cfg := &config.AuthConfig{
+		JWTSecret:              "test-secret-that-is-long-enough-for-hs256",
+		JWTExpiration:          15 * time.Minute,
+		RefreshTokenExpiration: 24 * time.Hour,
+		MaxLoginAttempts:       5,
+		LockoutDuration:        15 * time.Minute,
+		MFAIssuer:              "AnyBase",
+		MFAChallengeExpiration: 5 * time.Minute,
+		PasswordlessURL:        "http://localhost/login",
+		PasswordlessExpiration: 15 * time.Minute,
+	}
+	templates, err := mailer.LoadTemplates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := &testEnv{
+		users: &fakeUsers{users: map[primitive.ObjectID]*models.User{}},
+		db:    &memDB{collections: map[string]*memCollection{}},
+		mail:  &outbox{},
+	}
+	env.service = &service{
+		userRepo:     env.users,
+		sessionRepo:  session.NewRepository(env.db),
+		identities:   &fakeIdentities{identities: map[string]*models.UserIdentity{}},
+		auditLogs:    env.db.Collection("audit_logs"),
+		loginTokens:  env.db.Collection("login_tokens"),
+		settings:     &fakeSettings{system: &models.SystemSettings{}},
+		mail:         mailer.NewSender(env.mail, templates, "noreply@example.com"),
+		oidcStates:   &fakeStates{states: map[string]*oidc.LoginState{}},
+		tokenService: NewTokenService(cfg, nil),
+		config:       cfg,
+	}
+	return env
+}
+
+// systemSettings returns the settings the service reads, for a test to change
+func (env *testEnv) systemSettings() *models.SystemSettings {
+	return env.settings.(*fakeSettings).system
+}
+
+// addUser stores an active regular user
+func (env *testEnv) addUser(email string) *models.User {
+	return env.users.add(&models.User{Email: email, Active: true, UserType: models.UserTypeRegular})
+}
+
+// outbox is a mailer.Mailer that keeps what it sends
+type outbox struct {
+	mu   sync.Mutex
+	sent []*mailer.Message
+}
+
+func (o *outbox) Send(ctx context.Context, msg *mailer.Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.sent = append(o.sent, msg)
+	return nil
+}
+
+func (o *outbox) messages() []*mailer.Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]*mailer.Message(nil), o.sent...)
+}
+
+// fakeUsers is an in-memory user.Repository
+type fakeUsers struct {
+	user.Repository
+
+	mu    sync.Mutex
+	users map[primitive.ObjectID]*models.User
+}
+
+func (f *fakeUsers) add(u *models.User) *models.User {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if u.ID.IsZero() {
+		u.ID = primitive.NewObjectID()
+	}
+	stored := *u
+	f.users[u.ID] = &stored
+	return u
+}
+
+// get returns a copy of the stored user, as a fresh read from the database would
+func (f *fakeUsers) get(id primitive.ObjectID) *models.User {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if u, ok := f.users[id]; ok {
+		copied := *u
+		return &copied
+	}
+	return nil
+}
+
+func (f *fakeUsers) update(id primitive.ObjectID, fn func(u *models.User)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.users[id]
+	if !ok {
+		return user.ErrUserNotFound
+	}
+	fn(u)
+	return nil
+}
+
+func (f *fakeUsers) Create(ctx context.Context, u *models.User) error {
+	u.ID = primitive.NewObjectID()
+	f.add(u)
+	return nil
+}
+
+func (f *fakeUsers) GetByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	if u := f.get(id); u != nil {
+		return u, nil
+	}
+	return nil, user.ErrUserNotFound
+}
+
+// GetByEmail ignores case, as the repository does
+func (f *fakeUsers) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	f.mu.Lock()
+	var id primitive.ObjectID
+	for _, u := range f.users {
+		if strings.EqualFold(u.Email, email) {
+			id = u.ID
+		}
+	}
+	f.mu.Unlock()
+	return f.GetByID(ctx, id)
+}
+
+func (f *fakeUsers) UpdateRaw(ctx context.Context, id primitive.ObjectID, update interface{}) error {
+	fields, _ := update.(map[string]interface{})
+	return f.update(id, func(u *models.User) {
+		if role, ok := fields["role"].(string); ok {
+			u.Role = role
+		}
+		if roles, ok := fields["roles"].([]string); ok {
+			u.Roles = roles
+		}
+	})
+}
+
+func (f *fakeUsers) VerifyEmail(ctx context.Context, id primitive.ObjectID) error {
+	return f.update(id, func(u *models.User) { u.EmailVerified = true })
+}
+
+func (f *fakeUsers) UpdateLastLogin(ctx context.Context, id primitive.ObjectID) error {
+	return f.update(id, func(u *models.User) { u.LoginAttempts = 0 })
+}
+
+func (f *fakeUsers) UpdateLoginAttempts(ctx context.Context, id primitive.ObjectID, attempts int) error {
+	return f.update(id, func(u *models.User) { u.LoginAttempts = attempts })
+}
+
+func (f *fakeUsers) UpdateMFA(ctx context.Context, id primitive.ObjectID, revision int64, state *user.MFAState) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.users[id]
+	if !ok {
+		return user.ErrUserNotFound
+	}
+	if u.MFARevision != revision {
+		return user.ErrMFAConflict
+	}
+	u.MFAEnabled = state.Enabled
+	u.MFASecret = state.Secret
+	u.MFAPendingSecret = state.PendingSecret
+	u.MFARecoveryCodes = state.RecoveryCodes
+	u.MFALastStep = state.LastStep
+	u.MFARevision++
+	return nil
+}
+
+func (f *fakeUsers) UpdateLockedUntil(ctx context.Context, id primitive.ObjectID, until *time.Time) error {
+	return f.update(id, func(u *models.User) { u.LockedUntil = until })
+}
+
+// fakeIdentities is an in-memory user.IdentityRepository
+type fakeIdentities struct {
+	mu         sync.Mutex
+	identities map[string]*models.UserIdentity
+}
+
+func (f *fakeIdentities) Get(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if identity, ok := f.identities[provider+"|"+subject]; ok {
+		return identity, nil
+	}
+	return nil, user.ErrIdentityNotFound
+}
+
+func (f *fakeIdentities) Create(ctx context.Context, identity *models.UserIdentity) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	identity.ID = primitive.NewObjectID()
+	f.identities[identity.Provider+"|"+identity.Subject] = identity
+	return nil
+}
+
+// fakeSettings serves fixed system settings
+type fakeSettings struct {
+	settings.Service
+	system *models.SystemSettings
+}
+
+func (f *fakeSettings) GetSystemSettings(ctx context.Context) (*models.SystemSettings, error) {
+	return f.system, nil
+}
+
+// fakeStates is an in-memory oidc.StateStore
+type fakeStates struct {
+	mu     sync.Mutex
+	states map[string]*oidc.LoginState
+}
+
+func (f *fakeStates) Create(ctx context.Context, state *oidc.LoginState) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := primitive.NewObjectID().Hex()
+	f.states[key] = state
+	return key, nil
+}
+
+func (f *fakeStates) Consume(ctx context.Context, key string) (*oidc.LoginState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	state, ok := f.states[key]
+	if !ok {
+		return nil, oidc.ErrStateNotFound
+	}
+	delete(f.states, key)
+	return state, nil
+}
+
+// memDB hands out in-memory collections by name
+type memDB struct {
+	types.DB
+
+	mu          sync.Mutex
+	collections map[string]*memCollection
+}
+
+func (db *memDB) Collection(name string) types.Collection {
+	return db.collection(name)
+}
+
+func (db *memDB) collection(name string) *memCollection {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	c, ok := db.collections[name]
+	if !ok {
+		c = &memCollection{}
+		db.collections[name] = c
+	}
+	return c
+}
+
+// memCollection is an in-memory types.Collection. Documents are stored as decoded JSON, and
+// filters match top-level fields by equality, with nil matching a missing field.
+type memCollection struct {
+	types.Collection
+
+	mu   sync.Mutex
+	docs []map[string]interface{}
+}
+
+func (c *memCollection) InsertOne(ctx context.Context, document map[string]interface{}) (types.ID, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.docs = append(c.docs, normalize(document).(map[string]interface{}))
+	return nil, nil
+}
+
+func (c *memCollection) FindOne(ctx context.Context, filter map[string]interface{}, result interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, doc := range c.docs {
+		if matches(doc, filter) {
+			return decodeInto(doc, result)
+		}
+	}
+	return types.ErrNoDocuments
+}
+
+func (c *memCollection) Find(ctx context.Context, filter map[string]interface{}, options *types.FindOptions) (types.Cursor, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var found []map[string]interface{}
+	for _, doc := range c.docs {
+		if matches(doc, filter) {
+			found = append(found, doc)
+		}
+	}
+	return &memCursor{docs: found, pos: -1}, nil
+}
+
+func (c *memCollection) UpdateOne(ctx context.Context, filter, update map[string]interface{}) (*types.UpdateResult, error) {
+	return c.update(filter, update, 1)
+}
+
+func (c *memCollection) UpdateMany(ctx context.Context, filter, update map[string]interface{}) (*types.UpdateResult, error) {
+	return c.update(filter, update, -1)
+}
+
+func (c *memCollection) update(filter, update map[string]interface{}, limit int) (*types.UpdateResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	set, _ := normalize(update["$set"]).(map[string]interface{})
+	result := &types.UpdateResult{}
+	for _, doc := range c.docs {
+		if limit >= 0 && int(result.MatchedCount) == limit {
+			break
+		}
+		if !matches(doc, filter) {
+			continue
+		}
+		for k, v := range set {
+			doc[k] = v
+		}
+		result.MatchedCount++
+		result.ModifiedCount++
+	}
+	return result, nil
+}
+
+func (c *memCollection) DeleteOne(ctx context.Context, filter map[string]interface{}) (*types.DeleteResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, doc := range c.docs {
+		if matches(doc, filter) {
+			c.docs = append(c.docs[:i], c.docs[i+1:]...)
+			return &types.DeleteResult{DeletedCount: 1}, nil
+		}
+	}
+	return &types.DeleteResult{}, nil
+}
+
+// find returns the stored documents matching filter
+func (c *memCollection) find(filter map[string]interface{}) []map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var found []map[string]interface{}
+	for _, doc := range c.docs {
+		if matches(doc, filter) {
+			found = append(found, doc)
+		}
+	}
+	return found
+}
+
+type memCursor struct {
+	docs []map[string]interface{}
+	pos  int
+}
+
+func (c *memCursor) Next(ctx context.Context) bool {
+	c.pos++
+	return c.pos < len(c.docs)
+}
+
+func (c *memCursor) Decode(result interface{}) error {
+	return decodeInto(c.docs[c.pos], result)
+}
+
+func (c *memCursor) Close(ctx context.Context) error { return nil }
+
+func (c *memCursor) All(ctx context.Context, results interface{}) error {
+	data, err := json.Marshal(c.docs)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, results)
+}
+
+func matches(doc, filter map[string]interface{}) bool {
+	for k, want := range filter {
+		if strings.HasPrefix(k, "$") {
+			panic("memCollection: operator " + k + " is not supported")
+		}
+		got, ok := doc[k]
+		if want == nil {
+			if ok && got != nil {
+				return false
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got, normalize(want)) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalize round-trips a value through JSON, the way the Postgres adapter stores it
+func normalize(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		panic(err)
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		panic(err)
+	}
+	return out
+}
+
+func decodeInto(doc map[string]interface{}, result interface{}) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/madhouselabs/anybase/pkg/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrImpersonationNotAllowed = errors.New("this user cannot be impersonated")
+	ErrImpersonating           = errors.New("not available while impersonating a user")
+)
+
+// Impersonate issues an admin a short-lived access token for acting as another user, so
+// support can see exactly what the user sees. The token's subject and roles are the user's;
+// its "act" claim names the admin. It lives in a session of its own, cannot be refreshed
+// and ends early if the session is revoked.
+func (s *service) Impersonate(ctx context.Context, actorID, userID primitive.ObjectID, req *models.ImpersonationRequest) (*AuthResponse, error) {
+	if actorID == userID {
+		return nil, ErrImpersonationNotAllowed
+	}
+	actor, err := s.userRepo.GetByID(ctx, actorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u.IsServiceAccount() {
+		return nil, ErrImpersonationNotAllowed
+	}
+	// Admins are not impersonated: it would let one admin act under another's name
+	for _, role := range u.AllRoles() {
+		if role == "admin" {
+			return nil, ErrImpersonationNotAllowed
+		}
+	}
+	if !u.Active {
+		return nil, ErrAccountInactive
+	}
+
+	org, roles, err := s.resolveOrganization(ctx, u, req.Org)
+	if err != nil {
+		return nil, err
+	}
+	// Nor are the admins of the organization the token would be scoped to
+	for _, role := range roles {
+		if role == "admin" {
+			return nil, ErrImpersonationNotAllowed
+		}
+	}
+
+	expiresIn := s.config.ImpersonationExpiration
+	sess := &models.Session{
+		UserID:         u.ID,
+		IPAddress:      req.IPAddress,
+		UserAgent:      req.UserAgent,
+		ExpiresAt:      time.Now().UTC().Add(expiresIn),
+		ImpersonatorID: &actor.ID,
+	}
+	if err := s.sessionRepo.Create(ctx, sess); err != nil {
+		return nil, err
+	}
+
+	orgID := ""
+	if org != nil {
+		orgID = org.ID.Hex()
+	}
+	accessToken, err := s.tokenService.GenerateAccessToken(TokenSubject{
+		UserID:      u.ID,
+		Email:       u.Email,
+		Roles:       roles,
+		Permissions: []string{},
+		OrgID:       orgID,
+		SessionID:   sess.ID.Hex(),
+		Actor:       &Actor{UserID: actor.ID.Hex(), Email: actor.Email},
+		ExpiresIn:   expiresIn,
+	})
+	if err != nil {
+		s.sessionRepo.Revoke(ctx, sess.ID)
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	// A session nobody can trace back to the admin must not exist
+	if _, err := s.auditLogs.InsertOne(ctx, map[string]interface{}{
+		"_id":         primitive.NewObjectID().Hex(),
+		"user_id":     actor.ID.Hex(),
+		"action":      "auth.impersonation.start",
+		"resource":    "user",
+		"resource_id": u.ID.Hex(),
+		"details": map[string]interface{}{
+			"impersonator_email": actor.Email,
+			"user_email":         u.Email,
+			"reason":             req.Reason,
+			"org_id":             orgID,
+			"session_id":         sess.ID.Hex(),
+			"expires_at":         sess.ExpiresAt,
+		},
+		"ip_address": req.IPAddress,
+		"user_agent": req.UserAgent,
+		"status":     "success",
+		"created_at": time.Now().UTC(),
+	}); err != nil {
+		s.sessionRepo.Revoke(ctx, sess.ID)
+		return nil, fmt.Errorf("failed to record impersonation: %w", err)
+	}
+
+	u.Password = ""
+	return &AuthResponse{
+		User:         u,
+		Organization: org,
+		AccessToken:  accessToken,
+		ExpiresIn:    int64(expiresIn.Seconds()),
+	}, nil
+}
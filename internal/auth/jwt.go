@@ -7,6 +7,7 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/madhouselabs/anybase/internal/config"
+	"github.com/madhouselabs/anybase/internal/signing"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -20,6 +21,9 @@ type TokenType string
 const (
 	AccessToken  TokenType = "access"
 	RefreshToken TokenType = "refresh"
+	// MFAToken is handed out by a login that still needs a second factor. It only proves
+	// the password was correct and is accepted nowhere but the MFA endpoints.
+	MFAToken TokenType = "mfa"
 )
 
 type Claims struct {
@@ -28,36 +32,71 @@ type Claims struct {
 	Roles       []string `json:"roles"`
 	Permissions []string `json:"permissions"`
 	TokenType   TokenType `json:"token_type"`
+	OrgID       string   `json:"org_id,omitempty"` // Organization the token is scoped to; empty for the platform
+	SessionID   string   `json:"sid,omitempty"`    // Server-side session the token belongs to
+	Actor       *Actor   `json:"act,omitempty"`    // Set when an admin is impersonating the user
 	jwt.RegisteredClaims
 }
 
+// Actor is the admin behind an impersonation token, after the "act" claim of RFC 8693. The
+// token's subject stays the impersonated user, whose roles apply.
+type Actor struct {
+	UserID string `json:"sub"`
+	Email  string `json:"email,omitempty"`
+}
+
+// Impersonated reports whether the token was issued to an admin acting as the user
+func (c *Claims) Impersonated() bool {
+	return c.Actor != nil
+}
+
+// TokenSubject describes who a token is issued to
+type TokenSubject struct {
+	UserID      primitive.ObjectID
+	Email       string
+	Roles       []string
+	Permissions []string
+	OrgID       string
+	SessionID   string
+	// RefreshTokenID identifies the refresh token within its session, so that a rotated
+	// token can be told apart from the current one
+	RefreshTokenID string
+	// Actor and ExpiresIn are set for impersonation tokens, which are shorter lived
+	Actor     *Actor
+	ExpiresIn time.Duration
+}
+
 type TokenService interface {
-	GenerateTokenPair(userID primitive.ObjectID, email string, roles, permissions []string) (string, string, error)
-	GenerateAccessToken(userID primitive.ObjectID, email string, roles, permissions []string) (string, error)
-	GenerateRefreshToken(userID primitive.ObjectID, email string) (string, error)
+	GenerateTokenPair(subject TokenSubject) (string, string, error)
+	GenerateAccessToken(subject TokenSubject) (string, error)
+	GenerateRefreshToken(subject TokenSubject) (string, error)
+	GenerateMFAToken(subject TokenSubject) (string, error)
 	ValidateToken(tokenString string, tokenType TokenType) (*Claims, error)
-	RefreshAccessToken(refreshToken string) (string, error)
 }
 
 type tokenService struct {
 	config *config.AuthConfig
 	secret []byte
+	keys   *signing.KeyStore // Nil when tokens are signed with the shared secret
 }
 
-func NewTokenService(cfg *config.AuthConfig) TokenService {
+// NewTokenService returns a token service that signs with the key store's current key, or
+// with the shared HS256 secret when keys is nil
+func NewTokenService(cfg *config.AuthConfig, keys *signing.KeyStore) TokenService {
 	return &tokenService{
 		config: cfg,
 		secret: []byte(cfg.JWTSecret),
+		keys:   keys,
 	}
 }
 
-func (s *tokenService) GenerateTokenPair(userID primitive.ObjectID, email string, roles, permissions []string) (string, string, error) {
-	accessToken, err := s.GenerateAccessToken(userID, email, roles, permissions)
+func (s *tokenService) GenerateTokenPair(subject TokenSubject) (string, string, error) {
+	accessToken, err := s.GenerateAccessToken(subject)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to generate access token: %w", err)
 	}
 
-	refreshToken, err := s.GenerateRefreshToken(userID, email)
+	refreshToken, err := s.GenerateRefreshToken(subject)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to generate refresh token: %w", err)
 	}
@@ -65,25 +104,31 @@ func (s *tokenService) GenerateTokenPair(userID primitive.ObjectID, email string
 	return accessToken, refreshToken, nil
 }
 
-func (s *tokenService) GenerateAccessToken(userID primitive.ObjectID, email string, roles, permissions []string) (string, error) {
+func (s *tokenService) GenerateAccessToken(subject TokenSubject) (string, error) {
 	now := time.Now()
+	expiresIn := s.config.JWTExpiration
+	if subject.ExpiresIn > 0 {
+		expiresIn = subject.ExpiresIn
+	}
 	claims := Claims{
-		UserID:      userID.Hex(),
-		Email:       email,
-		Roles:       roles,
-		Permissions: permissions,
+		UserID:      subject.UserID.Hex(),
+		Email:       subject.Email,
+		Roles:       subject.Roles,
+		Permissions: subject.Permissions,
 		TokenType:   AccessToken,
+		OrgID:       subject.OrgID,
+		SessionID:   subject.SessionID,
+		Actor:       subject.Actor,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(now.Add(s.config.JWTExpiration)),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
 			Issuer:    "anybase",
-			Subject:   userID.Hex(),
+			Subject:   subject.UserID.Hex(),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(s.secret)
+	tokenString, err := s.sign(claims)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
@@ -91,23 +136,25 @@ func (s *tokenService) GenerateAccessToken(userID primitive.ObjectID, email stri
 	return tokenString, nil
 }
 
-func (s *tokenService) GenerateRefreshToken(userID primitive.ObjectID, email string) (string, error) {
+func (s *tokenService) GenerateRefreshToken(subject TokenSubject) (string, error) {
 	now := time.Now()
 	claims := Claims{
-		UserID:    userID.Hex(),
-		Email:     email,
+		UserID:    subject.UserID.Hex(),
+		Email:     subject.Email,
 		TokenType: RefreshToken,
+		OrgID:     subject.OrgID,
+		SessionID: subject.SessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        subject.RefreshTokenID,
 			ExpiresAt: jwt.NewNumericDate(now.Add(s.config.RefreshTokenExpiration)),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
 			Issuer:    "anybase",
-			Subject:   userID.Hex(),
+			Subject:   subject.UserID.Hex(),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(s.secret)
+	tokenString, err := s.sign(claims)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign refresh token: %w", err)
 	}
@@ -115,13 +162,71 @@ func (s *tokenService) GenerateRefreshToken(userID primitive.ObjectID, email str
 	return tokenString, nil
 }
 
-func (s *tokenService) ValidateToken(tokenString string, tokenType TokenType) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+// GenerateMFAToken issues the challenge token for the second step of a login. It carries
+// the organization the user asked to sign in to, which is resolved again once the code checks out.
+func (s *tokenService) GenerateMFAToken(subject TokenSubject) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		UserID:    subject.UserID.Hex(),
+		Email:     subject.Email,
+		TokenType: MFAToken,
+		OrgID:     subject.OrgID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.config.MFAChallengeExpiration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    "anybase",
+			Subject:   subject.UserID.Hex(),
+		},
+	}
+
+	tokenString, err := s.sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign mfa token: %w", err)
+	}
+
+	return tokenString, nil
+}
+
+// sign signs the claims, naming the key in the "kid" header so verifiers can pick it from
+// the published key set
+func (s *tokenService) sign(claims Claims) (string, error) {
+	if s.keys == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+	}
+
+	key, err := s.keys.SigningKey()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
+}
+
+// verificationKey returns the key a token's signature is checked with. With a key store,
+// any published key is accepted, so tokens outlive the rotation that retired their key.
+func (s *tokenService) verificationKey(token *jwt.Token) (interface{}, error) {
+	if s.keys == nil {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return s.secret, nil
-	})
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.keys.VerificationKey(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.Public(), nil
+}
+
+func (s *tokenService) ValidateToken(tokenString string, tokenType TokenType) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, s.verificationKey)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
@@ -143,25 +248,3 @@ func (s *tokenService) ValidateToken(tokenString string, tokenType TokenType) (*
 
 	return claims, nil
 }
-
-func (s *tokenService) RefreshAccessToken(refreshToken string) (string, error) {
-	claims, err := s.ValidateToken(refreshToken, RefreshToken)
-	if err != nil {
-		return "", fmt.Errorf("invalid refresh token: %w", err)
-	}
-
-	userID, err := primitive.ObjectIDFromHex(claims.UserID)
-	if err != nil {
-		return "", fmt.Errorf("invalid user ID: %w", err)
-	}
-
-	// Note: In a real implementation, you would fetch the latest user data
-	// including roles and permissions from the database here
-	// For now, we'll just generate a new access token with the existing claims
-	accessToken, err := s.GenerateAccessToken(userID, claims.Email, claims.Roles, claims.Permissions)
-	if err != nil {
-		return "", fmt.Errorf("failed to generate new access token: %w", err)
-	}
-
-	return accessToken, nil
-}
\ No newline at end of file
@@ -0,0 +1,351 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/madhouselabs/anybase/internal/mfa"
+	"github.com/madhouselabs/anybase/internal/user"
+	"github.com/madhouselabs/anybase/pkg/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MFAChallenge is returned by a login that needs a second factor
+type MFAChallenge struct {
+	Token string `json:"token"` // Sent back as mfa_token with the code
+	// The user has no authenticator yet but the system requires one; it has to be set up
+	// through the enrollment endpoint with this token before verifying
+	EnrollmentRequired bool  `json:"enrollment_required"`
+	ExpiresIn          int64 `json:"expires_in"`
+}
+
+// MFAEnrollment is a new TOTP secret awaiting confirmation with a code from the authenticator
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"` // Rendered as a QR code for authenticator apps
+}
+
+// mfaChallenge returns the challenge a login must pass before getting tokens, or nil when
+// the password is enough
+func (s *service) mfaChallenge(ctx context.Context, u *models.User, org *models.Organization) (*MFAChallenge, error) {
+	enrollmentRequired := false
+	if !u.MFAEnabled {
+		required, err := s.mfaRequired(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !required {
+			return nil, nil
+		}
+		enrollmentRequired = true
+	}
+
+	subject := TokenSubject{UserID: u.ID, Email: u.Email}
+	if org != nil {
+		subject.OrgID = org.ID.Hex()
+	}
+	token, err := s.tokenService.GenerateMFAToken(subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate mfa token: %w", err)
+	}
+
+	return &MFAChallenge{
+		Token:              token,
+		EnrollmentRequired: enrollmentRequired,
+		ExpiresIn:          int64(s.config.MFAChallengeExpiration.Seconds()),
+	}, nil
+}
+
+// VerifyMFA completes a login with a TOTP or recovery code. For a user enrolling because
+// the system requires MFA, the code confirms the new authenticator and the response also
+// carries the recovery codes.
+func (s *service) VerifyMFA(ctx context.Context, req *models.MFAVerification) (*AuthResponse, error) {
+	claims, err := s.tokenService.ValidateToken(req.MFAToken, MFAToken)
+	if err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+	u, err := s.challengeUser(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.LockedUntil != nil && u.LockedUntil.After(time.Now()) {
+		return nil, ErrAccountLocked
+	}
+	if !u.Active {
+		return nil, ErrAccountInactive
+	}
+
+	var recoveryCodes []string
+	switch {
+	case u.MFAEnabled:
+		err = s.spendCode(ctx, u, req.Code)
+	case u.MFAPendingSecret != "":
+		recoveryCodes, err = s.confirmEnrollment(ctx, u, req.Code)
+	default:
+		return nil, ErrMFANotEnrolling
+	}
+	if err != nil {
+		if errors.Is(err, ErrInvalidMFACode) && s.recordFailedAttempt(ctx, u) {
+			return nil, ErrAccountLocked
+		}
+		return nil, err
+	}
+
+	// Membership may have changed since the password step
+	org, roles, err := s.tokenOrganization(ctx, u, claims.OrgID)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.startSession(ctx, u, org, roles, req.IPAddress, req.UserAgent)
+	if err != nil {
+		return nil, err
+	}
+	response.RecoveryCodes = recoveryCodes
+	return response, nil
+}
+
+// ChallengeUser returns the user an MFA challenge token was issued to, which lets a user
+// who must enroll do so before they can sign in
+func (s *service) ChallengeUser(ctx context.Context, mfaToken string) (primitive.ObjectID, error) {
+	claims, err := s.tokenService.ValidateToken(mfaToken, MFAToken)
+	if err != nil {
+		return primitive.NilObjectID, ErrInvalidMFAToken
+	}
+	u, err := s.challengeUser(ctx, claims)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return u.ID, nil
+}
+
+func (s *service) challengeUser(ctx context.Context, claims *Claims) (*models.User, error) {
+	userID, err := primitive.ObjectIDFromHex(claims.UserID)
+	if err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			return nil, ErrInvalidMFAToken
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return u, nil
+}
+
+// BeginMFAEnrollment generates a TOTP secret for the user. It only takes effect once
+// confirmed with a code, so an abandoned enrollment changes nothing.
+func (s *service) BeginMFAEnrollment(ctx context.Context, userID primitive.ObjectID) (*MFAEnrollment, error) {
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if err := interactive(u); err != nil {
+		return nil, err
+	}
+	// Replacing an authenticator requires disabling the current one, which takes a code
+	if u.MFAEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := mfa.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	state := mfaState(u)
+	state.PendingSecret = secret
+	if err := s.userRepo.UpdateMFA(ctx, u.ID, u.MFARevision, state); err != nil {
+		return nil, err
+	}
+
+	return &MFAEnrollment{
+		Secret: secret,
+		URI:    mfa.KeyURI(s.config.MFAIssuer, u.Email, secret),
+	}, nil
+}
+
+// ConfirmMFAEnrollment turns MFA on once the user proves their authenticator works, and
+// returns the recovery codes, which are never shown again
+func (s *service) ConfirmMFAEnrollment(ctx context.Context, userID primitive.ObjectID, code string) ([]string, error) {
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if u.MFAEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	return s.confirmEnrollment(ctx, u, code)
+}
+
+func (s *service) confirmEnrollment(ctx context.Context, u *models.User, code string) ([]string, error) {
+	if u.MFAPendingSecret == "" {
+		return nil, ErrMFANotEnrolling
+	}
+	step, ok := mfa.Validate(u.MFAPendingSecret, code, time.Now(), 0)
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	state := &user.MFAState{
+		Enabled:       true,
+		Secret:        u.MFAPendingSecret,
+		RecoveryCodes: hashes,
+		LastStep:      step,
+	}
+	if err := s.updateMFA(ctx, u, state); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes, after checking a current code
+func (s *service) RegenerateRecoveryCodes(ctx context.Context, userID primitive.ObjectID, code string) ([]string, error) {
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if err := s.checkCode(ctx, u, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	state := mfaState(u)
+	state.RecoveryCodes = hashes
+	if err := s.updateMFA(ctx, u, state); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableMFA turns MFA off for the user, after checking a current code. It is refused
+// while the system settings require MFA.
+func (s *service) DisableMFA(ctx context.Context, userID primitive.ObjectID, code string) error {
+	required, err := s.mfaRequired(ctx)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrMFAEnforced
+	}
+
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if err := s.checkCode(ctx, u, code); err != nil {
+		return err
+	}
+	return s.updateMFA(ctx, u, &user.MFAState{})
+}
+
+// ResetMFA removes a user's authenticator and recovery codes, for an admin helping someone
+// who lost both. If the system requires MFA, the user enrolls again at their next login.
+func (s *service) ResetMFA(ctx context.Context, userID primitive.ObjectID) error {
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.updateMFA(ctx, u, &user.MFAState{})
+}
+
+// checkCode spends a code from an already signed-in user, counting a wrong one against the
+// account like a wrong password
+func (s *service) checkCode(ctx context.Context, u *models.User, code string) error {
+	err := s.spendCode(ctx, u, code)
+	if errors.Is(err, ErrInvalidMFACode) && s.recordFailedAttempt(ctx, u) {
+		return ErrAccountLocked
+	}
+	return err
+}
+
+// spendCode accepts a TOTP code or one of the recovery codes and uses it up
+func (s *service) spendCode(ctx context.Context, u *models.User, code string) error {
+	if !u.MFAEnabled {
+		return ErrMFANotEnabled
+	}
+
+	state := mfaState(u)
+	if mfa.IsRecoveryCode(code) {
+		hash := hashToken(mfa.NormalizeRecoveryCode(code))
+		remaining := make([]string, 0, len(state.RecoveryCodes))
+		for _, stored := range state.RecoveryCodes {
+			if stored != hash {
+				remaining = append(remaining, stored)
+			}
+		}
+		if len(remaining) == len(state.RecoveryCodes) {
+			return ErrInvalidMFACode
+		}
+		state.RecoveryCodes = remaining
+	} else {
+		step, ok := mfa.Validate(u.MFASecret, code, time.Now(), u.MFALastStep)
+		if !ok {
+			return ErrInvalidMFACode
+		}
+		state.LastStep = step
+	}
+
+	return s.updateMFA(ctx, u, state)
+}
+
+// updateMFA saves the user's new MFA state and applies it to u. A concurrent change means
+// the code was spent by another request, so it counts as invalid here.
+func (s *service) updateMFA(ctx context.Context, u *models.User, state *user.MFAState) error {
+	if err := s.userRepo.UpdateMFA(ctx, u.ID, u.MFARevision, state); err != nil {
+		if errors.Is(err, user.ErrMFAConflict) {
+			return ErrInvalidMFACode
+		}
+		return err
+	}
+
+	u.MFAEnabled = state.Enabled
+	u.MFASecret = state.Secret
+	u.MFAPendingSecret = state.PendingSecret
+	u.MFARecoveryCodes = state.RecoveryCodes
+	u.MFALastStep = state.LastStep
+	u.MFARevision++
+	return nil
+}
+
+// mfaRequired reports whether the system settings make MFA mandatory
+func (s *service) mfaRequired(ctx context.Context) (bool, error) {
+	systemSettings, err := s.settings.GetSystemSettings(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to load system settings: %w", err)
+	}
+	return systemSettings.MFARequired, nil
+}
+
+func mfaState(u *models.User) *user.MFAState {
+	return &user.MFAState{
+		Enabled:       u.MFAEnabled,
+		Secret:        u.MFASecret,
+		PendingSecret: u.MFAPendingSecret,
+		RecoveryCodes: u.MFARecoveryCodes,
+		LastStep:      u.MFALastStep,
+	}
+}
+
+// newRecoveryCodes returns a set of recovery codes along with the hashes that are stored
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := mfa.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = hashToken(code)
+	}
+	return codes, hashes, nil
+}
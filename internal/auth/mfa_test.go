@@ -0,0 +1,346 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/madhouselabs/anybase/internal/mfa"
+	"github.com/madhouselabs/anybase/pkg/models"
+)
+
+// enrollMFA turns MFA on for a new user and returns the user, the secret and the recovery codes
+func enrollMFA(t *testing.T, s *testEnv) (*models.User, string, []string) {
+	t.Helper()
+	ctx := context.Background()
+	u := s.addUser("ada@example.com")
+	enrollment, err := s.BeginMFAEnrollment(ctx, u.ID)
+	if err != nil {
+		t.Fatalf("BeginMFAEnrollment: %v", err)
+	}
+	codes, err := s.ConfirmMFAEnrollment(ctx, u.ID, totp(t, enrollment.Secret, time.Now()))
+	if err != nil {
+		t.Fatalf("ConfirmMFAEnrollment: %v", err)
+	}
+	return s.users.get(u.ID), enrollment.Secret, codes
+}
+
+// totp returns the code an authenticator would show at t
+func totp(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	code, err := mfa.Code(secret, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// nextCode returns a code for the step after the one the enrollment used up
+func nextCode(t *testing.T, secret string) string {
+	return totp(t, secret, time.Now().Add(mfa.Period))
+}
+
+// wrongCode returns a code that differs from the current one in every digit
+func wrongCode(t *testing.T, secret string) string {
+	current := nextCode(t, secret)
+	wrong := make([]byte, len(current))
+	for i := range current {
+		wrong[i] = '0' + (current[i]-'0'+5)%10
+	}
+	return string(wrong)
+}
+
+// challenge returns the token a login hands out when it needs a second factor
+func challenge(t *testing.T, s *testEnv, u *models.User) string {
+	t.Helper()
+	token, err := s.tokenService.GenerateMFAToken(TokenSubject{UserID: u.ID, Email: u.Email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestVerifyMFA(t *testing.T) {
+	tests := []struct {
+		name string
+		code func(t *testing.T, secret string, recovery []string) string
+	}{
+		{
+			name: "totp",
+			code: func(t *testing.T, secret string, recovery []string) string { return nextCode(t, secret) },
+		},
+		{
+			name: "totp in two groups",
+			code: func(t *testing.T, secret string, recovery []string) string {
+				code := nextCode(t, secret)
+				return code[:3] + " " + code[3:]
+			},
+		},
+		{
+			name: "recovery code",
+			code: func(t *testing.T, secret string, recovery []string) string { return recovery[0] },
+		},
+		{
+			name: "recovery code as typed",
+			code: func(t *testing.T, secret string, recovery []string) string {
+				return strings.ToUpper(strings.ReplaceAll(recovery[0], "-", ""))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t)
+			u, secret, recovery := enrollMFA(t, s)
+
+			resp, err := s.VerifyMFA(context.Background(), &models.MFAVerification{
+				MFAToken: challenge(t, s, u),
+				Code:     tt.code(t, secret, recovery),
+			})
+			if err != nil {
+				t.Fatalf("VerifyMFA: %v", err)
+			}
+			if resp.AccessToken == "" || resp.RefreshToken == "" {
+				t.Fatalf("expected tokens, got %+v", resp)
+			}
+		})
+	}
+}
+
+func TestVerifyMFACodesWorkOnce(t *testing.T) {
+	tests := []struct {
+		name string
+		code func(t *testing.T, secret string, recovery []string) string
+	}{
+		{
+			name: "totp",
+			code: func(t *testing.T, secret string, recovery []string) string { return nextCode(t, secret) },
+		},
+		{
+			name: "recovery code",
+			code: func(t *testing.T, secret string, recovery []string) string { return recovery[3] },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t)
+			ctx := context.Background()
+			u, secret, recovery := enrollMFA(t, s)
+			code := tt.code(t, secret, recovery)
+
+			if _, err := s.VerifyMFA(ctx, &models.MFAVerification{MFAToken: challenge(t, s, u), Code: code}); err != nil {
+				t.Fatalf("VerifyMFA: %v", err)
+			}
+			_, err := s.VerifyMFA(ctx, &models.MFAVerification{MFAToken: challenge(t, s, u), Code: code})
+			if !errors.Is(err, ErrInvalidMFACode) {
+				t.Fatalf("expected ErrInvalidMFACode for a spent code, got %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyMFARejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		token func(t *testing.T, s *testEnv, u *models.User) string
+		code  func(t *testing.T, secret string) string
+		want  error
+	}{
+		{
+			name:  "wrong code",
+			token: challenge,
+			code:  wrongCode,
+			want:  ErrInvalidMFACode,
+		},
+		{
+			name:  "unknown recovery code",
+			token: challenge,
+			code:  func(t *testing.T, secret string) string { return "aaaaa-aaaaa" },
+			want:  ErrInvalidMFACode,
+		},
+		{
+			name:  "code from before the enrollment",
+			token: challenge,
+			code:  func(t *testing.T, secret string) string { return totp(t, secret, time.Now().Add(-mfa.Period)) },
+			want:  ErrInvalidMFACode,
+		},
+		{
+			name: "access token",
+			token: func(t *testing.T, s *testEnv, u *models.User) string {
+				token, err := s.tokenService.GenerateAccessToken(TokenSubject{UserID: u.ID, Email: u.Email})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return token
+			},
+			code: nextCode,
+			want: ErrInvalidMFAToken,
+		},
+		{
+			name:  "garbage token",
+			token: func(t *testing.T, s *testEnv, u *models.User) string { return "not-a-token" },
+			code:  nextCode,
+			want:  ErrInvalidMFAToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t)
+			u, secret, _ := enrollMFA(t, s)
+
+			_, err := s.VerifyMFA(context.Background(), &models.MFAVerification{
+				MFAToken: tt.token(t, s, u),
+				Code:     tt.code(t, secret),
+			})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestVerifyMFALocksAccount(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	u, secret, _ := enrollMFA(t, s)
+
+	for i := 1; i < s.config.MaxLoginAttempts; i++ {
+		_, err := s.VerifyMFA(ctx, &models.MFAVerification{MFAToken: challenge(t, s, u), Code: wrongCode(t, secret)})
+		if !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("attempt %d: expected ErrInvalidMFACode, got %v", i, err)
+		}
+		if got := s.users.get(u.ID).LoginAttempts; got != i {
+			t.Fatalf("attempt %d: %d failed attempts recorded", i, got)
+		}
+	}
+
+	_, err := s.VerifyMFA(ctx, &models.MFAVerification{MFAToken: challenge(t, s, u), Code: wrongCode(t, secret)})
+	if !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("expected ErrAccountLocked, got %v", err)
+	}
+	// Once locked, not even the right code gets in
+	_, err = s.VerifyMFA(ctx, &models.MFAVerification{MFAToken: challenge(t, s, u), Code: nextCode(t, secret)})
+	if !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("expected ErrAccountLocked for a locked account, got %v", err)
+	}
+}
+
+func TestVerifyMFARace(t *testing.T) {
+	s := newTestService(t)
+	u, secret, _ := enrollMFA(t, s)
+	code := nextCode(t, secret)
+
+	// Requests racing with the same code read the same revision; only one can save it
+	const racers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, racers)
+	for i := 0; i < racers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.VerifyMFA(context.Background(), &models.MFAVerification{MFAToken: challenge(t, s, u), Code: code})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.Is(err, ErrInvalidMFACode), errors.Is(err, ErrAccountLocked):
+		default:
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("%d logins succeeded with the same code, want 1", succeeded)
+	}
+}
+
+func TestMFAEnrollment(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	u := s.addUser("ada@example.com")
+
+	if _, err := s.ConfirmMFAEnrollment(ctx, u.ID, "123456"); !errors.Is(err, ErrMFANotEnrolling) {
+		t.Fatalf("expected ErrMFANotEnrolling, got %v", err)
+	}
+	enrollment, err := s.BeginMFAEnrollment(ctx, u.ID)
+	if err != nil {
+		t.Fatalf("BeginMFAEnrollment: %v", err)
+	}
+	if !strings.HasPrefix(enrollment.URI, "otpauth://totp/AnyBase:ada@example.com?") {
+		t.Fatalf("unexpected key URI %q", enrollment.URI)
+	}
+	if s.users.get(u.ID).MFAEnabled {
+		t.Fatal("MFA must stay off until the enrollment is confirmed")
+	}
+
+	if _, err := s.ConfirmMFAEnrollment(ctx, u.ID, wrongCode(t, enrollment.Secret)); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("expected ErrInvalidMFACode, got %v", err)
+	}
+	codes, err := s.ConfirmMFAEnrollment(ctx, u.ID, totp(t, enrollment.Secret, time.Now()))
+	if err != nil {
+		t.Fatalf("ConfirmMFAEnrollment: %v", err)
+	}
+	if len(codes) != mfa.RecoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), mfa.RecoveryCodeCount)
+	}
+	stored := s.users.get(u.ID)
+	if !stored.MFAEnabled || stored.MFASecret != enrollment.Secret || stored.MFAPendingSecret != "" {
+		t.Fatalf("enrollment was not saved: %+v", stored)
+	}
+	for _, hash := range stored.MFARecoveryCodes {
+		for _, code := range codes {
+			if hash == code {
+				t.Fatal("recovery codes must be stored hashed")
+			}
+		}
+	}
+
+	if _, err := s.BeginMFAEnrollment(ctx, u.ID); !errors.Is(err, ErrMFAAlreadyEnabled) {
+		t.Fatalf("expected ErrMFAAlreadyEnabled, got %v", err)
+	}
+}
+
+func TestVerifyMFAEnrollsWhenRequired(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	s.systemSettings().MFARequired = true
+	u := s.addUser("ada@example.com")
+
+	c, err := s.mfaChallenge(ctx, u, nil)
+	if err != nil || c == nil || !c.EnrollmentRequired {
+		t.Fatalf("expected an enrollment challenge, got %+v, %v", c, err)
+	}
+	userID, err := s.ChallengeUser(ctx, c.Token)
+	if err != nil || userID != u.ID {
+		t.Fatalf("ChallengeUser: %v, %v", userID, err)
+	}
+	if _, err := s.VerifyMFA(ctx, &models.MFAVerification{MFAToken: c.Token, Code: "123456"}); !errors.Is(err, ErrMFANotEnrolling) {
+		t.Fatalf("expected ErrMFANotEnrolling before the enrollment starts, got %v", err)
+	}
+
+	enrollment, err := s.BeginMFAEnrollment(ctx, userID)
+	if err != nil {
+		t.Fatalf("BeginMFAEnrollment: %v", err)
+	}
+	resp, err := s.VerifyMFA(ctx, &models.MFAVerification{MFAToken: c.Token, Code: totp(t, enrollment.Secret, time.Now())})
+	if err != nil {
+		t.Fatalf("VerifyMFA: %v", err)
+	}
+	if resp.AccessToken == "" || len(resp.RecoveryCodes) != mfa.RecoveryCodeCount {
+		t.Fatalf("expected tokens and recovery codes, got %+v", resp)
+	}
+	if err := s.DisableMFA(ctx, u.ID, nextCode(t, enrollment.Secret)); !errors.Is(err, ErrMFAEnforced) {
+		t.Fatalf("expected ErrMFAEnforced, got %v", err)
+	}
+}
@@ -0,0 +1,305 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/madhouselabs/anybase/internal/config"
+	"github.com/madhouselabs/anybase/internal/oidc"
+	"github.com/madhouselabs/anybase/internal/user"
+	"github.com/madhouselabs/anybase/pkg/models"
+)
+
+var (
+	ErrInvalidOIDCState   = errors.New("invalid or expired sign-in request")
+	ErrOIDCEmailRequired  = errors.New("the identity provider did not share an email address")
+	ErrOIDCEmailConflict  = errors.New("an account with this email already exists, but the identity provider has not verified the email")
+	ErrOIDCSignupDisabled = errors.New("no account is linked to this identity")
+)
+
+// OIDCProvider describes an identity provider users can sign in with
+type OIDCProvider struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+// OIDCProviders lists the configured identity providers
+func (s *service) OIDCProviders() []OIDCProvider {
+	providers := []OIDCProvider{}
+	for _, provider := range s.oidc.List() {
+		cfg := provider.Config()
+		name := cfg.DisplayName
+		if name == "" {
+			name = cfg.Name
+		}
+		providers = append(providers, OIDCProvider{Name: cfg.Name, DisplayName: name})
+	}
+	return providers
+}
+
+// BeginOIDCLogin starts a sign-in at the identity provider and returns the URL to send the
+// user to. The PKCE verifier and nonce stay on the server until the callback.
+func (s *service) BeginOIDCLogin(ctx context.Context, providerName, org string) (string, error) {
+	provider, err := s.oidc.Get(providerName)
+	if err != nil {
+		return "", err
+	}
+
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		return "", err
+	}
+	loginState := &oidc.LoginState{
+		Provider:     providerName,
+		Nonce:        s.generateToken(),
+		CodeVerifier: verifier,
+		Org:          org,
+	}
+	state, err := s.oidcStates.Create(ctx, loginState)
+	if err != nil {
+		return "", err
+	}
+
+	return provider.AuthCodeURL(ctx, state, loginState.Nonce, verifier)
+}
+
+// CompleteOIDCLogin handles the provider's redirect back: it redeems the code, finds or
+// creates the user and signs them in like a password login would
+func (s *service) CompleteOIDCLogin(ctx context.Context, req *models.OIDCCallback) (*AuthResponse, error) {
+	loginState, err := s.oidcStates.Consume(ctx, req.State)
+	if err != nil {
+		if errors.Is(err, oidc.ErrStateNotFound) {
+			return nil, ErrInvalidOIDCState
+		}
+		return nil, err
+	}
+	if loginState.Provider != req.Provider {
+		return nil, ErrInvalidOIDCState
+	}
+	provider, err := s.oidc.Get(req.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := provider.Exchange(ctx, req.Code, loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := s.oidcUser(ctx, provider.Config(), identity)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.LockedUntil != nil && u.LockedUntil.After(time.Now()) {
+		return nil, ErrAccountLocked
+	}
+	if !u.Active {
+		return nil, ErrAccountInactive
+	}
+	if !u.EmailVerified {
+		required, err := s.emailVerificationRequired(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if required {
+			return nil, ErrEmailNotVerified
+		}
+	}
+
+	org, roles, err := s.resolveOrganization(ctx, u, loginState.Org)
+	if err != nil {
+		return nil, err
+	}
+
+	// The provider replaces the password, not the second factor
+	challenge, err := s.mfaChallenge(ctx, u, org)
+	if err != nil {
+		return nil, err
+	}
+	if challenge != nil {
+		return &AuthResponse{MFA: challenge}, nil
+	}
+
+	return s.startSession(ctx, u, org, roles, req.IPAddress, req.UserAgent)
+}
+
+// oidcUser returns the user behind an external identity. A known identity maps to its
+// user; otherwise a verified email links the identity to the account with that email, and
+// failing that a new account is created if the provider allows sign-up.
+func (s *service) oidcUser(ctx context.Context, cfg config.OIDCProviderConfig, identity *oidc.Identity) (*models.User, error) {
+	mappedRoles := mapRoles(cfg, identity.Claims)
+
+	linked, err := s.identities.Get(ctx, cfg.Name, identity.Subject)
+	if err == nil {
+		u, err := s.userRepo.GetByID(ctx, linked.UserID)
+		if err != nil {
+			if errors.Is(err, user.ErrUserNotFound) {
+				return nil, ErrAccountInactive
+			}
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+		return u, s.syncRoles(ctx, cfg, u, mappedRoles)
+	}
+	if !errors.Is(err, user.ErrIdentityNotFound) {
+		return nil, err
+	}
+
+	if identity.Email == "" {
+		return nil, ErrOIDCEmailRequired
+	}
+
+	existing, err := s.userRepo.GetByEmail(ctx, identity.Email)
+	if err != nil && !errors.Is(err, user.ErrUserNotFound) {
+		return nil, fmt.Errorf("failed to check existing user: %w", err)
+	}
+	if existing != nil {
+		if existing.IsServiceAccount() {
+			return nil, ErrOIDCEmailConflict
+		}
+		// Linking on an unverified email would let anyone who can set an address at the
+		// provider take over the local account
+		if !identity.EmailVerified {
+			return nil, ErrOIDCEmailConflict
+		}
+		if err := s.linkIdentity(ctx, existing, cfg.Name, identity); err != nil {
+			return nil, err
+		}
+		if !existing.EmailVerified {
+			if err := s.userRepo.VerifyEmail(ctx, existing.ID); err != nil {
+				return nil, err
+			}
+			existing.EmailVerified = true
+		}
+		return existing, s.syncRoles(ctx, cfg, existing, mappedRoles)
+	}
+
+	if !cfg.AllowSignup {
+		return nil, ErrOIDCSignupDisabled
+	}
+
+	// Just-in-time provisioning. The account has no password; one can be set through a reset.
+	roles := mappedRoles
+	if len(roles) == 0 && cfg.DefaultRole != "" {
+		roles = []string{cfg.DefaultRole}
+	}
+	newUser := &models.User{
+		Email:         identity.Email,
+		FirstName:     identity.GivenName,
+		LastName:      identity.FamilyName,
+		EmailVerified: identity.EmailVerified,
+		UserType:      models.UserTypeRegular,
+		Active:        true,
+	}
+	if len(roles) > 0 {
+		newUser.Role = roles[0]
+		newUser.Roles = roles
+	}
+	if err := s.userRepo.Create(ctx, newUser); err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+	if err := s.linkIdentity(ctx, newUser, cfg.Name, identity); err != nil {
+		return nil, err
+	}
+	return newUser, nil
+}
+
+func (s *service) linkIdentity(ctx context.Context, u *models.User, provider string, identity *oidc.Identity) error {
+	return s.identities.Create(ctx, &models.UserIdentity{
+		UserID:   u.ID,
+		Provider: provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	})
+}
+
+// syncRoles gives the user the roles mapped from the provider's claims, if any mapped. They
+// are added to the user's roles unless the provider is configured to replace them.
+func (s *service) syncRoles(ctx context.Context, cfg config.OIDCProviderConfig, u *models.User, roles []string) error {
+	if len(roles) == 0 {
+		return nil
+	}
+	if !cfg.ReplaceRoles {
+		roles = mergeRoles(u.AllRoles(), roles)
+	}
+	if equalRoles(roles, u.AllRoles()) {
+		return nil
+	}
+	if err := s.userRepo.UpdateRaw(ctx, u.ID, map[string]interface{}{
+		"role":  roles[0],
+		"roles": roles,
+	}); err != nil {
+		return fmt.Errorf("failed to update roles: %w", err)
+	}
+	u.Role = roles[0]
+	u.Roles = roles
+	return nil
+}
+
+// mapRoles translates the values of the provider's roles claim into AnyBase roles
+func mapRoles(cfg config.OIDCProviderConfig, claims map[string]interface{}) []string {
+	if cfg.RolesClaim == "" || len(cfg.RoleMapping) == 0 {
+		return nil
+	}
+
+	var values []string
+	switch claim := claims[cfg.RolesClaim].(type) {
+	case string:
+		values = []string{claim}
+	case []interface{}:
+		for _, v := range claim {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	// Configuration keys come back lowercased, so matching ignores case
+	mapping := make(map[string]string, len(cfg.RoleMapping))
+	for from, to := range cfg.RoleMapping {
+		mapping[strings.ToLower(from)] = to
+	}
+
+	roles := []string{}
+	seen := map[string]bool{}
+	for _, value := range values {
+		if role, ok := mapping[strings.ToLower(value)]; ok && !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// mergeRoles returns current followed by the roles of added it does not have yet
+func mergeRoles(current, added []string) []string {
+	merged := append([]string{}, current...)
+	for _, role := range added {
+		found := false
+		for _, have := range merged {
+			if have == role {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, role)
+		}
+	}
+	return merged
+}
+
+func equalRoles(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
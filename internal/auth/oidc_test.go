@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/madhouselabs/anybase/internal/config"
+	"github.com/madhouselabs/anybase/internal/oidc"
+	"github.com/madhouselabs/anybase/internal/oidc/oidctest"
+	"github.com/madhouselabs/anybase/pkg/models"
+)
+
+// newOIDCService returns a service with a single provider, "test", backed by a mock issuer
+func newOIDCService(t *testing.T, configure func(cfg *config.OIDCProviderConfig)) (*testEnv, *oidctest.Issuer) {
+	t.Helper()
+	iss := oidctest.NewIssuer("anybase-test")
+	t.Cleanup(iss.Close)
+
+	cfg := config.OIDCProviderConfig{
+		Name:        "test",
+		Issuer:      iss.URL,
+		ClientID:    "anybase-test",
+		RedirectURL: "http://localhost/callback",
+	}
+	if configure != nil {
+		configure(&cfg)
+	}
+	registry, err := oidc.NewRegistry([]config.OIDCProviderConfig{cfg}, iss.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := newTestService(t)
+	env.oidc = registry
+	return env, iss
+}
+
+// oidcCallback signs in at the mock issuer and returns the callback it redirects back with
+func oidcCallback(t *testing.T, s *testEnv, iss *oidctest.Issuer) *models.OIDCCallback {
+	t.Helper()
+	authURL, err := s.BeginOIDCLogin(context.Background(), "test", "")
+	if err != nil {
+		t.Fatalf("BeginOIDCLogin: %v", err)
+	}
+	code, state, err := iss.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &models.OIDCCallback{Provider: "test", Code: code, State: state}
+}
+
+func TestCompleteOIDCLoginLinksVerifiedEmail(t *testing.T) {
+	s, iss := newOIDCService(t, nil)
+	existing := s.addUser("ada@example.com")
+	iss.Claims["email"] = "ada@example.com"
+	iss.Claims["email_verified"] = true
+
+	resp, err := s.CompleteOIDCLogin(context.Background(), oidcCallback(t, s, iss))
+	if err != nil {
+		t.Fatalf("CompleteOIDCLogin: %v", err)
+	}
+	if resp.User == nil || resp.User.ID != existing.ID || resp.AccessToken == "" || resp.RefreshToken == "" {
+		t.Fatalf("expected tokens for the existing user, got %+v", resp)
+	}
+	identity, err := s.identities.Get(context.Background(), "test", "subject-1")
+	if err != nil || identity.UserID != existing.ID {
+		t.Fatalf("identity was not linked: %+v, %v", identity, err)
+	}
+	if !s.users.get(existing.ID).EmailVerified {
+		t.Fatal("the provider's verification should carry over to the account")
+	}
+
+	// The next sign-in finds the user through the identity
+	resp, err = s.CompleteOIDCLogin(context.Background(), oidcCallback(t, s, iss))
+	if err != nil || resp.User.ID != existing.ID {
+		t.Fatalf("second sign-in: %+v, %v", resp, err)
+	}
+}
+
+func TestCompleteOIDCLoginRefusesUnverifiedEmail(t *testing.T) {
+	s, iss := newOIDCService(t, nil)
+	s.addUser("ada@example.com")
+	iss.Claims["email"] = "ada@example.com"
+	iss.Claims["email_verified"] = false
+
+	if _, err := s.CompleteOIDCLogin(context.Background(), oidcCallback(t, s, iss)); !errors.Is(err, ErrOIDCEmailConflict) {
+		t.Fatalf("expected ErrOIDCEmailConflict, got %v", err)
+	}
+	if _, err := s.identities.Get(context.Background(), "test", "subject-1"); err == nil {
+		t.Fatal("an unverified email must not link the identity")
+	}
+}
+
+func TestCompleteOIDCLoginRejectsReplayedState(t *testing.T) {
+	s, iss := newOIDCService(t, nil)
+	s.addUser("ada@example.com")
+	iss.Claims["email"] = "ada@example.com"
+	iss.Claims["email_verified"] = true
+
+	callback := oidcCallback(t, s, iss)
+	if _, err := s.CompleteOIDCLogin(context.Background(), callback); err != nil {
+		t.Fatalf("CompleteOIDCLogin: %v", err)
+	}
+	if _, err := s.CompleteOIDCLogin(context.Background(), callback); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Fatalf("expected ErrInvalidOIDCState, got %v", err)
+	}
+}
+
+func TestCompleteOIDCLoginMapsRoles(t *testing.T) {
+	tests := []struct {
+		name    string
+		replace bool
+		want    []string
+	}{
+		{name: "merged by default", want: []string{"developer", "admin"}},
+		{name: "replaced when configured", replace: true, want: []string{"admin"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, iss := newOIDCService(t, func(cfg *config.OIDCProviderConfig) {
+				cfg.RolesClaim = "groups"
+				cfg.RoleMapping = map[string]string{"platform-admins": "admin"}
+				cfg.ReplaceRoles = tt.replace
+			})
+			existing := s.users.add(&models.User{
+				Email:    "ada@example.com",
+				Role:     "developer",
+				Roles:    []string{"developer"},
+				Active:   true,
+				UserType: models.UserTypeRegular,
+			})
+			iss.Claims["email"] = "ada@example.com"
+			iss.Claims["email_verified"] = true
+			iss.Claims["groups"] = []string{"platform-admins", "unmapped"}
+
+			if _, err := s.CompleteOIDCLogin(context.Background(), oidcCallback(t, s, iss)); err != nil {
+				t.Fatalf("CompleteOIDCLogin: %v", err)
+			}
+			if got := s.users.get(existing.ID).Roles; !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("roles %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
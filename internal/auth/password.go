@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/madhouselabs/anybase/internal/password"
+	"github.com/madhouselabs/anybase/internal/user"
+	"github.com/madhouselabs/anybase/pkg/models"
+)
+
+var ErrPasswordExpired = errors.New("password has expired and must be changed")
+
+// PasswordPolicy returns the password requirements in force, for forms to show up front
+func (s *service) PasswordPolicy(ctx context.Context) (*password.Policy, error) {
+	return s.passwords.Policy(ctx)
+}
+
+// RotatePassword replaces a password without a session, for users whose password expired
+// and who therefore can't log in. It asks for the same proof as a login, including the
+// second factor when the user has one, and counts failures towards the lockout.
+func (s *service) RotatePassword(ctx context.Context, email, oldPassword, newPassword, code string) error {
+	u, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			return ErrInvalidCredentials
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if u.IsServiceAccount() {
+		return ErrInvalidCredentials
+	}
+	if u.LockedUntil != nil && u.LockedUntil.After(time.Now()) {
+		return ErrAccountLocked
+	}
+	if !u.Active {
+		return ErrAccountInactive
+	}
+
+	if err := s.verifyPassword(u.Password, oldPassword); err != nil {
+		if s.recordFailedAttempt(ctx, u) {
+			return ErrAccountLocked
+		}
+		return ErrInvalidCredentials
+	}
+	if u.MFAEnabled {
+		if err := s.checkCode(ctx, u, code); err != nil {
+			return err
+		}
+	}
+
+	return s.setPassword(ctx, u, newPassword)
+}
+
+// setPassword checks a new password against the policy, stores it and signs the user out
+// everywhere, since whoever knew the old password may hold a session
+func (s *service) setPassword(ctx context.Context, u *models.User, newPassword string) error {
+	if err := s.passwords.Check(ctx, newPassword, u); err != nil {
+		return err
+	}
+	return s.storePassword(ctx, u, newPassword)
+}
+
+// storePassword is setPassword for a password that has already passed the policy check
+func (s *service) storePassword(ctx context.Context, u *models.User, newPassword string) error {
+	hashedPassword, err := s.hashPassword(newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	if err := s.userRepo.UpdatePassword(ctx, u.ID, hashedPassword, password.History(u)); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	if _, err := s.sessionRepo.RevokeAll(ctx, u.ID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,334 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	types "github.com/madhouselabs/anybase/internal/database/types"
+	"github.com/madhouselabs/anybase/internal/mailer"
+	"github.com/madhouselabs/anybase/pkg/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrPasswordlessDisabled = errors.New("passwordless login is disabled")
+	ErrInvalidLoginCode     = errors.New("invalid or expired sign-in link or code")
+	ErrTooManyLoginRequests = errors.New("too many sign-in emails requested for this address, try again later")
+)
+
+// maxLoginCodeAttempts is how many wrong codes a request takes before it is used up. With
+// 6 digits, that leaves a guesser a one in 200,000 chance per emailed code.
+const maxLoginCodeAttempts = 5
+
+// loginRateWindow is the period over which sign-in emails are counted per address
+const loginRateWindow = time.Hour
+
+// loginToken is a pending passwordless sign-in. Only hashes of the link token and the code
+// are stored. Requests for addresses without an account are kept too, already used up, so
+// that they count toward the rate limit in the same way.
+type loginToken struct {
+	ID        string     `json:"_id"`
+	Email     string     `json:"email"`
+	UserID    string     `json:"user_id,omitempty"`
+	Org       string     `json:"org,omitempty"`
+	LinkHash  string     `json:"link_hash,omitempty"`
+	CodeHash  string     `json:"code_hash,omitempty"`
+	Attempts  int        `json:"attempts"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// RequestPasswordlessLogin emails a sign-in link and a 6-digit code. Each new request
+// replaces the previous one. Like the password reset request, it succeeds silently for
+// addresses without an account.
+func (s *service) RequestPasswordlessLogin(ctx context.Context, req *models.PasswordlessRequest) error {
+	if err := s.passwordlessEnabled(ctx); err != nil {
+		return err
+	}
+
+	// Limited before the lookup, so the limit does not reveal which addresses are registered
+	email := normalizeEmail(req.Email)
+	if err := s.limitLoginRequests(ctx, email); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	tok := &loginToken{
+		ID:        primitive.NewObjectID().Hex(),
+		Email:     email,
+		Org:       req.Org,
+		ExpiresAt: now.Add(s.config.PasswordlessExpiration),
+		CreatedAt: now,
+	}
+
+	u, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil || u.IsServiceAccount() || !u.Active {
+		tok.UsedAt = &now
+		return s.insertLoginToken(ctx, tok)
+	}
+
+	link := s.generateToken()
+	code, err := generateLoginCode()
+	if err != nil {
+		return err
+	}
+	tok.UserID = u.ID.Hex()
+	tok.LinkHash = hashToken(link)
+	tok.CodeHash = hashLoginCode(tok.ID, code)
+
+	if _, err := s.loginTokens.UpdateMany(ctx, map[string]interface{}{
+		"email":   email,
+		"used_at": nil,
+	}, map[string]interface{}{
+		"$set": map[string]interface{}{"used_at": now},
+	}); err != nil {
+		return fmt.Errorf("failed to replace sign-in requests: %w", err)
+	}
+	if err := s.insertLoginToken(ctx, tok); err != nil {
+		return err
+	}
+
+	return s.mail.SendTemplate(ctx, u.Email, mailer.TemplatePasswordless, map[string]interface{}{
+		"Name":      u.FirstName,
+		"Email":     u.Email,
+		"Code":      code,
+		"Link":      tokenLink(s.config.PasswordlessURL, link),
+		"ExpiresIn": humanDuration(s.config.PasswordlessExpiration),
+	})
+}
+
+// CompletePasswordlessLogin signs the user in with the emailed link token, or with their
+// email address and code. Either one uses up the request. The email stands in for the
+// password only, so users with MFA still get the second factor challenge.
+func (s *service) CompletePasswordlessLogin(ctx context.Context, req *models.PasswordlessLogin) (*AuthResponse, error) {
+	if err := s.passwordlessEnabled(ctx); err != nil {
+		return nil, err
+	}
+
+	var tok *loginToken
+	var err error
+	switch {
+	case req.Token != "":
+		tok, err = s.findLoginToken(ctx, map[string]interface{}{"link_hash": hashToken(req.Token)})
+	case req.Email != "" && req.Code != "":
+		tok, err = s.findLoginToken(ctx, map[string]interface{}{
+			"email":   normalizeEmail(req.Email),
+			"used_at": nil,
+		})
+		if err == nil {
+			err = s.checkLoginCode(ctx, tok, strings.TrimSpace(req.Code))
+		}
+	default:
+		return nil, ErrInvalidLoginCode
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tok.UsedAt != nil || time.Now().After(tok.ExpiresAt) {
+		return nil, ErrInvalidLoginCode
+	}
+
+	// Whoever marks the request used owns the sign-in
+	now := time.Now().UTC()
+	result, err := s.loginTokens.UpdateOne(ctx, map[string]interface{}{
+		"_id":     tok.ID,
+		"used_at": nil,
+	}, map[string]interface{}{
+		"$set": map[string]interface{}{"used_at": now},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to use sign-in request: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return nil, ErrInvalidLoginCode
+	}
+
+	userID, err := primitive.ObjectIDFromHex(tok.UserID)
+	if err != nil {
+		return nil, ErrInvalidLoginCode
+	}
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, ErrInvalidLoginCode
+	}
+	// The address may have changed since the email was sent
+	if normalizeEmail(u.Email) != tok.Email || u.IsServiceAccount() {
+		return nil, ErrInvalidLoginCode
+	}
+	if u.LockedUntil != nil && u.LockedUntil.After(time.Now()) {
+		return nil, ErrAccountLocked
+	}
+	if !u.Active {
+		return nil, ErrAccountInactive
+	}
+
+	// Receiving the email proves the address belongs to the user
+	if !u.EmailVerified {
+		if err := s.userRepo.VerifyEmail(ctx, u.ID); err != nil {
+			fmt.Printf("failed to mark email verified: %v\n", err)
+		}
+		u.EmailVerified = true
+	}
+
+	org, roles, err := s.resolveOrganization(ctx, u, tok.Org)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := s.mfaChallenge(ctx, u, org)
+	if err != nil {
+		return nil, err
+	}
+	if challenge != nil {
+		return &AuthResponse{MFA: challenge}, nil
+	}
+
+	return s.startSession(ctx, u, org, roles, req.IPAddress, req.UserAgent)
+}
+
+func (s *service) passwordlessEnabled(ctx context.Context) error {
+	systemSettings, err := s.settings.GetSystemSettings(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get system settings: %w", err)
+	}
+	if !systemSettings.PasswordlessLogin {
+		return ErrPasswordlessDisabled
+	}
+	return nil
+}
+
+// limitLoginRequests refuses a request once the address has had its share of sign-in emails
+// within the window. Requests that have left the window are deleted on the way.
+func (s *service) limitLoginRequests(ctx context.Context, email string) error {
+	cursor, err := s.loginTokens.Find(ctx, map[string]interface{}{"email": email}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to check sign-in requests: %w", err)
+	}
+
+	windowStart := time.Now().Add(-loginRateWindow)
+	recent := 0
+	var stale []string
+	for cursor.Next(ctx) {
+		var tok loginToken
+		if err := cursor.Decode(&tok); err != nil {
+			continue
+		}
+		if tok.CreatedAt.After(windowStart) {
+			recent++
+		} else if time.Now().After(tok.ExpiresAt) {
+			stale = append(stale, tok.ID)
+		}
+	}
+	cursor.Close(ctx)
+
+	for _, id := range stale {
+		s.loginTokens.DeleteOne(ctx, map[string]interface{}{"_id": id})
+	}
+
+	if limit := s.config.PasswordlessRequestsPerHour; limit > 0 && recent >= limit {
+		return ErrTooManyLoginRequests
+	}
+	return nil
+}
+
+// checkLoginCode compares a code with the request's, using up the request after too many
+// wrong guesses. Each guess first claims an attempt with a conditional update, so parallel
+// guesses cannot share one and the limit holds.
+func (s *service) checkLoginCode(ctx context.Context, tok *loginToken, code string) error {
+	if tok.Attempts >= maxLoginCodeAttempts {
+		return ErrInvalidLoginCode
+	}
+
+	result, err := s.loginTokens.UpdateOne(ctx, map[string]interface{}{
+		"_id":      tok.ID,
+		"attempts": tok.Attempts,
+		"used_at":  nil,
+	}, map[string]interface{}{
+		"$set": map[string]interface{}{"attempts": tok.Attempts + 1},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record sign-in code attempt: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		// Another guess claimed this attempt first
+		return ErrInvalidLoginCode
+	}
+	tok.Attempts++
+
+	if subtle.ConstantTimeCompare([]byte(hashLoginCode(tok.ID, code)), []byte(tok.CodeHash)) == 1 {
+		return nil
+	}
+
+	// The last wrong guess uses up the request, emailed link included
+	if tok.Attempts >= maxLoginCodeAttempts {
+		if _, err := s.loginTokens.UpdateOne(ctx, map[string]interface{}{
+			"_id":     tok.ID,
+			"used_at": nil,
+		}, map[string]interface{}{
+			"$set": map[string]interface{}{"used_at": time.Now().UTC()},
+		}); err != nil {
+			fmt.Printf("failed to use up sign-in request: %v\n", err)
+		}
+	}
+	return ErrInvalidLoginCode
+}
+
+func (s *service) findLoginToken(ctx context.Context, filter map[string]interface{}) (*loginToken, error) {
+	var tok loginToken
+	if err := s.loginTokens.FindOne(ctx, filter, &tok); err != nil {
+		if errors.Is(err, types.ErrNoDocuments) {
+			return nil, ErrInvalidLoginCode
+		}
+		return nil, fmt.Errorf("failed to get sign-in request: %w", err)
+	}
+	return &tok, nil
+}
+
+func (s *service) insertLoginToken(ctx context.Context, tok *loginToken) error {
+	doc := map[string]interface{}{
+		"_id":        tok.ID,
+		"email":      tok.Email,
+		"org":        tok.Org,
+		"attempts":   tok.Attempts,
+		"expires_at": tok.ExpiresAt,
+		"created_at": tok.CreatedAt,
+	}
+	if tok.UserID != "" {
+		doc["user_id"] = tok.UserID
+		doc["link_hash"] = tok.LinkHash
+		doc["code_hash"] = tok.CodeHash
+	}
+	if tok.UsedAt != nil {
+		doc["used_at"] = *tok.UsedAt
+	}
+	if _, err := s.loginTokens.InsertOne(ctx, doc); err != nil {
+		return fmt.Errorf("failed to store sign-in request: %w", err)
+	}
+	return nil
+}
+
+// generateLoginCode returns a random 6-digit code
+func generateLoginCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate sign-in code: %w", err)
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// hashLoginCode returns the stored form of a code. Codes are short, so the request ID is
+// mixed in to keep equal codes from hashing alike.
+func hashLoginCode(requestID, code string) string {
+	return hashToken(requestID + ":" + code)
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
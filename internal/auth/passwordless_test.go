@@ -0,0 +1,417 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/madhouselabs/anybase/pkg/models"
+)
+
+// newPasswordlessService returns a service with passwordless login turned on
+func newPasswordlessService(t *testing.T) *testEnv {
+	t.Helper()
+	s := newTestService(t)
+	s.systemSettings().PasswordlessLogin = true
+	return s
+}
+
+// requestLogin asks for a sign-in email and returns the code and link token it carries
+func requestLogin(t *testing.T, s *testEnv, email string) (code, token string) {
+	t.Helper()
+	sent := len(s.mail.messages())
+	if err := s.RequestPasswordlessLogin(context.Background(), &models.PasswordlessRequest{Email: email}); err != nil {
+		t.Fatalf("RequestPasswordlessLogin: %v", err)
+	}
+	messages := s.mail.messages()
+	if len(messages) != sent+1 {
+		t.Fatalf("expected a sign-in email, %d were sent", len(messages)-sent)
+	}
+	msg := messages[len(messages)-1]
+
+	code = strings.TrimPrefix(msg.Subject, "Your sign-in code is ")
+	for _, line := range strings.Split(msg.Text, "\n") {
+		if link, err := url.Parse(strings.TrimSpace(line)); err == nil && link.Query().Get("token") != "" {
+			token = link.Query().Get("token")
+		}
+	}
+	if len(code) != 6 || token == "" {
+		t.Fatalf("no code or link in the email: %q\n%s", msg.Subject, msg.Text)
+	}
+	return code, token
+}
+
+// otherCode returns a 6-digit code that differs from code in every digit
+func otherCode(code string) string {
+	other := make([]byte, len(code))
+	for i := range code {
+		other[i] = '0' + (code[i]-'0'+5)%10
+	}
+	return string(other)
+}
+
+func TestPasswordlessDisabled(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	s.addUser("ada@example.com")
+
+	if err := s.RequestPasswordlessLogin(ctx, &models.PasswordlessRequest{Email: "ada@example.com"}); !errors.Is(err, ErrPasswordlessDisabled) {
+		t.Fatalf("expected ErrPasswordlessDisabled, got %v", err)
+	}
+	if _, err := s.CompletePasswordlessLogin(ctx, &models.PasswordlessLogin{Token: "token"}); !errors.Is(err, ErrPasswordlessDisabled) {
+		t.Fatalf("expected ErrPasswordlessDisabled, got %v", err)
+	}
+	if len(s.mail.messages()) != 0 {
+		t.Fatal("no email should be sent while passwordless login is off")
+	}
+}
+
+func TestCompletePasswordlessLogin(t *testing.T) {
+	tests := []struct {
+		name  string
+		login func(code, token string) *models.PasswordlessLogin
+	}{
+		{
+			name: "code",
+			login: func(code, token string) *models.PasswordlessLogin {
+				return &models.PasswordlessLogin{Email: "ada@example.com", Code: code}
+			},
+		},
+		{
+			name: "code with the address as typed",
+			login: func(code, token string) *models.PasswordlessLogin {
+				return &models.PasswordlessLogin{Email: "  Ada@Example.COM ", Code: " " + code + " "}
+			},
+		},
+		{
+			name:  "link",
+			login: func(code, token string) *models.PasswordlessLogin { return &models.PasswordlessLogin{Token: token} },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newPasswordlessService(t)
+			u := s.addUser("ada@example.com")
+			code, token := requestLogin(t, s, "ada@example.com")
+
+			resp, err := s.CompletePasswordlessLogin(context.Background(), tt.login(code, token))
+			if err != nil {
+				t.Fatalf("CompletePasswordlessLogin: %v", err)
+			}
+			if resp.User == nil || resp.User.ID != u.ID || resp.AccessToken == "" || resp.RefreshToken == "" {
+				t.Fatalf("expected tokens for the user, got %+v", resp)
+			}
+			if !s.users.get(u.ID).EmailVerified {
+				t.Fatal("signing in from the email should verify the address")
+			}
+		})
+	}
+}
+
+func TestPasswordlessLoginIgnoresStoredCase(t *testing.T) {
+	tests := []struct {
+		name      string
+		stored    string
+		requested string
+	}{
+		{name: "mixed case account", stored: "Alice@Example.com", requested: "alice@example.com"},
+		{name: "mixed case request", stored: "alice@example.com", requested: " ALICE@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newPasswordlessService(t)
+			u := s.addUser(tt.stored)
+
+			code, _ := requestLogin(t, s, tt.requested)
+			if to := s.mail.messages()[0].To; len(to) != 1 || to[0] != tt.stored {
+				t.Fatalf("email sent to %v, want the stored address %q", to, tt.stored)
+			}
+			resp, err := s.CompletePasswordlessLogin(context.Background(), &models.PasswordlessLogin{Email: tt.requested, Code: code})
+			if err != nil {
+				t.Fatalf("CompletePasswordlessLogin: %v", err)
+			}
+			if resp.User == nil || resp.User.ID != u.ID {
+				t.Fatalf("expected tokens for the user, got %+v", resp)
+			}
+		})
+	}
+}
+
+func TestCompletePasswordlessLoginRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		login func(t *testing.T, s *testEnv) *models.PasswordlessLogin
+		want  error
+	}{
+		{
+			name: "wrong code",
+			login: func(t *testing.T, s *testEnv) *models.PasswordlessLogin {
+				code, _ := requestLogin(t, s, "ada@example.com")
+				return &models.PasswordlessLogin{Email: "ada@example.com", Code: otherCode(code)}
+			},
+			want: ErrInvalidLoginCode,
+		},
+		{
+			name: "code for another address",
+			login: func(t *testing.T, s *testEnv) *models.PasswordlessLogin {
+				code, _ := requestLogin(t, s, "ada@example.com")
+				return &models.PasswordlessLogin{Email: "grace@example.com", Code: code}
+			},
+			want: ErrInvalidLoginCode,
+		},
+		{
+			name: "unknown link",
+			login: func(t *testing.T, s *testEnv) *models.PasswordlessLogin {
+				requestLogin(t, s, "ada@example.com")
+				return &models.PasswordlessLogin{Token: "not-a-token"}
+			},
+			want: ErrInvalidLoginCode,
+		},
+		{
+			name: "code used twice",
+			login: func(t *testing.T, s *testEnv) *models.PasswordlessLogin {
+				code, _ := requestLogin(t, s, "ada@example.com")
+				login := &models.PasswordlessLogin{Email: "ada@example.com", Code: code}
+				if _, err := s.CompletePasswordlessLogin(context.Background(), login); err != nil {
+					t.Fatal(err)
+				}
+				return login
+			},
+			want: ErrInvalidLoginCode,
+		},
+		{
+			name: "link after the code",
+			login: func(t *testing.T, s *testEnv) *models.PasswordlessLogin {
+				code, token := requestLogin(t, s, "ada@example.com")
+				if _, err := s.CompletePasswordlessLogin(context.Background(), &models.PasswordlessLogin{Email: "ada@example.com", Code: code}); err != nil {
+					t.Fatal(err)
+				}
+				return &models.PasswordlessLogin{Token: token}
+			},
+			want: ErrInvalidLoginCode,
+		},
+		{
+			name: "code from a replaced request",
+			login: func(t *testing.T, s *testEnv) *models.PasswordlessLogin {
+				code, _ := requestLogin(t, s, "ada@example.com")
+				for {
+					// A new code that happens to repeat the old one would sign in
+					if next, _ := requestLogin(t, s, "ada@example.com"); next != code {
+						break
+					}
+				}
+				return &models.PasswordlessLogin{Email: "ada@example.com", Code: code}
+			},
+			want: ErrInvalidLoginCode,
+		},
+		{
+			name: "link from a replaced request",
+			login: func(t *testing.T, s *testEnv) *models.PasswordlessLogin {
+				_, token := requestLogin(t, s, "ada@example.com")
+				requestLogin(t, s, "ada@example.com")
+				return &models.PasswordlessLogin{Token: token}
+			},
+			want: ErrInvalidLoginCode,
+		},
+		{
+			name: "expired",
+			login: func(t *testing.T, s *testEnv) *models.PasswordlessLogin {
+				s.config.PasswordlessExpiration = -time.Minute
+				_, token := requestLogin(t, s, "ada@example.com")
+				return &models.PasswordlessLogin{Token: token}
+			},
+			want: ErrInvalidLoginCode,
+		},
+		{
+			name: "locked account",
+			login: func(t *testing.T, s *testEnv) *models.PasswordlessLogin {
+				_, token := requestLogin(t, s, "ada@example.com")
+				u, _ := s.users.GetByEmail(context.Background(), "ada@example.com")
+				until := time.Now().Add(time.Hour)
+				s.users.UpdateLockedUntil(context.Background(), u.ID, &until)
+				return &models.PasswordlessLogin{Token: token}
+			},
+			want: ErrAccountLocked,
+		},
+		{
+			name: "neither link nor code",
+			login: func(t *testing.T, s *testEnv) *models.PasswordlessLogin {
+				return &models.PasswordlessLogin{Email: "ada@example.com"}
+			},
+			want: ErrInvalidLoginCode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newPasswordlessService(t)
+			s.addUser("ada@example.com")
+			s.addUser("grace@example.com")
+
+			_, err := s.CompletePasswordlessLogin(context.Background(), tt.login(t, s))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestPasswordlessCodeAttempts(t *testing.T) {
+	tests := []struct {
+		name    string
+		guesses int
+		wantErr bool
+	}{
+		{name: "right code after wrong guesses", guesses: maxLoginCodeAttempts - 1},
+		{name: "request used up", guesses: maxLoginCodeAttempts, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newPasswordlessService(t)
+			ctx := context.Background()
+			s.addUser("ada@example.com")
+			code, token := requestLogin(t, s, "ada@example.com")
+
+			for i := 0; i < tt.guesses; i++ {
+				_, err := s.CompletePasswordlessLogin(ctx, &models.PasswordlessLogin{Email: "ada@example.com", Code: otherCode(code)})
+				if !errors.Is(err, ErrInvalidLoginCode) {
+					t.Fatalf("guess %d: expected ErrInvalidLoginCode, got %v", i+1, err)
+				}
+			}
+
+			_, err := s.CompletePasswordlessLogin(ctx, &models.PasswordlessLogin{Email: "ada@example.com", Code: code})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("right code: got %v, want error %v", err, tt.wantErr)
+			}
+			// The link goes with the request
+			if tt.wantErr {
+				if _, err := s.CompletePasswordlessLogin(ctx, &models.PasswordlessLogin{Token: token}); !errors.Is(err, ErrInvalidLoginCode) {
+					t.Fatalf("expected the link to be used up too, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestCompletePasswordlessLoginChallengesMFA(t *testing.T) {
+	s := newPasswordlessService(t)
+	u, _, _ := enrollMFA(t, s)
+	_, token := requestLogin(t, s, u.Email)
+
+	resp, err := s.CompletePasswordlessLogin(context.Background(), &models.PasswordlessLogin{Token: token})
+	if err != nil {
+		t.Fatalf("CompletePasswordlessLogin: %v", err)
+	}
+	if resp.MFA == nil || resp.AccessToken != "" {
+		t.Fatalf("expected an MFA challenge instead of tokens, got %+v", resp)
+	}
+}
+
+func TestRequestPasswordlessLoginSendsNothingForUnknownAddresses(t *testing.T) {
+	tests := []struct {
+		name string
+		user *models.User
+	}{
+		{name: "no account"},
+		{name: "inactive", user: &models.User{Email: "ada@example.com", UserType: models.UserTypeRegular}},
+		{name: "service account", user: &models.User{Email: "ada@example.com", Active: true, UserType: models.UserTypeServiceAccount}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newPasswordlessService(t)
+			if tt.user != nil {
+				s.users.add(tt.user)
+			}
+			if err := s.RequestPasswordlessLogin(context.Background(), &models.PasswordlessRequest{Email: "ada@example.com"}); err != nil {
+				t.Fatalf("expected the request to succeed silently, got %v", err)
+			}
+			if n := len(s.mail.messages()); n != 0 {
+				t.Fatalf("%d emails sent", n)
+			}
+		})
+	}
+}
+
+func TestRequestPasswordlessLoginRateLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		registered bool
+	}{
+		{name: "registered address", registered: true},
+		// Unknown addresses are limited alike, so the limit does not reveal who has an account
+		{name: "unknown address"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newPasswordlessService(t)
+			ctx := context.Background()
+			s.config.PasswordlessRequestsPerHour = 3
+			if tt.registered {
+				s.addUser("ada@example.com")
+			}
+
+			for i := 0; i < 3; i++ {
+				// The address is counted in its normalized form
+				email := []string{"ada@example.com", "ADA@example.com", " ada@example.com"}[i]
+				if err := s.RequestPasswordlessLogin(ctx, &models.PasswordlessRequest{Email: email}); err != nil {
+					t.Fatalf("request %d: %v", i+1, err)
+				}
+			}
+			err := s.RequestPasswordlessLogin(ctx, &models.PasswordlessRequest{Email: "ada@example.com"})
+			if !errors.Is(err, ErrTooManyLoginRequests) {
+				t.Fatalf("expected ErrTooManyLoginRequests, got %v", err)
+			}
+			if err := s.RequestPasswordlessLogin(ctx, &models.PasswordlessRequest{Email: "grace@example.com"}); err != nil {
+				t.Fatalf("another address should not be limited: %v", err)
+			}
+		})
+	}
+}
+
+func TestCompletePasswordlessLoginRace(t *testing.T) {
+	s := newPasswordlessService(t)
+	s.addUser("ada@example.com")
+	code, token := requestLogin(t, s, "ada@example.com")
+
+	// Half the racers use the code, half the link; one request signs in once
+	const racers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, racers)
+	for i := 0; i < racers; i++ {
+		login := &models.PasswordlessLogin{Email: "ada@example.com", Code: code}
+		if i%2 == 1 {
+			login = &models.PasswordlessLogin{Token: token}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.CompletePasswordlessLogin(context.Background(), login)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.Is(err, ErrInvalidLoginCode):
+		default:
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("%d sign-ins succeeded with one request, want 1", succeeded)
+	}
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/madhouselabs/anybase/internal/session"
+)
+
+// signIn starts a session for a new user and returns its tokens
+func signIn(t *testing.T, s *testEnv) *AuthResponse {
+	t.Helper()
+	u := s.addUser("ada@example.com")
+	resp, err := s.startSession(context.Background(), u, nil, u.AllRoles(), "192.0.2.1", "test")
+	if err != nil {
+		t.Fatalf("startSession: %v", err)
+	}
+	return resp
+}
+
+func TestRefreshTokenRotates(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	first := signIn(t, s)
+
+	second, err := s.RefreshToken(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.AccessToken == "" {
+		t.Fatal("refreshing should issue a new token pair")
+	}
+
+	// The new refresh token works in turn
+	if _, err := s.RefreshToken(ctx, second.RefreshToken); err != nil {
+		t.Fatalf("RefreshToken with the rotated token: %v", err)
+	}
+}
+
+func TestRefreshTokenRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		token func(t *testing.T, s *testEnv, resp *AuthResponse) string
+		want  error
+	}{
+		{
+			name:  "access token",
+			token: func(t *testing.T, s *testEnv, resp *AuthResponse) string { return resp.AccessToken },
+		},
+		{
+			name:  "garbage",
+			token: func(t *testing.T, s *testEnv, resp *AuthResponse) string { return "not-a-token" },
+		},
+		{
+			name: "revoked session",
+			token: func(t *testing.T, s *testEnv, resp *AuthResponse) string {
+				if _, err := s.sessionRepo.RevokeAll(context.Background(), resp.User.ID); err != nil {
+					t.Fatal(err)
+				}
+				return resp.RefreshToken
+			},
+			want: session.ErrSessionRevoked,
+		},
+		{
+			name: "session of another user",
+			token: func(t *testing.T, s *testEnv, resp *AuthResponse) string {
+				other := s.addUser("grace@example.com")
+				claims, err := s.tokenService.ValidateToken(resp.RefreshToken, RefreshToken)
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, refresh, err := s.tokenService.GenerateTokenPair(TokenSubject{
+					UserID:         other.ID,
+					Email:          other.Email,
+					SessionID:      claims.SessionID,
+					RefreshTokenID: claims.ID,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return refresh
+			},
+			want: session.ErrSessionNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t)
+			resp := signIn(t, s)
+			_, err := s.RefreshToken(context.Background(), tt.token(t, s, resp))
+			if err == nil {
+				t.Fatal("expected the refresh to fail")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	first := signIn(t, s)
+
+	second, err := s.RefreshToken(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+
+	// Presenting the rotated token again ends the session for everyone holding it
+	if _, err := s.RefreshToken(ctx, first.RefreshToken); !errors.Is(err, session.ErrRefreshTokenReused) {
+		t.Fatalf("expected ErrRefreshTokenReused, got %v", err)
+	}
+	if _, err := s.RefreshToken(ctx, second.RefreshToken); !errors.Is(err, session.ErrSessionRevoked) {
+		t.Fatalf("expected the current token to die with the session, got %v", err)
+	}
+
+	events := s.db.collection("audit_logs").find(map[string]interface{}{"action": "auth.refresh_token.reuse"})
+	if len(events) != 1 || events[0]["user_id"] != first.User.ID.Hex() {
+		t.Fatalf("expected one reuse event for the user, got %v", events)
+	}
+}
+
+func TestRefreshTokenRace(t *testing.T) {
+	s := newTestService(t)
+	resp := signIn(t, s)
+
+	// Two refreshes with the same token: one rotates, the other is treated as reuse
+	const racers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, racers)
+	for i := 0; i < racers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.RefreshToken(context.Background(), resp.RefreshToken)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.Is(err, session.ErrRefreshTokenReused), errors.Is(err, session.ErrSessionRevoked):
+		default:
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("%d refreshes succeeded with the same token, want 1", succeeded)
+	}
+}
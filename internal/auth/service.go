@@ -3,12 +3,23 @@ package auth
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/madhouselabs/anybase/internal/config"
+	types "github.com/madhouselabs/anybase/internal/database/types"
+	"github.com/madhouselabs/anybase/internal/mailer"
+	"github.com/madhouselabs/anybase/internal/oidc"
+	"github.com/madhouselabs/anybase/internal/organization"
+	"github.com/madhouselabs/anybase/internal/password"
+	"github.com/madhouselabs/anybase/internal/serviceaccount"
+	"github.com/madhouselabs/anybase/internal/session"
+	"github.com/madhouselabs/anybase/internal/settings"
 	"github.com/madhouselabs/anybase/internal/user"
 	"github.com/madhouselabs/anybase/pkg/models"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -20,41 +31,100 @@ var (
 	ErrAccountLocked      = errors.New("account is locked due to too many failed login attempts")
 	ErrAccountInactive    = errors.New("account is inactive")
 	ErrEmailNotVerified   = errors.New("email address is not verified")
+	ErrInvalidEmailToken  = errors.New("invalid or expired token")
+	ErrInvalidMFAToken    = errors.New("invalid or expired mfa token")
+	ErrInvalidMFACode     = errors.New("invalid authentication code")
+	ErrMFANotEnabled      = errors.New("multi-factor authentication is not enabled")
+	ErrMFAAlreadyEnabled  = errors.New("multi-factor authentication is already enabled")
+	ErrMFANotEnrolling    = errors.New("no multi-factor enrollment in progress")
+	ErrMFAEnforced        = errors.New("multi-factor authentication is required by the system settings")
 )
 
 type Service interface {
 	Register(ctx context.Context, req *models.UserRegistration) (*models.User, error)
 	Login(ctx context.Context, req *models.UserLogin) (*AuthResponse, error)
 	RefreshToken(ctx context.Context, refreshToken string) (*AuthResponse, error)
-	Logout(ctx context.Context, userID primitive.ObjectID) error
+	Logout(ctx context.Context, userID, sessionID primitive.ObjectID) error
 	VerifyEmail(ctx context.Context, token string) error
+	ResendVerification(ctx context.Context, email string) error
 	RequestPasswordReset(ctx context.Context, email string) error
 	ResetPassword(ctx context.Context, token, newPassword string) error
 	ChangePassword(ctx context.Context, userID primitive.ObjectID, oldPassword, newPassword string) error
+	RotatePassword(ctx context.Context, email, oldPassword, newPassword, code string) error
+	PasswordPolicy(ctx context.Context) (*password.Policy, error)
+	SwitchOrganization(ctx context.Context, userID, sessionID primitive.ObjectID, slug string) (*AuthResponse, error)
+	ListSessions(ctx context.Context, userID primitive.ObjectID) ([]*models.Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID primitive.ObjectID) error
+	RevokeAllSessions(ctx context.Context, userID primitive.ObjectID) (int64, error)
+	VerifyMFA(ctx context.Context, req *models.MFAVerification) (*AuthResponse, error)
+	ChallengeUser(ctx context.Context, mfaToken string) (primitive.ObjectID, error)
+	BeginMFAEnrollment(ctx context.Context, userID primitive.ObjectID) (*MFAEnrollment, error)
+	ConfirmMFAEnrollment(ctx context.Context, userID primitive.ObjectID, code string) ([]string, error)
+	RegenerateRecoveryCodes(ctx context.Context, userID primitive.ObjectID, code string) ([]string, error)
+	DisableMFA(ctx context.Context, userID primitive.ObjectID, code string) error
+	ResetMFA(ctx context.Context, userID primitive.ObjectID) error
+	OIDCProviders() []OIDCProvider
+	BeginOIDCLogin(ctx context.Context, provider, org string) (string, error)
+	CompleteOIDCLogin(ctx context.Context, req *models.OIDCCallback) (*AuthResponse, error)
+	ExchangeClientCredentials(ctx context.Context, clientID, clientSecret, org string) (*AuthResponse, error)
+	Impersonate(ctx context.Context, actorID, userID primitive.ObjectID, req *models.ImpersonationRequest) (*AuthResponse, error)
+	RequestPasswordlessLogin(ctx context.Context, req *models.PasswordlessRequest) error
+	CompletePasswordlessLogin(ctx context.Context, req *models.PasswordlessLogin) (*AuthResponse, error)
 }
 
+// AuthResponse is the result of a login. When a second factor is needed it holds only the
+// MFA challenge, and the tokens come from VerifyMFA.
 type AuthResponse struct {
-	User         *models.User `json:"user"`
-	AccessToken  string       `json:"access_token"`
-	RefreshToken string       `json:"refresh_token"`
-	ExpiresIn    int64        `json:"expires_in"`
+	User          *models.User         `json:"user,omitempty"`
+	Organization  *models.Organization `json:"organization,omitempty"`
+	AccessToken   string               `json:"access_token,omitempty"`
+	RefreshToken  string               `json:"refresh_token,omitempty"`
+	ExpiresIn     int64                `json:"expires_in,omitempty"`
+	MFA           *MFAChallenge        `json:"mfa,omitempty"`
+	RecoveryCodes []string             `json:"recovery_codes,omitempty"` // Shown once, when enrollment completes at login
 }
 
 type service struct {
-	userRepo     user.Repository
-	tokenService TokenService
-	config       *config.AuthConfig
+	userRepo        user.Repository
+	orgRepo         organization.Repository
+	sessionRepo     session.Repository
+	identities      user.IdentityRepository
+	serviceAccounts serviceaccount.Service
+	auditLogs       types.Collection
+	loginTokens     types.Collection
+	settings        settings.Service
+	passwords       *password.Checker
+	mail            *mailer.Sender
+	oidc            *oidc.Registry
+	oidcStates      oidc.StateStore
+	tokenService    TokenService
+	config          *config.AuthConfig
 }
 
-func NewService(userRepo user.Repository, config *config.AuthConfig) Service {
+func NewService(db types.DB, userRepo user.Repository, orgRepo organization.Repository, sessionRepo session.Repository, settingsService settings.Service, passwords *password.Checker, mail *mailer.Sender, identityProviders *oidc.Registry, serviceAccounts serviceaccount.Service, tokenService TokenService, config *config.AuthConfig) Service {
 	return &service{
-		userRepo:     userRepo,
-		tokenService: NewTokenService(config),
-		config:       config,
+		userRepo:        userRepo,
+		orgRepo:         orgRepo,
+		sessionRepo:     sessionRepo,
+		identities:      user.NewIdentityRepository(db),
+		serviceAccounts: serviceAccounts,
+		auditLogs:       db.Collection("audit_logs"),
+		loginTokens:     db.Collection("login_tokens"),
+		settings:        settingsService,
+		passwords:       passwords,
+		mail:            mail,
+		oidc:            identityProviders,
+		oidcStates:      oidc.NewStateStore(db),
+		tokenService:    tokenService,
+		config:          config,
 	}
 }
 
 func (s *service) Register(ctx context.Context, req *models.UserRegistration) (*models.User, error) {
+	if models.IsServiceAccountEmail(req.Email) {
+		return nil, ErrReservedEmailDomain
+	}
+
 	// Check if user already exists
 	existingUser, err := s.userRepo.GetByEmail(ctx, req.Email)
 	if err != nil && err != user.ErrUserNotFound {
@@ -64,32 +134,41 @@ func (s *service) Register(ctx context.Context, req *models.UserRegistration) (*
 		return nil, user.ErrUserAlreadyExists
 	}
 
+	if err := s.passwords.Check(ctx, req.Password, &models.User{Email: req.Email}); err != nil {
+		return nil, err
+	}
+
 	// Hash password
 	hashedPassword, err := s.hashPassword(req.Password)
 	if err != nil {
 		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
 
-	// Generate email verification token
+	// Generate email verification token; only its hash is stored
 	verificationToken := s.generateToken()
+	verificationExpiry := time.Now().Add(s.config.EmailVerificationExpiration)
 
 	// Create user
 	newUser := &models.User{
-		Email:                  req.Email,
-		Password:               hashedPassword,
-		FirstName:              req.FirstName,
-		LastName:               req.LastName,
-		EmailVerificationToken: verificationToken,
-		Role:                   "developer", // Default role for new users
-		UserType:               models.UserTypeRegular,
-		Active:                 true,
+		Email:                   req.Email,
+		Password:                hashedPassword,
+		FirstName:               req.FirstName,
+		LastName:                req.LastName,
+		EmailVerificationToken:  hashToken(verificationToken),
+		EmailVerificationExpiry: &verificationExpiry,
+		Role:                    "developer", // Default role for new users
+		UserType:                models.UserTypeRegular,
+		Active:                  true,
 	}
 
 	if err := s.userRepo.Create(ctx, newUser); err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
-	// TODO: Send verification email with token
+	// The account exists either way; a failed email can be resent
+	if err := s.sendVerificationEmail(ctx, newUser, verificationToken); err != nil {
+		fmt.Printf("failed to send verification email: %v\n", err)
+	}
 
 	// Clear sensitive fields before returning
 	newUser.Password = ""
@@ -107,6 +186,10 @@ func (s *service) Login(ctx context.Context, req *models.UserLogin) (*AuthRespon
 		}
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
+	// Service accounts have no password and authenticate with client credentials
+	if u.IsServiceAccount() {
+		return nil, ErrInvalidCredentials
+	}
 
 	// Check if account is locked
 	if u.LockedUntil != nil && u.LockedUntil.After(time.Now()) {
@@ -120,23 +203,62 @@ func (s *service) Login(ctx context.Context, req *models.UserLogin) (*AuthRespon
 
 	// Verify password
 	if err := s.verifyPassword(u.Password, req.Password); err != nil {
-		// Increment login attempts
-		attempts := u.LoginAttempts + 1
-		if err := s.userRepo.UpdateLoginAttempts(ctx, u.ID, attempts); err != nil {
-			// Log error but don't fail the login attempt
-			fmt.Printf("failed to update login attempts: %v\n", err)
+		if s.recordFailedAttempt(ctx, u) {
+			return nil, ErrAccountLocked
 		}
+		return nil, ErrInvalidCredentials
+	}
 
-		// Lock account if max attempts reached
-		if attempts >= s.config.MaxLoginAttempts {
-			lockUntil := time.Now().Add(s.config.LockoutDuration)
-			if err := s.userRepo.UpdateLockedUntil(ctx, u.ID, &lockUntil); err != nil {
-				fmt.Printf("failed to lock account: %v\n", err)
-			}
-			return nil, ErrAccountLocked
+	// Checked only after the password, so it does not reveal which emails are registered
+	if !u.EmailVerified {
+		required, err := s.emailVerificationRequired(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if required {
+			return nil, ErrEmailNotVerified
 		}
+	}
 
-		return nil, ErrInvalidCredentials
+	// An expired password has to be rotated before it opens a session
+	expired, err := s.passwords.Expired(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	if expired {
+		return nil, ErrPasswordExpired
+	}
+
+	// Resolve the organization before the login counts as successful
+	org, roles, err := s.resolveOrganization(ctx, u, req.Org)
+	if err != nil {
+		return nil, err
+	}
+
+	// With MFA on, or required by the system, the tokens wait for the second factor
+	challenge, err := s.mfaChallenge(ctx, u, org)
+	if err != nil {
+		return nil, err
+	}
+	if challenge != nil {
+		return &AuthResponse{MFA: challenge}, nil
+	}
+
+	return s.startSession(ctx, u, org, roles, req.IPAddress, req.UserAgent)
+}
+
+// startSession completes a login: it opens a session and issues its first token pair
+func (s *service) startSession(ctx context.Context, u *models.User, org *models.Organization, roles []string, ipAddress, userAgent string) (*AuthResponse, error) {
+	// Every login gets its own session, which its tokens are tied to
+	sess := &models.Session{
+		UserID:         u.ID,
+		IPAddress:      ipAddress,
+		UserAgent:      userAgent,
+		ExpiresAt:      time.Now().UTC().Add(s.config.RefreshTokenExpiration),
+		RefreshTokenID: s.generateToken(),
+	}
+	if err := s.sessionRepo.Create(ctx, sess); err != nil {
+		return nil, err
 	}
 
 	// Reset login attempts and update last login
@@ -144,13 +266,119 @@ func (s *service) Login(ctx context.Context, req *models.UserLogin) (*AuthRespon
 		fmt.Printf("failed to update last login: %v\n", err)
 	}
 
-	// Generate tokens
-	accessToken, refreshToken, err := s.tokenService.GenerateTokenPair(
-		u.ID,
-		u.Email,
-		[]string{u.Role}, // Convert single role to array for token
-		[]string{}, // Permissions come from roles now
-	)
+	return s.issueTokens(u, org, roles, sess)
+}
+
+// recordFailedAttempt counts a wrong password or code against the account and locks it
+// once the limit is reached. It reports whether the account is now locked.
+func (s *service) recordFailedAttempt(ctx context.Context, u *models.User) bool {
+	attempts := u.LoginAttempts + 1
+	if err := s.userRepo.UpdateLoginAttempts(ctx, u.ID, attempts); err != nil {
+		// Log error but don't fail the login attempt
+		fmt.Printf("failed to update login attempts: %v\n", err)
+	}
+
+	// Lock account if max attempts reached
+	if attempts >= s.config.MaxLoginAttempts {
+		lockUntil := time.Now().Add(s.config.LockoutDuration)
+		if err := s.userRepo.UpdateLockedUntil(ctx, u.ID, &lockUntil); err != nil {
+			fmt.Printf("failed to lock account: %v\n", err)
+		}
+		return true
+	}
+	return false
+}
+
+// SwitchOrganization issues a token pair scoped to another organization the user belongs
+// to, or to the platform when slug is empty. The new tokens stay in the caller's session and
+// replace its refresh token.
+func (s *service) SwitchOrganization(ctx context.Context, userID, sessionID primitive.ObjectID, slug string) (*AuthResponse, error) {
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	// A service account exchanges its credentials for the organization instead
+	if err := interactive(u); err != nil {
+		return nil, err
+	}
+	if !u.Active {
+		return nil, ErrAccountInactive
+	}
+
+	org, roles, err := s.resolveOrganization(ctx, u, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	sess, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	// New tokens would drop the impersonator and come with a refresh token
+	if sess.ImpersonatorID != nil {
+		return nil, ErrImpersonating
+	}
+	if err := s.rotateRefreshToken(ctx, sess); err != nil {
+		return nil, err
+	}
+	return s.issueTokens(u, org, roles, sess)
+}
+
+// resolveOrganization returns the organization a token will be scoped to and the roles the
+// user holds there. Without a slug the token is for the platform and carries the user's own roles.
+func (s *service) resolveOrganization(ctx context.Context, u *models.User, slug string) (*models.Organization, []string, error) {
+	if slug == "" {
+		return nil, u.AllRoles(), nil
+	}
+	org, err := s.orgRepo.GetBySlug(ctx, slug)
+	if err != nil {
+		if errors.Is(err, organization.ErrOrganizationNotFound) {
+			return nil, nil, organization.ErrNotMember
+		}
+		return nil, nil, err
+	}
+	member, err := s.orgRepo.GetMember(ctx, org.ID, u.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return org, member.Roles, nil
+}
+
+// tokenOrganization is resolveOrganization for the organization ID carried by a token
+func (s *service) tokenOrganization(ctx context.Context, u *models.User, orgIDHex string) (*models.Organization, []string, error) {
+	if orgIDHex == "" {
+		return nil, u.AllRoles(), nil
+	}
+	orgID, err := primitive.ObjectIDFromHex(orgIDHex)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid organization ID in token: %w", err)
+	}
+	org, err := s.orgRepo.GetByID(ctx, orgID)
+	if err != nil {
+		return nil, nil, err
+	}
+	member, err := s.orgRepo.GetMember(ctx, org.ID, u.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return org, member.Roles, nil
+}
+
+func (s *service) issueTokens(u *models.User, org *models.Organization, roles []string, sess *models.Session) (*AuthResponse, error) {
+	orgID := ""
+	if org != nil {
+		orgID = org.ID.Hex()
+	}
+
+	accessToken, refreshToken, err := s.tokenService.GenerateTokenPair(TokenSubject{
+		UserID:         u.ID,
+		Email:          u.Email,
+		Roles:          roles,
+		Permissions:    []string{}, // Permissions come from roles now
+		OrgID:          orgID,
+		SessionID:      sess.ID.Hex(),
+		RefreshTokenID: sess.RefreshTokenID,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate tokens: %w", err)
 	}
@@ -160,12 +388,16 @@ func (s *service) Login(ctx context.Context, req *models.UserLogin) (*AuthRespon
 
 	return &AuthResponse{
 		User:         u,
+		Organization: org,
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
 		ExpiresIn:    int64(s.config.JWTExpiration.Seconds()),
 	}, nil
 }
 
+// RefreshToken exchanges a refresh token for a new token pair. Refresh tokens are single
+// use: each refresh rotates the session's current token, and presenting a rotated one is
+// treated as theft and revokes the session along with every token issued in it.
 func (s *service) RefreshToken(ctx context.Context, refreshToken string) (*AuthResponse, error) {
 	// Validate refresh token
 	claims, err := s.tokenService.ValidateToken(refreshToken, RefreshToken)
@@ -179,6 +411,29 @@ func (s *service) RefreshToken(ctx context.Context, refreshToken string) (*AuthR
 		return nil, fmt.Errorf("invalid user ID in token: %w", err)
 	}
 
+	// A refresh token dies with its session
+	sessionID, err := primitive.ObjectIDFromHex(claims.SessionID)
+	if err != nil {
+		return nil, session.ErrSessionNotFound
+	}
+	sess, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if sess.UserID != userID {
+		return nil, session.ErrSessionNotFound
+	}
+	if !sess.Active() {
+		return nil, session.ErrSessionRevoked
+	}
+	// Impersonation sessions never hand out refresh tokens
+	if sess.ImpersonatorID != nil {
+		return nil, ErrImpersonating
+	}
+	if claims.ID != sess.RefreshTokenID {
+		return nil, s.refreshTokenReused(ctx, sess, claims.ID)
+	}
+
 	// Get updated user data
 	u, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
@@ -190,87 +445,185 @@ func (s *service) RefreshToken(ctx context.Context, refreshToken string) (*AuthR
 		return nil, ErrAccountInactive
 	}
 
-	// Generate new access token with updated permissions
-	accessToken, err := s.tokenService.GenerateAccessToken(
-		u.ID,
-		u.Email,
-		[]string{u.Role}, // Convert single role to array for token
-		[]string{}, // Permissions come from roles now
-	)
+	// An organization token stays valid only while the user is a member
+	org, roles, err := s.tokenOrganization(ctx, u, claims.OrgID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate access token: %w", err)
+		return nil, err
 	}
 
-	// Clear sensitive fields
-	u.Password = ""
+	// Losing the race to rotate means someone else refreshed with the same token
+	if err := s.rotateRefreshToken(ctx, sess); err != nil {
+		if errors.Is(err, session.ErrRefreshTokenReused) {
+			return nil, s.refreshTokenReused(ctx, sess, claims.ID)
+		}
+		return nil, err
+	}
 
-	return &AuthResponse{
-		User:         u,
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken, // Return the same refresh token
-		ExpiresIn:    int64(s.config.JWTExpiration.Seconds()),
-	}, nil
+	return s.issueTokens(u, org, roles, sess)
 }
 
-func (s *service) Logout(ctx context.Context, userID primitive.ObjectID) error {
-	// TODO: Implement token blacklist or session management
-	// For now, logout is handled on the client side
+// rotateRefreshToken moves the session on to a new refresh token and extends it
+func (s *service) rotateRefreshToken(ctx context.Context, sess *models.Session) error {
+	next := s.generateToken()
+	expiresAt := time.Now().UTC().Add(s.config.RefreshTokenExpiration)
+	if err := s.sessionRepo.Rotate(ctx, sess.ID, sess.RefreshTokenID, next, expiresAt); err != nil {
+		return err
+	}
+	sess.RefreshTokenID = next
+	sess.ExpiresAt = expiresAt
 	return nil
 }
 
+// refreshTokenReused revokes a session whose rotated refresh token was presented again and
+// records a security event. Either the legitimate client or an attacker holds a stale
+// token, and there is no telling which, so the whole token family goes.
+func (s *service) refreshTokenReused(ctx context.Context, sess *models.Session, tokenID string) error {
+	if err := s.sessionRepo.Revoke(ctx, sess.ID); err != nil && !errors.Is(err, session.ErrSessionNotFound) {
+		fmt.Printf("failed to revoke session after refresh token reuse: %v\n", err)
+	}
+
+	s.auditLogs.InsertOne(ctx, map[string]interface{}{
+		"_id":         primitive.NewObjectID().Hex(),
+		"user_id":     sess.UserID.Hex(),
+		"action":      "auth.refresh_token.reuse",
+		"resource":    "session",
+		"resource_id": sess.ID.Hex(),
+		"details": map[string]interface{}{
+			"token_id":           tokenID,
+			"session_created_at": sess.CreatedAt,
+			"session_ip":         sess.IPAddress,
+			"session_user_agent": sess.UserAgent,
+		},
+		"status":     "failure",
+		"error":      session.ErrRefreshTokenReused.Error(),
+		"created_at": time.Now().UTC(),
+	})
+	return session.ErrRefreshTokenReused
+}
+
+// Logout revokes the session the caller's token belongs to
+func (s *service) Logout(ctx context.Context, userID, sessionID primitive.ObjectID) error {
+	return s.RevokeSession(ctx, userID, sessionID)
+}
+
+// ListSessions returns the user's active sessions
+func (s *service) ListSessions(ctx context.Context, userID primitive.ObjectID) ([]*models.Session, error) {
+	return s.sessionRepo.ListActive(ctx, userID)
+}
+
+// RevokeSession revokes one of the user's sessions
+func (s *service) RevokeSession(ctx context.Context, userID, sessionID primitive.ObjectID) error {
+	sess, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	// Other users' sessions are reported as missing rather than forbidden
+	if sess.UserID != userID {
+		return session.ErrSessionNotFound
+	}
+	return s.sessionRepo.Revoke(ctx, sessionID)
+}
+
+// RevokeAllSessions logs the user out everywhere
+func (s *service) RevokeAllSessions(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	return s.sessionRepo.RevokeAll(ctx, userID)
+}
+
+// VerifyEmail marks the address of the user holding the emailed token as verified
 func (s *service) VerifyEmail(ctx context.Context, token string) error {
-	// TODO: Get user by verification token
-	// For now, this is a placeholder
-	return fmt.Errorf("email verification not yet implemented")
+	if token == "" {
+		return ErrInvalidEmailToken
+	}
+	u, err := s.userRepo.GetByEmailVerificationToken(ctx, hashToken(token))
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			return ErrInvalidEmailToken
+		}
+		return err
+	}
+	if u.EmailVerificationExpiry == nil || u.EmailVerificationExpiry.Before(time.Now()) {
+		return ErrInvalidEmailToken
+	}
+
+	return s.userRepo.VerifyEmail(ctx, u.ID)
+}
+
+// ResendVerification emails a new verification link to an unverified account. Like the
+// password reset request, it succeeds silently for unknown or verified addresses.
+func (s *service) ResendVerification(ctx context.Context, email string) error {
+	u, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil || u.EmailVerified || !u.Active {
+		return nil
+	}
+
+	token := s.generateToken()
+	expiry := time.Now().Add(s.config.EmailVerificationExpiration)
+	if err := s.userRepo.SetEmailVerificationToken(ctx, u.ID, hashToken(token), expiry); err != nil {
+		return fmt.Errorf("failed to set verification token: %w", err)
+	}
+	return s.sendVerificationEmail(ctx, u, token)
 }
 
 func (s *service) RequestPasswordReset(ctx context.Context, email string) error {
 	u, err := s.userRepo.GetByEmail(ctx, email)
-	if err != nil {
+	if err != nil || u.IsServiceAccount() {
 		// Don't reveal if user exists or not
 		return nil
 	}
 
-	// Generate reset token
+	// Generate reset token; only its hash is stored
 	resetToken := s.generateToken()
-	expiry := time.Now().Add(1 * time.Hour)
+	expiry := time.Now().Add(s.config.PasswordResetExpiration)
 
-	if err := s.userRepo.SetPasswordResetToken(ctx, u.ID, resetToken, expiry); err != nil {
+	if err := s.userRepo.SetPasswordResetToken(ctx, u.ID, hashToken(resetToken), expiry); err != nil {
 		return fmt.Errorf("failed to set reset token: %w", err)
 	}
 
-	// TODO: Send password reset email with token
-
-	return nil
+	return s.mail.SendTemplate(ctx, u.Email, mailer.TemplatePasswordReset, map[string]interface{}{
+		"Name":      u.FirstName,
+		"Email":     u.Email,
+		"Token":     resetToken,
+		"Link":      tokenLink(s.config.PasswordResetURL, resetToken),
+		"ExpiresIn": humanDuration(s.config.PasswordResetExpiration),
+	})
 }
 
 func (s *service) ResetPassword(ctx context.Context, token, newPassword string) error {
+	if token == "" {
+		return ErrInvalidEmailToken
+	}
+
 	// Get user by reset token
-	u, err := s.userRepo.GetByPasswordResetToken(ctx, token)
+	u, err := s.userRepo.GetByPasswordResetToken(ctx, hashToken(token))
 	if err != nil {
 		return fmt.Errorf("invalid or expired token: %w", err)
 	}
 
 	// Check if token has expired
-	if u.PasswordResetExpiry != nil && u.PasswordResetExpiry.Before(time.Now()) {
+	if u.PasswordResetExpiry == nil || u.PasswordResetExpiry.Before(time.Now()) {
 		return fmt.Errorf("password reset token has expired")
 	}
 
-	// Hash new password
-	hashedPassword, err := s.hashPassword(newPassword)
-	if err != nil {
-		return fmt.Errorf("failed to hash password: %w", err)
+	// A password the policy rejects leaves the token usable for another try
+	if err := s.passwords.Check(ctx, newPassword, u); err != nil {
+		return err
 	}
 
-	// Update password and clear reset token
-	if err := s.userRepo.UpdatePassword(ctx, u.ID, hashedPassword); err != nil {
-		return fmt.Errorf("failed to update password: %w", err)
+	// Use up the token before changing anything, so that only one reset can succeed with it
+	if err := s.userRepo.ConsumePasswordResetToken(ctx, u.ID, hashToken(token)); err != nil {
+		return fmt.Errorf("invalid or expired token: %w", err)
 	}
 
-	// Clear reset token to prevent reuse
-	if err := s.userRepo.SetPasswordResetToken(ctx, u.ID, "", time.Time{}); err != nil {
-		// Log error but don't fail the password reset
-		fmt.Printf("failed to clear reset token: %v\n", err)
+	// Whoever knew the old password is logged out
+	if err := s.storePassword(ctx, u, newPassword); err != nil {
+		return err
+	}
+
+	// Following the emailed link proves the address belongs to the user
+	if !u.EmailVerified {
+		if err := s.userRepo.VerifyEmail(ctx, u.ID); err != nil {
+			fmt.Printf("failed to mark email verified: %v\n", err)
+		}
 	}
 
 	return nil
@@ -282,24 +635,17 @@ func (s *service) ChangePassword(ctx context.Context, userID primitive.ObjectID,
 	if err != nil {
 		return fmt.Errorf("failed to get user: %w", err)
 	}
+	if err := interactive(u); err != nil {
+		return err
+	}
 
 	// Verify old password
 	if err := s.verifyPassword(u.Password, oldPassword); err != nil {
 		return ErrInvalidCredentials
 	}
 
-	// Hash new password
-	hashedPassword, err := s.hashPassword(newPassword)
-	if err != nil {
-		return fmt.Errorf("failed to hash password: %w", err)
-	}
-
-	// Update password
-	if err := s.userRepo.UpdatePassword(ctx, userID, hashedPassword); err != nil {
-		return fmt.Errorf("failed to update password: %w", err)
-	}
-
-	return nil
+	// Sign out every session, including the current one
+	return s.setPassword(ctx, u, newPassword)
 }
 
 func (s *service) hashPassword(password string) (string, error) {
@@ -321,4 +667,52 @@ func (s *service) generateToken() string {
 		panic(fmt.Sprintf("failed to generate secure random token: %v", err))
 	}
 	return hex.EncodeToString(b)
-}
\ No newline at end of file
+}
+
+// sendVerificationEmail emails the link that confirms the user's address
+func (s *service) sendVerificationEmail(ctx context.Context, u *models.User, token string) error {
+	return s.mail.SendTemplate(ctx, u.Email, mailer.TemplateVerifyEmail, map[string]interface{}{
+		"Name":      u.FirstName,
+		"Email":     u.Email,
+		"Token":     token,
+		"Link":      tokenLink(s.config.VerifyEmailURL, token),
+		"ExpiresIn": humanDuration(s.config.EmailVerificationExpiration),
+	})
+}
+
+// emailVerificationRequired reports whether the system settings block unverified logins
+func (s *service) emailVerificationRequired(ctx context.Context) (bool, error) {
+	systemSettings, err := s.settings.GetSystemSettings(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to load system settings: %w", err)
+	}
+	return systemSettings.RequireEmailVerification, nil
+}
+
+// hashToken returns the stored form of an emailed token. Tokens are random, so a plain
+// SHA-256 is enough to make a leaked database useless for taking over accounts.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// tokenLink appends the token to an emailed URL as the "token" query parameter
+func tokenLink(base, token string) string {
+	separator := "?"
+	if strings.Contains(base, "?") {
+		separator = "&"
+	}
+	return base + separator + "token=" + url.QueryEscape(token)
+}
+
+// humanDuration formats a token lifetime for an email, such as "48 hours" or "15 minutes"
+func humanDuration(d time.Duration) string {
+	unit, n := "minute", int(d.Minutes())
+	if d >= time.Hour && d%time.Hour == 0 {
+		unit, n = "hour", int(d.Hours())
+	}
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
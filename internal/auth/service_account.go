@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/madhouselabs/anybase/pkg/models"
+)
+
+var (
+	ErrServiceAccount      = errors.New("not available to service accounts")
+	ErrReservedEmailDomain = errors.New("this email domain is reserved for service accounts")
+)
+
+// ExchangeClientCredentials issues an access token to a service account for a client ID and
+// secret (the OAuth 2.0 client credentials grant). The token belongs to the credential's
+// session, so it stops working when the credential is revoked. No refresh token is issued;
+// clients exchange their credentials again instead.
+func (s *service) ExchangeClientCredentials(ctx context.Context, clientID, clientSecret, org string) (*AuthResponse, error) {
+	account, cred, err := s.serviceAccounts.Authenticate(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	organization, roles, err := s.resolveOrganization(ctx, account, org)
+	if err != nil {
+		return nil, err
+	}
+	orgID := ""
+	if organization != nil {
+		orgID = organization.ID.Hex()
+	}
+
+	accessToken, err := s.tokenService.GenerateAccessToken(TokenSubject{
+		UserID:      account.ID,
+		Email:       account.Email,
+		Roles:       roles,
+		Permissions: []string{},
+		OrgID:       orgID,
+		SessionID:   cred.SessionID.Hex(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	return &AuthResponse{
+		Organization: organization,
+		AccessToken:  accessToken,
+		ExpiresIn:    int64(s.config.JWTExpiration.Seconds()),
+	}, nil
+}
+
+// interactive rejects service accounts from flows meant for people: passwords, MFA and
+// session management
+func interactive(u *models.User) error {
+	if u.IsServiceAccount() {
+		return ErrServiceAccount
+	}
+	return nil
+}
@@ -5,27 +5,112 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/madhouselabs/anybase/internal/governance"
+	"github.com/madhouselabs/anybase/pkg/models"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Access to collection data and views is decided in this order:
+//
+//  1. A Public rule allows everyone, including anonymous callers and any access key.
+//  2. An access key is allowed only when one of its own permission patterns covers the
+//     action, e.g. collection:<name>:read. Roles and Users in a rule never apply to keys.
+//  3. Users holding *:*:* are allowed.
+//  4. A rule that lists Roles or Users is authoritative for its action: the caller is allowed
+//     when it names the caller or one of the caller's roles. RBAC patterns such as
+//     collection:*:* neither add to nor override it.
+//  5. Otherwise the caller's RBAC permissions decide, e.g. collection:<name>:read.
+//
+// Anonymous callers only ever pass step 1. Row-level conditions are
+// applied on top of whichever step allowed the action. Managing a collection's schema,
+// indexes and views is governed by RBAC alone.
+
 // CanRead checks if a user can read from a collection
 func (s *AdapterService) CanRead(ctx context.Context, userID primitive.ObjectID, collection string) (bool, error) {
-	return s.rbacService.HasPermission(ctx, userID, fmt.Sprintf("collection:%s", collection), "read")
+	return s.checkCollectionAccess(ctx, userID, collection, rowActionRead)
 }
 
 // CanWrite checks if a user can write to a collection
 func (s *AdapterService) CanWrite(ctx context.Context, userID primitive.ObjectID, collection string) (bool, error) {
-	return s.rbacService.HasPermission(ctx, userID, fmt.Sprintf("collection:%s", collection), "write")
+	return s.checkCollectionAccess(ctx, userID, collection, rowActionWrite)
 }
 
 // CanUpdate checks if a user can update a collection
 func (s *AdapterService) CanUpdate(ctx context.Context, userID primitive.ObjectID, collection string) (bool, error) {
-	return s.rbacService.HasPermission(ctx, userID, fmt.Sprintf("collection:%s", collection), "update")
+	return s.checkCollectionAccess(ctx, userID, collection, rowActionUpdate)
 }
 
 // CanDelete checks if a user can delete from a collection
 func (s *AdapterService) CanDelete(ctx context.Context, userID primitive.ObjectID, collection string) (bool, error) {
-	return s.rbacService.HasPermission(ctx, userID, fmt.Sprintf("collection:%s", collection), "delete")
+	return s.checkCollectionAccess(ctx, userID, collection, rowActionDelete)
+}
+
+// checkCollectionAccess applies the collection's permission rule for action, falling back to RBAC
+func (s *AdapterService) checkCollectionAccess(ctx context.Context, userID primitive.ObjectID, name, action string) (bool, error) {
+	var rule models.PermissionRule
+	// Unknown collections fall through to RBAC so callers keep reporting them as not found
+	if collection, err := s.loadCollection(ctx, name); err == nil {
+		rule = *permissionRule(&collection.Permissions, action)
+	}
+	return s.authorize(ctx, userID, rule.Roles, rule.Users, rule.Public, fmt.Sprintf("collection:%s", name), action)
+}
+
+// checkViewAccess applies the view's permissions; without any, reading the view requires
+// read access to its collection
+func (s *AdapterService) checkViewAccess(ctx context.Context, userID primitive.ObjectID, view *models.View) (bool, error) {
+	perms := view.Permissions
+	if _, ok := governance.AccessKeyFromContext(ctx); ok && !perms.Public {
+		// Keys are issued view:<name>:execute to run a view
+		return s.rbacService.HasPermission(ctx, userID, fmt.Sprintf("view:%s", view.Name), "execute")
+	}
+	if perms.Public || len(perms.Roles) > 0 || len(perms.Users) > 0 {
+		return s.authorize(ctx, userID, perms.Roles, perms.Users, perms.Public, fmt.Sprintf("view:%s", view.Name), "read")
+	}
+	return s.checkCollectionAccess(ctx, userID, view.Collection, rowActionRead)
+}
+
+// authorize implements the precedence documented above for a single rule
+func (s *AdapterService) authorize(ctx context.Context, userID primitive.ObjectID, roles []string, users []primitive.ObjectID, public bool, resource, action string) (bool, error) {
+	if public {
+		return true, nil
+	}
+	if _, ok := governance.AccessKeyFromContext(ctx); ok {
+		return s.rbacService.HasPermission(ctx, userID, resource, action)
+	}
+	if userID.IsZero() {
+		return false, nil
+	}
+
+	permissions, err := s.rbacService.GetEffectivePermissions(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if containsString(permissions, "*:*:*") {
+		return true, nil
+	}
+
+	if len(roles) > 0 || len(users) > 0 {
+		for _, id := range users {
+			if id == userID {
+				return true, nil
+			}
+		}
+		if len(roles) == 0 {
+			return false, nil
+		}
+		userRoles, err := s.rbacService.GetUserRoles(ctx, userID)
+		if err != nil {
+			return false, err
+		}
+		for _, role := range userRoles {
+			if containsString(roles, role) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	return s.rbacService.HasPermission(ctx, userID, resource, action)
 }
 
 // logAccess logs access attempts for auditing
@@ -2,6 +2,7 @@ package collection
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -19,14 +20,26 @@ func (s *AdapterService) CreateCollection(ctx context.Context, userID primitive.
 		return fmt.Errorf("invalid collection name: %w", err)
 	}
 
-	// Get user role
-	userRole, err := s.rbacService.GetUserRole(ctx, userID)
+	// Reject computed fields and references that cannot be honoured
+	computed, err := compileComputedFields(collection.Schema)
+	if err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	if err := s.validateReferences(ctx, collection.Name, collection.Schema); err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	if err := validateFieldRules(collection.Permissions.Fields); err != nil {
+		return fmt.Errorf("invalid permissions: %w", err)
+	}
+
+	// Get user roles
+	userRoles, err := s.rbacService.GetUserRoles(ctx, userID)
 	if err != nil {
 		return fmt.Errorf("failed to get user role: %w", err)
 	}
 
 	// Admins and developers can create collections
-	if userRole != "admin" && userRole != "developer" {
+	if !containsString(userRoles, "admin") && !containsString(userRoles, "developer") {
 		// For other users, check if they have permission to create collections
 		hasPermission, err := s.rbacService.HasPermission(ctx, userID, "collections", "create")
 		if err != nil {
@@ -70,6 +83,14 @@ func (s *AdapterService) CreateCollection(ctx context.Context, userID primitive.
 		}
 	}
 
+	// Set up full-text search if configured
+	if collection.TextSearch != nil {
+		if err := s.applyTextSearch(ctx, collection, collection.TextSearch); err != nil {
+			s.db.DropCollection(ctx, dataCollectionName)
+			return fmt.Errorf("failed to configure text search: %w", err)
+		}
+	}
+
 	// Store collection metadata
 	// collectionsCol already defined above
 	
@@ -80,6 +101,8 @@ func (s *AdapterService) CreateCollection(ctx context.Context, userID primitive.
 		"description": collection.Description,
 		"schema":      collection.Schema,
 		"indexes":     collection.Indexes,
+		"text_search": collection.TextSearch,
+		"permissions": collection.Permissions,
 		"settings":    collection.Settings,
 		"created_by":  userID.Hex(),
 		"created_at":  collection.CreatedAt,
@@ -91,6 +114,7 @@ func (s *AdapterService) CreateCollection(ctx context.Context, userID primitive.
 		s.db.DropCollection(ctx, dataCollectionName)
 		return fmt.Errorf("failed to store collection metadata: %w", err)
 	}
+	s.cacheComputedFields(collection.Name, collection.Schema, computed)
 
 	s.logAccess(ctx, userID, collection.Name, nil, "create", "allowed", "collection created")
 	return nil
@@ -98,18 +122,13 @@ func (s *AdapterService) CreateCollection(ctx context.Context, userID primitive.
 
 // GetCollection retrieves a collection with governance checks
 func (s *AdapterService) GetCollection(ctx context.Context, userID primitive.ObjectID, name string) (*models.Collection, error) {
-	// Skip permission checks if access key is already validated
-	validated, _ := ctx.Value("access_key_validated").(bool)
-	if !validated {
-		// Check permissions only for JWT auth
-		hasPermission, err := s.rbacService.HasPermission(ctx, userID, fmt.Sprintf("collection:%s", name), "read")
-		if err != nil {
-			return nil, fmt.Errorf("failed to check permissions: %w", err)
-		}
-		if !hasPermission {
-			s.logAccess(ctx, userID, name, nil, "read", "denied", "insufficient permissions")
-			return nil, fmt.Errorf("insufficient permissions to read collection")
-		}
+	hasPermission, err := s.checkCollectionAccess(ctx, userID, name, rowActionRead)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check permissions: %w", err)
+	}
+	if !hasPermission {
+		s.logAccess(ctx, userID, name, nil, "read", "denied", "insufficient permissions")
+		return nil, fmt.Errorf("insufficient permissions to read collection")
 	}
 
 	collectionsCol := s.db.Collection("collections")
@@ -117,7 +136,7 @@ func (s *AdapterService) GetCollection(ctx context.Context, userID primitive.Obj
 	var collection models.Collection
 	filter := map[string]interface{}{"name": name}
 	
-	err := collectionsCol.FindOne(ctx, filter, &collection)
+	err = collectionsCol.FindOne(ctx, filter, &collection)
 	if err != nil {
 		if err == types.ErrNoDocuments {
 			return nil, fmt.Errorf("collection not found")
@@ -143,8 +162,49 @@ func (s *AdapterService) UpdateCollection(ctx context.Context, userID primitive.
 
 	collectionsCol := s.db.Collection("collections")
 	
+	// Computed fields of a new schema, cached once it is saved
+	var schema *models.CollectionSchema
+	var computed []computedField
+
 	// Add updated_at timestamp
 	if updateSet, ok := updates["$set"].(bson.M); ok {
+		// The text search column is managed through ConfigureTextSearch
+		if _, ok := updateSet["text_search"]; ok {
+			return fmt.Errorf("text search must be configured through the text-search endpoint")
+		}
+
+		// Reject computed fields and references that cannot be honoured
+		if schemaUpdate, hasSchema := updateSet["schema"]; hasSchema && schemaUpdate != nil {
+			schemaBytes, err := json.Marshal(schemaUpdate)
+			if err != nil {
+				return fmt.Errorf("invalid schema: %w", err)
+			}
+			schema = &models.CollectionSchema{}
+			if err := json.Unmarshal(schemaBytes, schema); err != nil {
+				return fmt.Errorf("invalid schema: %w", err)
+			}
+			if computed, err = compileComputedFields(schema); err != nil {
+				return fmt.Errorf("invalid schema: %w", err)
+			}
+			if err := s.validateReferences(ctx, name, schema); err != nil {
+				return fmt.Errorf("invalid schema: %w", err)
+			}
+		}
+
+		// Field rules must name a known access level and masking strategy
+		if permissionsUpdate, hasPermissions := updateSet["permissions"]; hasPermissions && permissionsUpdate != nil {
+			permissionsBytes, err := json.Marshal(permissionsUpdate)
+			if err != nil {
+				return fmt.Errorf("invalid permissions: %w", err)
+			}
+			var permissions models.CollectionPermissions
+			if err := json.Unmarshal(permissionsBytes, &permissions); err != nil {
+				return fmt.Errorf("invalid permissions: %w", err)
+			}
+			if err := validateFieldRules(permissions.Fields); err != nil {
+				return fmt.Errorf("invalid permissions: %w", err)
+			}
+		}
 		updateSet["updated_at"] = time.Now().UTC()
 	} else {
 		updates["$set"] = bson.M{"updated_at": time.Now().UTC()}
@@ -155,6 +215,9 @@ func (s *AdapterService) UpdateCollection(ctx context.Context, userID primitive.
 	if err != nil {
 		return fmt.Errorf("failed to update collection: %w", err)
 	}
+	if schema != nil {
+		s.cacheComputedFields(name, schema, computed)
+	}
 
 	s.logAccess(ctx, userID, name, nil, "update", "allowed", "collection updated")
 	return nil
@@ -188,6 +251,7 @@ func (s *AdapterService) DeleteCollection(ctx context.Context, userID primitive.
 	if err != nil {
 		return fmt.Errorf("failed to delete collection metadata: %w", err)
 	}
+	s.computed.Delete(name)
 
 	s.logAccess(ctx, userID, name, nil, "delete", "allowed", "collection deleted")
 	return nil
@@ -0,0 +1,234 @@
+package collection
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/madhouselabs/anybase/internal/database/adapters/postgres"
+	"github.com/madhouselabs/anybase/internal/expr"
+	"github.com/madhouselabs/anybase/pkg/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// computedField is a compiled computed property
+type computedField struct {
+	name    string
+	program *expr.Program
+}
+
+// compileComputedFields compiles the computed properties of a schema and orders them
+// so that fields are evaluated after the computed fields they read
+func compileComputedFields(schema *models.CollectionSchema) ([]computedField, error) {
+	if schema == nil {
+		return nil, nil
+	}
+
+	programs := map[string]*expr.Program{}
+	for name, prop := range schema.Properties {
+		if prop == nil || prop.Computed == "" {
+			continue
+		}
+		switch prop.ComputeOn {
+		case "", models.ComputeOnAlways, models.ComputeOnInsert:
+		default:
+			return nil, fmt.Errorf("computed field '%s': computeOn must be '%s' or '%s'", name, models.ComputeOnAlways, models.ComputeOnInsert)
+		}
+		program, err := expr.Compile(prop.Computed)
+		if err != nil {
+			return nil, fmt.Errorf("computed field '%s': %w", name, err)
+		}
+		for _, call := range program.Calls() {
+			if call == "sequence" && prop.ComputeOn != models.ComputeOnInsert {
+				return nil, fmt.Errorf("computed field '%s': sequence() requires computeOn '%s'", name, models.ComputeOnInsert)
+			}
+		}
+		programs[name] = program
+	}
+
+	// Topologically sort by dependencies on other computed fields
+	names := make([]string, 0, len(programs))
+	for name := range programs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var ordered []computedField
+	state := map[string]int{} // 1 = visiting, 2 = done
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("computed field '%s' depends on itself", name)
+		case 2:
+			return nil
+		}
+		state[name] = 1
+		for _, dep := range programs[name].Fields() {
+			if _, ok := programs[dep]; ok {
+				if err := visit(dep); err != nil {
+					return err
+				}
+			}
+		}
+		state[name] = 2
+		ordered = append(ordered, computedField{name: name, program: programs[name]})
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return ordered, nil
+}
+
+// compiledComputed is a collection's compiled computed fields, with the signature of the
+// properties they were compiled from
+type compiledComputed struct {
+	signature string
+	fields    []computedField
+}
+
+// computedSignature identifies the computed properties of a schema
+func computedSignature(schema *models.CollectionSchema) string {
+	if schema == nil {
+		return ""
+	}
+	var parts []string
+	for name, prop := range schema.Properties {
+		if prop != nil && prop.Computed != "" {
+			parts = append(parts, name+"\x00"+prop.ComputeOn+"\x00"+prop.Computed)
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "\x00")
+}
+
+// cacheComputedFields stores a collection's compiled computed fields when it is saved
+func (s *AdapterService) cacheComputedFields(collection string, schema *models.CollectionSchema, fields []computedField) {
+	s.computed.Store(collection, &compiledComputed{signature: computedSignature(schema), fields: fields})
+}
+
+// computedFields returns a collection's compiled computed fields. They are compiled when
+// the collection is saved; a collection changed elsewhere, such as by another instance,
+// is compiled again on first use.
+func (s *AdapterService) computedFields(col *models.Collection) ([]computedField, error) {
+	signature := computedSignature(col.Schema)
+	if cached, ok := s.computed.Load(col.Name); ok {
+		if entry := cached.(*compiledComputed); entry.signature == signature {
+			return entry.fields, nil
+		}
+	}
+
+	fields, err := compileComputedFields(col.Schema)
+	if err != nil {
+		return nil, err
+	}
+	s.computed.Store(col.Name, &compiledComputed{signature: signature, fields: fields})
+	return fields, nil
+}
+
+// applyComputedFields evaluates the schema's computed fields into data. Fields computed
+// only on insert are left untouched on updates, where their stored values are preserved.
+func (s *AdapterService) applyComputedFields(ctx context.Context, col *models.Collection, data map[string]interface{}, docID, userID primitive.ObjectID, insert bool) error {
+	fields, err := s.computedFields(col)
+	if err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	var user map[string]interface{}
+	variable := func(name string) (interface{}, error) {
+		switch name {
+		case "user":
+			if user == nil {
+				user = s.computeUser(ctx, userID)
+			}
+			return user, nil
+		case "now":
+			return now.Format(time.RFC3339), nil
+		case "id":
+			return docID.Hex(), nil
+		}
+		return nil, fmt.Errorf("unknown variable $%s", name)
+	}
+
+	for _, field := range fields {
+		if !insert && col.Schema.Properties[field.name].ComputeOn == models.ComputeOnInsert {
+			continue
+		}
+
+		env := &expr.Env{
+			Doc:       data,
+			Variable:  variable,
+			Functions: map[string]expr.Func{"sequence": s.sequenceFunc(ctx, col.Name, field.name)},
+		}
+		value, err := field.program.Eval(env)
+		if err != nil {
+			return fmt.Errorf("failed to compute field '%s': %w", field.name, err)
+		}
+		if value == nil {
+			delete(data, field.name)
+			continue
+		}
+		data[field.name] = value
+	}
+
+	return nil
+}
+
+// computeUser exposes the acting user to expressions as $user
+func (s *AdapterService) computeUser(ctx context.Context, userID primitive.ObjectID) map[string]interface{} {
+	result := map[string]interface{}{"id": userID.Hex()}
+
+	var user models.User
+	if err := s.db.Collection("users").FindOne(ctx, map[string]interface{}{"_id": userID.Hex()}, &user); err != nil {
+		// Access keys have no user record; only the id is available
+		return result
+	}
+
+	result["email"] = user.Email
+	result["first_name"] = user.FirstName
+	result["last_name"] = user.LastName
+	result["role"] = user.Role
+	result["roles"] = user.AllRoles()
+	if user.Metadata != nil {
+		result["metadata"] = user.Metadata
+	}
+	return result
+}
+
+// sequenceFunc returns the sequence() function for a computed field. The counter is
+// named after the field unless the expression passes a name.
+func (s *AdapterService) sequenceFunc(ctx context.Context, collection, field string) expr.Func {
+	return func(args []interface{}) (interface{}, error) {
+		name := field
+		if len(args) > 1 {
+			return nil, fmt.Errorf("sequence() expects at most 1 argument, got %d", len(args))
+		}
+		if len(args) == 1 {
+			counter, ok := args[0].(string)
+			if !ok || counter == "" {
+				return nil, fmt.Errorf("sequence() name must be a non-empty string")
+			}
+			name = counter
+		}
+
+		pgCol, ok := s.db.Collection("data_" + collection).(*postgres.PostgresCollection)
+		if !ok {
+			return nil, fmt.Errorf("sequence() requires PostgreSQL adapter")
+		}
+		value, err := pgCol.NextSequence(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		return float64(value), nil
+	}
+}
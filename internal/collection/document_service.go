@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/madhouselabs/anybase/internal/database/types"
+	"github.com/madhouselabs/anybase/internal/geo"
 	"github.com/madhouselabs/anybase/pkg/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -13,31 +14,65 @@ import (
 
 // InsertDocument inserts a document with governance checks
 func (s *AdapterService) InsertDocument(ctx context.Context, mutation *models.DataMutation) (*models.Document, error) {
-	// Skip permission checks if access key is already validated
-	validated, _ := ctx.Value("access_key_validated").(bool)
-	if !validated {
-		// Check permissions only for JWT auth
-		hasPermission, err := s.rbacService.HasPermission(ctx, mutation.UserID, fmt.Sprintf("collection:%s", mutation.Collection), "write")
-		if err != nil {
-			return nil, fmt.Errorf("failed to check permissions: %w", err)
-		}
-		if !hasPermission {
-			s.logAccess(ctx, mutation.UserID, mutation.Collection, nil, "insert", "denied", "insufficient permissions")
-			return nil, fmt.Errorf("insufficient permissions to insert document")
-		}
+	hasPermission, err := s.checkCollectionAccess(ctx, mutation.UserID, mutation.Collection, rowActionWrite)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check permissions: %w", err)
+	}
+	if !hasPermission {
+		s.logAccess(ctx, mutation.UserID, mutation.Collection, nil, "insert", "denied", "insufficient permissions")
+		return nil, fmt.Errorf("insufficient permissions to insert document")
 	}
 
-	// Get collection to check if it exists
-	col, err := s.GetCollection(ctx, mutation.UserID, mutation.Collection)
+	// Get collection to check if it exists; write access does not imply read access
+	col, err := s.loadCollection(ctx, mutation.Collection)
 	if err != nil {
 		return nil, fmt.Errorf("collection not found: %w", err)
 	}
 
+	// Allocate the ID up front so computed fields can reference it
+	docID := primitive.NewObjectID()
+
+	// Fields the caller's field rules protect cannot be set
+	access := s.fieldAccessFor(ctx, col, mutation.UserID)
+	if mutation.Data, err = access.enforceWrite(mutation.Data, nil, col.Settings.ReadOnlyPolicy); err != nil {
+		s.logAccess(ctx, mutation.UserID, mutation.Collection, nil, "insert", "denied", err.Error())
+		return nil, err
+	}
+
 	// Validate against schema if exists
 	if col.Schema != nil {
+		// Enforce readOnly fields, then fill in defaults and computed fields before validating
+		data, err := s.enforceReadOnly(col, mutation.Data, nil)
+		if err != nil {
+			return nil, fmt.Errorf("schema validation failed: %w", err)
+		}
+		if data == nil {
+			data = map[string]interface{}{}
+		}
+		s.validator.ApplyDefaults(data, col.Schema)
+		if err := s.applyComputedFields(ctx, col, data, docID, mutation.UserID, true); err != nil {
+			return nil, err
+		}
+		mutation.Data = data
+
 		if err := s.ValidateAgainstSchema(ctx, mutation.Collection, mutation.Data); err != nil {
 			return nil, fmt.Errorf("schema validation failed: %w", err)
 		}
+		if err := s.checkReferences(ctx, mutation.UserID, col.Schema, mutation.Data, nil); err != nil {
+			return nil, fmt.Errorf("schema validation failed: %w", err)
+		}
+	}
+
+	// The new document must satisfy the collection's row-level write conditions
+	candidate := make(map[string]interface{}, len(mutation.Data)+2)
+	for k, v := range mutation.Data {
+		candidate[k] = v
+	}
+	candidate["_id"] = docID.Hex()
+	candidate["_created_by"] = mutation.UserID.Hex()
+	if err := s.checkRowConditions(ctx, col, mutation.UserID, rowActionWrite, candidate); err != nil {
+		s.logAccess(ctx, mutation.UserID, mutation.Collection, nil, "insert", "denied", err.Error())
+		return nil, err
 	}
 
 	// Get the data collection
@@ -45,7 +80,7 @@ func (s *AdapterService) InsertDocument(ctx context.Context, mutation *models.Da
 
 	// Create document with metadata
 	doc := &models.Document{
-		ID:         primitive.NewObjectID(),
+		ID:         docID,
 		Collection: mutation.Collection,
 		Data:       mutation.Data,
 		CreatedBy:  mutation.UserID,
@@ -71,60 +106,99 @@ func (s *AdapterService) InsertDocument(ctx context.Context, mutation *models.Da
 		return nil, fmt.Errorf("failed to insert document: %w", err)
 	}
 
+	// Never echo writeOnly or restricted fields back to the caller
+	doc.Data = s.shapeFor(ctx, col, mutation.UserID).apply(doc.Data)
+
 	s.logAccess(ctx, mutation.UserID, mutation.Collection, doc.ID, "insert", "allowed", "document inserted")
 	return doc, nil
 }
 
 // UpdateDocument updates a document with governance checks
 func (s *AdapterService) UpdateDocument(ctx context.Context, mutation *models.DataMutation) error {
-	// Skip permission checks if access key is already validated
-	validated, _ := ctx.Value("access_key_validated").(bool)
-	if !validated {
-		// Check permissions only for JWT auth
-		hasPermission, err := s.rbacService.HasPermission(ctx, mutation.UserID, fmt.Sprintf("collection:%s", mutation.Collection), "update")
-		if err != nil {
-			return fmt.Errorf("failed to check permissions: %w", err)
-		}
-		if !hasPermission {
-			s.logAccess(ctx, mutation.UserID, mutation.Collection, mutation.DocumentID, "update", "denied", "insufficient permissions")
-			return fmt.Errorf("insufficient permissions to update document")
-		}
+	hasPermission, err := s.checkCollectionAccess(ctx, mutation.UserID, mutation.Collection, rowActionUpdate)
+	if err != nil {
+		return fmt.Errorf("failed to check permissions: %w", err)
+	}
+	if !hasPermission {
+		s.logAccess(ctx, mutation.UserID, mutation.Collection, mutation.DocumentID, "update", "denied", "insufficient permissions")
+		return fmt.Errorf("insufficient permissions to update document")
 	}
 
-	// Get collection to check if it exists
-	col, err := s.GetCollection(ctx, mutation.UserID, mutation.Collection)
+	// Get collection to check if it exists; write access does not imply read access
+	col, err := s.loadCollection(ctx, mutation.Collection)
 	if err != nil {
 		return fmt.Errorf("collection not found: %w", err)
 	}
 
+	// Get the data collection
+	dataCol := s.db.Collection("data_" + mutation.Collection)
+
+	// Only documents matching the row-level update conditions can be updated
+	conditions, err := s.rowConditions(ctx, col, mutation.UserID, rowActionUpdate)
+	if err != nil {
+		s.logAccess(ctx, mutation.UserID, mutation.Collection, mutation.DocumentID, "update", "denied", err.Error())
+		return fmt.Errorf("insufficient permissions: %w", err)
+	}
+
+	filter := withConditions(map[string]interface{}{
+		"_id": mutation.DocumentID.Hex(),
+		"_deleted_at": nil,
+	}, conditions)
+
+	// Load the stored document so protected, readOnly and writeOnly fields survive the update
+	access := s.fieldAccessFor(ctx, col, mutation.UserID)
+	var existing models.Document
+	if col.Schema != nil || len(access) > 0 {
+		if err := dataCol.FindOne(ctx, filter, &existing); err != nil {
+			if err == types.ErrNoDocuments {
+				return fmt.Errorf("document not found or already deleted")
+			}
+			return fmt.Errorf("failed to load document: %w", err)
+		}
+	}
+
+	// Fields the caller's field rules protect keep their stored values
+	if mutation.Data, err = access.enforceWrite(mutation.Data, existing.Data, col.Settings.ReadOnlyPolicy); err != nil {
+		s.logAccess(ctx, mutation.UserID, mutation.Collection, mutation.DocumentID, "update", "denied", err.Error())
+		return err
+	}
+
 	// Validate against schema if exists
 	if col.Schema != nil {
+		data, err := s.enforceReadOnly(col, mutation.Data, existing.Data)
+		if err != nil {
+			return fmt.Errorf("schema validation failed: %w", err)
+		}
+		if data == nil {
+			data = map[string]interface{}{}
+		}
+		s.validator.PreserveProtected(data, existing.Data, col.Schema)
+		if err := s.applyComputedFields(ctx, col, data, mutation.DocumentID, mutation.UserID, false); err != nil {
+			return err
+		}
+		mutation.Data = data
+
 		if err := s.ValidateAgainstSchema(ctx, mutation.Collection, mutation.Data); err != nil {
 			return fmt.Errorf("schema validation failed: %w", err)
 		}
+		if err := s.checkReferences(ctx, mutation.UserID, col.Schema, mutation.Data, existing.Data); err != nil {
+			return fmt.Errorf("schema validation failed: %w", err)
+		}
 	}
 
-	// Get the data collection
-	dataCol := s.db.Collection("data_" + mutation.Collection)
-
-	// Update the document
-	filter := map[string]interface{}{
-		"_id": mutation.DocumentID.Hex(),
-		"_deleted_at": nil,
+	// The updated document must still satisfy the conditions, so it cannot be moved out of reach
+	candidate := make(map[string]interface{}, len(mutation.Data)+1)
+	for k, v := range mutation.Data {
+		candidate[k] = v
 	}
-
-	update := map[string]interface{}{
-		"$set": map[string]interface{}{
-			"data":        mutation.Data,
-			"_updated_by": mutation.UserID.Hex(),
-			"_updated_at": time.Now().UTC(),
-		},
-		"$inc": map[string]interface{}{
-			"_version": 1,
-		},
+	candidate["_updated_by"] = mutation.UserID.Hex()
+	if err := s.checkRowConditions(ctx, col, mutation.UserID, rowActionUpdate, candidate); err != nil {
+		s.logAccess(ctx, mutation.UserID, mutation.Collection, mutation.DocumentID, "update", "denied", err.Error())
+		return err
 	}
 
-	result, err := dataCol.UpdateOne(ctx, filter, update)
+	// Replace the document data, keeping fields at the top level so they stay queryable
+	result, err := s.replaceDocumentData(ctx, mutation.Collection, mutation.DocumentID, mutation.UserID, mutation.Data, conditions)
 	if err != nil {
 		return fmt.Errorf("failed to update document: %w", err)
 	}
@@ -137,29 +211,79 @@ func (s *AdapterService) UpdateDocument(ctx context.Context, mutation *models.Da
 	return nil
 }
 
-// DeleteDocument deletes a document with governance checks
+// replaceDocumentData overwrites the stored data of a live document that matches conditions
+func (s *AdapterService) replaceDocumentData(ctx context.Context, collection string, docID, userID primitive.ObjectID, data, conditions map[string]interface{}) (*types.UpdateResult, error) {
+	replacement := make(map[string]interface{}, len(data)+2)
+	for k, v := range data {
+		replacement[k] = v
+	}
+	replacement["_id"] = docID.Hex()
+	replacement["_updated_by"] = userID.Hex()
+
+	filter := withConditions(map[string]interface{}{
+		"_id": docID.Hex(),
+		"_deleted_at": nil,
+	}, conditions)
+	return s.db.Collection("data_"+collection).ReplaceOne(ctx, filter, replacement)
+}
+
+// DeleteDocument deletes a document with governance checks. The delete and the onDelete
+// rules it triggers run in one transaction, so they take effect together or not at all.
 func (s *AdapterService) DeleteDocument(ctx context.Context, mutation *models.DataMutation) error {
-	// Skip permission checks if access key is already validated
-	validated, _ := ctx.Value("access_key_validated").(bool)
-	if !validated {
-		// Check permissions only for JWT auth
-		hasPermission, err := s.rbacService.HasPermission(ctx, mutation.UserID, fmt.Sprintf("collection:%s", mutation.Collection), "delete")
+	err := s.db.RunInTransaction(ctx, func(ctx context.Context, _ types.Transaction) error {
+		return s.deleteDocument(ctx, mutation, map[string]bool{})
+	})
+	if err != nil {
+		// Access log entries written inside the transaction were rolled back with it
+		s.logAccess(ctx, mutation.UserID, mutation.Collection, mutation.DocumentID, "delete", "denied", err.Error())
+	}
+	return err
+}
+
+// deleteDocument deletes a document and applies the onDelete rules of references to it;
+// visited guards against cascade cycles
+func (s *AdapterService) deleteDocument(ctx context.Context, mutation *models.DataMutation, visited map[string]bool) error {
+	key := mutation.Collection + "/" + mutation.DocumentID.Hex()
+	if visited[key] {
+		return nil
+	}
+	visited[key] = true
+
+	hasPermission, err := s.checkCollectionAccess(ctx, mutation.UserID, mutation.Collection, rowActionDelete)
+	if err != nil {
+		return fmt.Errorf("failed to check permissions: %w", err)
+	}
+	if !hasPermission {
+		s.logAccess(ctx, mutation.UserID, mutation.Collection, mutation.DocumentID, "delete", "denied", "insufficient permissions")
+		return fmt.Errorf("insufficient permissions to delete document")
+	}
+
+	// Only documents matching the row-level delete conditions can be deleted; check before
+	// any references are touched
+	filter, err := s.scopeFilterByName(ctx, mutation.Collection, mutation.UserID, rowActionDelete, map[string]interface{}{
+		"_id": mutation.DocumentID.Hex(),
+	})
+	if err != nil {
+		return err
+	}
+	dataCol := s.db.Collection("data_" + mutation.Collection)
+	if _, scoped := filter["$and"]; scoped {
+		count, err := dataCol.CountDocuments(ctx, withConditions(filter, map[string]interface{}{"_deleted_at": nil}))
 		if err != nil {
-			return fmt.Errorf("failed to check permissions: %w", err)
+			return fmt.Errorf("failed to check document: %w", err)
 		}
-		if !hasPermission {
-			s.logAccess(ctx, mutation.UserID, mutation.Collection, mutation.DocumentID, "delete", "denied", "insufficient permissions")
-			return fmt.Errorf("insufficient permissions to delete document")
+		if count == 0 {
+			s.logAccess(ctx, mutation.UserID, mutation.Collection, mutation.DocumentID, "delete", "denied", "row-level conditions not met")
+			return fmt.Errorf("document not found")
 		}
 	}
 
-	// Get the data collection
-	dataCol := s.db.Collection("data_" + mutation.Collection)
+	// Restrict, cascade or clear references pointing at this document
+	if err := s.applyDeleteRules(ctx, mutation, visited); err != nil {
+		return err
+	}
 
 	// Soft delete the document
-	filter := map[string]interface{}{
-		"_id": mutation.DocumentID.Hex(),
-	}
 
 	update := map[string]interface{}{
 		"$set": map[string]interface{}{
@@ -183,18 +307,13 @@ func (s *AdapterService) DeleteDocument(ctx context.Context, mutation *models.Da
 
 // QueryDocuments queries documents with governance checks
 func (s *AdapterService) QueryDocuments(ctx context.Context, query *models.DataQuery) ([]models.Document, error) {
-	// Skip permission checks if access key is already validated
-	validated, _ := ctx.Value("access_key_validated").(bool)
-	if !validated {
-		// Check permissions only for JWT auth
-		hasPermission, err := s.rbacService.HasPermission(ctx, query.UserID, fmt.Sprintf("collection:%s", query.Collection), "read")
-		if err != nil {
-			return nil, fmt.Errorf("failed to check permissions: %w", err)
-		}
-		if !hasPermission {
-			s.logAccess(ctx, query.UserID, query.Collection, nil, "query", "denied", "insufficient permissions")
-			return nil, fmt.Errorf("insufficient permissions to query collection")
-		}
+	hasPermission, err := s.checkCollectionAccess(ctx, query.UserID, query.Collection, rowActionRead)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check permissions: %w", err)
+	}
+	if !hasPermission {
+		s.logAccess(ctx, query.UserID, query.Collection, nil, "query", "denied", "insufficient permissions")
+		return nil, fmt.Errorf("insufficient permissions to query collection")
 	}
 
 	// Get the data collection
@@ -209,6 +328,30 @@ func (s *AdapterService) QueryDocuments(ctx context.Context, query *models.DataQ
 	// Always exclude soft-deleted documents
 	filter["_deleted_at"] = nil
 
+	if err := geo.ValidateFilter(filter); err != nil {
+		return nil, err
+	}
+
+	// Fields the caller cannot see cannot be filtered or sorted on either
+	shape, err := s.readShape(ctx, query.Collection, query.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := shape.fields.checkFilter(filter); err != nil {
+		return nil, err
+	}
+	for field := range query.Sort {
+		if err := shape.fields.checkFields(field); err != nil {
+			return nil, err
+		}
+	}
+
+	// Restrict to the rows the caller may read
+	filter, err = s.scopeFilterByName(ctx, query.Collection, query.UserID, rowActionRead, filter)
+	if err != nil {
+		return nil, err
+	}
+
 	// Build options
 	opts := &types.FindOptions{
 		Sort: query.Sort,
@@ -240,41 +383,45 @@ func (s *AdapterService) QueryDocuments(ctx context.Context, query *models.DataQ
 
 		// Set the collection name
 		document.Collection = query.Collection
+		document.Data = shape.apply(document.Data)
 
 		documents = append(documents, document)
 	}
 
+	// Inline referenced documents
+	if err := s.ExpandReferences(ctx, query.UserID, query.Collection, documents, query.Expand); err != nil {
+		return nil, err
+	}
+
 	s.logAccess(ctx, query.UserID, query.Collection, nil, "query", "allowed", fmt.Sprintf("%d results", len(documents)))
 	return documents, nil
 }
 
 // GetDocument retrieves a single document with governance checks
 func (s *AdapterService) GetDocument(ctx context.Context, userID primitive.ObjectID, collection string, docID primitive.ObjectID) (*models.Document, error) {
-	// Skip permission checks if access key is already validated
-	validated, _ := ctx.Value("access_key_validated").(bool)
-	if !validated {
-		// Check permissions only for JWT auth
-		hasPermission, err := s.rbacService.HasPermission(ctx, userID, fmt.Sprintf("collection:%s", collection), "read")
-		if err != nil {
-			return nil, fmt.Errorf("failed to check permissions: %w", err)
-		}
-		if !hasPermission {
-			s.logAccess(ctx, userID, collection, docID, "read", "denied", "insufficient permissions")
-			return nil, fmt.Errorf("insufficient permissions to read document")
-		}
+	hasPermission, err := s.checkCollectionAccess(ctx, userID, collection, rowActionRead)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check permissions: %w", err)
+	}
+	if !hasPermission {
+		s.logAccess(ctx, userID, collection, docID, "read", "denied", "insufficient permissions")
+		return nil, fmt.Errorf("insufficient permissions to read document")
 	}
 
 	// Get the data collection
 	dataCol := s.db.Collection("data_" + collection)
 
-	// Find the document
-	filter := map[string]interface{}{
+	// Find the document among the rows the caller may read
+	filter, err := s.scopeFilterByName(ctx, collection, userID, rowActionRead, map[string]interface{}{
 		"_id": docID.Hex(),
 		"_deleted_at": nil,
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	var doc bson.M
-	err := dataCol.FindOne(ctx, filter, &doc)
+	err = dataCol.FindOne(ctx, filter, &doc)
 	if err != nil {
 		if err == types.ErrNoDocuments {
 			return nil, fmt.Errorf("document not found")
@@ -295,6 +442,12 @@ func (s *AdapterService) GetDocument(ctx context.Context, userID primitive.Objec
 		document.Data = map[string]interface{}{"value": data}
 	}
 
+	shape, err := s.readShape(ctx, collection, userID)
+	if err != nil {
+		return nil, err
+	}
+	document.Data = shape.apply(document.Data)
+
 	if createdBy, ok := doc["_created_by"].(string); ok {
 		if objID, err := primitive.ObjectIDFromHex(createdBy); err == nil {
 			document.CreatedBy = objID
@@ -321,17 +474,12 @@ func (s *AdapterService) GetDocument(ctx context.Context, userID primitive.Objec
 
 // CountDocuments counts documents in a collection with governance checks
 func (s *AdapterService) CountDocuments(ctx context.Context, userID primitive.ObjectID, collection string, filter map[string]interface{}) (int, error) {
-	// Skip permission checks if access key is already validated
-	validated, _ := ctx.Value("access_key_validated").(bool)
-	if !validated {
-		// Check permissions only for JWT auth
-		hasPermission, err := s.rbacService.HasPermission(ctx, userID, fmt.Sprintf("collection:%s", collection), "read")
-		if err != nil {
-			return 0, fmt.Errorf("failed to check permissions: %w", err)
-		}
-		if !hasPermission {
-			return 0, fmt.Errorf("insufficient permissions to read collection")
-		}
+	hasPermission, err := s.checkCollectionAccess(ctx, userID, collection, rowActionRead)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check permissions: %w", err)
+	}
+	if !hasPermission {
+		return 0, fmt.Errorf("insufficient permissions to read collection")
 	}
 
 	// Get the data collection
@@ -343,6 +491,22 @@ func (s *AdapterService) CountDocuments(ctx context.Context, userID primitive.Ob
 	}
 	filter["_deleted_at"] = nil
 
+	if err := geo.ValidateFilter(filter); err != nil {
+		return 0, err
+	}
+	shape, err := s.readShape(ctx, collection, userID)
+	if err != nil {
+		return 0, err
+	}
+	if err := shape.fields.checkFilter(filter); err != nil {
+		return 0, err
+	}
+
+	filter, err = s.scopeFilterByName(ctx, collection, userID, rowActionRead, filter)
+	if err != nil {
+		return 0, err
+	}
+
 	count, err := dataCol.CountDocuments(ctx, filter)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count documents: %w", err)
@@ -0,0 +1,175 @@
+package collection
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/madhouselabs/anybase/internal/database/adapters/postgres"
+	"github.com/madhouselabs/anybase/internal/validator"
+	"github.com/madhouselabs/anybase/pkg/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultDistinctLimit = 100
+	maxDistinctLimit     = 1000
+	maxFacetsPerQuery    = 20
+	maxFacetSchemaDepth  = 8
+)
+
+// DistinctValues returns the distinct values of a field among matching documents with their counts
+func (s *AdapterService) DistinctValues(ctx context.Context, userID primitive.ObjectID, collectionName string, field string, filter map[string]interface{}, limit int) ([]models.FacetBucket, error) {
+	if err := s.checkAggregateAccess(ctx, userID, collectionName, "distinct"); err != nil {
+		return nil, err
+	}
+
+	collection, err := s.loadCollection(ctx, collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("collection not found: %w", err)
+	}
+	if err := checkFacetField(collection, field); err != nil {
+		return nil, err
+	}
+	access := s.fieldAccessFor(ctx, collection, userID)
+	if err := access.checkFields(field); err != nil {
+		return nil, err
+	}
+	if err := access.checkFilter(filter); err != nil {
+		return nil, err
+	}
+
+	pgCol, ok := s.db.Collection("data_" + collectionName).(*postgres.PostgresCollection)
+	if !ok {
+		return nil, fmt.Errorf("distinct values require PostgreSQL adapter")
+	}
+
+	if limit <= 0 {
+		limit = defaultDistinctLimit
+	}
+	if limit > maxDistinctLimit {
+		limit = maxDistinctLimit
+	}
+
+	scoped, err := s.scopeFilter(ctx, collection, userID, rowActionRead, liveFilter(filter))
+	if err != nil {
+		return nil, err
+	}
+
+	buckets, err := pgCol.Distinct(ctx, field, scoped, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logAccess(ctx, userID, collectionName, nil, "distinct", "allowed", fmt.Sprintf("field %s", field))
+	return buckets, nil
+}
+
+// ComputeFacets computes term, range and date histogram aggregates over matching documents
+func (s *AdapterService) ComputeFacets(ctx context.Context, userID primitive.ObjectID, collectionName string, opts FacetOptions) (map[string]models.FacetResult, error) {
+	if len(opts.Facets) > maxFacetsPerQuery {
+		return nil, fmt.Errorf("too many facets: at most %d are allowed", maxFacetsPerQuery)
+	}
+	if err := s.checkAggregateAccess(ctx, userID, collectionName, "facets"); err != nil {
+		return nil, err
+	}
+
+	collection, err := s.loadCollection(ctx, collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("collection not found: %w", err)
+	}
+	access := s.fieldAccessFor(ctx, collection, userID)
+	if err := access.checkFilter(opts.Filter); err != nil {
+		return nil, err
+	}
+	for name, facet := range opts.Facets {
+		if err := postgres.ValidateFacet(facet); err != nil {
+			return nil, fmt.Errorf("facet '%s': %w", name, err)
+		}
+		if err := checkFacetField(collection, facet.Field); err != nil {
+			return nil, fmt.Errorf("facet '%s': %w", name, err)
+		}
+		if err := access.checkFields(facet.Field); err != nil {
+			return nil, fmt.Errorf("facet '%s': %w", name, err)
+		}
+	}
+	if opts.TextQuery != "" && collection.TextSearch == nil {
+		return nil, fmt.Errorf("text search is not configured for collection '%s'", collectionName)
+	}
+
+	pgCol, ok := s.db.Collection("data_" + collectionName).(*postgres.PostgresCollection)
+	if !ok {
+		return nil, fmt.Errorf("facets require PostgreSQL adapter")
+	}
+
+	scoped, err := s.scopeFilter(ctx, collection, userID, rowActionRead, liveFilter(opts.Filter))
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := pgCol.Facets(ctx, postgres.FacetQuery{
+		Filter:     scoped,
+		Facets:     opts.Facets,
+		TextSearch: collection.TextSearch,
+		Text:       opts.TextQuery,
+		TextMode:   opts.TextMode,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logAccess(ctx, userID, collectionName, nil, "facets", "allowed", fmt.Sprintf("%d facets", len(results)))
+	return results, nil
+}
+
+// checkAggregateAccess applies the collection read permission to aggregate queries
+func (s *AdapterService) checkAggregateAccess(ctx context.Context, userID primitive.ObjectID, collectionName, action string) error {
+	hasPermission, err := s.checkCollectionAccess(ctx, userID, collectionName, rowActionRead)
+	if err != nil {
+		return fmt.Errorf("failed to check permissions: %w", err)
+	}
+	if !hasPermission {
+		s.logAccess(ctx, userID, collectionName, nil, action, "denied", "insufficient permissions")
+		return fmt.Errorf("insufficient permissions to read collection")
+	}
+	return nil
+}
+
+// checkFacetField rejects aggregating over fields that are, or contain, writeOnly values,
+// since bucket keys would return them
+func checkFacetField(collection *models.Collection, field string) error {
+	if collection.Schema == nil {
+		return nil
+	}
+	root := validator.RootSchema(collection.Schema)
+	if containsWriteOnly(schemaProperty(root, field), root, 0) {
+		return fmt.Errorf("field '%s' is or contains writeOnly data", field)
+	}
+	return nil
+}
+
+// containsWriteOnly reports whether a property or any nested property is writeOnly
+func containsWriteOnly(prop, root *models.SchemaProperty, depth int) bool {
+	// Recursive $refs are cut off rather than followed forever
+	if prop == nil || depth > maxFacetSchemaDepth {
+		return false
+	}
+	if prop.WriteOnly {
+		return true
+	}
+	for _, child := range prop.Properties {
+		if containsWriteOnly(validator.Resolve(child, root), root, depth+1) {
+			return true
+		}
+	}
+	return containsWriteOnly(validator.Resolve(prop.Items, root), root, depth+1)
+}
+
+// liveFilter copies a filter and restricts it to documents that are not soft-deleted
+func liveFilter(filter map[string]interface{}) map[string]interface{} {
+	live := map[string]interface{}{}
+	for k, v := range filter {
+		live[k] = v
+	}
+	live["_deleted_at"] = nil
+	return live
+}
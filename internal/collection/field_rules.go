@@ -0,0 +1,331 @@
+package collection
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/madhouselabs/anybase/internal/governance"
+	"github.com/madhouselabs/anybase/internal/masking"
+	"github.com/madhouselabs/anybase/pkg/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fieldAccessRank orders access levels so the most restrictive rule for a field wins
+var fieldAccessRank = map[string]int{
+	models.FieldAccessReadOnly: 1,
+	models.FieldAccessMasked:   2,
+	models.FieldAccessHidden:   3,
+}
+
+// fieldRestriction is the rule that applies to one field for one caller
+type fieldRestriction struct {
+	access string
+	mask   string
+}
+
+// fieldAccess maps field paths to the caller's restrictions. A nil fieldAccess restricts nothing.
+type fieldAccess map[string]fieldRestriction
+
+// validateFieldRules checks the field rules of a collection definition
+func validateFieldRules(rules []models.FieldRule) error {
+	for _, rule := range rules {
+		field := strings.TrimSpace(rule.Field)
+		if field == "" {
+			return fmt.Errorf("field rule is missing a field")
+		}
+		for _, segment := range strings.Split(field, ".") {
+			if segment == "" {
+				return fmt.Errorf("field rule '%s': invalid field path", rule.Field)
+			}
+		}
+		if strings.HasPrefix(field, "_") {
+			return fmt.Errorf("field rule '%s': system fields cannot be restricted", rule.Field)
+		}
+		if _, ok := fieldAccessRank[rule.Access]; !ok {
+			return fmt.Errorf("field rule '%s': access must be '%s', '%s' or '%s'", rule.Field, models.FieldAccessHidden, models.FieldAccessMasked, models.FieldAccessReadOnly)
+		}
+		if rule.Mask != "" {
+			if rule.Access != models.FieldAccessMasked {
+				return fmt.Errorf("field rule '%s': mask only applies to masked fields", rule.Field)
+			}
+			if _, ok := masking.Lookup(rule.Mask); !ok {
+				return fmt.Errorf("field rule '%s': unknown mask '%s', expected one of %s", rule.Field, rule.Mask, strings.Join(masking.Names(), ", "))
+			}
+		}
+		for _, permission := range append(append([]string{}, rule.Permissions...), rule.ExceptPermissions...) {
+			if err := governance.ValidatePermission(permission); err != nil {
+				return fmt.Errorf("field rule '%s': %w", rule.Field, err)
+			}
+		}
+	}
+	return nil
+}
+
+// fieldAccessFor resolves the collection's field rules for the caller. Super admins are never
+// restricted. If the caller's roles cannot be read, every rule applies.
+func (s *AdapterService) fieldAccessFor(ctx context.Context, collection *models.Collection, userID primitive.ObjectID) fieldAccess {
+	if collection == nil || len(collection.Permissions.Fields) == 0 {
+		return nil
+	}
+
+	var roles, permissions []string
+	known := true
+	if key, ok := governance.AccessKeyFromContext(ctx); ok {
+		permissions = key.Permissions
+	} else if !userID.IsZero() {
+		var err error
+		if roles, err = s.rbacService.GetUserRoles(ctx, userID); err != nil {
+			known = false
+		}
+		if permissions, err = s.rbacService.GetEffectivePermissions(ctx, userID); err != nil {
+			known = false
+		}
+	}
+	if known && containsString(permissions, "*:*:*") {
+		return nil
+	}
+
+	access := fieldAccess{}
+	for _, rule := range collection.Permissions.Fields {
+		if known && !fieldRuleApplies(rule, roles, permissions) {
+			continue
+		}
+		field := strings.TrimSpace(rule.Field)
+		if current, ok := access[field]; ok && fieldAccessRank[current.access] >= fieldAccessRank[rule.Access] {
+			continue
+		}
+		access[field] = fieldRestriction{access: rule.Access, mask: rule.Mask}
+	}
+	if len(access) == 0 {
+		return nil
+	}
+	return access
+}
+
+func fieldRuleApplies(rule models.FieldRule, roles, permissions []string) bool {
+	if holdsAnyRole(roles, rule.ExceptRoles) || holdsAnyPermission(permissions, rule.ExceptPermissions) {
+		return false
+	}
+	if len(rule.Roles) == 0 && len(rule.Permissions) == 0 {
+		return true
+	}
+	return holdsAnyRole(roles, rule.Roles) || holdsAnyPermission(permissions, rule.Permissions)
+}
+
+func holdsAnyRole(roles, wanted []string) bool {
+	for _, role := range wanted {
+		if containsString(roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+func holdsAnyPermission(granted, wanted []string) bool {
+	for _, required := range wanted {
+		for _, pattern := range granted {
+			if governance.MatchPermission(pattern, required) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// conceals reports whether the caller may not see the value at path, either because the
+// field itself or a field nested in it is hidden or masked
+func (a fieldAccess) conceals(path string) bool {
+	for field, r := range a {
+		if r.access == models.FieldAccessReadOnly {
+			continue
+		}
+		if path == field || strings.HasPrefix(path, field+".") || strings.HasPrefix(field, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// applyRead hides and masks restricted fields in a copy of data
+func (a fieldAccess) applyRead(data map[string]interface{}) map[string]interface{} {
+	if len(a) == 0 || data == nil {
+		return data
+	}
+
+	shaped := copyMap(data)
+	for field, r := range a {
+		switch r.access {
+		case models.FieldAccessHidden:
+			editPath(shaped, field, false, func(parent map[string]interface{}, key string) {
+				delete(parent, key)
+			})
+		case models.FieldAccessMasked:
+			editPath(shaped, field, false, func(parent map[string]interface{}, key string) {
+				if value, ok := parent[key]; ok {
+					parent[key] = masking.Apply(r.mask, value)
+				}
+			})
+		}
+	}
+
+	// Text search highlights quote the matched field
+	if highlights, ok := shaped["_highlights"].(map[string][]string); ok {
+		visible := make(map[string][]string, len(highlights))
+		for field, fragments := range highlights {
+			if !a.conceals(field) {
+				visible[field] = fragments
+			}
+		}
+		shaped["_highlights"] = visible
+	}
+	return shaped
+}
+
+// enforceWrite keeps restricted fields at their stored values, with existing nil on insert.
+// Fields the caller leaves out, or sends back as they were shown, are restored. Any other
+// change is rejected, or dropped under the strip read-only policy.
+func (a fieldAccess) enforceWrite(data, existing map[string]interface{}, policy string) (map[string]interface{}, error) {
+	if len(a) == 0 {
+		return data, nil
+	}
+
+	result := copyMap(data)
+	if result == nil {
+		result = map[string]interface{}{}
+	}
+
+	fields := make([]string, 0, len(a))
+	for field := range a {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var denied []string
+	for _, field := range fields {
+		r := a[field]
+		stored, hasStored := lookupPath(existing, field)
+		sent, hasSent := lookupPath(result, field)
+
+		if hasSent {
+			unchanged := hasStored && (jsonEqual(sent, stored) ||
+				(r.access == models.FieldAccessMasked && jsonEqual(sent, masking.Apply(r.mask, stored))))
+			if !unchanged && policy != models.ReadOnlyPolicyStrip {
+				denied = append(denied, field)
+				continue
+			}
+		}
+
+		if hasStored {
+			editPath(result, field, true, func(parent map[string]interface{}, key string) {
+				parent[key] = stored
+			})
+		} else if hasSent {
+			editPath(result, field, false, func(parent map[string]interface{}, key string) {
+				delete(parent, key)
+			})
+		}
+	}
+
+	if len(denied) > 0 {
+		return nil, fmt.Errorf("insufficient permissions to write fields: %s", strings.Join(denied, ", "))
+	}
+	return result, nil
+}
+
+// checkFilter rejects filters that reference fields the caller cannot see, which would
+// otherwise reveal their values one query at a time
+func (a fieldAccess) checkFilter(filter map[string]interface{}) error {
+	if len(a) == 0 {
+		return nil
+	}
+	for key, value := range filter {
+		if strings.HasPrefix(key, "$") {
+			if err := a.checkFilterValue(value); err != nil {
+				return err
+			}
+			continue
+		}
+		if a.conceals(key) {
+			return fmt.Errorf("insufficient permissions to query field '%s'", key)
+		}
+	}
+	return nil
+}
+
+func (a fieldAccess) checkFilterValue(value interface{}) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return a.checkFilter(v)
+	case bson.M:
+		return a.checkFilter(v)
+	case []interface{}:
+		for _, item := range v {
+			if err := a.checkFilterValue(item); err != nil {
+				return err
+			}
+		}
+	case bson.A:
+		return a.checkFilterValue([]interface{}(v))
+	}
+	return nil
+}
+
+// checkFields rejects sorting or aggregating on fields the caller cannot see
+func (a fieldAccess) checkFields(fields ...string) error {
+	for _, field := range fields {
+		if a.conceals(field) {
+			return fmt.Errorf("insufficient permissions to use field '%s'", field)
+		}
+	}
+	return nil
+}
+
+// lookupPath returns the value at a dotted path of nested objects
+func lookupPath(data map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = data
+	for _, segment := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[segment]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// editPath calls edit with the object holding the last segment of path. Nested objects on
+// the way are copied so the caller's document is left untouched; missing ones are created
+// when create is set.
+func editPath(data map[string]interface{}, path string, create bool, edit func(parent map[string]interface{}, key string)) {
+	segments := strings.Split(path, ".")
+	parent := data
+	for _, segment := range segments[:len(segments)-1] {
+		child, ok := parent[segment].(map[string]interface{})
+		if !ok {
+			if !create || parent[segment] != nil {
+				return
+			}
+			child = map[string]interface{}{}
+		}
+		child = copyMap(child)
+		parent[segment] = child
+		parent = child
+	}
+	edit(parent, segments[len(segments)-1])
+}
+
+func copyMap(data map[string]interface{}) map[string]interface{} {
+	if data == nil {
+		return nil
+	}
+	copied := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		copied[k] = v
+	}
+	return copied
+}
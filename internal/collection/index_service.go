@@ -27,19 +27,8 @@ func (s *AdapterService) CreateIndex(ctx context.Context, collectionName string,
 	// Convert to types.Index
 	index := types.Index{
 		Name: indexName,
-		Keys: make(map[string]int),
-	}
-
-	// Convert keys
-	for field, order := range keys {
-		if orderInt, ok := order.(int); ok {
-			index.Keys[field] = orderInt
-		} else if orderFloat, ok := order.(float64); ok {
-			index.Keys[field] = int(orderFloat)
-		} else {
-			index.Keys[field] = 1 // Default to ascending
-		}
 	}
+	index.Keys, index.Type = indexKeys(keys)
 
 	// Parse options
 	if options != nil {
@@ -49,12 +38,19 @@ func (s *AdapterService) CreateIndex(ctx context.Context, collectionName string,
 		if sparse, ok := options["sparse"].(bool); ok {
 			index.Sparse = sparse
 		}
+		if indexType, ok := options["type"].(string); ok {
+			index.Type = indexType
+		}
 		if ttl, ok := options["expireAfterSeconds"].(int); ok {
 			ttlDuration := time.Duration(ttl) * time.Second
 			index.TTL = &ttlDuration
 		}
 	}
 
+	if index.Type != "" && index.Type != types.IndexTypeGeo {
+		return fmt.Errorf("unsupported index type '%s'", index.Type)
+	}
+
 	// Create the index
 	if err := dataCol.CreateIndex(ctx, index); err != nil {
 		return fmt.Errorf("failed to create index: %w", err)
@@ -84,21 +80,10 @@ func (s *AdapterService) createIndexes(ctx context.Context, collection *models.C
 		// Convert index definition to types.Index
 		idx := types.Index{
 			Name:   index.Name,
-			Keys:   make(map[string]int),
 			Unique: index.Unique,
 			Sparse: index.Sparse,
 		}
-		
-		// Convert keys
-		for field, order := range index.Fields {
-			if orderInt, ok := order.(int); ok {
-				idx.Keys[field] = orderInt
-			} else if orderFloat, ok := order.(float64); ok {
-				idx.Keys[field] = int(orderFloat)
-			} else {
-				idx.Keys[field] = 1 // Default to ascending
-			}
-		}
+		idx.Keys, idx.Type = indexKeys(index.Fields)
 		
 		if err := dataCol.CreateIndex(ctx, idx); err != nil {
 			return fmt.Errorf("failed to create index %s: %w", index.Name, err)
@@ -106,4 +91,24 @@ func (s *AdapterService) createIndexes(ctx context.Context, collection *models.C
 	}
 	
 	return nil
-}
\ No newline at end of file
+}
+
+// indexKeys converts index key directions; a "2dsphere" or "geo" key requests a
+// geospatial index, as in MongoDB
+func indexKeys(fields map[string]interface{}) (map[string]int, string) {
+	keys := make(map[string]int)
+	indexType := ""
+	for field, order := range fields {
+		if kind, ok := order.(string); ok && (kind == "2dsphere" || kind == types.IndexTypeGeo) {
+			keys[field] = 1
+			indexType = types.IndexTypeGeo
+		} else if orderInt, ok := order.(int); ok {
+			keys[field] = orderInt
+		} else if orderFloat, ok := order.(float64); ok {
+			keys[field] = int(orderFloat)
+		} else {
+			keys[field] = 1 // Default to ascending
+		}
+	}
+	return keys, indexType
+}
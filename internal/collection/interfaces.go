@@ -37,6 +37,7 @@ type Service interface {
 	UpdateDocument(ctx context.Context, mutation *models.DataMutation) error
 	DeleteDocument(ctx context.Context, mutation *models.DataMutation) error
 	CountDocuments(ctx context.Context, userID primitive.ObjectID, collection string, filter map[string]interface{}) (int, error)
+	ExpandReferences(ctx context.Context, userID primitive.ObjectID, collection string, docs []models.Document, paths []string) error
 	
 	// Schema validation
 	ValidateAgainstSchema(ctx context.Context, collectionName string, document interface{}) error
@@ -50,6 +51,14 @@ type Service interface {
 	VectorSearch(ctx context.Context, userID primitive.ObjectID, collectionName string, opts VectorSearchOptions) ([]bson.M, error)
 	HybridSearch(ctx context.Context, userID primitive.ObjectID, collectionName string, opts HybridSearchOptions) ([]bson.M, error)
 	
+	// Full-text search
+	ConfigureTextSearch(ctx context.Context, userID primitive.ObjectID, collectionName string, config *models.TextSearchConfig) error
+	TextSearch(ctx context.Context, userID primitive.ObjectID, collectionName string, opts TextSearchOptions) ([]bson.M, error)
+	
+	// Aggregations
+	DistinctValues(ctx context.Context, userID primitive.ObjectID, collectionName string, field string, filter map[string]interface{}, limit int) ([]models.FacetBucket, error)
+	ComputeFacets(ctx context.Context, userID primitive.ObjectID, collectionName string, opts FacetOptions) (map[string]models.FacetResult, error)
+	
 	// Permissions
 	CanRead(ctx context.Context, userID primitive.ObjectID, collection string) (bool, error)
 	CanWrite(ctx context.Context, userID primitive.ObjectID, collection string) (bool, error)
@@ -94,4 +103,23 @@ type HybridSearchOptions struct {
 	Alpha        float32                `json:"alpha"` // Weight between text (0) and vector (1) search
 	Filter       map[string]interface{} `json:"filter,omitempty"`
 	IncludeScore bool                   `json:"include_score,omitempty"`
-}
\ No newline at end of file
+}
+
+// TextSearchOptions defines options for full-text search
+type TextSearchOptions struct {
+	Query     string                 `json:"query"`
+	Mode      string                 `json:"mode,omitempty"` // websearch (default), plain, phrase, prefix
+	Filter    map[string]interface{} `json:"filter,omitempty"`
+	Limit     int                    `json:"limit,omitempty"`
+	Skip      int                    `json:"skip,omitempty"`
+	Highlight bool                   `json:"highlight,omitempty"` // Include ts_headline snippets per field
+	Facets    map[string]models.FacetRequest `json:"facets,omitempty"` // Aggregates over all matches, not just the returned page
+}
+
+// FacetOptions defines the documents and aggregates of a facet computation
+type FacetOptions struct {
+	Filter    map[string]interface{}
+	Facets    map[string]models.FacetRequest
+	TextQuery string // Restrict to documents matching this full-text query
+	TextMode  string
+}
@@ -0,0 +1,651 @@
+package collection
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/madhouselabs/anybase/internal/validator"
+	"github.com/madhouselabs/anybase/pkg/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// maxExpandPaths bounds the number of expand paths per request
+	maxExpandPaths = 10
+	// maxExpandDepth bounds the number of segments in one expand path
+	maxExpandDepth = 4
+	// maxSchemaWalkDepth bounds recursion when checking reference declarations
+	maxSchemaWalkDepth = 16
+)
+
+// documentReference is a reference value found in a document
+type documentReference struct {
+	path  string
+	ref   *models.PropertyRef
+	value interface{}
+}
+
+// incomingReference is a top-level property of another collection that points at a collection
+type incomingReference struct {
+	collection string
+	field      string
+	ref        *models.PropertyRef
+	list       bool // The property is an array of references
+}
+
+// ExpandReferences replaces reference values in docs with the referenced documents.
+// Paths are dotted, e.g. "author" or "comments.user"; each level is fetched in one query
+// per target collection and requires read permission on that collection.
+func (s *AdapterService) ExpandReferences(ctx context.Context, userID primitive.ObjectID, collection string, docs []models.Document, paths []string) error {
+	if len(paths) == 0 || len(docs) == 0 {
+		return nil
+	}
+	if len(paths) > maxExpandPaths {
+		return fmt.Errorf("at most %d expand paths are allowed", maxExpandPaths)
+	}
+
+	schema := s.readSchema(ctx, collection)
+	if schema == nil {
+		return fmt.Errorf("collection '%s' has no schema to expand references from", collection)
+	}
+	root := validator.RootSchema(schema)
+
+	nodes := make([]map[string]interface{}, 0, len(docs))
+	for i := range docs {
+		if docs[i].Data != nil {
+			nodes = append(nodes, docs[i].Data)
+		}
+	}
+
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		segments := strings.Split(path, ".")
+		if len(segments) > maxExpandDepth {
+			return fmt.Errorf("cannot expand '%s': paths are limited to %d levels", path, maxExpandDepth)
+		}
+		if err := s.expandPath(ctx, userID, nodes, root, root, segments, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expandPath expands the first segment of a path in every node, then continues with the rest
+func (s *AdapterService) expandPath(ctx context.Context, userID primitive.ObjectID, nodes []map[string]interface{}, parent, root *models.SchemaProperty, segments []string, path string) error {
+	if len(nodes) == 0 || parent == nil {
+		return nil
+	}
+	name := segments[0]
+	prop := validator.Resolve(parent.Properties[name], root)
+	if prop == nil {
+		return fmt.Errorf("cannot expand '%s': unknown field '%s'", path, name)
+	}
+
+	// The property is a reference, or an array of references
+	ref, list := prop.Reference, false
+	if ref == nil && prop.Items != nil {
+		if items := validator.Resolve(prop.Items, root); items != nil && items.Reference != nil {
+			ref, list = items.Reference, true
+		}
+	}
+
+	if ref == nil {
+		if len(segments) == 1 {
+			return fmt.Errorf("cannot expand '%s': '%s' is not a reference", path, name)
+		}
+
+		// Descend into nested objects and arrays of objects
+		child := prop
+		if prop.Items != nil {
+			child = validator.Resolve(prop.Items, root)
+		}
+		var children []map[string]interface{}
+		for _, node := range nodes {
+			switch value := node[name].(type) {
+			case map[string]interface{}:
+				children = append(children, value)
+			case []interface{}:
+				for _, item := range value {
+					if m, ok := item.(map[string]interface{}); ok {
+						children = append(children, m)
+					}
+				}
+			}
+		}
+		return s.expandPath(ctx, userID, children, child, root, segments[1:], path)
+	}
+
+	if !s.canReadReferenced(ctx, userID, ref.Collection) {
+		s.logAccess(ctx, userID, ref.Collection, nil, "expand", "denied", "insufficient permissions")
+		return fmt.Errorf("insufficient permissions to expand '%s'", path)
+	}
+
+	// Collect every key first so the level is resolved with a single query
+	var keys []string
+	for _, node := range nodes {
+		for _, value := range referenceValues(node[name], list) {
+			if _, expanded := value.(map[string]interface{}); !expanded && value != nil {
+				keys = append(keys, referenceKey(value))
+			}
+		}
+	}
+	// Expanded documents are limited to the rows the caller may read
+	scope, err := s.scopeFilterByName(ctx, ref.Collection, userID, rowActionRead, map[string]interface{}{})
+	if err != nil {
+		return fmt.Errorf("failed to expand '%s': %w", path, err)
+	}
+	shape, err := s.readShape(ctx, ref.Collection, userID)
+	if err != nil {
+		return fmt.Errorf("failed to expand '%s': %w", path, err)
+	}
+	fetched, err := s.fetchReferenced(ctx, ref, keys, scope, shape)
+	if err != nil {
+		return fmt.Errorf("failed to expand '%s': %w", path, err)
+	}
+
+	var next []map[string]interface{}
+	resolve := func(value interface{}) interface{} {
+		if m, ok := value.(map[string]interface{}); ok {
+			// Already expanded by an earlier path
+			next = append(next, m)
+			return m
+		}
+		if value == nil {
+			return nil
+		}
+		if doc, ok := fetched[referenceKey(value)]; ok {
+			next = append(next, doc)
+			return doc
+		}
+		return value
+	}
+	for _, node := range nodes {
+		value, ok := node[name]
+		if !ok {
+			continue
+		}
+		if !list {
+			node[name] = resolve(value)
+			continue
+		}
+		if items, ok := value.([]interface{}); ok {
+			expanded := make([]interface{}, len(items))
+			for i, item := range items {
+				expanded[i] = resolve(item)
+			}
+			node[name] = expanded
+		}
+	}
+
+	if len(segments) == 1 {
+		return nil
+	}
+	targetSchema := s.readSchema(ctx, ref.Collection)
+	if targetSchema == nil {
+		return fmt.Errorf("cannot expand '%s': collection '%s' has no schema", path, ref.Collection)
+	}
+	targetRoot := validator.RootSchema(targetSchema)
+	return s.expandPath(ctx, userID, next, targetRoot, targetRoot, segments[1:], path)
+}
+
+// canReadReferenced checks read permission on a referenced collection
+func (s *AdapterService) canReadReferenced(ctx context.Context, userID primitive.ObjectID, collection string) bool {
+	allowed, err := s.CanRead(ctx, userID, collection)
+	return err == nil && allowed
+}
+
+// fetchReferenced loads the documents of ref's collection within scope whose reference field matches
+// one of keys. Results are keyed by the matched value and shaped for the reader; reference checks,
+// which return nothing to the caller, pass a nil shape.
+func (s *AdapterService) fetchReferenced(ctx context.Context, ref *models.PropertyRef, keys []string, scope map[string]interface{}, shape *documentShape) (map[string]map[string]interface{}, error) {
+	result := map[string]map[string]interface{}{}
+	keys = uniqueStrings(keys)
+	if len(keys) == 0 {
+		return result, nil
+	}
+
+	field := referenceField(ref)
+	dataCol := s.db.Collection("data_" + ref.Collection)
+	filter := make(map[string]interface{}, len(scope)+2)
+	for k, v := range scope {
+		filter[k] = v
+	}
+	filter[field] = map[string]interface{}{"$in": keys}
+	filter["_deleted_at"] = nil
+	cursor, err := dataCol.Find(ctx, filter, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc models.Document
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+
+		key := doc.ID.Hex()
+		if field != "_id" {
+			key = referenceKey(doc.Data[field])
+		}
+		if _, seen := result[key]; seen {
+			continue
+		}
+
+		data := shape.apply(doc.Data)
+		if data == nil {
+			data = map[string]interface{}{}
+		}
+		data["_id"] = doc.ID.Hex()
+		result[key] = data
+	}
+	return result, nil
+}
+
+// checkReferences verifies that every reference in data points at a document the writer can
+// read, so the check cannot be used to probe rows hidden from them. On updates, references
+// already present in the stored document are not re-checked.
+func (s *AdapterService) checkReferences(ctx context.Context, userID primitive.ObjectID, schema *models.CollectionSchema, data, existing map[string]interface{}) error {
+	if schema == nil {
+		return nil
+	}
+	root := validator.RootSchema(schema)
+
+	var refs []documentReference
+	collectReferences(data, root, root, "", &refs)
+	if len(refs) == 0 {
+		return nil
+	}
+
+	unchanged := map[string]bool{}
+	if existing != nil {
+		var old []documentReference
+		collectReferences(existing, root, root, "", &old)
+		for _, r := range old {
+			unchanged[referenceGroup(r.ref)+"|"+referenceKey(r.value)] = true
+		}
+	}
+
+	// Group the values to check by target so each target is queried once
+	groups := map[string][]documentReference{}
+	var order []string
+	for _, r := range refs {
+		group := referenceGroup(r.ref)
+		if unchanged[group+"|"+referenceKey(r.value)] {
+			continue
+		}
+		if _, ok := groups[group]; !ok {
+			order = append(order, group)
+		}
+		groups[group] = append(groups[group], r)
+	}
+
+	var errs validator.ValidationErrors
+	for _, group := range order {
+		members := groups[group]
+		keys := make([]string, len(members))
+		for i, r := range members {
+			keys[i] = referenceKey(r.value)
+		}
+		target := members[0].ref.Collection
+		if !s.canReadReferenced(ctx, userID, target) {
+			for _, r := range members {
+				errs = append(errs, validator.ValidationError{
+					Path:    r.path,
+					Keyword: "ref",
+					Message: fmt.Sprintf("references '%s', which you cannot read", target),
+				})
+			}
+			continue
+		}
+		scope, err := s.scopeFilterByName(ctx, target, userID, rowActionRead, map[string]interface{}{})
+		if err != nil {
+			return fmt.Errorf("failed to check references: %w", err)
+		}
+		found, err := s.fetchReferenced(ctx, members[0].ref, keys, scope, nil)
+		if err != nil {
+			return fmt.Errorf("failed to check references: %w", err)
+		}
+		for _, r := range members {
+			if _, ok := found[referenceKey(r.value)]; !ok {
+				errs = append(errs, validator.ValidationError{
+					Path:    r.path,
+					Keyword: "ref",
+					Message: fmt.Sprintf("references a missing document in '%s'", r.ref.Collection),
+				})
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		sort.Slice(errs, func(i, j int) bool { return errs[i].Path < errs[j].Path })
+		return errs
+	}
+	return nil
+}
+
+// collectReferences finds the reference values in a document, with their JSON pointers
+func collectReferences(value interface{}, prop, root *models.SchemaProperty, path string, out *[]documentReference) {
+	prop = validator.Resolve(prop, root)
+	if prop == nil || value == nil {
+		return
+	}
+	if prop.Reference != nil {
+		if _, isObject := value.(map[string]interface{}); !isObject {
+			*out = append(*out, documentReference{path: path, ref: prop.Reference, value: value})
+		}
+		return
+	}
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		for key, item := range val {
+			if sub := prop.Properties[key]; sub != nil {
+				collectReferences(item, sub, root, path+"/"+key, out)
+			}
+		}
+	case []interface{}:
+		if prop.Items == nil {
+			return
+		}
+		for i, item := range val {
+			collectReferences(item, prop.Items, root, path+"/"+strconv.Itoa(i), out)
+		}
+	}
+}
+
+// validateReferences checks the reference declarations of a collection schema
+func (s *AdapterService) validateReferences(ctx context.Context, collection string, schema *models.CollectionSchema) error {
+	if schema == nil {
+		return nil
+	}
+
+	var walk func(prop *models.SchemaProperty, path string, topLevel bool, depth int) error
+	walk = func(prop *models.SchemaProperty, path string, topLevel bool, depth int) error {
+		if prop == nil || depth > maxSchemaWalkDepth {
+			return nil
+		}
+		if ref := prop.Reference; ref != nil {
+			if ref.Collection == "" {
+				return fmt.Errorf("reference '%s': collection is required", path)
+			}
+			if ref.Collection != collection {
+				if _, err := s.loadCollection(ctx, ref.Collection); err != nil {
+					return fmt.Errorf("reference '%s': collection '%s' not found", path, ref.Collection)
+				}
+			}
+			switch ref.OnDelete {
+			case "", models.OnDeleteRestrict, models.OnDeleteCascade, models.OnDeleteSetNull:
+			default:
+				return fmt.Errorf("reference '%s': onDelete must be '%s', '%s' or '%s'", path, models.OnDeleteRestrict, models.OnDeleteCascade, models.OnDeleteSetNull)
+			}
+			if ref.OnDelete != "" && !topLevel {
+				return fmt.Errorf("reference '%s': onDelete is only supported on top-level properties", path)
+			}
+		}
+		if err := walk(prop.Items, path+"[]", topLevel, depth+1); err != nil {
+			return err
+		}
+		for name, sub := range prop.Properties {
+			if err := walk(sub, path+"."+name, false, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	root := validator.RootSchema(schema)
+	for name, prop := range schema.Properties {
+		if err := walk(prop, name, true, 0); err != nil {
+			return err
+		}
+
+		// Clearing a single reference stores null, which the field must accept
+		resolved := validator.Resolve(prop, root)
+		if resolved == nil || resolved.Reference == nil || resolved.Reference.OnDelete != models.OnDeleteSetNull {
+			continue
+		}
+		for _, required := range schema.Required {
+			if required == name {
+				return fmt.Errorf("reference '%s': onDelete '%s' cannot be used on a required field", name, models.OnDeleteSetNull)
+			}
+		}
+		if !s.validator.AcceptsNull(prop, root) {
+			return fmt.Errorf("reference '%s': onDelete '%s' requires the field to accept null", name, models.OnDeleteSetNull)
+		}
+	}
+	return nil
+}
+
+// incomingReferences lists the top-level properties of all collections that reference
+// collection and declare an onDelete behavior
+func (s *AdapterService) incomingReferences(ctx context.Context, collection string) ([]incomingReference, error) {
+	cursor, err := s.db.Collection("collections").Find(ctx, map[string]interface{}{}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var incoming []incomingReference
+	for cursor.Next(ctx) {
+		var col models.Collection
+		if err := cursor.Decode(&col); err != nil || col.Schema == nil {
+			continue
+		}
+		root := validator.RootSchema(col.Schema)
+		for name, prop := range col.Schema.Properties {
+			prop = validator.Resolve(prop, root)
+			if prop == nil {
+				continue
+			}
+			ref, list := prop.Reference, false
+			if ref == nil && prop.Items != nil {
+				if items := validator.Resolve(prop.Items, root); items != nil {
+					ref, list = items.Reference, true
+				}
+			}
+			if ref != nil && ref.Collection == collection && ref.OnDelete != "" {
+				incoming = append(incoming, incomingReference{collection: col.Name, field: name, ref: ref, list: list})
+			}
+		}
+	}
+
+	// Deterministic order: restrict rules are checked before anything is modified
+	sort.Slice(incoming, func(i, j int) bool {
+		if incoming[i].collection != incoming[j].collection {
+			return incoming[i].collection < incoming[j].collection
+		}
+		return incoming[i].field < incoming[j].field
+	})
+	return incoming, nil
+}
+
+// applyDeleteRules enforces the onDelete behavior of references pointing at the document being deleted
+func (s *AdapterService) applyDeleteRules(ctx context.Context, mutation *models.DataMutation, visited map[string]bool) error {
+	incoming, err := s.incomingReferences(ctx, mutation.Collection)
+	if err != nil {
+		return err
+	}
+	if len(incoming) == 0 {
+		return nil
+	}
+
+	// Resolve the value each reference stores for this document
+	var stored *models.Document
+	keyFor := func(ref *models.PropertyRef) (interface{}, error) {
+		field := referenceField(ref)
+		if field == "_id" {
+			return mutation.DocumentID.Hex(), nil
+		}
+		if stored == nil {
+			var doc models.Document
+			filter := map[string]interface{}{"_id": mutation.DocumentID.Hex(), "_deleted_at": nil}
+			if err := s.db.Collection("data_"+mutation.Collection).FindOne(ctx, filter, &doc); err != nil {
+				return nil, fmt.Errorf("failed to load document: %w", err)
+			}
+			stored = &doc
+		}
+		return stored.Data[field], nil
+	}
+	filterFor := func(in incomingReference) (map[string]interface{}, error) {
+		key, err := keyFor(in.ref)
+		if err != nil || key == nil {
+			return nil, err
+		}
+		filter := map[string]interface{}{"_deleted_at": nil}
+		if in.list {
+			// JSONB containment matches arrays holding the value
+			filter[in.field] = []interface{}{key}
+		} else {
+			filter[in.field] = key
+		}
+		return filter, nil
+	}
+
+	// Check every restrict rule before modifying anything
+	for _, in := range incoming {
+		if in.ref.OnDelete != models.OnDeleteRestrict {
+			continue
+		}
+		filter, err := filterFor(in)
+		if err != nil {
+			return err
+		}
+		if filter == nil {
+			continue
+		}
+		count, err := s.db.Collection("data_"+in.collection).CountDocuments(ctx, filter)
+		if err != nil {
+			return fmt.Errorf("failed to check references: %w", err)
+		}
+		if count > 0 {
+			s.logAccess(ctx, mutation.UserID, mutation.Collection, mutation.DocumentID, "delete", "denied", fmt.Sprintf("referenced from %s.%s", in.collection, in.field))
+			return fmt.Errorf("cannot delete document: it is referenced by %d document(s) in '%s'", count, in.collection)
+		}
+	}
+
+	for _, in := range incoming {
+		if in.ref.OnDelete == models.OnDeleteRestrict {
+			continue
+		}
+		filter, err := filterFor(in)
+		if err != nil {
+			return err
+		}
+		if filter == nil {
+			continue
+		}
+		key, _ := keyFor(in.ref)
+
+		dataCol := s.db.Collection("data_" + in.collection)
+		cursor, err := dataCol.Find(ctx, filter, nil)
+		if err != nil {
+			return fmt.Errorf("failed to find referencing documents: %w", err)
+		}
+		var referencing []models.Document
+		for cursor.Next(ctx) {
+			var doc models.Document
+			if err := cursor.Decode(&doc); err == nil {
+				referencing = append(referencing, doc)
+			}
+		}
+		cursor.Close(ctx)
+
+		switch in.ref.OnDelete {
+		case models.OnDeleteCascade:
+			for _, doc := range referencing {
+				child := &models.DataMutation{
+					Collection: in.collection,
+					Operation:  "delete",
+					DocumentID: doc.ID,
+					UserID:     mutation.UserID,
+				}
+				if err := s.deleteDocument(ctx, child, visited); err != nil {
+					return fmt.Errorf("failed to cascade delete to '%s': %w", in.collection, err)
+				}
+			}
+
+		case models.OnDeleteSetNull:
+			// Cleared through the normal update path, so the caller's update permission, row
+			// conditions, field rules and the schema all apply to the referencing document
+			for _, doc := range referencing {
+				data := doc.Data
+				if in.list {
+					items, _ := data[in.field].([]interface{})
+					kept := make([]interface{}, 0, len(items))
+					for _, item := range items {
+						if referenceKey(item) != referenceKey(key) {
+							kept = append(kept, item)
+						}
+					}
+					data[in.field] = kept
+				} else {
+					data[in.field] = nil
+				}
+				child := &models.DataMutation{
+					Collection: in.collection,
+					Operation:  "update",
+					DocumentID: doc.ID,
+					Data:       data,
+					UserID:     mutation.UserID,
+				}
+				if err := s.UpdateDocument(ctx, child); err != nil {
+					return fmt.Errorf("failed to clear reference in '%s': %w", in.collection, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// referenceValues returns the reference values held by a property
+func referenceValues(value interface{}, list bool) []interface{} {
+	if !list {
+		return []interface{}{value}
+	}
+	items, _ := value.([]interface{})
+	return items
+}
+
+func referenceField(ref *models.PropertyRef) string {
+	if ref.Field == "" {
+		return "_id"
+	}
+	return ref.Field
+}
+
+func referenceGroup(ref *models.PropertyRef) string {
+	return ref.Collection + "|" + referenceField(ref)
+}
+
+// referenceKey renders a reference value the way PostgreSQL renders the JSONB field as text
+func referenceKey(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case primitive.ObjectID:
+		return v.Hex()
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	out := values[:0:0]
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
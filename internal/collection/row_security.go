@@ -0,0 +1,304 @@
+package collection
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/madhouselabs/anybase/internal/governance"
+	"github.com/madhouselabs/anybase/pkg/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Row-level security actions, matching the rules of models.CollectionPermissions
+const (
+	rowActionRead   = "read"
+	rowActionWrite  = "write"
+	rowActionUpdate = "update"
+	rowActionDelete = "delete"
+)
+
+// errUnresolvedVariable is returned when a condition references a variable the caller lacks,
+// e.g. $user.id for an access key
+var errUnresolvedVariable = errors.New("row-level security condition references an unavailable variable")
+
+// rowConditions returns the filter documents must match for the caller to perform action on
+// the collection, with $user and $key variables resolved. It returns nil when the action is
+// unrestricted or the caller holds the super admin permission.
+func (s *AdapterService) rowConditions(ctx context.Context, collection *models.Collection, userID primitive.ObjectID, action string) (map[string]interface{}, error) {
+	rule := permissionRule(&collection.Permissions, action)
+	if rule == nil || len(rule.Conditions) == 0 {
+		return nil, nil
+	}
+
+	vars := map[string]interface{}{}
+	if key, ok := governance.AccessKeyFromContext(ctx); ok {
+		if containsString(key.Permissions, "*:*:*") {
+			return nil, nil
+		}
+		vars["key"] = map[string]interface{}{
+			"id":          key.ID.Hex(),
+			"name":        key.Name,
+			"created_by":  key.CreatedBy.Hex(),
+			"permissions": key.Permissions,
+		}
+	} else if !userID.IsZero() {
+		if permissions, err := s.rbacService.GetEffectivePermissions(ctx, userID); err == nil && containsString(permissions, "*:*:*") {
+			return nil, nil
+		}
+		vars["user"] = s.computeUser(ctx, userID)
+	}
+
+	resolved, err := resolveVariables(rule.Conditions, vars)
+	if err != nil {
+		return nil, err
+	}
+	return resolved.(map[string]interface{}), nil
+}
+
+// scopeFilter restricts a query filter to the documents the caller may read, update or delete.
+// Conditions the caller cannot satisfy yield a filter that matches nothing.
+func (s *AdapterService) scopeFilter(ctx context.Context, collection *models.Collection, userID primitive.ObjectID, action string, filter map[string]interface{}) (map[string]interface{}, error) {
+	conditions, err := s.rowConditions(ctx, collection, userID, action)
+	if errors.Is(err, errUnresolvedVariable) {
+		conditions = map[string]interface{}{"$or": []interface{}{}}
+	} else if err != nil {
+		return nil, err
+	}
+	return withConditions(filter, conditions), nil
+}
+
+// scopeFilterByName is scopeFilter for callers that have not loaded the collection
+func (s *AdapterService) scopeFilterByName(ctx context.Context, collectionName string, userID primitive.ObjectID, action string, filter map[string]interface{}) (map[string]interface{}, error) {
+	collection, err := s.loadCollection(ctx, collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("collection not found: %w", err)
+	}
+	return s.scopeFilter(ctx, collection, userID, action, filter)
+}
+
+// checkRowConditions verifies that a document about to be written satisfies the conditions
+// of action. System fields the document does not carry are enforced by the query filter instead.
+func (s *AdapterService) checkRowConditions(ctx context.Context, collection *models.Collection, userID primitive.ObjectID, action string, doc map[string]interface{}) error {
+	conditions, err := s.rowConditions(ctx, collection, userID, action)
+	if err != nil {
+		return fmt.Errorf("insufficient permissions: %w", err)
+	}
+	if conditions == nil {
+		return nil
+	}
+	ok, err := matchConditions(doc, conditions)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("insufficient permissions: document does not satisfy the collection's %s conditions", action)
+	}
+	return nil
+}
+
+// withConditions ANDs conditions into a copy of filter. Top-level operators such as $near
+// stay in place so they keep their meaning.
+func withConditions(filter, conditions map[string]interface{}) map[string]interface{} {
+	if conditions == nil {
+		return filter
+	}
+	scoped := make(map[string]interface{}, len(filter)+1)
+	for k, v := range filter {
+		scoped[k] = v
+	}
+
+	var and []interface{}
+	switch existing := scoped["$and"].(type) {
+	case []interface{}:
+		and = append(and, existing...)
+	case bson.A:
+		and = append(and, existing...)
+	case nil:
+	default:
+		and = append(and, existing)
+	}
+	scoped["$and"] = append(and, conditions)
+	return scoped
+}
+
+func permissionRule(permissions *models.CollectionPermissions, action string) *models.PermissionRule {
+	switch action {
+	case rowActionRead:
+		return &permissions.Read
+	case rowActionWrite:
+		return &permissions.Write
+	case rowActionUpdate:
+		return &permissions.Update
+	case rowActionDelete:
+		return &permissions.Delete
+	}
+	return nil
+}
+
+// resolveVariables replaces "$user.<path>" and "$key.<path>" strings with values from vars
+func resolveVariables(value interface{}, vars map[string]interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		resolved := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			r, err := resolveVariables(item, vars)
+			if err != nil {
+				return nil, err
+			}
+			resolved[k] = r
+		}
+		return resolved, nil
+	case bson.M:
+		return resolveVariables(map[string]interface{}(v), vars)
+	case []interface{}:
+		resolved := make([]interface{}, len(v))
+		for i, item := range v {
+			r, err := resolveVariables(item, vars)
+			if err != nil {
+				return nil, err
+			}
+			resolved[i] = r
+		}
+		return resolved, nil
+	case bson.A:
+		return resolveVariables([]interface{}(v), vars)
+	case string:
+		if !strings.HasPrefix(v, "$user") && !strings.HasPrefix(v, "$key") {
+			return v, nil
+		}
+		path := strings.Split(strings.TrimPrefix(v, "$"), ".")
+		var current interface{} = vars
+		for _, name := range path {
+			m, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", errUnresolvedVariable, v)
+			}
+			if current, ok = m[name]; !ok || current == nil {
+				return nil, fmt.Errorf("%w: %s", errUnresolvedVariable, v)
+			}
+		}
+		return current, nil
+	}
+	return value, nil
+}
+
+// matchConditions evaluates resolved conditions against a document in memory. It supports
+// the subset of filters conditions are written in: equality, null, $in, $and and $or.
+func matchConditions(doc map[string]interface{}, conditions map[string]interface{}) (bool, error) {
+	for key, expected := range conditions {
+		switch key {
+		case "$and", "$or":
+			items, ok := expected.([]interface{})
+			if !ok {
+				return false, fmt.Errorf("%s condition must be an array", key)
+			}
+			matched := key == "$and"
+			for _, item := range items {
+				sub, ok := item.(map[string]interface{})
+				if !ok {
+					return false, fmt.Errorf("%s condition must contain objects", key)
+				}
+				m, err := matchConditions(doc, sub)
+				if err != nil {
+					return false, err
+				}
+				if key == "$and" && !m {
+					matched = false
+					break
+				}
+				if key == "$or" && m {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return false, nil
+			}
+			continue
+		}
+
+		actual, present := doc[key]
+		if !present && (key == "_id" || key == "_created_by" || key == "_updated_by") {
+			continue
+		}
+
+		if ops, ok := expected.(map[string]interface{}); ok && len(ops) == 1 {
+			if list, ok := ops["$in"].([]interface{}); ok {
+				found := false
+				for _, item := range list {
+					if conditionText(item) == conditionText(actual) {
+						found = true
+						break
+					}
+				}
+				if !found {
+					return false, nil
+				}
+				continue
+			}
+			for op := range ops {
+				if strings.HasPrefix(op, "$") {
+					return false, fmt.Errorf("operator %s is not supported in write conditions", op)
+				}
+			}
+		}
+
+		switch expected.(type) {
+		case nil:
+			if actual != nil {
+				return false, nil
+			}
+		case string, bool, float64, float32, int, int32, int64:
+			if actual == nil || conditionText(expected) != conditionText(actual) {
+				return false, nil
+			}
+		default:
+			if !jsonEqual(expected, actual) {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// conditionText renders a scalar the way PostgreSQL renders a JSONB value as text
+func conditionText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case primitive.ObjectID:
+		return v.Hex()
+	case nil:
+		return "\x00"
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func jsonEqual(a, b interface{}) bool {
+	var na, nb interface{}
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	if errA != nil || errB != nil || json.Unmarshal(ja, &na) != nil || json.Unmarshal(jb, &nb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(na, nb)
+}
+
+func containsString(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
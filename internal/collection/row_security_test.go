@@ -0,0 +1,172 @@
+package collection
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMatchConditions(t *testing.T) {
+	doc := map[string]interface{}{
+		"owner":    "u1",
+		"team":     "red",
+		"level":    float64(3),
+		"public":   true,
+		"archived": nil,
+		"meta":     map[string]interface{}{"region": "eu", "tier": float64(1)},
+	}
+
+	tests := []struct {
+		name       string
+		conditions map[string]interface{}
+		want       bool
+	}{
+		{name: "empty", conditions: map[string]interface{}{}, want: true},
+		{name: "equal string", conditions: map[string]interface{}{"owner": "u1"}, want: true},
+		{name: "different string", conditions: map[string]interface{}{"owner": "u2"}, want: false},
+		{name: "all fields must match", conditions: map[string]interface{}{"owner": "u1", "team": "blue"}, want: false},
+		{name: "number", conditions: map[string]interface{}{"level": 3}, want: true},
+		{name: "number compares as text", conditions: map[string]interface{}{"level": "3"}, want: true},
+		{name: "bool", conditions: map[string]interface{}{"public": true}, want: true},
+		{name: "bool mismatch", conditions: map[string]interface{}{"public": false}, want: false},
+		{name: "null matches null", conditions: map[string]interface{}{"archived": nil}, want: true},
+		{name: "null matches missing", conditions: map[string]interface{}{"deleted_at": nil}, want: true},
+		{name: "null rejects a value", conditions: map[string]interface{}{"owner": nil}, want: false},
+		{name: "value rejects null", conditions: map[string]interface{}{"archived": "no"}, want: false},
+		{name: "value rejects missing", conditions: map[string]interface{}{"deleted_at": "no"}, want: false},
+		{name: "object", conditions: map[string]interface{}{"meta": map[string]interface{}{"tier": 1, "region": "eu"}}, want: true},
+		{name: "object mismatch", conditions: map[string]interface{}{"meta": map[string]interface{}{"region": "us", "tier": 1}}, want: false},
+		{name: "in", conditions: map[string]interface{}{"team": map[string]interface{}{"$in": []interface{}{"blue", "red"}}}, want: true},
+		{name: "in numbers", conditions: map[string]interface{}{"level": map[string]interface{}{"$in": []interface{}{1, 3}}}, want: true},
+		{name: "not in", conditions: map[string]interface{}{"team": map[string]interface{}{"$in": []interface{}{"blue"}}}, want: false},
+		{name: "empty in", conditions: map[string]interface{}{"team": map[string]interface{}{"$in": []interface{}{}}}, want: false},
+		{name: "and", conditions: map[string]interface{}{"$and": []interface{}{
+			map[string]interface{}{"owner": "u1"},
+			map[string]interface{}{"team": "red"},
+		}}, want: true},
+		{name: "and with one failing", conditions: map[string]interface{}{"$and": []interface{}{
+			map[string]interface{}{"owner": "u1"},
+			map[string]interface{}{"team": "blue"},
+		}}, want: false},
+		{name: "or", conditions: map[string]interface{}{"$or": []interface{}{
+			map[string]interface{}{"owner": "u2"},
+			map[string]interface{}{"public": true},
+		}}, want: true},
+		{name: "or with none matching", conditions: map[string]interface{}{"$or": []interface{}{
+			map[string]interface{}{"owner": "u2"},
+			map[string]interface{}{"public": false},
+		}}, want: false},
+		{name: "empty or matches nothing", conditions: map[string]interface{}{"$or": []interface{}{}}, want: false},
+		{name: "nested", conditions: map[string]interface{}{"level": 3, "$or": []interface{}{
+			map[string]interface{}{"owner": "u2"},
+			map[string]interface{}{"$and": []interface{}{
+				map[string]interface{}{"team": "red"},
+				map[string]interface{}{"archived": nil},
+			}},
+		}}, want: true},
+		// System fields the document does not carry are left to the query filter
+		{name: "missing system field", conditions: map[string]interface{}{"_created_by": "u9"}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := matchConditions(doc, tt.conditions)
+			if err != nil {
+				t.Fatalf("matchConditions: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if ok, _ := matchConditions(map[string]interface{}{"_created_by": "u1"}, map[string]interface{}{"_created_by": "u9"}); ok {
+		t.Fatal("a system field the document carries must still match")
+	}
+}
+
+func TestMatchConditionsRejectsUnsupported(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions map[string]interface{}
+	}{
+		{name: "operator", conditions: map[string]interface{}{"level": map[string]interface{}{"$gt": 1}}},
+		{name: "and is not an array", conditions: map[string]interface{}{"$and": map[string]interface{}{"owner": "u1"}}},
+		{name: "or holds a scalar", conditions: map[string]interface{}{"$or": []interface{}{"owner"}}},
+		{name: "nested operator", conditions: map[string]interface{}{"$and": []interface{}{
+			map[string]interface{}{"level": map[string]interface{}{"$ne": 1}},
+		}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := matchConditions(map[string]interface{}{"owner": "u1", "level": float64(1)}, tt.conditions); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestResolveVariables(t *testing.T) {
+	vars := map[string]interface{}{
+		"user": map[string]interface{}{
+			"id":       "u1",
+			"roles":    []string{"editor"},
+			"metadata": map[string]interface{}{"team": "red"},
+		},
+	}
+
+	conditions := map[string]interface{}{
+		"owner":  "$user.id",
+		"team":   "$user.metadata.team",
+		"status": "$published",
+		"$or": bson.A{
+			bson.M{"editors": map[string]interface{}{"$in": []interface{}{"$user.id"}}},
+		},
+	}
+	got, err := resolveVariables(conditions, vars)
+	if err != nil {
+		t.Fatalf("resolveVariables: %v", err)
+	}
+	want := map[string]interface{}{
+		"owner":  "u1",
+		"team":   "red",
+		"status": "$published",
+		"$or": []interface{}{
+			map[string]interface{}{"editors": map[string]interface{}{"$in": []interface{}{"u1"}}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+
+	for _, unresolved := range []string{"$key.id", "$user.email", "$user.metadata.team.name", "$user.metadata.region"} {
+		_, err := resolveVariables(map[string]interface{}{"owner": unresolved}, vars)
+		if !errors.Is(err, errUnresolvedVariable) {
+			t.Errorf("%s: expected errUnresolvedVariable, got %v", unresolved, err)
+		}
+	}
+}
+
+func TestWithConditions(t *testing.T) {
+	conditions := map[string]interface{}{"owner": "u1"}
+
+	if got := withConditions(map[string]interface{}{"a": 1}, nil); !reflect.DeepEqual(got, map[string]interface{}{"a": 1}) {
+		t.Fatalf("nil conditions changed the filter: %v", got)
+	}
+
+	filter := map[string]interface{}{"a": 1, "$and": []interface{}{map[string]interface{}{"b": 2}}}
+	got := withConditions(filter, conditions)
+	want := map[string]interface{}{"a": 1, "$and": []interface{}{
+		map[string]interface{}{"b": 2},
+		conditions,
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if len(filter["$and"].([]interface{})) != 1 {
+		t.Fatal("the caller's filter was modified")
+	}
+}
@@ -2,22 +2,18 @@ package collection
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 
 	"github.com/madhouselabs/anybase/internal/validator"
+	"github.com/madhouselabs/anybase/pkg/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // ValidateAgainstSchema validates a document against collection schema
 func (s *AdapterService) ValidateAgainstSchema(ctx context.Context, collectionName string, document interface{}) error {
 	// Get the collection
-	collectionsCol := s.db.Collection("collections")
-	
-	filter := map[string]interface{}{"name": collectionName}
-	var collection struct {
-		Schema interface{} `bson:"schema"`
-	}
-	
-	err := collectionsCol.FindOne(ctx, filter, &collection)
+	collection, err := s.loadCollection(ctx, collectionName)
 	if err != nil {
 		return fmt.Errorf("failed to get collection schema: %w", err)
 	}
@@ -27,20 +23,94 @@ func (s *AdapterService) ValidateAgainstSchema(ctx context.Context, collectionNa
 		return nil
 	}
 
-	// Validate document against schema
-	schemaValidator := validator.NewSchemaValidator()
-	
-	// Convert schema to map if needed
-	schemaMap, ok := collection.Schema.(map[string]interface{})
+	// Convert document to map if needed
+	docMap, ok := document.(map[string]interface{})
 	if !ok {
-		// If schema is not a map, skip validation
-		return nil
+		data, err := json.Marshal(document)
+		if err != nil {
+			return fmt.Errorf("failed to encode document: %w", err)
+		}
+		if err := json.Unmarshal(data, &docMap); err != nil {
+			return fmt.Errorf("document must be a JSON object: %w", err)
+		}
 	}
-	
-	// Validate document
-	if err := schemaValidator.ValidateDocument(schemaMap, nil); err != nil {
+
+	// Validate document, collecting every violation
+	if err := s.validator.ValidateDocument(docMap, collection.Schema); err != nil {
 		return fmt.Errorf("document does not match schema: %w", err)
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// loadCollection reads collection metadata without permission checks, for internal use
+func (s *AdapterService) loadCollection(ctx context.Context, name string) (*models.Collection, error) {
+	collectionsCol := s.db.Collection("collections")
+
+	var collection models.Collection
+	if err := collectionsCol.FindOne(ctx, map[string]interface{}{"name": name}, &collection); err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+// readSchema returns the schema of a collection, or nil if there is none
+func (s *AdapterService) readSchema(ctx context.Context, name string) *models.CollectionSchema {
+	collection, err := s.loadCollection(ctx, name)
+	if err != nil {
+		return nil
+	}
+	return collection.Schema
+}
+
+// enforceReadOnly rejects or strips client writes to readOnly fields according to the collection policy
+func (s *AdapterService) enforceReadOnly(col *models.Collection, data, existing map[string]interface{}) (map[string]interface{}, error) {
+	if col.Schema == nil {
+		return data, nil
+	}
+	if col.Settings.ReadOnlyPolicy == models.ReadOnlyPolicyStrip {
+		return s.validator.StripReadOnly(data, col.Schema), nil
+	}
+	if err := s.validator.CheckReadOnly(data, existing, col.Schema); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// documentShape is how a collection's documents are presented to one caller: without
+// writeOnly fields and with the caller's field rules applied
+type documentShape struct {
+	validator *validator.SchemaValidator
+	schema    *models.CollectionSchema
+	fields    fieldAccess
+}
+
+// shapeFor prepares the document shape of a loaded collection for the caller
+func (s *AdapterService) shapeFor(ctx context.Context, collection *models.Collection, userID primitive.ObjectID) *documentShape {
+	return &documentShape{
+		validator: s.validator,
+		schema:    collection.Schema,
+		fields:    s.fieldAccessFor(ctx, collection, userID),
+	}
+}
+
+// readShape is shapeFor for callers that have not loaded the collection. Without the
+// collection there is no way to tell which fields to withhold, so nothing is returned.
+func (s *AdapterService) readShape(ctx context.Context, name string, userID primitive.ObjectID) (*documentShape, error) {
+	collection, err := s.loadCollection(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load collection '%s': %w", name, err)
+	}
+	return s.shapeFor(ctx, collection, userID), nil
+}
+
+// apply shapes one document; a nil shape leaves it untouched
+func (d *documentShape) apply(data map[string]interface{}) map[string]interface{} {
+	if d == nil || data == nil {
+		return data
+	}
+	if d.schema != nil {
+		data = d.validator.StripWriteOnly(data, d.schema)
+	}
+	return d.fields.applyRead(data)
+}
@@ -1,6 +1,8 @@
 package collection
 
 import (
+	"sync"
+
 	"github.com/madhouselabs/anybase/internal/database/types"
 	"github.com/madhouselabs/anybase/internal/governance"
 	"github.com/madhouselabs/anybase/internal/validator"
@@ -12,6 +14,7 @@ type AdapterService struct {
 	rbacService    governance.RBACService
 	validator      *validator.SchemaValidator
 	inputValidator *validator.InputValidator
+	computed       sync.Map // Compiled computed fields keyed by collection name
 }
 
 // NewAdapterService creates a new adapter-based service
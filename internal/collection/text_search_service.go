@@ -0,0 +1,225 @@
+package collection
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/madhouselabs/anybase/internal/database/adapters/postgres"
+	"github.com/madhouselabs/anybase/internal/validator"
+	"github.com/madhouselabs/anybase/pkg/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultTextSearchLimit = 20
+	maxTextSearchLimit     = 100
+)
+
+// ConfigureTextSearch sets the full-text configuration of a collection; nil disables text search
+func (s *AdapterService) ConfigureTextSearch(ctx context.Context, userID primitive.ObjectID, collectionName string, config *models.TextSearchConfig) error {
+	// Check permissions
+	hasPermission, err := s.rbacService.HasPermission(ctx, userID, fmt.Sprintf("collection:%s", collectionName), "update")
+	if err != nil {
+		return fmt.Errorf("failed to check permissions: %w", err)
+	}
+	if !hasPermission {
+		s.logAccess(ctx, userID, collectionName, nil, "configure_text_search", "denied", "insufficient permissions")
+		return fmt.Errorf("insufficient permissions to configure text search")
+	}
+
+	collection, err := s.loadCollection(ctx, collectionName)
+	if err != nil {
+		return fmt.Errorf("collection not found: %w", err)
+	}
+
+	if err := s.applyTextSearch(ctx, collection, config); err != nil {
+		return err
+	}
+
+	// Update collection metadata
+	update := map[string]interface{}{
+		"$set": map[string]interface{}{
+			"text_search": config,
+			"updated_at":  time.Now().UTC(),
+		},
+	}
+	if _, err := s.db.Collection("collections").UpdateOne(ctx, map[string]interface{}{"name": collectionName}, update); err != nil {
+		return fmt.Errorf("failed to update collection: %w", err)
+	}
+
+	action := "enabled"
+	if config == nil {
+		action = "disabled"
+	}
+	s.logAccess(ctx, userID, collectionName, nil, "configure_text_search", "allowed", "text search "+action)
+	return nil
+}
+
+// applyTextSearch creates or drops the tsvector column backing a collection's text search
+func (s *AdapterService) applyTextSearch(ctx context.Context, collection *models.Collection, config *models.TextSearchConfig) error {
+	pgCol, ok := s.db.Collection("data_" + collection.Name).(*postgres.PostgresCollection)
+	if !ok {
+		return fmt.Errorf("text search requires PostgreSQL adapter")
+	}
+	textOps := pgCol.GetTextSearchOperations()
+
+	if config == nil {
+		return textOps.DisableTextSearch(ctx)
+	}
+
+	// Indexing writeOnly fields would expose them through matches and snippets
+	if collection.Schema != nil {
+		root := validator.RootSchema(collection.Schema)
+		for _, field := range config.Fields {
+			if prop := schemaProperty(root, field.Name); prop != nil && prop.WriteOnly {
+				return fmt.Errorf("text search field '%s' is writeOnly", field.Name)
+			}
+		}
+	}
+
+	return textOps.EnableTextSearch(ctx, config)
+}
+
+// TextSearch runs a ranked full-text query over the collection's configured text fields
+func (s *AdapterService) TextSearch(ctx context.Context, userID primitive.ObjectID, collectionName string, opts TextSearchOptions) ([]bson.M, error) {
+	hasPermission, err := s.checkCollectionAccess(ctx, userID, collectionName, rowActionRead)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check permissions: %w", err)
+	}
+	if !hasPermission {
+		s.logAccess(ctx, userID, collectionName, nil, "text_search", "denied", "insufficient permissions")
+		return nil, fmt.Errorf("insufficient permissions to search collection")
+	}
+
+	collection, err := s.loadCollection(ctx, collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("collection not found: %w", err)
+	}
+	if collection.TextSearch == nil {
+		return nil, fmt.Errorf("text search is not configured for collection '%s'", collectionName)
+	}
+
+	pgCol, ok := s.db.Collection("data_" + collectionName).(*postgres.PostgresCollection)
+	if !ok {
+		return nil, fmt.Errorf("text search requires PostgreSQL adapter")
+	}
+
+	if opts.Limit <= 0 {
+		opts.Limit = defaultTextSearchLimit
+	}
+	if opts.Limit > maxTextSearchLimit {
+		opts.Limit = maxTextSearchLimit
+	}
+	if opts.Skip < 0 {
+		opts.Skip = 0
+	}
+
+	// Always exclude soft-deleted documents
+	filter := map[string]interface{}{}
+	for k, v := range opts.Filter {
+		filter[k] = v
+	}
+	filter["_deleted_at"] = nil
+
+	// Matching on a field the caller cannot see would reveal its contents
+	shape := s.shapeFor(ctx, collection, userID)
+	for _, field := range collection.TextSearch.Fields {
+		if shape.fields.conceals(field.Name) {
+			return nil, fmt.Errorf("insufficient permissions to search field '%s'", field.Name)
+		}
+	}
+	if err := shape.fields.checkFilter(filter); err != nil {
+		return nil, err
+	}
+
+	filter, err = s.scopeFilter(ctx, collection, userID, rowActionRead, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := pgCol.GetTextSearchOperations().TextSearch(ctx, collection.TextSearch, postgres.TextQuery{
+		Query:     opts.Query,
+		Mode:      opts.Mode,
+		Filter:    filter,
+		Limit:     opts.Limit,
+		Skip:      opts.Skip,
+		Highlight: opts.Highlight,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []bson.M{}
+	for rows.Next() {
+		var id sql.NullString
+		var data, headlines []byte
+		var rank float32
+		if err := rows.Scan(&id, &data, &rank, &headlines); err != nil {
+			return nil, fmt.Errorf("failed to scan result: %w", err)
+		}
+
+		doc := bson.M{}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			continue
+		}
+		doc["_id"] = id.String
+		doc["_rank"] = rank
+
+		if highlights := parseHighlights(headlines); len(highlights) > 0 {
+			doc["_highlights"] = highlights
+		}
+
+		results = append(results, bson.M(shape.apply(doc)))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("text search failed: %w", err)
+	}
+
+	s.logAccess(ctx, userID, collectionName, nil, "text_search", "allowed", fmt.Sprintf("%d results", len(results)))
+	return results, nil
+}
+
+// highlightMarkup turns the highlight markers into <mark> tags, once the fragment's document
+// text is escaped, so stored markup cannot reach a client that renders the highlights
+var highlightMarkup = strings.NewReplacer(postgres.HighlightStart, "<mark>", postgres.HighlightStop, "</mark>")
+
+// parseHighlights splits ts_headline output into fragments, keeping only those with a match.
+// Fragments are returned as HTML.
+func parseHighlights(raw []byte) map[string][]string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var headlines map[string]string
+	if err := json.Unmarshal(raw, &headlines); err != nil {
+		return nil
+	}
+
+	highlights := map[string][]string{}
+	for field, headline := range headlines {
+		for _, fragment := range strings.Split(headline, postgres.HighlightDelimiter) {
+			if fragment = strings.TrimSpace(fragment); strings.Contains(fragment, postgres.HighlightStart) {
+				highlights[field] = append(highlights[field], highlightMarkup.Replace(html.EscapeString(fragment)))
+			}
+		}
+	}
+	return highlights
+}
+
+// schemaProperty resolves a dotted field path to its schema property
+func schemaProperty(root *models.SchemaProperty, path string) *models.SchemaProperty {
+	prop := root
+	for _, name := range strings.Split(path, ".") {
+		if prop == nil {
+			return nil
+		}
+		prop = validator.Resolve(prop.Properties[name], root)
+	}
+	return prop
+}
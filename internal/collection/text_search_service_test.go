@@ -0,0 +1,53 @@
+package collection
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/madhouselabs/anybase/internal/database/adapters/postgres"
+)
+
+func TestParseHighlights(t *testing.T) {
+	mark := func(word string) string { return postgres.HighlightStart + word + postgres.HighlightStop }
+
+	tests := []struct {
+		name      string
+		headlines map[string]string
+		want      map[string][]string
+	}{
+		{
+			name:      "fragments with a match",
+			headlines: map[string]string{"body": "the " + mark("engine") + " ran" + postgres.HighlightDelimiter + "no match here"},
+			want:      map[string][]string{"body": {"the <mark>engine</mark> ran"}},
+		},
+		{
+			name:      "stored markup is escaped",
+			headlines: map[string]string{"body": `<script>alert(1)</script> ` + mark("engine") + ` <img src=x onerror="alert('x')">`},
+			want: map[string][]string{"body": {
+				`&lt;script&gt;alert(1)&lt;/script&gt; <mark>engine</mark> &lt;img src=x onerror=&#34;alert(&#39;x&#39;)&#34;&gt;`,
+			}},
+		},
+		{
+			name:      "tags in the text are not taken for highlights",
+			headlines: map[string]string{"body": "<mark>fake</mark> text"},
+			want:      map[string][]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := json.Marshal(tt.headlines)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := parseHighlights(raw); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := parseHighlights(nil); got != nil {
+		t.Fatalf("expected nil for no headlines, got %v", got)
+	}
+}
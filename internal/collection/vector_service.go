@@ -207,7 +207,7 @@ func (s *AdapterService) ListVectorFields(ctx context.Context, collectionName st
 // VectorSearch performs vector similarity search
 func (s *AdapterService) VectorSearch(ctx context.Context, userID primitive.ObjectID, collectionName string, opts VectorSearchOptions) ([]bson.M, error) {
 	// Check read permissions
-	hasPermission, err := s.rbacService.HasPermission(ctx, userID, fmt.Sprintf("collection:%s", collectionName), "read")
+	hasPermission, err := s.checkCollectionAccess(ctx, userID, collectionName, rowActionRead)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check permissions: %w", err)
 	}
@@ -239,14 +239,25 @@ func (s *AdapterService) VectorSearch(ctx context.Context, userID primitive.Obje
 		return nil, fmt.Errorf("vector search requires PostgreSQL adapter")
 	}
 
+	// Only rank documents the caller may read
+	scoped, err := s.scopeFilterByName(ctx, collectionName, userID, rowActionRead, map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+
 	// Perform vector search using PostgreSQL operations
-	vectorOps := pgCol.GetVectorOperations()
+	vectorOps := pgCol.GetVectorOperations().WithFilter(scoped)
 	rows, err := vectorOps.VectorSearch(ctx, opts.VectorField, opts.QueryVector, opts.TopK, opts.Metric)
 	if err != nil {
 		return nil, fmt.Errorf("vector search failed: %w", err)
 	}
 	defer rows.Close()
 
+	shape, err := s.readShape(ctx, collectionName, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Parse results
 	var results []bson.M
 	for rows.Next() {
@@ -267,7 +278,7 @@ func (s *AdapterService) VectorSearch(ctx context.Context, userID primitive.Obje
 			}
 		}
 		
-		results = append(results, doc)
+		results = append(results, bson.M(shape.apply(doc)))
 	}
 
 	s.logAccess(ctx, userID, collectionName, nil, "vector_search", "success", opts.VectorField)
@@ -277,7 +288,7 @@ func (s *AdapterService) VectorSearch(ctx context.Context, userID primitive.Obje
 // HybridSearch performs combined text and vector search
 func (s *AdapterService) HybridSearch(ctx context.Context, userID primitive.ObjectID, collectionName string, opts HybridSearchOptions) ([]bson.M, error) {
 	// Check read permissions
-	hasPermission, err := s.rbacService.HasPermission(ctx, userID, fmt.Sprintf("collection:%s", collectionName), "read")
+	hasPermission, err := s.checkCollectionAccess(ctx, userID, collectionName, rowActionRead)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check permissions: %w", err)
 	}
@@ -309,14 +320,31 @@ func (s *AdapterService) HybridSearch(ctx context.Context, userID primitive.Obje
 		return nil, fmt.Errorf("hybrid search requires PostgreSQL adapter")
 	}
 
-	// Perform hybrid search using PostgreSQL operations
-	vectorOps := pgCol.GetVectorOperations()
+	// Perform hybrid search using PostgreSQL operations, ranking text with the
+	// collection's full-text configuration when there is one
+	collection, err := s.loadCollection(ctx, collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("collection not found: %w", err)
+	}
+	scoped, err := s.scopeFilter(ctx, collection, userID, rowActionRead, map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	vectorOps := pgCol.GetVectorOperations().WithFilter(scoped)
+	if collection.TextSearch != nil {
+		vectorOps.WithTextSearch(collection.TextSearch)
+	}
 	rows, err := vectorOps.HybridSearch(ctx, opts.VectorField, opts.QueryVector, opts.TextQuery, opts.TopK, opts.Alpha)
 	if err != nil {
 		return nil, fmt.Errorf("hybrid search failed: %w", err)
 	}
 	defer rows.Close()
 
+	shape, err := s.readShape(ctx, collectionName, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Parse results
 	var results []bson.M
 	for rows.Next() {
@@ -337,7 +365,7 @@ func (s *AdapterService) HybridSearch(ctx context.Context, userID primitive.Obje
 			}
 		}
 		
-		results = append(results, doc)
+		results = append(results, bson.M(shape.apply(doc)))
 	}
 
 	s.logAccess(ctx, userID, collectionName, nil, "hybrid_search", "success", fmt.Sprintf("text+%s", opts.VectorField))
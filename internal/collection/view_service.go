@@ -209,8 +209,8 @@ func (s *AdapterService) QueryView(ctx context.Context, userID primitive.ObjectI
 		return nil, err
 	}
 
-	// Check if user has permission on the underlying collection
-	hasPermission, err := s.rbacService.HasPermission(ctx, userID, fmt.Sprintf("collection:%s", view.Collection), "read")
+	// Check the view's own permissions, or read access to the underlying collection
+	hasPermission, err := s.checkViewAccess(ctx, userID, view)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check permissions: %w", err)
 	}
@@ -222,14 +222,35 @@ func (s *AdapterService) QueryView(ctx context.Context, userID primitive.ObjectI
 	// Get the underlying collection
 	dataCol := s.db.Collection("data_" + view.Collection)
 
-	// Build the aggregation pipeline
+	// Build the aggregation pipeline, starting with the rows the caller may read
 	pipeline := []interface{}{}
+	scoped, err := s.scopeFilterByName(ctx, view.Collection, userID, rowActionRead, map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	if len(scoped) > 0 {
+		pipeline = append(pipeline, map[string]interface{}{"$match": scoped})
+	}
 
 	// Add view filter if specified
 	if view.Filter != nil && len(view.Filter) > 0 {
 		pipeline = append(pipeline, map[string]interface{}{"$match": view.Filter})
 	}
 
+	// The caller's filter and sort cannot reach fields the field rules keep from them
+	shape, err := s.readShape(ctx, view.Collection, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := shape.fields.checkFilter(opts.Filter); err != nil {
+		return nil, err
+	}
+	for field := range opts.Sort {
+		if err := shape.fields.checkFields(field); err != nil {
+			return nil, err
+		}
+	}
+
 	// Add query filter if specified
 	if opts.Filter != nil && len(opts.Filter) > 0 {
 		pipeline = append(pipeline, map[string]interface{}{"$match": opts.Filter})
@@ -286,7 +307,7 @@ func (s *AdapterService) QueryView(ctx context.Context, userID primitive.ObjectI
 		if err := cursor.Decode(&doc); err != nil {
 			continue
 		}
-		results = append(results, doc)
+		results = append(results, bson.M(shape.apply(doc)))
 	}
 
 	s.logAccess(ctx, userID, viewName, nil, "query", "allowed", fmt.Sprintf("%d results", len(results)))
@@ -13,6 +13,7 @@ type Config struct {
 	Database DatabaseConfig `mapstructure:"database"`
 	Auth     AuthConfig     `mapstructure:"auth"`
 	AWS      AWSConfig      `mapstructure:"aws"`
+	Mail     MailConfig     `mapstructure:"mail"`
 	Logging  LoggingConfig  `mapstructure:"logging"`
 }
 
@@ -45,12 +46,61 @@ type DatabaseConfig struct {
 
 type AuthConfig struct {
 	JWTSecret             string        `mapstructure:"jwt_secret"`
+	JWTAlgorithm          string        `mapstructure:"jwt_algorithm"` // HS256 signs with jwt_secret; RS256 and ES256 use rotating key pairs
+	SigningKeyRotation    time.Duration `mapstructure:"signing_key_rotation"` // How long each key pair signs before the next takes over
+	SigningKeySecret      string        `mapstructure:"signing_key_secret"` // Encrypts stored private keys, defaults to the JWT secret
 	JWTExpiration         time.Duration `mapstructure:"jwt_expiration"`
 	RefreshTokenExpiration time.Duration `mapstructure:"refresh_token_expiration"`
-	PasswordMinLength     int           `mapstructure:"password_min_length"`
+	PasswordMinLength     int           `mapstructure:"password_min_length"` // Floor under the policy tier's minimum
+	BreachedPasswordsFile string        `mapstructure:"breached_passwords_file"` // Extra breached passwords, one per line
 	BcryptCost           int           `mapstructure:"bcrypt_cost"`
 	MaxLoginAttempts     int           `mapstructure:"max_login_attempts"`
 	LockoutDuration      time.Duration `mapstructure:"lockout_duration"`
+	AccessKeySecret      string        `mapstructure:"access_key_secret"` // HMAC key for access key secrets, defaults to the JWT secret
+
+	// Emailed links; the token is appended as the "token" query parameter
+	VerifyEmailURL              string        `mapstructure:"verify_email_url"`
+	PasswordResetURL            string        `mapstructure:"password_reset_url"`
+	EmailVerificationExpiration time.Duration `mapstructure:"email_verification_expiration"`
+	PasswordResetExpiration     time.Duration `mapstructure:"password_reset_expiration"`
+
+	// Passwordless sign-in with an emailed link or one-time code, when the system settings allow it
+	PasswordlessURL             string        `mapstructure:"passwordless_url"` // Page that completes the sign-in with the link's token
+	PasswordlessExpiration      time.Duration `mapstructure:"passwordless_expiration"`
+	PasswordlessRequestsPerHour int           `mapstructure:"passwordless_requests_per_hour"` // Per email address
+
+	// TOTP multi-factor authentication
+	MFAIssuer              string        `mapstructure:"mfa_issuer"`               // Shown in authenticator apps
+	MFAChallengeExpiration time.Duration `mapstructure:"mfa_challenge_expiration"` // Time allowed between password and code
+
+	// Lifetime of the token an admin gets when impersonating a user; it cannot be refreshed
+	ImpersonationExpiration time.Duration `mapstructure:"impersonation_expiration"`
+
+	// External identity providers users can sign in with
+	OIDCProviders []OIDCProviderConfig `mapstructure:"oidc_providers"`
+}
+
+// OIDCProviderConfig configures an OpenID Connect identity provider
+type OIDCProviderConfig struct {
+	Name         string   `mapstructure:"name"` // Used in the login URL: /auth/oidc/<name>/login
+	DisplayName  string   `mapstructure:"display_name"`
+	Issuer       string   `mapstructure:"issuer"`
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"` // Empty for public clients, which rely on PKCE alone
+	Scopes       []string `mapstructure:"scopes"`        // Defaults to openid, email and profile
+	RedirectURL  string   `mapstructure:"redirect_url"`  // The callback URL registered with the provider
+
+	// Roles are taken from RolesClaim, a string or list of strings such as groups, by
+	// mapping its values through RoleMapping. Mapped roles are added to the user's roles at
+	// every sign-in, or replace them with ReplaceRoles, so that roles taken away at the
+	// provider are taken away here too. When nothing maps, new users get DefaultRole and
+	// existing users keep theirs.
+	RolesClaim   string            `mapstructure:"roles_claim"`
+	RoleMapping  map[string]string `mapstructure:"role_mapping"` // Keys are matched case-insensitively
+	DefaultRole  string            `mapstructure:"default_role"`
+	ReplaceRoles bool              `mapstructure:"replace_roles"`
+
+	AllowSignup bool `mapstructure:"allow_signup"` // Create accounts for unknown users on first sign-in
 }
 
 type AWSConfig struct {
@@ -60,6 +110,22 @@ type AWSConfig struct {
 	SessionToken    string `mapstructure:"session_token"`
 }
 
+type MailConfig struct {
+	Driver       string     `mapstructure:"driver"` // smtp, file, log
+	From         string     `mapstructure:"from"`
+	TemplatesDir string     `mapstructure:"templates_dir"` // Overrides for the built-in templates
+	OutputDir    string     `mapstructure:"output_dir"`    // Where the file driver writes messages
+	SMTP         SMTPConfig `mapstructure:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	TLS      string `mapstructure:"tls"` // starttls, tls, none
+}
+
 type LoggingConfig struct {
 	Level      string `mapstructure:"level"`
 	Format     string `mapstructure:"format"` // json, console
@@ -130,12 +196,38 @@ func setDefaults() {
 	viper.SetDefault("database.connect_timeout", 10*time.Second)
 
 	// Auth defaults
+	viper.SetDefault("auth.jwt_algorithm", "HS256")
+	viper.SetDefault("auth.signing_key_rotation", 30*24*time.Hour)
+	viper.SetDefault("auth.signing_key_secret", "") // Empty falls back to the JWT secret
 	viper.SetDefault("auth.jwt_expiration", 24*time.Hour)
 	viper.SetDefault("auth.refresh_token_expiration", 7*24*time.Hour)
 	viper.SetDefault("auth.password_min_length", 8)
+	viper.SetDefault("auth.breached_passwords_file", "")
 	viper.SetDefault("auth.bcrypt_cost", 10)
 	viper.SetDefault("auth.max_login_attempts", 5)
 	viper.SetDefault("auth.lockout_duration", 15*time.Minute)
+	viper.SetDefault("auth.access_key_secret", "") // Empty falls back to the JWT secret
+	viper.SetDefault("auth.verify_email_url", "http://localhost:8080/api/v1/auth/verify-email")
+	viper.SetDefault("auth.password_reset_url", "http://localhost:3000/reset-password")
+	viper.SetDefault("auth.email_verification_expiration", 48*time.Hour)
+	viper.SetDefault("auth.password_reset_expiration", time.Hour)
+	viper.SetDefault("auth.passwordless_url", "http://localhost:3000/login/passwordless")
+	viper.SetDefault("auth.passwordless_expiration", 10*time.Minute)
+	viper.SetDefault("auth.passwordless_requests_per_hour", 5)
+	viper.SetDefault("auth.mfa_issuer", "AnyBase")
+	viper.SetDefault("auth.mfa_challenge_expiration", 5*time.Minute)
+	viper.SetDefault("auth.impersonation_expiration", 15*time.Minute)
+
+	// Mail defaults: messages are logged until a real driver is configured
+	viper.SetDefault("mail.driver", "log")
+	viper.SetDefault("mail.from", "AnyBase <no-reply@anybase.local>")
+	viper.SetDefault("mail.templates_dir", "")
+	viper.SetDefault("mail.output_dir", "./mail")
+	viper.SetDefault("mail.smtp.host", "")
+	viper.SetDefault("mail.smtp.port", 587)
+	viper.SetDefault("mail.smtp.username", "")
+	viper.SetDefault("mail.smtp.password", "")
+	viper.SetDefault("mail.smtp.tls", "starttls")
 
 	// Logging defaults
 	viper.SetDefault("logging.level", "info")
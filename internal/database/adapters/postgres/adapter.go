@@ -9,6 +9,7 @@ import (
 
 	"github.com/madhouselabs/anybase/internal/config"
 	"github.com/madhouselabs/anybase/internal/database/types"
+	"github.com/madhouselabs/anybase/internal/tenant"
 	_ "github.com/lib/pq"
 )
 
@@ -17,6 +18,7 @@ type PostgresAdapter struct {
 	db       *sql.DB
 	config   *config.DatabaseConfig
 	database string
+	postgis  bool
 }
 
 // NewPostgresAdapter creates a new PostgreSQL adapter
@@ -55,9 +57,26 @@ func (p *PostgresAdapter) Connect(ctx context.Context) error {
 		return fmt.Errorf("failed to initialize schema: %w", err)
 	}
 	
+	// Geo queries fall back to plain SQL when PostGIS is not installed
+	p.postgis = p.detectPostGIS(ctx)
+	
 	return nil
 }
 
+// detectPostGIS enables the PostGIS extension when the server provides it
+func (p *PostgresAdapter) detectPostGIS(ctx context.Context) bool {
+	var available bool
+	err := p.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'postgis')").Scan(&available)
+	if err != nil || !available {
+		return false
+	}
+	// Creating the extension needs privileges; it may also have been installed by an administrator
+	p.db.ExecContext(ctx, "CREATE EXTENSION IF NOT EXISTS postgis")
+	var installed bool
+	err = p.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'postgis')").Scan(&installed)
+	return err == nil && installed
+}
+
 // buildConnectionString builds PostgreSQL connection string
 func (p *PostgresAdapter) buildConnectionString() string {
 	// If URI is provided, use it directly
@@ -143,12 +162,20 @@ func (p *PostgresAdapter) initializeSchema(ctx context.Context) error {
 		"users",
 		"sessions",
 		"access_keys",
+		"roles",
 		"audit_logs",
 		"settings",
 		"collections",  // Add collections table for metadata storage
 		"ai_providers",
 		"rag_configs",
 		"embedding_jobs",
+		"organizations",
+		"org_members",
+		"user_identities",
+		"oidc_states",
+		"signing_keys",
+		"service_account_credentials",
+		"login_tokens",
 	}
 	
 	for _, collection := range systemCollections {
@@ -160,9 +187,33 @@ func (p *PostgresAdapter) initializeSchema(ctx context.Context) error {
 	return nil
 }
 
+// tenantCollections are the system collections each organization keeps in its own namespace
+var tenantCollections = []string{
+	"roles",
+	"audit_logs",
+	"collections",
+	"_views",
+	"ai_providers",
+	"rag_configs",
+	"embedding_jobs",
+}
+
+// EnsureTenantTables creates the system tables of the organization bound to ctx
+func (p *PostgresAdapter) EnsureTenantTables(ctx context.Context) error {
+	if _, ok := tenant.FromContext(ctx); !ok {
+		return fmt.Errorf("no organization in context")
+	}
+	for _, collection := range tenantCollections {
+		if err := p.ensureCollectionTable(ctx, collection); err != nil {
+			return fmt.Errorf("failed to create %s table: %w", collection, err)
+		}
+	}
+	return nil
+}
+
 // ensureCollectionTable creates a table for a collection if it doesn't exist
 func (p *PostgresAdapter) ensureCollectionTable(ctx context.Context, name string) error {
-	tableName := p.sanitizeTableName(name)
+	tableName := qualifiedTable(ctx, p.sanitizeTableName(name))
 	
 	// Create the collection table with JSONB data field
 	query := fmt.Sprintf(`
@@ -233,9 +284,34 @@ func (p *PostgresAdapter) sanitizeTableName(name string) string {
 	return name
 }
 
+// qualifiedTable returns the table holding a collection for the organization bound to ctx
+func qualifiedTable(ctx context.Context, tableName string) string {
+	return strings.ReplaceAll(tenant.Qualify(ctx, tableName), "-", "_")
+}
+
+// qualifiedIndex returns the name of an index for the organization bound to ctx. Index names
+// share one namespace across the schema, so they are prefixed like tables; names that
+// already carry the organization's prefix are left alone.
+func qualifiedIndex(ctx context.Context, name string) string {
+	prefix := indexPrefix(ctx)
+	if name == "" || strings.Contains(name, prefix) {
+		return name
+	}
+	return prefix + name
+}
+
+// indexPrefix returns the prefix of index names for the organization bound to ctx
+func indexPrefix(ctx context.Context) string {
+	org, ok := tenant.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	return strings.ReplaceAll(tenant.Prefix(org.Slug), "-", "_")
+}
+
 // GetIndexes returns the list of indexes for a table
 func (p *PostgresAdapter) GetIndexes(ctx context.Context, tableName string) ([]types.Index, error) {
-	sanitizedName := p.sanitizeTableName(tableName)
+	sanitizedName := qualifiedTable(ctx, p.sanitizeTableName(tableName))
 	
 	query := `
 		SELECT indexname, indexdef 
@@ -348,8 +424,8 @@ func (p *PostgresAdapter) GetIndexes(ctx context.Context, tableName string) ([]t
 
 // CreateIndex creates an index on a table
 func (p *PostgresAdapter) CreateIndex(ctx context.Context, tableName, indexName string, fields []string, unique bool) error {
-	sanitizedTable := p.sanitizeTableName(tableName)
-	sanitizedIndex := p.sanitizeTableName(indexName)
+	sanitizedTable := qualifiedTable(ctx, p.sanitizeTableName(tableName))
+	sanitizedIndex := qualifiedIndex(ctx, p.sanitizeTableName(indexName))
 	
 	indexType := ""
 	if unique {
@@ -378,7 +454,7 @@ func (p *PostgresAdapter) CreateIndex(ctx context.Context, tableName, indexName
 
 // DropIndex drops an index
 func (p *PostgresAdapter) DropIndex(ctx context.Context, indexName string) error {
-	sanitizedIndex := p.sanitizeTableName(indexName)
+	sanitizedIndex := qualifiedIndex(ctx, p.sanitizeTableName(indexName))
 	query := fmt.Sprintf("DROP INDEX IF EXISTS %s", sanitizedIndex)
 	_, err := p.db.ExecContext(ctx, query)
 	return err
@@ -403,6 +479,7 @@ func (p *PostgresAdapter) Collection(name string) types.Collection {
 		db:         p.db,
 		name:       name,
 		tableName:  p.sanitizeTableName(name),
+		postgis:    p.postgis,
 	}
 }
 
@@ -423,21 +500,21 @@ func (p *PostgresAdapter) CreateCollection(ctx context.Context, name string, sch
 		}
 	}
 	
-	// Store collection metadata
+	// Store collection metadata under the table's name, which is unique across organizations
 	_, err := p.db.ExecContext(ctx, `
 		INSERT INTO _collections (name, schema, created_at, updated_at)
 		VALUES ($1, $2, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 		ON CONFLICT (name) DO UPDATE SET
 			schema = EXCLUDED.schema,
 			updated_at = CURRENT_TIMESTAMP
-	`, name, schemaJSON)
+	`, qualifiedTable(ctx, name), schemaJSON)
 	
 	return err
 }
 
 // DropCollection drops a collection (table)
 func (p *PostgresAdapter) DropCollection(ctx context.Context, name string) error {
-	tableName := p.sanitizeTableName(name)
+	tableName := qualifiedTable(ctx, p.sanitizeTableName(name))
 	
 	// Drop the table
 	_, err := p.db.ExecContext(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s CASCADE", tableName))
@@ -446,7 +523,7 @@ func (p *PostgresAdapter) DropCollection(ctx context.Context, name string) error
 	}
 	
 	// Remove from metadata
-	_, err = p.db.ExecContext(ctx, "DELETE FROM _collections WHERE name = $1", name)
+	_, err = p.db.ExecContext(ctx, "DELETE FROM _collections WHERE name = $1", qualifiedTable(ctx, name))
 	return err
 }
 
@@ -464,13 +541,21 @@ func (p *PostgresAdapter) ListCollections(ctx context.Context) ([]string, error)
 	}
 	defer rows.Close()
 	
+	// Only the tables of the organization in the context are listed
+	prefix := qualifiedTable(ctx, "")
 	var collections []string
 	for rows.Next() {
 		var name string
 		if err := rows.Scan(&name); err != nil {
 			return nil, err
 		}
-		collections = append(collections, name)
+		if prefix == "" && strings.HasPrefix(name, "org_") && strings.Contains(name, "__") {
+			continue
+		}
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		collections = append(collections, strings.TrimPrefix(name, prefix))
 	}
 	
 	return collections, nil
@@ -490,12 +575,19 @@ func (p *PostgresAdapter) BeginTransaction(ctx context.Context) (types.Transacti
 	}, nil
 }
 
-// RunInTransaction executes a function within a transaction
+// RunInTransaction executes a function within a transaction. Collections used with the
+// context passed to fn run their statements in the transaction; calls made while one is
+// already running join it.
 func (p *PostgresAdapter) RunInTransaction(ctx context.Context, fn func(ctx context.Context, tx types.Transaction) error) error {
+	if outer := transactionFrom(ctx); outer != nil {
+		return fn(ctx, outer)
+	}
+
 	tx, err := p.BeginTransaction(ctx)
 	if err != nil {
 		return err
 	}
+	ctx = context.WithValue(ctx, txContextKey{}, tx)
 	
 	defer func() {
 		if r := recover(); r != nil {
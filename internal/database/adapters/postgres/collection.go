@@ -7,10 +7,13 @@ import (
 	"fmt"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/madhouselabs/anybase/internal/database/types"
+	"github.com/madhouselabs/anybase/internal/geo"
 	"github.com/madhouselabs/anybase/pkg/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -19,8 +22,27 @@ import (
 // PostgresCollection wraps PostgreSQL table operations to implement types.Collection
 type PostgresCollection struct {
 	db        *sql.DB
+	tx        *sql.Tx // Set for collections obtained from a transaction
 	name      string
 	tableName string
+	postgis   bool // Geo queries use PostGIS instead of the haversine fallback
+}
+
+// conn returns where statements run: the collection's own transaction, the one bound to
+// ctx by RunInTransaction, or the connection pool
+func (c *PostgresCollection) conn(ctx context.Context) executor {
+	if c.tx != nil {
+		return c.tx
+	}
+	if tx := transactionFrom(ctx); tx != nil {
+		return tx.tx
+	}
+	return c.db
+}
+
+// table returns the table holding the collection for the organization bound to ctx
+func (c *PostgresCollection) table(ctx context.Context) string {
+	return qualifiedTable(ctx, c.tableName)
 }
 
 // InsertOne inserts a single document
@@ -53,14 +75,14 @@ func (c *PostgresCollection) InsertOne(ctx context.Context, document map[string]
 	
 	// Extract metadata fields - store as strings for MongoDB ObjectID compatibility
 	var createdBy, updatedBy *string
-	if cb, ok := document["created_by"]; ok {
-		if strID, ok := cb.(string); ok {
+	for _, key := range []string{"created_by", "_created_by"} {
+		if strID, ok := document[key].(string); ok {
 			createdBy = &strID
 		}
 	}
 	
-	if ub, ok := document["updated_by"]; ok {
-		if strID, ok := ub.(string); ok {
+	for _, key := range []string{"updated_by", "_updated_by"} {
+		if strID, ok := document[key].(string); ok {
 			updatedBy = &strID
 		}
 	}
@@ -76,6 +98,7 @@ func (c *PostgresCollection) InsertOne(ctx context.Context, document map[string]
 			}
 		} else if k != "_id" && k != "collection" && k != "created_by" && 
 		          k != "updated_by" && k != "created_at" && k != "updated_at" && 
+		          k != "_created_by" && k != "_updated_by" &&
 		          k != "_created_at" && k != "_updated_at" && k != "_version" && 
 		          k != "_deleted_at" {
 			// Include non-metadata fields
@@ -88,6 +111,7 @@ func (c *PostgresCollection) InsertOne(ctx context.Context, document map[string]
 		for k, v := range document {
 			if k != "_id" && k != "collection" && k != "created_by" && 
 			   k != "updated_by" && k != "created_at" && k != "updated_at" && 
+			   k != "_created_by" && k != "_updated_by" &&
 			   k != "_created_at" && k != "_updated_at" && k != "_version" && 
 			   k != "_deleted_at" {
 				dataOnly[k] = v
@@ -108,10 +132,10 @@ func (c *PostgresCollection) InsertOne(ctx context.Context, document map[string]
 		INSERT INTO %s (_id, data, _created_by, _updated_by, _created_at, _updated_at)
 		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 		RETURNING _id
-	`, c.tableName)
+	`, c.table(ctx))
 	
 	var returnedID uuid.UUID
-	err = c.db.QueryRowContext(ctx, query, id, data, createdBy, updatedBy).Scan(&returnedID)
+	err = c.conn(ctx).QueryRowContext(ctx, query, id, data, createdBy, updatedBy).Scan(&returnedID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert document: %w", err)
 	}
@@ -127,11 +151,16 @@ func (c *PostgresCollection) InsertMany(ctx context.Context, documents []map[str
 		return []types.ID{}, nil
 	}
 	
-	tx, err := c.db.BeginTx(ctx, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	// Inside a transaction the documents are inserted as part of it
+	tx, inTransaction := c.conn(ctx).(*sql.Tx)
+	if !inTransaction {
+		var err error
+		tx, err = c.db.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
 	}
-	defer tx.Rollback()
 	
 	ids := make([]types.ID, len(documents))
 	
@@ -173,7 +202,7 @@ func (c *PostgresCollection) InsertMany(ctx context.Context, documents []map[str
 		query := fmt.Sprintf(`
 			INSERT INTO %s (_id, data, _created_at, _updated_at)
 			VALUES ($1, $2, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
-		`, c.tableName)
+		`, c.table(ctx))
 		
 		if _, err := tx.ExecContext(ctx, query, id, data); err != nil {
 			return nil, fmt.Errorf("failed to insert document %d: %w", i, err)
@@ -182,6 +211,9 @@ func (c *PostgresCollection) InsertMany(ctx context.Context, documents []map[str
 		ids[i] = types.FromUUID(id)
 	}
 	
+	if inTransaction {
+		return ids, nil
+	}
 	if err := tx.Commit(); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
@@ -191,14 +223,17 @@ func (c *PostgresCollection) InsertMany(ctx context.Context, documents []map[str
 
 // FindOne finds a single document
 func (c *PostgresCollection) FindOne(ctx context.Context, filter map[string]interface{}, result interface{}) error {
-	where, args := c.buildWhereClause(filter)
+	where, args, err := c.buildWhereClause(filter)
+	if err != nil {
+		return err
+	}
 	
 	query := fmt.Sprintf(`
 		SELECT _id, data, _created_by, _updated_by, _created_at, _updated_at, _version
 		FROM %s
 		WHERE _deleted_at IS NULL %s
 		LIMIT 1
-	`, c.tableName, where)
+	`, c.table(ctx), where)
 	
 	var id uuid.UUID
 	var data json.RawMessage
@@ -206,7 +241,7 @@ func (c *PostgresCollection) FindOne(ctx context.Context, filter map[string]inte
 	var createdAt, updatedAt sql.NullTime
 	var version int
 	
-	err := c.db.QueryRowContext(ctx, query, args...).Scan(&id, &data, &createdBy, &updatedBy, &createdAt, &updatedAt, &version)
+	err = c.conn(ctx).QueryRowContext(ctx, query, args...).Scan(&id, &data, &createdBy, &updatedBy, &createdAt, &updatedAt, &version)
 	if err == sql.ErrNoRows {
 		return types.ErrNoDocuments
 	}
@@ -227,10 +262,8 @@ func (c *PostgresCollection) FindOne(ctx context.Context, filter map[string]inte
 	
 	// Handle special cases for known models
 	if userPtr, ok := result.(*models.User); ok {
-		// Manually set the password field since it has json:"-" tag
-		if pwd, ok := dataMap["password"].(string); ok {
-			userPtr.Password = pwd
-		}
+		// Restore the fields hidden from JSON, such as the password hash
+		restoreUserFields(userPtr, dataMap)
 		
 		// Get the _id from the data if it exists
 		if idStr, ok := dataMap["_id"].(string); ok {
@@ -256,6 +289,31 @@ func (c *PostgresCollection) FindOne(ctx context.Context, filter map[string]inte
 		if updatedAt.Valid {
 			userPtr.UpdatedAt = updatedAt.Time
 		}
+	} else if akPtr, ok := result.(*models.AccessKey); ok {
+		// Key hashes have json:"-" and the ID is stored as _id
+		if hash, ok := dataMap["key_hash"].(string); ok {
+			akPtr.KeyHash = hash
+		}
+		if hash, ok := dataMap["previous_key_hash"].(string); ok {
+			akPtr.PreviousKeyHash = hash
+		}
+		if idStr, ok := dataMap["_id"].(string); ok {
+			if objID, err := primitive.ObjectIDFromHex(idStr); err == nil {
+				akPtr.ID = objID
+			}
+		}
+		// created_by and the timestamps are kept in columns
+		if createdBy.Valid {
+			if objID, err := primitive.ObjectIDFromHex(createdBy.String); err == nil {
+				akPtr.CreatedBy = objID
+			}
+		}
+		if createdAt.Valid {
+			akPtr.CreatedAt = createdAt.Time
+		}
+		if updatedAt.Valid {
+			akPtr.UpdatedAt = updatedAt.Time
+		}
 	} else if docPtr, ok := result.(*models.Document); ok {
 		// Handle Document type specifically
 		// Get the _id from the JSONB data
@@ -433,21 +491,40 @@ func (c *PostgresCollection) FindOne(ctx context.Context, filter map[string]inte
 
 // Find finds multiple documents
 func (c *PostgresCollection) Find(ctx context.Context, filter map[string]interface{}, opts *types.FindOptions) (types.Cursor, error) {
-	where, args := c.buildWhereClause(filter)
+	where, args, err := c.buildWhereClause(filter)
+	if err != nil {
+		return nil, err
+	}
+	
+	// $near results carry their distance and are ordered nearest first
+	dataExpr := "data"
+	var orderBy []string
+	if field, near := geo.FindNear(filter); near != nil && fieldPathPattern.MatchString(field) {
+		distance, distanceArgs := c.geoDistance(field, near, len(args)+1)
+		args = append(args, distanceArgs...)
+		dataExpr = fmt.Sprintf("data || jsonb_build_object('%s', %s)", distanceField, distance)
+		orderBy = append(orderBy, distance)
+	}
 	
 	query := fmt.Sprintf(`
-		SELECT _id, data, _created_by, _updated_by, _created_at, _updated_at, _version
+		SELECT _id, %s, _created_by, _updated_by, _created_at, _updated_at, _version
 		FROM %s
 		WHERE _deleted_at IS NULL %s
-	`, c.tableName, where)
+	`, dataExpr, c.table(ctx), where)
 	
 	// Add sorting
 	if opts != nil && opts.Sort != nil {
-		orderBy := c.buildOrderBy(opts.Sort)
-		if orderBy != "" {
-			query += " ORDER BY " + orderBy
+		sortBy, err := c.buildOrderBy(opts.Sort)
+		if err != nil {
+			return nil, err
+		}
+		if sortBy != "" {
+			orderBy = append(orderBy, sortBy)
 		}
 	}
+	if len(orderBy) > 0 {
+		query += " ORDER BY " + strings.Join(orderBy, ", ")
+	}
 	
 	// Add limit and offset
 	if opts != nil {
@@ -459,7 +536,7 @@ func (c *PostgresCollection) Find(ctx context.Context, filter map[string]interfa
 		}
 	}
 	
-	rows, err := c.db.QueryContext(ctx, query, args...)
+	rows, err := c.conn(ctx).QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -470,7 +547,7 @@ func (c *PostgresCollection) Find(ctx context.Context, filter map[string]interfa
 // UpdateOne updates a single document
 func (c *PostgresCollection) UpdateOne(ctx context.Context, filter map[string]interface{}, update map[string]interface{}) (*types.UpdateResult, error) {
 	// Handle MongoDB update operators
-	var setOps map[string]interface{}
+	var setOps, incOps map[string]interface{}
 	hasSet := false
 	
 	// Check if this is a MongoDB-style update with operators
@@ -492,20 +569,34 @@ func (c *PostgresCollection) UpdateOne(ctx context.Context, filter map[string]in
 					hasSet = true
 				}
 			}
-			// Could handle other operators like $inc, $push, etc. here
+			if key == "$inc" {
+				switch v := update["$inc"].(type) {
+				case map[string]interface{}:
+					incOps = v
+				case bson.M:
+					incOps = map[string]interface{}(v)
+				}
+			}
+			// Could handle other operators like $push, etc. here
 		}
 	}
 	
 	// If no operators found, treat entire update as $set
-	if !hasSet {
+	if !hasSet && incOps == nil {
 		setOps = update
 	}
+	if setOps == nil {
+		setOps = map[string]interface{}{}
+	}
 	
-	where, args := c.buildWhereClause(filter)
+	where, args, err := c.buildWhereClause(filter)
+	if err != nil {
+		return nil, err
+	}
 	
 	// Build the update query with deep merge support
 	// Pass len(args)+1 as the starting index for update parameters
-	updateClause, updateArgs := c.buildJSONBUpdateClause(setOps, len(args)+1)
+	updateClause, updateArgs := c.buildJSONBUpdateClause(setOps, incOps, len(args)+1)
 	args = append(args, updateArgs...)
 	
 	var query string
@@ -515,17 +606,17 @@ func (c *PostgresCollection) UpdateOne(ctx context.Context, filter map[string]in
 			SET %s,
 			    _updated_at = CURRENT_TIMESTAMP
 			WHERE _deleted_at IS NULL %s
-		`, c.tableName, updateClause, where)
+		`, c.table(ctx), updateClause, where)
 	} else {
 		// Only updating timestamp if no data changes
 		query = fmt.Sprintf(`
 			UPDATE %s
 			SET _updated_at = CURRENT_TIMESTAMP
 			WHERE _deleted_at IS NULL %s
-		`, c.tableName, where)
+		`, c.table(ctx), where)
 	}
 	
-	result, err := c.db.ExecContext(ctx, query, args...)
+	result, err := c.conn(ctx).ExecContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -581,7 +672,10 @@ func (c *PostgresCollection) ReplaceOne(ctx context.Context, filter map[string]i
 		return nil, fmt.Errorf("failed to marshal replacement: %w", err)
 	}
 	
-	where, args := c.buildWhereClause(filter)
+	where, args, err := c.buildWhereClause(filter)
+	if err != nil {
+		return nil, err
+	}
 	
 	// Build update query
 	setClauses := []string{
@@ -605,9 +699,9 @@ func (c *PostgresCollection) ReplaceOne(ctx context.Context, filter map[string]i
 		    _updated_at = CURRENT_TIMESTAMP,
 		    _version = _version + 1
 		WHERE _deleted_at IS NULL %s
-	`, c.tableName, strings.Join(setClauses, ", "), where)
+	`, c.table(ctx), strings.Join(setClauses, ", "), where)
 	
-	result, err := c.db.ExecContext(ctx, query, args...)
+	result, err := c.conn(ctx).ExecContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -628,11 +722,14 @@ func (c *PostgresCollection) UpdateMany(ctx context.Context, filter map[string]i
 		setOps = update
 	}
 	
-	where, args := c.buildWhereClause(filter)
+	where, args, err := c.buildWhereClause(filter)
+	if err != nil {
+		return nil, err
+	}
 	
 	// Build the update query with deep merge support
 	// Pass len(args)+1 as the starting index for update parameters
-	updateClause, updateArgs := c.buildJSONBUpdateClause(setOps, len(args)+1)
+	updateClause, updateArgs := c.buildJSONBUpdateClause(setOps, nil, len(args)+1)
 	args = append(args, updateArgs...)
 	
 	var query string
@@ -642,17 +739,17 @@ func (c *PostgresCollection) UpdateMany(ctx context.Context, filter map[string]i
 			SET %s,
 			    _updated_at = CURRENT_TIMESTAMP
 			WHERE _deleted_at IS NULL %s
-		`, c.tableName, updateClause, where)
+		`, c.table(ctx), updateClause, where)
 	} else {
 		// Only updating timestamp if no data changes
 		query = fmt.Sprintf(`
 			UPDATE %s
 			SET _updated_at = CURRENT_TIMESTAMP
 			WHERE _deleted_at IS NULL %s
-		`, c.tableName, where)
+		`, c.table(ctx), where)
 	}
 	
-	result, err := c.db.ExecContext(ctx, query, args...)
+	result, err := c.conn(ctx).ExecContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -667,16 +764,19 @@ func (c *PostgresCollection) UpdateMany(ctx context.Context, filter map[string]i
 
 // DeleteOne deletes a single document
 func (c *PostgresCollection) DeleteOne(ctx context.Context, filter map[string]interface{}) (*types.DeleteResult, error) {
-	where, args := c.buildWhereClause(filter)
+	where, args, err := c.buildWhereClause(filter)
+	if err != nil {
+		return nil, err
+	}
 	
 	// Soft delete by default
 	query := fmt.Sprintf(`
 		UPDATE %s
 		SET _deleted_at = CURRENT_TIMESTAMP
 		WHERE _deleted_at IS NULL %s
-	`, c.tableName, where)
+	`, c.table(ctx), where)
 	
-	result, err := c.db.ExecContext(ctx, query, args...)
+	result, err := c.conn(ctx).ExecContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -690,15 +790,18 @@ func (c *PostgresCollection) DeleteOne(ctx context.Context, filter map[string]in
 
 // DeleteMany deletes multiple documents
 func (c *PostgresCollection) DeleteMany(ctx context.Context, filter map[string]interface{}) (*types.DeleteResult, error) {
-	where, args := c.buildWhereClause(filter)
+	where, args, err := c.buildWhereClause(filter)
+	if err != nil {
+		return nil, err
+	}
 	
 	query := fmt.Sprintf(`
 		UPDATE %s
 		SET _deleted_at = CURRENT_TIMESTAMP
 		WHERE _deleted_at IS NULL %s
-	`, c.tableName, where)
+	`, c.table(ctx), where)
 	
-	result, err := c.db.ExecContext(ctx, query, args...)
+	result, err := c.conn(ctx).ExecContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -712,16 +815,19 @@ func (c *PostgresCollection) DeleteMany(ctx context.Context, filter map[string]i
 
 // CountDocuments counts documents matching the filter
 func (c *PostgresCollection) CountDocuments(ctx context.Context, filter map[string]interface{}) (int64, error) {
-	where, args := c.buildWhereClause(filter)
+	where, args, err := c.buildWhereClause(filter)
+	if err != nil {
+		return 0, err
+	}
 	
 	query := fmt.Sprintf(`
 		SELECT COUNT(*)
 		FROM %s
 		WHERE _deleted_at IS NULL %s
-	`, c.tableName, where)
+	`, c.table(ctx), where)
 	
 	var count int64
-	err := c.db.QueryRowContext(ctx, query, args...).Scan(&count)
+	err = c.conn(ctx).QueryRowContext(ctx, query, args...).Scan(&count)
 	return count, err
 }
 
@@ -732,6 +838,10 @@ func (c *PostgresCollection) CreateIndex(ctx context.Context, index types.Index)
 		return fmt.Errorf("no fields specified for index")
 	}
 	
+	if index.Type == types.IndexTypeGeo {
+		return c.createGeoIndex(ctx, index)
+	}
+	
 	// Build index creation query for JSONB fields
 	// We need to preserve field order for compound indexes
 	// Convert map to sorted slice for consistent ordering
@@ -764,9 +874,9 @@ func (c *PostgresCollection) CreateIndex(ctx context.Context, index types.Index)
 		indexParts = append(indexParts, fmt.Sprintf("%s%s", jsonPath, order))
 	}
 	
-	indexName := index.Name
+	indexName := qualifiedIndex(ctx, index.Name)
 	if indexName == "" {
-		indexName = fmt.Sprintf("idx_%s_%d", c.tableName, len(indexParts))
+		indexName = fmt.Sprintf("idx_%s_%d", c.table(ctx), len(indexParts))
 	}
 	
 	// Use B-tree for unique indexes (GIN doesn't support unique)
@@ -781,16 +891,16 @@ func (c *PostgresCollection) CreateIndex(ctx context.Context, index types.Index)
 		query += "UNIQUE "
 	}
 	query += fmt.Sprintf("INDEX IF NOT EXISTS %s ON %s %s (%s)",
-		indexName, c.tableName, indexType, strings.Join(indexParts, ", "))
+		indexName, c.table(ctx), indexType, strings.Join(indexParts, ", "))
 	
-	_, err := c.db.ExecContext(ctx, query)
+	_, err := c.conn(ctx).ExecContext(ctx, query)
 	return err
 }
 
 // DropIndex drops an index from the collection
 func (c *PostgresCollection) DropIndex(ctx context.Context, name string) error {
-	query := fmt.Sprintf("DROP INDEX IF EXISTS %s", name)
-	_, err := c.db.ExecContext(ctx, query)
+	query := fmt.Sprintf("DROP INDEX IF EXISTS %s", qualifiedIndex(ctx, name))
+	_, err := c.conn(ctx).ExecContext(ctx, query)
 	return err
 }
 
@@ -802,7 +912,7 @@ func (c *PostgresCollection) ListIndexes(ctx context.Context) ([]types.Index, er
 		WHERE tablename = $1
 	`
 	
-	rows, err := c.db.QueryContext(ctx, query, c.tableName)
+	rows, err := c.conn(ctx).QueryContext(ctx, query, c.table(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -814,6 +924,16 @@ func (c *PostgresCollection) ListIndexes(ctx context.Context) ([]types.Index, er
 		if err := rows.Scan(&name, &def); err != nil {
 			continue
 		}
+		name = strings.TrimPrefix(name, indexPrefix(ctx))
+		
+		if field, ok := geoIndexField(def); ok {
+			indexes = append(indexes, types.Index{
+				Name: name,
+				Type: types.IndexTypeGeo,
+				Keys: map[string]int{field: 1},
+			})
+			continue
+		}
 		
 		// Initialize the index with an empty Keys map
 		keys := make(map[string]int)
@@ -911,9 +1031,12 @@ func (c *PostgresCollection) Aggregate(ctx context.Context, pipeline []map[strin
 	return nil, fmt.Errorf("aggregation not yet implemented for PostgreSQL")
 }
 
+// incFieldPattern restricts $inc to plain top-level field names, which are inlined into SQL
+var incFieldPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
 // buildJSONBUpdateClause builds an UPDATE clause that handles nested JSONB fields
 // It starts with the current argIndex from the WHERE clause
-func (c *PostgresCollection) buildJSONBUpdateClause(updates map[string]interface{}, startArgIndex int) (string, []interface{}) {
+func (c *PostgresCollection) buildJSONBUpdateClause(updates map[string]interface{}, increments map[string]interface{}, startArgIndex int) (string, []interface{}) {
 	var setClauses []string
 	var args []interface{}
 	argIndex := startArgIndex
@@ -971,8 +1094,9 @@ func (c *PostgresCollection) buildJSONBUpdateClause(updates map[string]interface
 	}
 	
 	// Handle nested updates using jsonb_set
+	dataUpdate := ""
 	if len(nested) > 0 {
-		dataUpdate := "data"
+		dataUpdate = "data"
 		for topKey, nestedMap := range nested {
 			// For each top-level key with nested updates, use jsonb_set
 			nestedJSON, _ := json.Marshal(nestedMap)
@@ -989,15 +1113,41 @@ func (c *PostgresCollection) buildJSONBUpdateClause(updates map[string]interface
 			topJSON, _ := json.Marshal(topLevel)
 			dataUpdate = fmt.Sprintf("%s || $%d::jsonb", dataUpdate, argIndex)
 			args = append(args, string(topJSON))
+			argIndex++
 		}
-		
-		setClauses = append(setClauses, fmt.Sprintf("data = %s", dataUpdate))
 	} else if len(topLevel) > 0 {
 		// Only top-level updates - use simple merge
 		topJSON, _ := json.Marshal(topLevel)
-		setClauses = append(setClauses, fmt.Sprintf("data = data || $%d::jsonb", argIndex))
+		dataUpdate = fmt.Sprintf("data || $%d::jsonb", argIndex)
 		args = append(args, string(topJSON))
-		argIndex++ // Increment for consistency, even though not used after this
+		argIndex++
+	}
+	
+	// $inc adds to top-level numeric fields, treating missing fields as zero
+	if len(increments) > 0 {
+		if dataUpdate == "" {
+			dataUpdate = "data"
+		}
+		fields := make([]string, 0, len(increments))
+		for field := range increments {
+			if incFieldPattern.MatchString(field) {
+				fields = append(fields, field)
+			}
+		}
+		sort.Strings(fields)
+		pairs := make([]string, 0, len(fields))
+		for _, field := range fields {
+			pairs = append(pairs, fmt.Sprintf("'%s', COALESCE((data->>'%s')::numeric, 0) + $%d::numeric", field, field, argIndex))
+			args = append(args, increments[field])
+			argIndex++
+		}
+		if len(pairs) > 0 {
+			dataUpdate = fmt.Sprintf("%s || jsonb_build_object(%s)", dataUpdate, strings.Join(pairs, ", "))
+		}
+	}
+	
+	if dataUpdate != "" && dataUpdate != "data" {
+		setClauses = append(setClauses, fmt.Sprintf("data = %s", dataUpdate))
 	}
 	
 	// If no setClauses were added but we have updates, ensure we at least update the data
@@ -1012,16 +1162,95 @@ func (c *PostgresCollection) buildJSONBUpdateClause(updates map[string]interface
 }
 
 // buildWhereClause builds a WHERE clause from a filter
-func (c *PostgresCollection) buildWhereClause(filter map[string]interface{}) (string, []interface{}) {
-	if len(filter) == 0 {
-		return "", nil
+func (c *PostgresCollection) buildWhereClause(filter map[string]interface{}) (string, []interface{}, error) {
+	conditions, args, err := c.buildConditions(filter, 1)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(conditions) > 0 {
+		return " AND " + strings.Join(conditions, " AND "), args, nil
 	}
 	
+	return "", nil, nil
+}
+
+// checkFieldPath rejects field names that are not plain dotted paths. Field names are
+// written into the SQL, so anything else could change the meaning of the query.
+func checkFieldPath(field string) error {
+	if !fieldPathPattern.MatchString(field) {
+		return fmt.Errorf("invalid field '%s'", field)
+	}
+	return nil
+}
+
+// buildConditions translates a filter into conditions whose placeholders start at argIndex
+func (c *PostgresCollection) buildConditions(filter map[string]interface{}, argIndex int) ([]string, []interface{}, error) {
 	var conditions []string
 	var args []interface{}
-	argIndex := 1
 	
 	for key, value := range filter {
+		// Logical operators: {"$and": [filter, ...]} and {"$or": [filter, ...]}
+		if key == types.OpAnd || key == types.OpOr {
+			condition, logicalArgs, err := c.buildLogical(key, value, argIndex)
+			if err != nil {
+				return nil, nil, err
+			}
+			conditions = append(conditions, condition)
+			args = append(args, logicalArgs...)
+			argIndex += len(logicalArgs)
+			continue
+		}
+		if err := checkFieldPath(key); err != nil {
+			return nil, nil, err
+		}
+
+		// Creator and last editor are stored in columns; older rows kept them in the data
+		if key == "_created_by" || key == "_updated_by" {
+			if value == nil {
+				conditions = append(conditions, fmt.Sprintf("COALESCE(%s, data->>'%s') IS NULL", key, key))
+				continue
+			}
+			conditions = append(conditions, fmt.Sprintf("COALESCE(%s, data->>'%s') = $%d", key, key, argIndex))
+			if objID, ok := value.(primitive.ObjectID); ok {
+				args = append(args, objID.Hex())
+			} else {
+				args = append(args, fmt.Sprintf("%v", value))
+			}
+			argIndex++
+			continue
+		}
+
+		// Geospatial operators: {"field": {"$near" | "$geoWithin" | "$geoIntersects": ...}}
+		if query, ok, err := geo.ParseQuery(value); ok {
+			if err != nil {
+				// Filters are validated by callers; an invalid query never matches
+				conditions = append(conditions, "FALSE")
+				continue
+			}
+			condition, geoArgs := c.geoCondition(key, query, argIndex)
+			conditions = append(conditions, condition)
+			args = append(args, geoArgs...)
+			argIndex += len(geoArgs)
+			continue
+		}
+
+		// Case-insensitive equality: {"field": {"$eqfold": "text"}}
+		if text, ok := foldOperand(value); ok {
+			conditions = append(conditions, fmt.Sprintf("lower(data->>'%s') = lower($%d)", key, argIndex))
+			args = append(args, text)
+			argIndex++
+			continue
+		}
+
+		// Membership tests: {"field": {"$in": [...]}}
+		if values, ok := inOperand(value); ok {
+			column := fmt.Sprintf("data->>'%s'", key)
+			conditions = append(conditions, fmt.Sprintf("%s = ANY($%d)", column, argIndex))
+			args = append(args, pq.Array(values))
+			argIndex++
+			continue
+		}
+
 		// Handle special fields
 		if key == "_id" {
 			// For MongoDB compatibility, check the _id in JSONB data first
@@ -1059,21 +1288,138 @@ func (c *PostgresCollection) buildWhereClause(filter map[string]interface{}) (st
 		}
 	}
 	
-	if len(conditions) > 0 {
-		return " AND " + strings.Join(conditions, " AND "), args
+	return conditions, args, nil
+}
+
+// buildLogical combines sub-filters with AND or OR. An empty $and matches everything
+// and an empty $or matches nothing.
+func (c *PostgresCollection) buildLogical(op string, value interface{}, argIndex int) (string, []interface{}, error) {
+	var items []interface{}
+	switch v := value.(type) {
+	case []interface{}:
+		items = v
+	case bson.A:
+		items = v
+	case []map[string]interface{}:
+		for _, item := range v {
+			items = append(items, item)
+		}
+	default:
+		return "FALSE", nil, nil
 	}
-	
-	return "", nil
+
+	var parts []string
+	var args []interface{}
+	for _, item := range items {
+		var sub map[string]interface{}
+		switch v := item.(type) {
+		case map[string]interface{}:
+			sub = v
+		case bson.M:
+			sub = v
+		default:
+			return "FALSE", nil, nil
+		}
+		conditions, subArgs, err := c.buildConditions(sub, argIndex+len(args))
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, subArgs...)
+		if len(conditions) == 0 {
+			parts = append(parts, "TRUE")
+		} else {
+			parts = append(parts, "("+strings.Join(conditions, " AND ")+")")
+		}
+	}
+
+	if len(parts) == 0 {
+		if op == types.OpAnd {
+			return "TRUE", nil, nil
+		}
+		return "FALSE", nil, nil
+	}
+	joiner := " AND "
+	if op == types.OpOr {
+		joiner = " OR "
+	}
+	return "(" + strings.Join(parts, joiner) + ")", args, nil
+}
+
+// inOperand extracts the values of an {"$in": [...]} filter as the text form JSONB fields are compared in
+func inOperand(value interface{}) ([]string, bool) {
+	var operators map[string]interface{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		operators = v
+	case bson.M:
+		operators = v
+	default:
+		return nil, false
+	}
+	list, ok := operators["$in"]
+	if !ok || len(operators) != 1 {
+		return nil, false
+	}
+
+	var items []interface{}
+	switch v := list.(type) {
+	case []interface{}:
+		items = v
+	case []string:
+		for _, item := range v {
+			items = append(items, item)
+		}
+	case bson.A:
+		items = v
+	default:
+		return nil, false
+	}
+
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		switch v := item.(type) {
+		case string:
+			values = append(values, v)
+		case primitive.ObjectID:
+			values = append(values, v.Hex())
+		case float64:
+			values = append(values, strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			values = append(values, fmt.Sprintf("%v", v))
+		}
+	}
+	return values, true
+}
+
+// foldOperand extracts the string of an {"$eqfold": "text"} filter
+func foldOperand(value interface{}) (string, bool) {
+	var operators map[string]interface{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		operators = v
+	case bson.M:
+		operators = v
+	default:
+		return "", false
+	}
+	text, ok := operators[types.OpEqualFold].(string)
+	if !ok || len(operators) != 1 {
+		return "", false
+	}
+	return text, true
 }
 
 // buildOrderBy builds an ORDER BY clause
-func (c *PostgresCollection) buildOrderBy(sort map[string]int) string {
+func (c *PostgresCollection) buildOrderBy(sort map[string]int) (string, error) {
 	if len(sort) == 0 {
-		return ""
+		return "", nil
 	}
 	
 	var parts []string
 	for field, direction := range sort {
+		if err := checkFieldPath(field); err != nil {
+			return "", err
+		}
 		order := "ASC"
 		if direction < 0 {
 			order = "DESC"
@@ -1088,10 +1434,12 @@ func (c *PostgresCollection) buildOrderBy(sort map[string]int) string {
 		}
 	}
 	
-	return strings.Join(parts, ", ")
+	return strings.Join(parts, ", "), nil
 }
 
 // GetVectorOperations returns a VectorOperations instance for this collection
 func (c *PostgresCollection) GetVectorOperations() *VectorOperations {
-	return NewVectorOperations(c.db, c.tableName)
+	ops := NewVectorOperations(c.db, c.tableName)
+	ops.collection = c
+	return ops
 }
\ No newline at end of file
@@ -0,0 +1,93 @@
+package postgres
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildWhereClauseRejectsUnsafeKeys(t *testing.T) {
+	c := &PostgresCollection{}
+
+	tests := []struct {
+		name   string
+		filter map[string]interface{}
+	}{
+		{"scalar", map[string]interface{}{"a')) OR ((TRUE": "x"}},
+		{"null", map[string]interface{}{"a' OR '1'='1": nil}},
+		{"in", map[string]interface{}{"a') OR ('1": map[string]interface{}{"$in": []interface{}{"x"}}}},
+		{"contains", map[string]interface{}{"a', 1) OR TRUE --": map[string]interface{}{"b": 1}}},
+		{"and", map[string]interface{}{"$and": []interface{}{
+			map[string]interface{}{"name": "x"},
+			map[string]interface{}{"a')) OR ((TRUE": "x"},
+		}}},
+		{"nested or", map[string]interface{}{"$or": []interface{}{
+			map[string]interface{}{"$and": []interface{}{
+				map[string]interface{}{"a;DROP TABLE users": "x"},
+			}},
+		}}},
+		{"empty", map[string]interface{}{"": "x"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, args, err := c.buildWhereClause(tt.filter)
+			if err == nil {
+				t.Fatalf("expected an error, got %q with %v", where, args)
+			}
+		})
+	}
+}
+
+func TestBuildWhereClauseAcceptsFieldPaths(t *testing.T) {
+	c := &PostgresCollection{}
+
+	filter := map[string]interface{}{
+		"$or": []interface{}{
+			map[string]interface{}{"status": "active"},
+			map[string]interface{}{"owner.id": map[string]interface{}{"$in": []interface{}{"a", "b"}}},
+		},
+		"first-name": nil,
+		"_id":        "abc",
+	}
+	where, args, err := c.buildWhereClause(filter)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(args) != 3 {
+		t.Fatalf("expected 3 arguments, got %d: %v", len(args), args)
+	}
+	for _, want := range []string{"data->>'status'", "data->>'owner.id'", "data->>'first-name' IS NULL"} {
+		if !strings.Contains(where, want) {
+			t.Errorf("where clause %q is missing %q", where, want)
+		}
+	}
+}
+
+func TestBuildOrderByRejectsUnsafeKeys(t *testing.T) {
+	c := &PostgresCollection{}
+
+	for _, field := range []string{"name; DROP TABLE users", "_id, (SELECT 1)", "a' DESC --"} {
+		if _, err := c.buildOrderBy(map[string]int{field: 1}); err == nil {
+			t.Errorf("expected %q to be rejected", field)
+		}
+	}
+
+	orderBy, err := c.buildOrderBy(map[string]int{"created.at": -1})
+	if err != nil || orderBy != "data->>'created.at' DESC" {
+		t.Fatalf("got %q, %v", orderBy, err)
+	}
+}
+
+func TestBuildWhereClauseEqualFold(t *testing.T) {
+	c := &PostgresCollection{}
+
+	where, args, err := c.buildWhereClause(map[string]interface{}{
+		"email": map[string]interface{}{"$eqfold": "Alice@Example.com"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if where != " AND lower(data->>'email') = lower($1)" || len(args) != 1 || args[0] != "Alice@Example.com" {
+		t.Fatalf("got %q with %v", where, args)
+	}
+}
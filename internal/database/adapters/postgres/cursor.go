@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/madhouselabs/anybase/pkg/models"
@@ -48,10 +49,7 @@ func (c *PostgresCursor) Decode(result interface{}) error {
 	// Handle specific types
 	if userPtr, ok := result.(*models.User); ok {
 		// Handle User type - similar to FindOne
-		// Manually set the password field since it has json:"-" tag
-		if pwd, ok := dataMap["password"].(string); ok {
-			userPtr.Password = pwd
-		}
+		restoreUserFields(userPtr, dataMap)
 		
 		// Get the _id from the data if it exists
 		if idStr, ok := dataMap["_id"].(string); ok {
@@ -77,6 +75,31 @@ func (c *PostgresCursor) Decode(result interface{}) error {
 		if updatedAt.Valid {
 			userPtr.UpdatedAt = updatedAt.Time
 		}
+	} else if akPtr, ok := result.(*models.AccessKey); ok {
+		// Key hashes have json:"-" and the ID is stored as _id
+		if hash, ok := dataMap["key_hash"].(string); ok {
+			akPtr.KeyHash = hash
+		}
+		if hash, ok := dataMap["previous_key_hash"].(string); ok {
+			akPtr.PreviousKeyHash = hash
+		}
+		if idStr, ok := dataMap["_id"].(string); ok {
+			if objID, err := primitive.ObjectIDFromHex(idStr); err == nil {
+				akPtr.ID = objID
+			}
+		}
+		// created_by and the timestamps are kept in columns
+		if createdBy.Valid {
+			if objID, err := primitive.ObjectIDFromHex(createdBy.String); err == nil {
+				akPtr.CreatedBy = objID
+			}
+		}
+		if createdAt.Valid {
+			akPtr.CreatedAt = createdAt.Time
+		}
+		if updatedAt.Valid {
+			akPtr.UpdatedAt = updatedAt.Time
+		}
 	} else if docPtr, ok := result.(*models.Document); ok {
 		// Handle Document type specifically
 		// Get the _id from the JSONB data
@@ -271,4 +294,66 @@ func (c *PostgresCursor) All(ctx context.Context, results interface{}) error {
 	}
 	
 	return fmt.Errorf("unsupported result type for All(): %T", results)
-}
\ No newline at end of file
+}
+
+// restoreUserFields sets the user fields tagged json:"-", which decoding the stored JSON
+// skips. Without them lockout state and emailed token hashes would never be read back.
+func restoreUserFields(user *models.User, data map[string]interface{}) {
+	if pwd, ok := data["password"].(string); ok {
+		user.Password = pwd
+	}
+	if history, ok := data["password_history"].([]interface{}); ok {
+		user.PasswordHistory = make([]string, 0, len(history))
+		for _, hash := range history {
+			if s, ok := hash.(string); ok {
+				user.PasswordHistory = append(user.PasswordHistory, s)
+			}
+		}
+	}
+	if token, ok := data["email_verification_token"].(string); ok {
+		user.EmailVerificationToken = token
+	}
+	if token, ok := data["password_reset_token"].(string); ok {
+		user.PasswordResetToken = token
+	}
+	if attempts, ok := data["login_attempts"].(float64); ok {
+		user.LoginAttempts = int(attempts)
+	}
+	if secret, ok := data["mfa_secret"].(string); ok {
+		user.MFASecret = secret
+	}
+	if secret, ok := data["mfa_pending_secret"].(string); ok {
+		user.MFAPendingSecret = secret
+	}
+	if codes, ok := data["mfa_recovery_codes"].([]interface{}); ok {
+		user.MFARecoveryCodes = make([]string, 0, len(codes))
+		for _, code := range codes {
+			if s, ok := code.(string); ok {
+				user.MFARecoveryCodes = append(user.MFARecoveryCodes, s)
+			}
+		}
+	}
+	if step, ok := data["mfa_last_step"].(float64); ok {
+		user.MFALastStep = int64(step)
+	}
+	if revision, ok := data["mfa_revision"].(float64); ok {
+		user.MFARevision = int64(revision)
+	}
+	user.EmailVerificationExpiry = storedTime(data["email_verification_expiry"])
+	user.PasswordResetExpiry = storedTime(data["password_reset_expiry"])
+	user.LockedUntil = storedTime(data["locked_until"])
+	user.DeletedAt = storedTime(data["deleted_at"])
+}
+
+// storedTime parses a timestamp kept in a JSON document, returning nil when it is unset
+func storedTime(value interface{}) *time.Time {
+	s, ok := value.(string)
+	if !ok || s == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil || t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,268 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/madhouselabs/anybase/pkg/models"
+)
+
+const (
+	defaultTermsFacetSize = 10
+	maxHistogramBuckets   = 1000
+)
+
+// dateHistogramIntervals are the date_trunc units accepted by date histograms
+var dateHistogramIntervals = map[string]bool{
+	"hour": true, "day": true, "week": true, "month": true, "quarter": true, "year": true,
+}
+
+// FacetQuery describes aggregates computed over the documents matching a filter and,
+// optionally, a full-text query
+type FacetQuery struct {
+	Filter     map[string]interface{}
+	Facets     map[string]models.FacetRequest
+	TextSearch *models.TextSearchConfig
+	Text       string
+	TextMode   string
+}
+
+// ValidateFacet checks the field, type and options of a facet request
+func ValidateFacet(facet models.FacetRequest) error {
+	if !fieldPathPattern.MatchString(facet.Field) {
+		return fmt.Errorf("invalid facet field '%s'", facet.Field)
+	}
+	switch facet.Type {
+	case models.FacetTypeTerms:
+		if facet.Size < 0 {
+			return fmt.Errorf("facet size must not be negative")
+		}
+	case models.FacetTypeRange:
+		if len(facet.Ranges) == 0 {
+			return fmt.Errorf("range facet on '%s' requires at least one range", facet.Field)
+		}
+		for _, r := range facet.Ranges {
+			if r.From == nil && r.To == nil {
+				return fmt.Errorf("range facet on '%s' has a range without bounds", facet.Field)
+			}
+		}
+	case models.FacetTypeDateHistogram:
+		if !dateHistogramIntervals[facet.Interval] {
+			return fmt.Errorf("invalid date histogram interval '%s': use hour, day, week, month, quarter or year", facet.Interval)
+		}
+	default:
+		return fmt.Errorf("unsupported facet type '%s'", facet.Type)
+	}
+	return nil
+}
+
+// Distinct returns the distinct values of a field with their document counts, most
+// frequent first. Array fields contribute each of their elements.
+func (c *PostgresCollection) Distinct(ctx context.Context, field string, filter map[string]interface{}, limit int) ([]models.FacetBucket, error) {
+	if !fieldPathPattern.MatchString(field) {
+		return nil, fmt.Errorf("invalid field '%s'", field)
+	}
+	where, args, err := c.buildWhereClause(filter)
+	if err != nil {
+		return nil, err
+	}
+	return c.termsFacet(ctx, where, args, field, limit)
+}
+
+// Facets computes every requested facet over the same set of matching documents
+func (c *PostgresCollection) Facets(ctx context.Context, q FacetQuery) (map[string]models.FacetResult, error) {
+	where, args, err := c.buildWhereClause(q.Filter)
+	if err != nil {
+		return nil, err
+	}
+	if q.Text != "" {
+		language := textLanguage(q.TextSearch)
+		if !textSearchLanguage.MatchString(language) {
+			return nil, fmt.Errorf("invalid text search language '%s'", language)
+		}
+		tsQuery, queryText, err := buildTSQuery(language, q.TextMode, q.Text)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, queryText)
+		where += fmt.Sprintf(" AND %s @@ %s", textSearchColumn, fmt.Sprintf(tsQuery, len(args)))
+	}
+
+	// Run facets in a stable order so errors are reproducible
+	names := make([]string, 0, len(q.Facets))
+	for name := range q.Facets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make(map[string]models.FacetResult, len(names))
+	for _, name := range names {
+		facet := q.Facets[name]
+		if err := ValidateFacet(facet); err != nil {
+			return nil, fmt.Errorf("facet '%s': %w", name, err)
+		}
+
+		var buckets []models.FacetBucket
+		var err error
+		switch facet.Type {
+		case models.FacetTypeTerms:
+			buckets, err = c.termsFacet(ctx, where, args, facet.Field, facet.Size)
+		case models.FacetTypeRange:
+			buckets, err = c.rangeFacet(ctx, where, args, facet.Field, facet.Ranges)
+		case models.FacetTypeDateHistogram:
+			buckets, err = c.dateHistogramFacet(ctx, where, args, facet.Field, facet.Interval)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("facet '%s': %w", name, err)
+		}
+		results[name] = models.FacetResult{Type: facet.Type, Buckets: buckets}
+	}
+	return results, nil
+}
+
+// termsFacet counts documents per value of a field
+func (c *PostgresCollection) termsFacet(ctx context.Context, where string, args []interface{}, field string, size int) ([]models.FacetBucket, error) {
+	if size <= 0 {
+		size = defaultTermsFacetSize
+	}
+	value := fieldJSONExpr(field)
+	query := fmt.Sprintf(`
+		SELECT value, COUNT(*)
+		FROM (
+			SELECT jsonb_array_elements(
+				CASE WHEN jsonb_typeof(%[1]s) = 'array' THEN %[1]s ELSE jsonb_build_array(%[1]s) END
+			) AS value
+			FROM %[2]s
+			WHERE _deleted_at IS NULL %[3]s
+		) v
+		WHERE value IS NOT NULL AND value <> 'null'::jsonb
+		GROUP BY value
+		ORDER BY COUNT(*) DESC, value
+		LIMIT $%[4]d
+	`, value, c.table(ctx), where, len(args)+1)
+
+	rows, err := c.conn(ctx).QueryContext(ctx, query, append(args, size)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute terms: %w", err)
+	}
+	defer rows.Close()
+
+	buckets := []models.FacetBucket{}
+	for rows.Next() {
+		var raw []byte
+		var count int64
+		if err := rows.Scan(&raw, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan terms: %w", err)
+		}
+		var key interface{}
+		if err := json.Unmarshal(raw, &key); err != nil {
+			continue
+		}
+		buckets = append(buckets, models.FacetBucket{Key: key, Count: count})
+	}
+	return buckets, rows.Err()
+}
+
+// rangeFacet counts documents whose numeric field falls in each range
+func (c *PostgresCollection) rangeFacet(ctx context.Context, where string, args []interface{}, field string, ranges []models.FacetRange) ([]models.FacetBucket, error) {
+	var counts []string
+	for _, r := range ranges {
+		var bounds []string
+		if r.From != nil {
+			args = append(args, *r.From)
+			bounds = append(bounds, fmt.Sprintf("n >= $%d", len(args)))
+		}
+		if r.To != nil {
+			args = append(args, *r.To)
+			bounds = append(bounds, fmt.Sprintf("n < $%d", len(args)))
+		}
+		counts = append(counts, fmt.Sprintf("COUNT(*) FILTER (WHERE %s)", strings.Join(bounds, " AND ")))
+	}
+
+	value := fieldJSONExpr(field)
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM (
+			SELECT CASE WHEN jsonb_typeof(%s) = 'number' THEN (%s)::numeric END AS n
+			FROM %s
+			WHERE _deleted_at IS NULL %s
+		) v
+	`, strings.Join(counts, ", "), value, textFieldExpr(field), c.table(ctx), where)
+
+	results := make([]int64, len(ranges))
+	dest := make([]interface{}, len(ranges))
+	for i := range results {
+		dest[i] = &results[i]
+	}
+	if err := c.conn(ctx).QueryRowContext(ctx, query, args...).Scan(dest...); err != nil {
+		return nil, fmt.Errorf("failed to compute ranges: %w", err)
+	}
+
+	buckets := make([]models.FacetBucket, len(ranges))
+	for i, r := range ranges {
+		key := r.Key
+		if key == "" {
+			key = rangeKey(r)
+		}
+		buckets[i] = models.FacetBucket{Key: key, Count: results[i], From: r.From, To: r.To}
+	}
+	return buckets, nil
+}
+
+// dateHistogramFacet counts documents per calendar interval of a timestamp field
+func (c *PostgresCollection) dateHistogramFacet(ctx context.Context, where string, args []interface{}, field, interval string) ([]models.FacetBucket, error) {
+	text := textFieldExpr(field)
+	// Values that do not start with a date are skipped rather than failing the cast
+	query := fmt.Sprintf(`
+		SELECT bucket, COUNT(*)
+		FROM (
+			SELECT date_trunc('%s', (%s)::timestamptz AT TIME ZONE 'UTC') AS bucket
+			FROM %s
+			WHERE _deleted_at IS NULL %s
+			  AND %s ~ '^\d{4}-\d{2}-\d{2}'
+		) v
+		GROUP BY bucket
+		ORDER BY bucket
+		LIMIT %d
+	`, interval, text, c.table(ctx), where, text, maxHistogramBuckets)
+
+	rows, err := c.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute date histogram: %w", err)
+	}
+	defer rows.Close()
+
+	buckets := []models.FacetBucket{}
+	for rows.Next() {
+		var bucket time.Time
+		var count int64
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan date histogram: %w", err)
+		}
+		key := time.Date(bucket.Year(), bucket.Month(), bucket.Day(), bucket.Hour(), 0, 0, 0, time.UTC)
+		buckets = append(buckets, models.FacetBucket{Key: key.Format(time.RFC3339), Count: count})
+	}
+	return buckets, rows.Err()
+}
+
+// rangeKey names a range by its bounds, using * for an open end
+func rangeKey(r models.FacetRange) string {
+	from, to := "*", "*"
+	if r.From != nil {
+		from = strconv.FormatFloat(*r.From, 'f', -1, 64)
+	}
+	if r.To != nil {
+		to = strconv.FormatFloat(*r.To, 'f', -1, 64)
+	}
+	return from + "-" + to
+}
+
+// fieldJSONExpr extracts a (possibly nested) field of the JSONB data as jsonb
+func fieldJSONExpr(field string) string {
+	return fmt.Sprintf("(data #> '{%s}')", strings.ReplaceAll(field, ".", ","))
+}
@@ -0,0 +1,201 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/madhouselabs/anybase/internal/database/types"
+	"github.com/madhouselabs/anybase/internal/geo"
+)
+
+// distanceField is added to documents returned by $near queries, in meters
+const distanceField = "_distance"
+
+// geoIndexPath finds the JSONB paths referenced by a geo index definition
+var geoIndexPath = regexp.MustCompile(`#>> '\{([^}]+)\}'`)
+
+// geoCondition translates a geo operator on a field into a WHERE condition whose
+// placeholders start at argIndex. PostGIS evaluates Points and Polygons on the spheroid;
+// without it only Point fields can match and distances use the haversine formula.
+func (c *PostgresCollection) geoCondition(field string, q *geo.Query, argIndex int) (string, []interface{}) {
+	if !fieldPathPattern.MatchString(field) {
+		return "FALSE", nil
+	}
+	if c.postgis {
+		return postgisCondition(field, q, argIndex)
+	}
+	return haversineCondition(field, q, argIndex)
+}
+
+// geoDistance returns the expression for the distance in meters from a field to the
+// $near point
+func (c *PostgresCollection) geoDistance(field string, q *geo.Query, argIndex int) (string, []interface{}) {
+	if c.postgis {
+		return fmt.Sprintf("ST_Distance(%s, ST_GeomFromGeoJSON($%d::text)::geography)", geographyExpr(field), argIndex),
+			[]interface{}{q.Shape.GeoJSON()}
+	}
+	return haversineExpr(field, argIndex, argIndex+1), []interface{}{q.Shape.Point.Lat, q.Shape.Point.Lng}
+}
+
+// createGeoIndex indexes a GeoJSON field: a GiST index on the geography with PostGIS,
+// otherwise a B-tree on the point's latitude and longitude
+func (c *PostgresCollection) createGeoIndex(ctx context.Context, index types.Index) error {
+	if len(index.Keys) != 1 {
+		return fmt.Errorf("a geo index covers exactly one field")
+	}
+	var field string
+	for f := range index.Keys {
+		field = f
+	}
+	if !fieldPathPattern.MatchString(field) {
+		return fmt.Errorf("invalid geo index field '%s'", field)
+	}
+
+	indexName := qualifiedIndex(ctx, index.Name)
+	if indexName == "" {
+		indexName = fmt.Sprintf("idx_%s_%s_geo", c.table(ctx), strings.NewReplacer(".", "_", "-", "_").Replace(field))
+	}
+
+	var query string
+	if c.postgis {
+		query = fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING gist ((%s))",
+			indexName, c.table(ctx), geographyExpr(field))
+	} else {
+		query = fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING btree ((%s), (%s))",
+			indexName, c.table(ctx), pointCoordExpr(field, 1), pointCoordExpr(field, 0))
+	}
+	_, err := c.conn(ctx).ExecContext(ctx, query)
+	return err
+}
+
+// geoIndexField reports the indexed field when an index definition is a geo index
+func geoIndexField(def string) (string, bool) {
+	lower := strings.ToLower(def)
+	if !strings.Contains(lower, "st_geomfromgeojson") && !strings.Contains(lower, ",coordinates,1}") {
+		return "", false
+	}
+	match := geoIndexPath.FindStringSubmatch(def)
+	if match == nil {
+		return "", false
+	}
+	path := strings.TrimSuffix(strings.TrimSuffix(match[1], ",coordinates,1"), ",coordinates,0")
+	return strings.ReplaceAll(path, ",", "."), true
+}
+
+func postgisCondition(field string, q *geo.Query, argIndex int) (string, []interface{}) {
+	column := geographyExpr(field)
+	shape := fmt.Sprintf("ST_GeomFromGeoJSON($%d::text)::geography", argIndex)
+	args := []interface{}{q.Shape.GeoJSON()}
+
+	switch q.Operator {
+	case geo.OpNear:
+		conditions := []string{column + " IS NOT NULL"}
+		if q.MaxDistance != nil {
+			args = append(args, *q.MaxDistance)
+			conditions = append(conditions, fmt.Sprintf("ST_DWithin(%s, %s, $%d::float8)", column, shape, argIndex+len(args)-1))
+		}
+		if q.MinDistance != nil {
+			args = append(args, *q.MinDistance)
+			conditions = append(conditions, fmt.Sprintf("ST_Distance(%s, %s) >= $%d::float8", column, shape, argIndex+len(args)-1))
+		}
+		return "(" + strings.Join(conditions, " AND ") + ")", args
+	case geo.OpGeoWithin:
+		if q.Radius > 0 {
+			// Points are compared by distance; buffering is only needed for polygons
+			args = append(args, q.Radius)
+			radius := fmt.Sprintf("$%d::float8", argIndex+1)
+			return fmt.Sprintf("(CASE WHEN %s->>'type' = 'Point' THEN ST_DWithin(%s, %s, %s) ELSE ST_CoveredBy(%s, ST_Buffer(%s, %s)) END)",
+				fieldJSONExpr(field), column, shape, radius, column, shape, radius), args
+		}
+		return fmt.Sprintf("ST_CoveredBy(%s, %s)", column, shape), args
+	default:
+		return fmt.Sprintf("ST_Intersects(%s, %s)", column, shape), args
+	}
+}
+
+func haversineCondition(field string, q *geo.Query, argIndex int) (string, []interface{}) {
+	lat, lng := pointCoordExpr(field, 1), pointCoordExpr(field, 0)
+
+	switch {
+	case q.Operator == geo.OpNear || q.Radius > 0:
+		distance := haversineExpr(field, argIndex, argIndex+1)
+		args := []interface{}{q.Shape.Point.Lat, q.Shape.Point.Lng}
+		conditions := []string{lat + " IS NOT NULL"}
+		if q.Radius > 0 {
+			args = append(args, q.Radius)
+			conditions = append(conditions, fmt.Sprintf("%s <= $%d", distance, argIndex+len(args)-1))
+		}
+		if q.MaxDistance != nil {
+			args = append(args, *q.MaxDistance)
+			conditions = append(conditions, fmt.Sprintf("%s <= $%d", distance, argIndex+len(args)-1))
+		}
+		if q.MinDistance != nil {
+			args = append(args, *q.MinDistance)
+			conditions = append(conditions, fmt.Sprintf("%s >= $%d", distance, argIndex+len(args)-1))
+		}
+		return "(" + strings.Join(conditions, " AND ") + ")", args
+	case q.Shape.Type == geo.TypePoint:
+		// $geoIntersects with a point matches documents at exactly that point
+		return fmt.Sprintf("(%s = $%d AND %s = $%d)", lat, argIndex, lng, argIndex+1),
+			[]interface{}{q.Shape.Point.Lat, q.Shape.Point.Lng}
+	default:
+		return pointInPolygon(lat, lng, q.Shape.Ring, argIndex)
+	}
+}
+
+// pointInPolygon tests a point against a ring by ray casting. The bounding box check
+// comes first so a geo index on the coordinates can narrow the candidates.
+func pointInPolygon(lat, lng string, ring []geo.Point, argIndex int) (string, []interface{}) {
+	xs := make([]float64, len(ring))
+	ys := make([]float64, len(ring))
+	minLng, minLat, maxLng, maxLat := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for i, p := range ring {
+		xs[i], ys[i] = p.Lng, p.Lat
+		minLng, maxLng = math.Min(minLng, p.Lng), math.Max(maxLng, p.Lng)
+		minLat, maxLat = math.Min(minLat, p.Lat), math.Max(maxLat, p.Lat)
+	}
+
+	x := fmt.Sprintf("($%d::float8[])", argIndex)
+	y := fmt.Sprintf("($%d::float8[])", argIndex+1)
+	condition := fmt.Sprintf(`(%[1]s BETWEEN $%[5]d AND $%[6]d AND %[2]s BETWEEN $%[7]d AND $%[8]d AND (
+		SELECT count(*) FROM generate_series(2, cardinality(%[3]s)) AS i
+		WHERE (%[4]s[i] > %[1]s) <> (%[4]s[i-1] > %[1]s)
+		  AND %[2]s < (%[3]s[i-1] - %[3]s[i]) * (%[1]s - %[4]s[i]) / NULLIF(%[4]s[i-1] - %[4]s[i], 0) + %[3]s[i]
+	) %% 2 = 1)`, lat, lng, x, y, argIndex+2, argIndex+3, argIndex+4, argIndex+5)
+
+	return condition, []interface{}{pq.Array(xs), pq.Array(ys), minLat, maxLat, minLng, maxLng}
+}
+
+// haversineExpr is the great-circle distance in meters from a Point field to the
+// latitude and longitude bound at the given placeholders
+func haversineExpr(field string, latArg, lngArg int) string {
+	lat, lng := pointCoordExpr(field, 1), pointCoordExpr(field, 0)
+	return fmt.Sprintf(
+		"(2 * %v * asin(LEAST(1, sqrt(power(sin(radians(%s - $%d::float8) / 2), 2) + cos(radians($%d::float8)) * cos(radians(%s)) * power(sin(radians(%s - $%d::float8) / 2), 2)))))",
+		geo.EarthRadiusMeters, lat, latArg, latArg, lat, lng, lngArg,
+	)
+}
+
+// geographyExpr converts a GeoJSON field to a PostGIS geography, or NULL when the field
+// holds no supported geometry. Geo indexes are built on this exact expression.
+func geographyExpr(field string) string {
+	value := fieldJSONExpr(field)
+	return fmt.Sprintf("(CASE WHEN jsonb_typeof(%s) = 'object' AND %s->>'type' IN ('Point', 'Polygon') THEN ST_GeomFromGeoJSON(%s)::geography END)",
+		value, value, geoPathText(field, ""))
+}
+
+// pointCoordExpr extracts a coordinate of a GeoJSON Point field (0 = longitude,
+// 1 = latitude), or NULL for anything else
+func pointCoordExpr(field string, index int) string {
+	suffix := fmt.Sprintf(",coordinates,%d", index)
+	return fmt.Sprintf("(CASE WHEN %s->>'type' = 'Point' AND jsonb_typeof(data #> '{%s%s}') = 'number' THEN (%s)::float8 END)",
+		fieldJSONExpr(field), strings.ReplaceAll(field, ".", ","), suffix, geoPathText(field, suffix))
+}
+
+func geoPathText(field, suffix string) string {
+	return fmt.Sprintf("data #>> '{%s%s}'", strings.ReplaceAll(field, ".", ","), suffix)
+}
@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var sequenceNameInvalid = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// maxIdentifierLength is PostgreSQL's limit on identifiers; longer ones are truncated
+const maxIdentifierLength = 63
+
+// NextSequence returns the next value of a per-collection, per-field counter,
+// creating the backing sequence on first use
+func (c *PostgresCollection) NextSequence(ctx context.Context, field string) (int64, error) {
+	sequenceName, err := sequenceName(c.table(ctx), field)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := c.conn(ctx).ExecContext(ctx, fmt.Sprintf("CREATE SEQUENCE IF NOT EXISTS %s", sequenceName)); err != nil {
+		return 0, fmt.Errorf("failed to create sequence: %w", err)
+	}
+
+	var value int64
+	if err := c.conn(ctx).QueryRowContext(ctx, "SELECT nextval($1)", sequenceName).Scan(&value); err != nil {
+		return 0, fmt.Errorf("failed to read sequence: %w", err)
+	}
+	return value, nil
+}
+
+// sequenceName returns the name of the sequence behind a counter. A name over the
+// identifier limit is cut short and ends in a hash of the full name instead, so that
+// counters whose names only differ past the limit do not share a sequence.
+func sequenceName(table, field string) (string, error) {
+	name := sequenceNameInvalid.ReplaceAllString(strings.ToLower(field), "_")
+	if name == "" {
+		return "", fmt.Errorf("invalid sequence name")
+	}
+	full := fmt.Sprintf("%s_%s_seq", table, name)
+	if len(full) <= maxIdentifierLength {
+		return full, nil
+	}
+	sum := sha256.Sum256([]byte(full))
+	suffix := "_" + hex.EncodeToString(sum[:6]) + "_seq"
+	return full[:maxIdentifierLength-len(suffix)] + suffix, nil
+}
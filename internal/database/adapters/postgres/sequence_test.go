@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSequenceName(t *testing.T) {
+	long := strings.Repeat("a", 60)
+
+	tests := []struct {
+		name  string
+		table string
+		field string
+		want  string
+	}{
+		{name: "plain", table: "data_orders", field: "number", want: "data_orders_number_seq"},
+		{name: "sanitized", table: "data_orders", field: "Invoice-No", want: "data_orders_invoice_no_seq"},
+		{name: "at the limit", table: "data_" + strings.Repeat("t", 49), field: "n", want: "data_" + strings.Repeat("t", 49) + "_n_seq"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sequenceName(tt.table, tt.field)
+			if err != nil || got != tt.want {
+				t.Fatalf("got %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := sequenceName("data_orders", ""); err == nil {
+		t.Fatal("expected an empty name to be rejected")
+	}
+
+	// Names that only differ past the limit still get sequences of their own
+	first, _ := sequenceName("data_orders", long+"_first")
+	second, _ := sequenceName("data_orders", long+"_second")
+	if len(first) > maxIdentifierLength || len(second) > maxIdentifierLength {
+		t.Fatalf("names exceed the identifier limit: %q, %q", first, second)
+	}
+	if first == second {
+		t.Fatalf("distinct counters share the sequence %q", first)
+	}
+	if again, _ := sequenceName("data_orders", long+"_first"); again != first {
+		t.Fatalf("name is not stable: %q, %q", first, again)
+	}
+}
@@ -0,0 +1,245 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/madhouselabs/anybase/pkg/models"
+)
+
+const (
+	// textSearchColumn holds the generated tsvector for full-text search
+	textSearchColumn = "_tsv"
+
+	// Highlight markers and fragment separator used by ts_headline. The markers are control
+	// characters rather than tags because the document text around them is not escaped;
+	// callers escape the fragments and then swap the markers for markup.
+	HighlightStart     = "\x02"
+	HighlightStop      = "\x03"
+	HighlightDelimiter = " ... "
+)
+
+var (
+	fieldPathPattern   = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*$`)
+	textSearchLanguage = regexp.MustCompile(`^[a-z_]+$`)
+)
+
+// Text query modes
+const (
+	TextModeWebsearch = "websearch" // Google-like syntax: "quoted phrases", or, -exclusion
+	TextModePlain     = "plain"     // All words must match
+	TextModePhrase    = "phrase"    // Words must appear in order
+	TextModePrefix    = "prefix"    // Every word matches as a prefix
+)
+
+// TextSearchOperations handles full-text search on a collection table
+type TextSearchOperations struct {
+	collection *PostgresCollection
+}
+
+// TextQuery describes a full-text search
+type TextQuery struct {
+	Query     string
+	Mode      string
+	Filter    map[string]interface{}
+	Limit     int
+	Skip      int
+	Highlight bool
+}
+
+// GetTextSearchOperations returns a TextSearchOperations instance for this collection
+func (c *PostgresCollection) GetTextSearchOperations() *TextSearchOperations {
+	return &TextSearchOperations{collection: c}
+}
+
+// ValidateTextSearchConfig checks field names, weights and language of a text search configuration
+func (t *TextSearchOperations) ValidateTextSearchConfig(ctx context.Context, config *models.TextSearchConfig) error {
+	if len(config.Fields) == 0 {
+		return fmt.Errorf("at least one text search field is required")
+	}
+	for _, field := range config.Fields {
+		if !fieldPathPattern.MatchString(field.Name) {
+			return fmt.Errorf("invalid text search field '%s'", field.Name)
+		}
+		switch field.Weight {
+		case "", "A", "B", "C", "D":
+		default:
+			return fmt.Errorf("invalid weight '%s' for field '%s': use A, B, C or D", field.Weight, field.Name)
+		}
+	}
+
+	language := textLanguage(config)
+	if !textSearchLanguage.MatchString(language) {
+		return fmt.Errorf("invalid text search language '%s'", language)
+	}
+	var exists bool
+	err := t.collection.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = $1)", language).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check text search language: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("unsupported text search language '%s'", language)
+	}
+	return nil
+}
+
+// EnableTextSearch (re)creates the generated tsvector column and its GIN index
+func (t *TextSearchOperations) EnableTextSearch(ctx context.Context, config *models.TextSearchConfig) error {
+	if err := t.ValidateTextSearchConfig(ctx, config); err != nil {
+		return err
+	}
+	language := textLanguage(config)
+
+	var parts []string
+	for _, field := range config.Fields {
+		weight := field.Weight
+		if weight == "" {
+			weight = "D"
+		}
+		parts = append(parts, fmt.Sprintf(
+			"setweight(to_tsvector('%s'::regconfig, coalesce(%s, '')), '%s')",
+			language, textFieldExpr(field.Name), weight,
+		))
+	}
+
+	// Generated columns cannot be altered, so the column is replaced
+	tableName := t.collection.table(ctx)
+	indexName := fmt.Sprintf("idx_%s_%s", tableName, strings.TrimPrefix(textSearchColumn, "_"))
+	statements := []string{
+		fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS %s", tableName, textSearchColumn),
+		fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s tsvector GENERATED ALWAYS AS (%s) STORED",
+			tableName, textSearchColumn, strings.Join(parts, " || ")),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING GIN (%s)", indexName, tableName, textSearchColumn),
+	}
+	for _, stmt := range statements {
+		if _, err := t.collection.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to enable text search: %w", err)
+		}
+	}
+	return nil
+}
+
+// DisableTextSearch drops the tsvector column and its index
+func (t *TextSearchOperations) DisableTextSearch(ctx context.Context) error {
+	query := fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS %s", t.collection.table(ctx), textSearchColumn)
+	if _, err := t.collection.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to disable text search: %w", err)
+	}
+	return nil
+}
+
+// TextSearch runs a ranked full-text query. Rows contain the document id, data, rank and,
+// when highlighting, a JSON object of ts_headline snippets per configured field.
+func (t *TextSearchOperations) TextSearch(ctx context.Context, config *models.TextSearchConfig, q TextQuery) (*sql.Rows, error) {
+	language := textLanguage(config)
+	if !textSearchLanguage.MatchString(language) {
+		return nil, fmt.Errorf("invalid text search language '%s'", language)
+	}
+
+	tsQuery, queryText, err := buildTSQuery(language, q.Mode, q.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	where, args, err := t.collection.buildWhereClause(q.Filter)
+	if err != nil {
+		return nil, err
+	}
+	queryArg := len(args) + 1
+	args = append(args, queryText, q.Limit, q.Skip)
+
+	headlines := "NULL::jsonb"
+	if q.Highlight {
+		options := fmt.Sprintf("StartSel=\"%s\", StopSel=\"%s\", FragmentDelimiter=\"%s\", MaxFragments=3, MaxWords=25, MinWords=8",
+			HighlightStart, HighlightStop, HighlightDelimiter)
+		var pairs []string
+		for _, field := range config.Fields {
+			pairs = append(pairs, fmt.Sprintf(
+				"'%s', ts_headline('%s'::regconfig, coalesce(%s, ''), q.query, '%s')",
+				field.Name, language, textFieldExpr(field.Name), options,
+			))
+		}
+		headlines = fmt.Sprintf("jsonb_build_object(%s)", strings.Join(pairs, ", "))
+	}
+
+	// Rank and paginate first so snippets are only generated for returned rows
+	query := fmt.Sprintf(`
+		WITH q AS (
+			SELECT %s AS query
+		),
+		hits AS (
+			SELECT data, ts_rank(%s, q.query) AS rank
+			FROM %s, q
+			WHERE _deleted_at IS NULL
+			  AND %s @@ q.query %s
+			ORDER BY rank DESC
+			LIMIT $%d OFFSET $%d
+		)
+		SELECT hits.data->>'_id', hits.data, hits.rank, %s
+		FROM hits, q
+		ORDER BY hits.rank DESC
+	`, fmt.Sprintf(tsQuery, queryArg), textSearchColumn, t.collection.table(ctx), textSearchColumn, where,
+		queryArg+1, queryArg+2, headlines)
+
+	rows, err := t.collection.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("text search failed: %w", err)
+	}
+	return rows, nil
+}
+
+// textSearchCondition returns the expressions hybrid search uses to match and rank against
+// the tsvector column; the query text is read from the given placeholder
+func textSearchCondition(config *models.TextSearchConfig, placeholder string) (match, rank string) {
+	query := fmt.Sprintf("websearch_to_tsquery('%s'::regconfig, %s)", textLanguage(config), placeholder)
+	return fmt.Sprintf("%s @@ %s", textSearchColumn, query), fmt.Sprintf("ts_rank_cd(%s, %s)", textSearchColumn, query)
+}
+
+// buildTSQuery returns the tsquery constructor for a mode, with a %d for the argument
+// placeholder, and the query text to bind
+func buildTSQuery(language, mode, text string) (string, string, error) {
+	if strings.TrimSpace(text) == "" {
+		return "", "", fmt.Errorf("query is required")
+	}
+
+	switch mode {
+	case "", TextModeWebsearch:
+		return fmt.Sprintf("websearch_to_tsquery('%s'::regconfig, $%%d)", language), text, nil
+	case TextModePlain:
+		return fmt.Sprintf("plainto_tsquery('%s'::regconfig, $%%d)", language), text, nil
+	case TextModePhrase:
+		return fmt.Sprintf("phraseto_tsquery('%s'::regconfig, $%%d)", language), text, nil
+	case TextModePrefix:
+		// Keep only letters and digits so the words cannot inject tsquery syntax
+		words := strings.FieldsFunc(text, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(words) == 0 {
+			return "", "", fmt.Errorf("query has no searchable words")
+		}
+		for i, word := range words {
+			words[i] = word + ":*"
+		}
+		return fmt.Sprintf("to_tsquery('%s'::regconfig, $%%d)", language), strings.Join(words, " & "), nil
+	}
+	return "", "", fmt.Errorf("unsupported text search mode '%s'", mode)
+}
+
+// textFieldExpr extracts a (possibly nested) field of the JSONB data as text
+func textFieldExpr(field string) string {
+	if !strings.Contains(field, ".") {
+		return fmt.Sprintf("data->>'%s'", field)
+	}
+	return fmt.Sprintf("data #>> '{%s}'", strings.ReplaceAll(field, ".", ","))
+}
+
+func textLanguage(config *models.TextSearchConfig) string {
+	if config == nil || config.Language == "" {
+		return "english"
+	}
+	return config.Language
+}
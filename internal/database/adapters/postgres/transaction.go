@@ -7,6 +7,22 @@ import (
 	"github.com/madhouselabs/anybase/internal/database/types"
 )
 
+// txContextKey binds the transaction started by RunInTransaction to its context
+type txContextKey struct{}
+
+// executor is what collections run statements on: the connection pool or a transaction
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// transactionFrom returns the transaction bound to ctx, if any
+func transactionFrom(ctx context.Context) *PostgresTransaction {
+	tx, _ := ctx.Value(txContextKey{}).(*PostgresTransaction)
+	return tx
+}
+
 // PostgresTransaction wraps a SQL transaction to implement types.Transaction
 type PostgresTransaction struct {
 	tx      *sql.Tx
@@ -26,9 +42,9 @@ func (t *PostgresTransaction) Rollback(ctx context.Context) error {
 
 // Collection returns a collection that uses this transaction
 func (t *PostgresTransaction) Collection(name string) types.Collection {
-	// For now, return the regular collection
-	// TODO: Implement transaction-aware collection
-	return t.adapter.Collection(name)
+	col := t.adapter.Collection(name).(*PostgresCollection)
+	col.tx = t.tx
+	return col
 }
 
 // Context returns the transaction context
@@ -11,18 +11,47 @@ import (
 
 // VectorOperations handles vector-specific database operations
 type VectorOperations struct {
-	db        *sql.DB
-	tableName string
+	db         *sql.DB
+	tableName  string
+	textSearch *models.TextSearchConfig
+	collection *PostgresCollection
+	filter     map[string]interface{}
 }
 
 // NewVectorOperations creates a new vector operations handler
 func NewVectorOperations(db *sql.DB, tableName string) *VectorOperations {
 	return &VectorOperations{
-		db:        db,
-		tableName: tableName,
+		db:         db,
+		tableName:  tableName,
+		collection: &PostgresCollection{db: db, tableName: tableName},
 	}
 }
 
+// WithTextSearch makes hybrid search use the collection's full-text configuration
+// instead of indexing the whole JSON document at query time
+func (v *VectorOperations) WithTextSearch(config *models.TextSearchConfig) *VectorOperations {
+	v.textSearch = config
+	return v
+}
+
+// WithFilter restricts searches to documents matching a filter
+func (v *VectorOperations) WithFilter(filter map[string]interface{}) *VectorOperations {
+	v.filter = filter
+	return v
+}
+
+// filterClause renders the filter with placeholders following the query's own arguments
+func (v *VectorOperations) filterClause(argIndex int) (string, []interface{}, error) {
+	conditions, args, err := v.collection.buildConditions(v.filter, argIndex)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(conditions) == 0 {
+		return "", nil, nil
+	}
+	return " AND " + strings.Join(conditions, " AND "), args, nil
+}
+
 // CreateVectorColumn adds a vector column to the table
 func (v *VectorOperations) CreateVectorColumn(ctx context.Context, field models.VectorField) error {
 	// Create column name with vec_ prefix to avoid conflicts
@@ -32,7 +61,7 @@ func (v *VectorOperations) CreateVectorColumn(ctx context.Context, field models.
 	query := fmt.Sprintf(`
 		ALTER TABLE %s 
 		ADD COLUMN IF NOT EXISTS %s vector(%d)
-	`, v.tableName, columnName, field.Dimensions)
+	`, v.collection.table(ctx), columnName, field.Dimensions)
 	
 	if _, err := v.db.ExecContext(ctx, query); err != nil {
 		return fmt.Errorf("failed to create vector column: %w", err)
@@ -49,7 +78,7 @@ func (v *VectorOperations) CreateVectorColumn(ctx context.Context, field models.
 // CreateVectorIndex creates an index on a vector column
 func (v *VectorOperations) CreateVectorIndex(ctx context.Context, field models.VectorField) error {
 	columnName := fmt.Sprintf("vec_%s", field.Name)
-	indexName := fmt.Sprintf("idx_%s_%s", v.tableName, columnName)
+	indexName := fmt.Sprintf("idx_%s_%s", v.collection.table(ctx), columnName)
 	
 	// Determine operator class based on metric
 	var opClass string
@@ -77,7 +106,7 @@ func (v *VectorOperations) CreateVectorIndex(ctx context.Context, field models.V
 			ON %s 
 			USING ivfflat (%s %s) 
 			WITH (lists = %d)
-		`, indexName, v.tableName, columnName, opClass, listSize)
+		`, indexName, v.collection.table(ctx), columnName, opClass, listSize)
 		
 	case "hnsw":
 		// Set HNSW parameters (defaults if not specified)
@@ -94,7 +123,7 @@ func (v *VectorOperations) CreateVectorIndex(ctx context.Context, field models.V
 			ON %s 
 			USING hnsw (%s %s) 
 			WITH (m = %d, ef_construction = %d)
-		`, indexName, v.tableName, columnName, opClass, m, efConstruct)
+		`, indexName, v.collection.table(ctx), columnName, opClass, m, efConstruct)
 		
 	default:
 		// No index or unknown type
@@ -115,7 +144,7 @@ func (v *VectorOperations) DropVectorColumn(ctx context.Context, fieldName strin
 	query := fmt.Sprintf(`
 		ALTER TABLE %s 
 		DROP COLUMN IF EXISTS %s
-	`, v.tableName, columnName)
+	`, v.collection.table(ctx), columnName)
 	
 	if _, err := v.db.ExecContext(ctx, query); err != nil {
 		return fmt.Errorf("failed to drop vector column: %w", err)
@@ -144,17 +173,22 @@ func (v *VectorOperations) VectorSearch(ctx context.Context, fieldName string, q
 		operator = "<=>" // default to cosine
 	}
 	
+	where, filterArgs, err := v.filterClause(2)
+	if err != nil {
+		return nil, err
+	}
+	
 	// Build query
 	query := fmt.Sprintf(`
 		SELECT _id, data, %s %s '%s'::vector as distance
 		FROM %s
 		WHERE %s IS NOT NULL
-		  AND _deleted_at IS NULL
+		  AND _deleted_at IS NULL %s
 		ORDER BY %s %s '%s'::vector
 		LIMIT $1
-	`, columnName, operator, vectorStr, v.tableName, columnName, columnName, operator, vectorStr)
+	`, columnName, operator, vectorStr, v.collection.table(ctx), columnName, where, columnName, operator, vectorStr)
 	
-	rows, err := v.db.QueryContext(ctx, query, limit)
+	rows, err := v.db.QueryContext(ctx, query, append([]interface{}{limit}, filterArgs...)...)
 	if err != nil {
 		return nil, fmt.Errorf("vector search failed: %w", err)
 	}
@@ -167,22 +201,34 @@ func (v *VectorOperations) HybridSearch(ctx context.Context, fieldName string, q
 	columnName := fmt.Sprintf("vec_%s", fieldName)
 	vectorStr := v.vectorToString(queryVector)
 	
+	// Without a text search configuration, fall back to indexing the whole document
+	textMatch := "to_tsvector('english', data::text) @@ plainto_tsquery('english', $2)"
+	textRank := "ts_rank_cd(to_tsvector('english', data::text), plainto_tsquery('english', $2))"
+	if v.textSearch != nil {
+		textMatch, textRank = textSearchCondition(v.textSearch, "$2")
+	}
+
+	where, filterArgs, err := v.filterClause(4)
+	if err != nil {
+		return nil, err
+	}
+
 	// Alpha controls the weight between text search (0) and vector search (1)
 	// Combined score = (1-alpha) * text_score + alpha * (1 - vector_distance)
 	query := fmt.Sprintf(`
 		WITH text_search AS (
 			SELECT _id, data, 
-				   ts_rank_cd(to_tsvector('english', data::text), plainto_tsquery('english', $2)) as text_score
+				   %s as text_score
 			FROM %s
 			WHERE _deleted_at IS NULL
-			  AND to_tsvector('english', data::text) @@ plainto_tsquery('english', $2)
+			  AND %s %s
 		),
 		vector_search AS (
 			SELECT _id, data,
 				   1 - (%s <=> '%s'::vector) as vector_score
 			FROM %s
 			WHERE %s IS NOT NULL
-			  AND _deleted_at IS NULL
+			  AND _deleted_at IS NULL %s
 		),
 		combined AS (
 			SELECT 
@@ -196,9 +242,9 @@ func (v *VectorOperations) HybridSearch(ctx context.Context, fieldName string, q
 		FROM combined
 		ORDER BY combined_score DESC
 		LIMIT $1
-	`, v.tableName, columnName, vectorStr, v.tableName, columnName)
+	`, textRank, v.collection.table(ctx), textMatch, where, columnName, vectorStr, v.collection.table(ctx), columnName, where)
 	
-	rows, err := v.db.QueryContext(ctx, query, limit, textQuery, alpha)
+	rows, err := v.db.QueryContext(ctx, query, append([]interface{}{limit, textQuery, alpha}, filterArgs...)...)
 	if err != nil {
 		return nil, fmt.Errorf("hybrid search failed: %w", err)
 	}
@@ -226,7 +272,7 @@ func (v *VectorOperations) GetVectorColumns(ctx context.Context) ([]string, erro
 		  AND column_name LIKE 'vec_%'
 	`
 	
-	rows, err := v.db.QueryContext(ctx, query, v.tableName)
+	rows, err := v.db.QueryContext(ctx, query, v.collection.table(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get vector columns: %w", err)
 	}
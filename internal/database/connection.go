@@ -89,6 +89,11 @@ func createStandardIndexes(ctx context.Context, adapter types.DB) error {
 			Keys:   map[string]int{"key_hash": 1},
 			Unique: true,
 		},
+		{
+			Name:   "public_id_unique",
+			Keys:   map[string]int{"public_id": 1},
+			Unique: true,
+		},
 		{
 			Name: "owner_id",
 			Keys: map[string]int{"owner_id": 1},
@@ -101,6 +106,16 @@ func createStandardIndexes(ctx context.Context, adapter types.DB) error {
 		}
 	}
 	
+	// Roles collection indexes
+	rolesCol := adapter.Collection("roles")
+	if err := rolesCol.CreateIndex(ctx, types.Index{
+		Name:   "name_unique",
+		Keys:   map[string]int{"name": 1},
+		Unique: true,
+	}); err != nil {
+		fmt.Printf("Warning: Failed to create index name_unique on roles: %v\n", err)
+	}
+	
 	// Collections collection indexes
 	collectionsCol := adapter.Collection("collections")
 	collectionIndexes := []types.Index{
@@ -152,11 +167,6 @@ func createStandardIndexes(ctx context.Context, adapter types.DB) error {
 	sessionsCol := adapter.Collection("sessions")
 	ttl := SessionTTL
 	sessionIndexes := []types.Index{
-		{
-			Name:   "token_unique",
-			Keys:   map[string]int{"token": 1},
-			Unique: true,
-		},
 		{
 			Name: "user_id",
 			Keys: map[string]int{"user_id": 1},
@@ -173,6 +183,60 @@ func createStandardIndexes(ctx context.Context, adapter types.DB) error {
 			fmt.Printf("Warning: Failed to create index %s on sessions: %v\n", idx.Name, err)
 		}
 	}
-	
+
+	// Organizations and memberships
+	if err := adapter.Collection("organizations").CreateIndex(ctx, types.Index{
+		Name:   "organizations_slug_unique",
+		Keys:   map[string]int{"slug": 1},
+		Unique: true,
+	}); err != nil {
+		fmt.Printf("Warning: Failed to create index organizations_slug_unique on organizations: %v\n", err)
+	}
+	if err := adapter.Collection("org_members").CreateIndex(ctx, types.Index{
+		Name:   "org_members_org_user_unique",
+		Keys:   map[string]int{"org_id": 1, "user_id": 1},
+		Unique: true,
+	}); err != nil {
+		fmt.Printf("Warning: Failed to create index org_members_org_user_unique on org_members: %v\n", err)
+	}
+
+	// External identities and pending identity provider sign-ins
+	if err := adapter.Collection("user_identities").CreateIndex(ctx, types.Index{
+		Name:   "user_identities_provider_subject_unique",
+		Keys:   map[string]int{"provider": 1, "subject": 1},
+		Unique: true,
+	}); err != nil {
+		fmt.Printf("Warning: Failed to create index user_identities_provider_subject_unique on user_identities: %v\n", err)
+	}
+	if err := adapter.Collection("oidc_states").CreateIndex(ctx, types.Index{
+		Name: "oidc_states_expires_at_ttl",
+		Keys: map[string]int{"expires_at": 1},
+		TTL:  &ttl,
+	}); err != nil {
+		fmt.Printf("Warning: Failed to create index oidc_states_expires_at_ttl on oidc_states: %v\n", err)
+	}
+
+	// Service account credentials are looked up by client ID on every token request
+	if err := adapter.Collection("service_account_credentials").CreateIndex(ctx, types.Index{
+		Name:   "service_account_credentials_client_id_unique",
+		Keys:   map[string]int{"client_id": 1},
+		Unique: true,
+	}); err != nil {
+		fmt.Printf("Warning: Failed to create index service_account_credentials_client_id_unique on service_account_credentials: %v\n", err)
+	}
+
+	// Passwordless sign-in requests, looked up by link token or by email for a code
+	loginTokensCol := adapter.Collection("login_tokens")
+	loginTokenIndexes := []types.Index{
+		{Name: "login_tokens_link_hash", Keys: map[string]int{"link_hash": 1}},
+		{Name: "login_tokens_email", Keys: map[string]int{"email": 1}},
+		{Name: "login_tokens_expires_at_ttl", Keys: map[string]int{"expires_at": 1}, TTL: &ttl},
+	}
+	for _, idx := range loginTokenIndexes {
+		if err := loginTokensCol.CreateIndex(ctx, idx); err != nil {
+			fmt.Printf("Warning: Failed to create index %s on login_tokens: %v\n", idx.Name, err)
+		}
+	}
+
 	return nil
 }
\ No newline at end of file
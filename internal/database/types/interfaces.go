@@ -35,6 +35,7 @@ type Collection interface {
 	FindOne(ctx context.Context, filter map[string]interface{}, result interface{}) error
 	Find(ctx context.Context, filter map[string]interface{}, options *FindOptions) (Cursor, error)
 	UpdateOne(ctx context.Context, filter map[string]interface{}, update map[string]interface{}) (*UpdateResult, error)
+	ReplaceOne(ctx context.Context, filter map[string]interface{}, replacement map[string]interface{}) (*UpdateResult, error)
 	UpdateMany(ctx context.Context, filter map[string]interface{}, update map[string]interface{}) (*UpdateResult, error)
 	DeleteOne(ctx context.Context, filter map[string]interface{}) (*DeleteResult, error)
 	DeleteMany(ctx context.Context, filter map[string]interface{}) (*DeleteResult, error)
@@ -94,8 +95,15 @@ type Index struct {
 	Unique bool           `json:"unique"`
 	Sparse bool           `json:"sparse"`
 	TTL    *time.Duration `json:"ttl"` // For PostgreSQL: handled via trigger
+	Type   string         `json:"type,omitempty"` // Empty for a regular index, or IndexTypeGeo
 }
 
+// Index types
+const (
+	// IndexTypeGeo indexes a single GeoJSON field for $near, $geoWithin and $geoIntersects
+	IndexTypeGeo = "geo"
+)
+
 // ID represents a generic database ID
 type ID interface {
 	String() string
@@ -109,6 +117,7 @@ type ID interface {
 // QueryOperators that work consistently across MongoDB and PostgreSQL JSONB
 const (
 	OpEqual        = "$eq"
+	OpEqualFold    = "$eqfold" // Case-insensitive string equality
 	OpNotEqual     = "$ne"
 	OpGreater      = "$gt"
 	OpGreaterEqual = "$gte"
@@ -0,0 +1,351 @@
+// Package expr implements the small, sandboxed expression language used for
+// computed collection fields. Expressions can read document fields, $variables
+// supplied by the caller and a fixed set of pure built-in functions; they have
+// no access to I/O beyond the functions the caller registers in Env.
+package expr
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// maxEvalSteps bounds the work done by a single evaluation
+const maxEvalSteps = 10000
+
+// Func is a function callable from expressions
+type Func func(args []interface{}) (interface{}, error)
+
+// Env supplies the values visible to an evaluation
+type Env struct {
+	Doc       map[string]interface{}                 // Document fields referenced by bare identifiers
+	Variable  func(name string) (interface{}, error) // Resolves $name references; nil means none are defined
+	Functions map[string]Func                        // Extra functions, checked before the built-ins
+}
+
+// Program is a compiled expression
+type Program struct {
+	source string
+	root   node
+}
+
+// Compile parses an expression
+func Compile(source string) (*Program, error) {
+	root, err := parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", source, err)
+	}
+	if err := checkCalls(root); err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", source, err)
+	}
+	return &Program{source: source, root: root}, nil
+}
+
+// String returns the expression source
+func (p *Program) String() string {
+	return p.source
+}
+
+// Fields returns the top-level document fields the expression reads
+func (p *Program) Fields() []string {
+	seen := map[string]bool{}
+	walk(p.root, func(n node) {
+		if f, ok := n.(*fieldNode); ok {
+			seen[f.name] = true
+		}
+	})
+	return sortedKeys(seen)
+}
+
+// Calls returns the names of the functions the expression calls
+func (p *Program) Calls() []string {
+	seen := map[string]bool{}
+	walk(p.root, func(n node) {
+		if c, ok := n.(*callNode); ok {
+			seen[c.name] = true
+		}
+	})
+	return sortedKeys(seen)
+}
+
+// Eval evaluates the expression against env
+func (p *Program) Eval(env *Env) (interface{}, error) {
+	if env == nil {
+		env = &Env{}
+	}
+	ev := &evaluator{env: env}
+	return ev.eval(p.root)
+}
+
+type evaluator struct {
+	env   *Env
+	steps int
+}
+
+func (ev *evaluator) eval(n node) (interface{}, error) {
+	ev.steps++
+	if ev.steps > maxEvalSteps {
+		return nil, fmt.Errorf("expression exceeded evaluation limit")
+	}
+
+	switch n := n.(type) {
+	case *literalNode:
+		return n.value, nil
+
+	case *fieldNode:
+		return normalize(ev.env.Doc[n.name]), nil
+
+	case *varNode:
+		if ev.env.Variable == nil {
+			return nil, fmt.Errorf("unknown variable $%s", n.name)
+		}
+		value, err := ev.env.Variable(n.name)
+		if err != nil {
+			return nil, err
+		}
+		return normalize(value), nil
+
+	case *memberNode:
+		target, err := ev.eval(n.target)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ev.eval(n.key)
+		if err != nil {
+			return nil, err
+		}
+		return member(target, key), nil
+
+	case *unaryNode:
+		operand, err := ev.eval(n.operand)
+		if err != nil {
+			return nil, err
+		}
+		if n.op == "!" {
+			return !truthy(operand), nil
+		}
+		num, ok := toNumber(operand)
+		if !ok {
+			return nil, fmt.Errorf("cannot negate %s", typeName(operand))
+		}
+		return -num, nil
+
+	case *binaryNode:
+		return ev.evalBinary(n)
+
+	case *ternaryNode:
+		cond, err := ev.eval(n.cond)
+		if err != nil {
+			return nil, err
+		}
+		if truthy(cond) {
+			return ev.eval(n.then)
+		}
+		return ev.eval(n.otherwise)
+
+	case *callNode:
+		args := make([]interface{}, len(n.args))
+		for i, arg := range n.args {
+			value, err := ev.eval(arg)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = value
+		}
+		if fn, ok := ev.env.Functions[n.name]; ok {
+			return fn(args)
+		}
+		fn, ok := builtins[n.name]
+		if !ok {
+			return nil, fmt.Errorf("unknown function %s()", n.name)
+		}
+		return fn(args)
+	}
+
+	return nil, fmt.Errorf("unsupported expression node %T", n)
+}
+
+func (ev *evaluator) evalBinary(n *binaryNode) (interface{}, error) {
+	left, err := ev.eval(n.left)
+	if err != nil {
+		return nil, err
+	}
+
+	// Logical operators short-circuit
+	switch n.op {
+	case "&&":
+		if !truthy(left) {
+			return false, nil
+		}
+		right, err := ev.eval(n.right)
+		return truthy(right), err
+	case "||":
+		if truthy(left) {
+			return true, nil
+		}
+		right, err := ev.eval(n.right)
+		return truthy(right), err
+	}
+
+	right, err := ev.eval(n.right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "+":
+		_, ls := left.(string)
+		_, rs := right.(string)
+		if ls || rs {
+			return toString(left) + toString(right), nil
+		}
+	}
+
+	l, lok := toNumber(left)
+	r, rok := toNumber(right)
+	if !lok || !rok {
+		// Strings compare lexically
+		if ls, ok := left.(string); ok {
+			if rs, ok := right.(string); ok {
+				return compareStrings(n.op, ls, rs)
+			}
+		}
+		return nil, fmt.Errorf("operator %s not supported for %s and %s", n.op, typeName(left), typeName(right))
+	}
+
+	switch n.op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return l / r, nil
+	case "%":
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return math.Mod(l, r), nil
+	case "<":
+		return l < r, nil
+	case "<=":
+		return l <= r, nil
+	case ">":
+		return l > r, nil
+	case ">=":
+		return l >= r, nil
+	}
+	return nil, fmt.Errorf("unsupported operator %s", n.op)
+}
+
+func compareStrings(op, l, r string) (interface{}, error) {
+	switch op {
+	case "<":
+		return l < r, nil
+	case "<=":
+		return l <= r, nil
+	case ">":
+		return l > r, nil
+	case ">=":
+		return l >= r, nil
+	}
+	return nil, fmt.Errorf("operator %s not supported for strings", op)
+}
+
+// checkCalls rejects calls to functions that are neither built in nor registrable
+func checkCalls(root node) error {
+	var err error
+	walk(root, func(n node) {
+		if c, ok := n.(*callNode); ok && err == nil {
+			if _, known := builtins[c.name]; !known && !contains(ExternalFunctions, c.name) {
+				err = fmt.Errorf("unknown function %s()", c.name)
+			}
+		}
+	})
+	return err
+}
+
+// walk visits every node in the tree
+func walk(n node, visit func(node)) {
+	visit(n)
+	switch n := n.(type) {
+	case *memberNode:
+		walk(n.target, visit)
+		walk(n.key, visit)
+	case *unaryNode:
+		walk(n.operand, visit)
+	case *binaryNode:
+		walk(n.left, visit)
+		walk(n.right, visit)
+	case *ternaryNode:
+		walk(n.cond, visit)
+		walk(n.then, visit)
+		walk(n.otherwise, visit)
+	case *callNode:
+		for _, arg := range n.args {
+			walk(arg, visit)
+		}
+	}
+}
+
+func member(target, key interface{}) interface{} {
+	switch t := target.(type) {
+	case map[string]interface{}:
+		return normalize(t[toString(key)])
+	case []interface{}:
+		idx, ok := toNumber(key)
+		if !ok || idx < 0 || int(idx) >= len(t) {
+			return nil
+		}
+		return normalize(t[int(idx)])
+	case string:
+		if field := toString(key); field == "length" {
+			return float64(len([]rune(t)))
+		}
+	}
+	return nil
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func joinValues(values []interface{}, sep string) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = toString(v)
+	}
+	return strings.Join(parts, sep)
+}
@@ -0,0 +1,214 @@
+package expr_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/madhouselabs/anybase/internal/expr"
+)
+
+func TestEval(t *testing.T) {
+	doc := map[string]interface{}{
+		"first":    "Ada",
+		"last":     "Lovelace",
+		"price":    12.5,
+		"quantity": 4,
+		"tags":     []interface{}{"math", "engines"},
+		"address":  map[string]interface{}{"city": "London"},
+		"empty":    "",
+		"missing":  nil,
+	}
+
+	tests := []struct {
+		source string
+		want   interface{}
+	}{
+		// Literals and arithmetic
+		{`1 + 2 * 3`, 7.0},
+		{`(1 + 2) * 3`, 9.0},
+		{`10 % 4`, 2.0},
+		{`-price`, -12.5},
+		{`.5 + 1`, 1.5},
+		{`price * quantity`, 50.0},
+		{`true + 1`, 2.0},
+
+		// Strings
+		{`first + ' ' + last`, "Ada Lovelace"},
+		{`"n=" + quantity`, "n=4"},
+		{`'it\'s'`, "it's"},
+		{`"a" < "b"`, true},
+		{`first.length`, 3.0},
+
+		// Comparison and logic
+		{`price > 10 && quantity <= 4`, true},
+		{`missing == null`, true},
+		{`quantity == "4"`, false},
+		{`empty || "fallback"`, true},
+		{`!empty`, true},
+		{`price > 100 ? "high" : "low"`, "low"},
+		{`missing ? missing.x : "none"`, "none"},
+
+		// Members
+		{`address.city`, "London"},
+		{`address["city"]`, "London"},
+		{`tags[1]`, "engines"},
+		{`tags[5]`, nil},
+		{`missing.city`, nil},
+
+		// Built-in functions
+		{`lower(first)`, "ada"},
+		{`upper(missing)`, nil},
+		{`slugify("  Hello, World!  ")`, "hello-world"},
+		{`concat(first, "-", quantity)`, "Ada-4"},
+		{`join(tags, " & ")`, "math & engines"},
+		{`join(tags)`, "math,engines"},
+		{`coalesce(missing, empty, last)`, "Lovelace"},
+		{`substr("Lovelace", 4)`, "lace"},
+		{`substr("Lovelace", 0, 4)`, "Love"},
+		{`substr("Lovelace", -3, 100)`, "Lovelace"},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`len(tags) + len("héllo") + len(address)`, 8.0},
+		{`if(quantity > 3, "bulk", "single")`, "bulk"},
+		{`round(2.345, 2)`, 2.35},
+		{`round(price)`, 13.0},
+		{`floor(-1.5)`, -2.0},
+		{`ceil(1.2)`, 2.0},
+		{`abs(-3)`, 3.0},
+		{`min(3, "x", 1, 2)`, 1.0},
+		{`max(missing)`, nil},
+		{`number(" 42 ")`, 42.0},
+		{`number("abc")`, nil},
+		{`string(1.5) + string(true)`, "1.5true"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			program, err := expr.Compile(tt.source)
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			got, err := program.Eval(&expr.Env{Doc: doc})
+			if err != nil {
+				t.Fatalf("Eval: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileRejects(t *testing.T) {
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{`1 +`, "unexpected end of expression"},
+		{`(1 + 2`, "expected ')'"},
+		{`a ? b`, "expected ':'"},
+		{`"open`, "unterminated string"},
+		{`a # b`, "unexpected character '#'"},
+		{`$`, "expected variable name"},
+		{`a.(b)`, "expected property name"},
+		{`f(1 2)`, "expected ',' or ')'"},
+		{`1 2`, "unexpected '2'"},
+		{`exec("rm -rf /")`, "unknown function exec()"},
+		{`lower(system())`, "unknown function system()"},
+		{strings.Repeat("(", 40) + "1" + strings.Repeat(")", 40), "nested too deeply"},
+		{strings.Repeat("-", 40) + "1", "nested too deeply"},
+		{strings.Repeat("a", expr.MaxExpressionLength+1), "exceeds"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			_, err := expr.Compile(tt.source)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expected an error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{`1 / 0`, "division by zero"},
+		{`1 % 0`, "division by zero"},
+		{`-"a"`, "cannot negate string"},
+		{`tags * 2`, "operator * not supported for array and number"},
+		{`"a" - "b"`, "operator - not supported for strings"},
+		{`$unknown`, "unknown variable $unknown"},
+		{`lower("a", "b")`, "expected 1 argument"},
+		{`substr("a")`, "substr() expects 2 to 3 arguments"},
+		{`now(1)`, "now() expects 0 argument(s)"},
+		{`min()`, "min() expects at least 1 argument"},
+		{`sequence()`, "unknown function sequence()"},
+	}
+
+	doc := map[string]interface{}{"tags": []interface{}{"a"}}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			program, err := expr.Compile(tt.source)
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			_, err = program.Eval(&expr.Env{Doc: doc})
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expected an error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestEvalEnv(t *testing.T) {
+	program, err := expr.Compile(`$user.email + ":" + sequence("orders")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := &expr.Env{
+		Variable: func(name string) (interface{}, error) {
+			if name != "user" {
+				return nil, fmt.Errorf("unknown variable $%s", name)
+			}
+			return map[string]interface{}{"email": "ada@example.com"}, nil
+		},
+		Functions: map[string]expr.Func{
+			"sequence": func(args []interface{}) (interface{}, error) {
+				if len(args) != 1 || args[0] != "orders" {
+					return nil, fmt.Errorf("unexpected arguments %v", args)
+				}
+				return float64(7), nil
+			},
+		},
+	}
+	got, err := program.Eval(env)
+	if err != nil || got != "ada@example.com:7" {
+		t.Fatalf("got %#v, %v", got, err)
+	}
+
+	// Functions supplied by the caller take precedence over the built-ins
+	program, _ = expr.Compile(`upper("a")`)
+	got, _ = program.Eval(&expr.Env{Functions: map[string]expr.Func{
+		"upper": func(args []interface{}) (interface{}, error) { return "custom", nil },
+	}})
+	if got != "custom" {
+		t.Fatalf("got %#v, want the caller's function", got)
+	}
+}
+
+func TestProgramFieldsAndCalls(t *testing.T) {
+	program, err := expr.Compile(`slugify(title) + "-" + lower(author.name) + $user.id + (draft ? "" : concat(title))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := program.Fields(), []string{"author", "draft", "title"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields() = %v, want %v", got, want)
+	}
+	if got, want := program.Calls(), []string{"concat", "lower", "slugify"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Calls() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,300 @@
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExternalFunctions are functions the language reserves but the caller must supply through Env.Functions
+var ExternalFunctions = []string{"sequence"}
+
+var slugInvalid = regexp.MustCompile(`[^a-z0-9]+`)
+
+// builtins are the functions available to every expression
+var builtins = map[string]Func{
+	"lower": stringFunc(strings.ToLower),
+	"upper": stringFunc(strings.ToUpper),
+	"trim":  stringFunc(strings.TrimSpace),
+	"slugify": stringFunc(func(s string) string {
+		return strings.Trim(slugInvalid.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	}),
+	"string": func(args []interface{}) (interface{}, error) {
+		if err := arity("string", args, 1, 1); err != nil {
+			return nil, err
+		}
+		return toString(args[0]), nil
+	},
+	"number": func(args []interface{}) (interface{}, error) {
+		if err := arity("number", args, 1, 1); err != nil {
+			return nil, err
+		}
+		if n, ok := toNumber(args[0]); ok {
+			return n, nil
+		}
+		if s, ok := args[0].(string); ok {
+			if n, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+				return n, nil
+			}
+		}
+		return nil, nil
+	},
+	"len": func(args []interface{}) (interface{}, error) {
+		if err := arity("len", args, 1, 1); err != nil {
+			return nil, err
+		}
+		switch v := args[0].(type) {
+		case string:
+			return float64(len([]rune(v))), nil
+		case []interface{}:
+			return float64(len(v)), nil
+		case map[string]interface{}:
+			return float64(len(v)), nil
+		}
+		return float64(0), nil
+	},
+	"concat": func(args []interface{}) (interface{}, error) {
+		return joinValues(args, ""), nil
+	},
+	"join": func(args []interface{}) (interface{}, error) {
+		if err := arity("join", args, 1, 2); err != nil {
+			return nil, err
+		}
+		sep := ","
+		if len(args) == 2 {
+			sep = toString(args[1])
+		}
+		list, _ := args[0].([]interface{})
+		return joinValues(list, sep), nil
+	},
+	"coalesce": func(args []interface{}) (interface{}, error) {
+		for _, arg := range args {
+			if arg != nil && arg != "" {
+				return arg, nil
+			}
+		}
+		return nil, nil
+	},
+	"substr": func(args []interface{}) (interface{}, error) {
+		if err := arity("substr", args, 2, 3); err != nil {
+			return nil, err
+		}
+		runes := []rune(toString(args[0]))
+		start := clampIndex(args[1], len(runes))
+		end := len(runes)
+		if len(args) == 3 {
+			if n, ok := toNumber(args[2]); ok {
+				end = start + int(math.Max(n, 0))
+				if end > len(runes) {
+					end = len(runes)
+				}
+			}
+		}
+		return string(runes[start:end]), nil
+	},
+	"replace": func(args []interface{}) (interface{}, error) {
+		if err := arity("replace", args, 3, 3); err != nil {
+			return nil, err
+		}
+		return strings.ReplaceAll(toString(args[0]), toString(args[1]), toString(args[2])), nil
+	},
+	"if": func(args []interface{}) (interface{}, error) {
+		if err := arity("if", args, 3, 3); err != nil {
+			return nil, err
+		}
+		if truthy(args[0]) {
+			return args[1], nil
+		}
+		return args[2], nil
+	},
+	"round": func(args []interface{}) (interface{}, error) {
+		if err := arity("round", args, 1, 2); err != nil {
+			return nil, err
+		}
+		n, ok := toNumber(args[0])
+		if !ok {
+			return nil, nil
+		}
+		scale := 1.0
+		if len(args) == 2 {
+			if digits, ok := toNumber(args[1]); ok {
+				scale = math.Pow(10, math.Trunc(digits))
+			}
+		}
+		return math.Round(n*scale) / scale, nil
+	},
+	"floor": numberFunc(math.Floor),
+	"ceil":  numberFunc(math.Ceil),
+	"abs":   numberFunc(math.Abs),
+	"min": func(args []interface{}) (interface{}, error) {
+		return reduceNumbers("min", args, math.Min)
+	},
+	"max": func(args []interface{}) (interface{}, error) {
+		return reduceNumbers("max", args, math.Max)
+	},
+	"now": func(args []interface{}) (interface{}, error) {
+		if err := arity("now", args, 0, 0); err != nil {
+			return nil, err
+		}
+		return time.Now().UTC().Format(time.RFC3339), nil
+	},
+	"uuid": func(args []interface{}) (interface{}, error) {
+		if err := arity("uuid", args, 0, 0); err != nil {
+			return nil, err
+		}
+		return uuid.NewString(), nil
+	},
+}
+
+func stringFunc(fn func(string) string) Func {
+	return func(args []interface{}) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+		}
+		if args[0] == nil {
+			return nil, nil
+		}
+		return fn(toString(args[0])), nil
+	}
+}
+
+func numberFunc(fn func(float64) float64) Func {
+	return func(args []interface{}) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+		}
+		n, ok := toNumber(args[0])
+		if !ok {
+			return nil, nil
+		}
+		return fn(n), nil
+	}
+}
+
+func reduceNumbers(name string, args []interface{}, fn func(a, b float64) float64) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%s() expects at least 1 argument", name)
+	}
+	var result interface{}
+	for _, arg := range args {
+		n, ok := toNumber(arg)
+		if !ok {
+			continue
+		}
+		if result == nil {
+			result = n
+		} else {
+			result = fn(result.(float64), n)
+		}
+	}
+	return result, nil
+}
+
+func arity(name string, args []interface{}, min, max int) error {
+	if len(args) < min || len(args) > max {
+		if min == max {
+			return fmt.Errorf("%s() expects %d argument(s), got %d", name, min, len(args))
+		}
+		return fmt.Errorf("%s() expects %d to %d arguments, got %d", name, min, max, len(args))
+	}
+	return nil
+}
+
+func clampIndex(value interface{}, length int) int {
+	n, _ := toNumber(value)
+	idx := int(n)
+	if idx < 0 {
+		idx = 0
+	}
+	if idx > length {
+		idx = length
+	}
+	return idx
+}
+
+// normalize converts Go numeric types into float64 so expressions see JSON values
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	}
+	return value
+}
+
+func toNumber(value interface{}) (float64, bool) {
+	switch v := normalize(value).(type) {
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func toString(value interface{}) string {
+	switch v := normalize(value).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(data)
+	}
+}
+
+func truthy(value interface{}) bool {
+	switch v := normalize(value).(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+func equal(a, b interface{}) bool {
+	a, b = normalize(a), normalize(b)
+	if an, ok := a.(float64); ok {
+		bn, ok := b.(float64)
+		return ok && an == bn
+	}
+	switch a.(type) {
+	case nil, string, bool:
+		return a == b
+	}
+	return toString(a) == toString(b)
+}
@@ -0,0 +1,155 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokVariable
+	tokOperator
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokComma
+	tokDot
+	tokQuestion
+	tokColon
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+// twoCharOperators are matched before their single-character prefixes
+var twoCharOperators = []string{"==", "!=", "<=", ">=", "&&", "||"}
+
+// tokenize splits an expression into tokens
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(src) {
+		ch := rune(src[i])
+
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+
+		case ch >= '0' && ch <= '9' || ch == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+				i++
+			}
+			num, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number '%s' at position %d", src[start:i], start)
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: src[start:i], num: num, pos: start})
+
+		case ch == '"' || ch == '\'':
+			start := i
+			str, next, err := readString(src, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokString, text: str, pos: start})
+			i = next
+
+		case ch == '$' || isIdentStart(src[i]):
+			start := i
+			kind := tokIdent
+			if ch == '$' {
+				kind = tokVariable
+				i++
+			}
+			for i < len(src) && (isIdentStart(src[i]) || src[i] >= '0' && src[i] <= '9') {
+				i++
+			}
+			name := src[start:i]
+			if kind == tokVariable {
+				name = name[1:]
+				if name == "" {
+					return nil, fmt.Errorf("expected variable name after '$' at position %d", start)
+				}
+			}
+			tokens = append(tokens, token{kind: kind, text: name, pos: start})
+
+		default:
+			if op := matchOperator(src[i:]); op != "" {
+				tokens = append(tokens, token{kind: tokOperator, text: op, pos: i})
+				i += len(op)
+				continue
+			}
+
+			kinds := map[byte]tokenKind{
+				'(': tokLParen, ')': tokRParen, '[': tokLBracket, ']': tokRBracket,
+				',': tokComma, '.': tokDot, '?': tokQuestion, ':': tokColon,
+			}
+			kind, ok := kinds[src[i]]
+			if !ok {
+				return nil, fmt.Errorf("unexpected character '%c' at position %d", src[i], i)
+			}
+			tokens = append(tokens, token{kind: kind, text: string(src[i]), pos: i})
+			i++
+		}
+	}
+
+	tokens = append(tokens, token{kind: tokEOF, pos: len(src)})
+	return tokens, nil
+}
+
+func isIdentStart(ch byte) bool {
+	return ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z'
+}
+
+func matchOperator(rest string) string {
+	for _, op := range twoCharOperators {
+		if strings.HasPrefix(rest, op) {
+			return op
+		}
+	}
+	if strings.ContainsRune("+-*/%<>!", rune(rest[0])) {
+		return rest[:1]
+	}
+	return ""
+}
+
+// readString reads a quoted string literal starting at src[start], handling backslash escapes
+func readString(src string, start int) (string, int, error) {
+	quote := src[start]
+	var sb strings.Builder
+	i := start + 1
+	for i < len(src) {
+		ch := src[i]
+		if ch == quote {
+			return sb.String(), i + 1, nil
+		}
+		if ch == '\\' && i+1 < len(src) {
+			i++
+			switch src[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			default:
+				sb.WriteByte(src[i])
+			}
+			i++
+			continue
+		}
+		sb.WriteByte(ch)
+		i++
+	}
+	return "", 0, fmt.Errorf("unterminated string starting at position %d", start)
+}
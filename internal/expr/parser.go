@@ -0,0 +1,267 @@
+package expr
+
+import (
+	"fmt"
+)
+
+const (
+	// MaxExpressionLength bounds the source size of a single expression
+	MaxExpressionLength = 1024
+	// maxNestingDepth bounds parser recursion
+	maxNestingDepth = 32
+)
+
+// node is an expression AST node
+type node interface{}
+
+type (
+	literalNode struct{ value interface{} }
+	fieldNode   struct{ name string }
+	varNode     struct{ name string }
+	memberNode  struct {
+		target node
+		key    node
+	}
+	unaryNode struct {
+		op      string
+		operand node
+	}
+	binaryNode struct {
+		op          string
+		left, right node
+	}
+	ternaryNode struct {
+		cond, then, otherwise node
+	}
+	callNode struct {
+		name string
+		args []node
+	}
+)
+
+// binaryPrecedence lists binary operators from lowest to highest precedence
+var binaryPrecedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+}
+
+func parse(src string) (node, error) {
+	if len(src) > MaxExpressionLength {
+		return nil, fmt.Errorf("expression exceeds %d characters", MaxExpressionLength)
+	}
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected '%s' at position %d", tok.text, tok.pos)
+	}
+	return root, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) expect(kind tokenKind, what string) error {
+	tok := p.next()
+	if tok.kind != kind {
+		return fmt.Errorf("expected %s at position %d", what, tok.pos)
+	}
+	return nil
+}
+
+func (p *parser) parseExpression() (node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxNestingDepth {
+		return nil, fmt.Errorf("expression is nested too deeply")
+	}
+
+	cond, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokQuestion {
+		return cond, nil
+	}
+	p.next()
+
+	then, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(tokColon, "':'"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	return &ternaryNode{cond: cond, then: then, otherwise: otherwise}, nil
+}
+
+func (p *parser) parseBinary(level int) (node, error) {
+	if level == len(binaryPrecedence) {
+		return p.parseUnary()
+	}
+
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if tok.kind != tokOperator || !contains(binaryPrecedence[level], tok.text) {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: tok.text, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	tok := p.peek()
+	if tok.kind == tokOperator && (tok.text == "!" || tok.text == "-") {
+		p.next()
+		p.depth++
+		defer func() { p.depth-- }()
+		if p.depth > maxNestingDepth {
+			return nil, fmt.Errorf("expression is nested too deeply")
+		}
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: tok.text, operand: operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	target, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek().kind {
+		case tokDot:
+			p.next()
+			tok := p.next()
+			if tok.kind != tokIdent {
+				return nil, fmt.Errorf("expected property name at position %d", tok.pos)
+			}
+			target = &memberNode{target: target, key: &literalNode{value: tok.text}}
+		case tokLBracket:
+			p.next()
+			key, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(tokRBracket, "']'"); err != nil {
+				return nil, err
+			}
+			target = &memberNode{target: target, key: key}
+		default:
+			return target, nil
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokNumber:
+		return &literalNode{value: tok.num}, nil
+	case tokString:
+		return &literalNode{value: tok.text}, nil
+	case tokVariable:
+		return &varNode{name: tok.text}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		}
+		if p.peek().kind == tokLParen {
+			return p.parseCall(tok.text)
+		}
+		return &fieldNode{name: tok.text}, nil
+	case tokLParen:
+		inner, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected '%s' at position %d", tok.text, tok.pos)
+}
+
+func (p *parser) parseCall(name string) (node, error) {
+	p.next() // consume '('
+	call := &callNode{name: name}
+	if p.peek().kind == tokRParen {
+		p.next()
+		return call, nil
+	}
+	for {
+		arg, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+
+		tok := p.next()
+		if tok.kind == tokRParen {
+			return call, nil
+		}
+		if tok.kind != tokComma {
+			return nil, fmt.Errorf("expected ',' or ')' at position %d", tok.pos)
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,335 @@
+// Package geo parses GeoJSON geometries and geospatial query operators.
+// Coordinates are always [longitude, latitude] in degrees, as in GeoJSON.
+package geo
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EarthRadiusMeters is the mean Earth radius used for spherical distances
+const EarthRadiusMeters = 6371008.8
+
+// Geometry types
+const (
+	TypePoint   = "Point"
+	TypePolygon = "Polygon"
+)
+
+// Query operators
+const (
+	OpNear          = "$near"
+	OpGeoWithin     = "$geoWithin"
+	OpGeoIntersects = "$geoIntersects"
+)
+
+// Point is a longitude/latitude pair
+type Point struct {
+	Lng float64
+	Lat float64
+}
+
+// Shape is a GeoJSON Point or Polygon. Only the outer ring of a polygon is kept;
+// the ring is always closed.
+type Shape struct {
+	Type  string
+	Point Point
+	Ring  []Point
+}
+
+// GeoJSON renders the shape as a GeoJSON geometry
+func (s Shape) GeoJSON() string {
+	var geometry map[string]interface{}
+	if s.Type == TypePoint {
+		geometry = map[string]interface{}{"type": TypePoint, "coordinates": []float64{s.Point.Lng, s.Point.Lat}}
+	} else {
+		ring := make([][]float64, len(s.Ring))
+		for i, p := range s.Ring {
+			ring[i] = []float64{p.Lng, p.Lat}
+		}
+		geometry = map[string]interface{}{"type": TypePolygon, "coordinates": [][][]float64{ring}}
+	}
+	data, _ := json.Marshal(geometry)
+	return string(data)
+}
+
+// Query is a parsed geospatial filter on a single field
+type Query struct {
+	Operator    string
+	Shape       Shape    // Query geometry; the center for $near and $centerSphere
+	Radius      float64  // $geoWithin $centerSphere radius in meters, zero otherwise
+	MinDistance *float64 // $near lower bound in meters
+	MaxDistance *float64 // $near upper bound in meters
+}
+
+// ParseGeometry parses a GeoJSON Point or Polygon
+func ParseGeometry(value interface{}) (Shape, error) {
+	obj, ok := asMap(value)
+	if !ok {
+		return Shape{}, fmt.Errorf("geometry must be a GeoJSON object")
+	}
+	switch obj["type"] {
+	case TypePoint:
+		point, err := parsePoint(obj["coordinates"])
+		if err != nil {
+			return Shape{}, err
+		}
+		return Shape{Type: TypePoint, Point: point}, nil
+	case TypePolygon:
+		rings, ok := obj["coordinates"].([]interface{})
+		if !ok || len(rings) == 0 {
+			return Shape{}, fmt.Errorf("polygon coordinates must be an array of rings")
+		}
+		ring, err := parseRing(rings[0], false)
+		if err != nil {
+			return Shape{}, err
+		}
+		return Shape{Type: TypePolygon, Ring: ring}, nil
+	}
+	return Shape{}, fmt.Errorf("unsupported geometry type %v: use Point or Polygon", obj["type"])
+}
+
+// ParseQuery recognises a geospatial operator object such as {"$near": {...}}. It returns
+// ok=false when the value is not a geo operator at all.
+func ParseQuery(value interface{}) (query *Query, ok bool, err error) {
+	obj, isMap := asMap(value)
+	if !isMap || len(obj) != 1 {
+		return nil, false, nil
+	}
+	for op, operand := range obj {
+		switch op {
+		case OpNear:
+			query, err = parseNear(operand)
+		case OpGeoWithin:
+			query, err = parseWithin(operand)
+		case OpGeoIntersects:
+			query, err = parseIntersects(operand)
+		default:
+			return nil, false, nil
+		}
+	}
+	return query, true, err
+}
+
+// ValidateFilter checks every geo operator in a filter; at most one $near is allowed
+// because it determines the result order
+func ValidateFilter(filter map[string]interface{}) error {
+	near := 0
+	for field, value := range filter {
+		query, ok, err := ParseQuery(value)
+		if !ok {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("invalid geo query on '%s': %w", field, err)
+		}
+		if query.Operator == OpNear {
+			near++
+		}
+	}
+	if near > 1 {
+		return fmt.Errorf("only one $near query is allowed")
+	}
+	return nil
+}
+
+// FindNear returns the field and query of the $near operator in a filter, if any
+func FindNear(filter map[string]interface{}) (string, *Query) {
+	for field, value := range filter {
+		if query, ok, err := ParseQuery(value); ok && err == nil && query.Operator == OpNear {
+			return field, query
+		}
+	}
+	return "", nil
+}
+
+// Distance returns the great-circle distance between two points in meters
+func Distance(a, b Point) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat := lat2 - lat1
+	dLng := (b.Lng - a.Lng) * math.Pi / 180
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLng/2), 2)
+	return 2 * EarthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// parseNear reads {"$geometry": Point, "$maxDistance": m, "$minDistance": m}
+func parseNear(operand interface{}) (*Query, error) {
+	obj, ok := asMap(operand)
+	if !ok {
+		return nil, fmt.Errorf("$near requires an object with $geometry")
+	}
+	shape, err := ParseGeometry(obj["$geometry"])
+	if err != nil {
+		return nil, err
+	}
+	if shape.Type != TypePoint {
+		return nil, fmt.Errorf("$near requires a Point geometry")
+	}
+
+	query := &Query{Operator: OpNear, Shape: shape}
+	for key, target := range map[string]**float64{"$minDistance": &query.MinDistance, "$maxDistance": &query.MaxDistance} {
+		if raw, present := obj[key]; present {
+			distance, ok := toFloat(raw)
+			if !ok || distance < 0 {
+				return nil, fmt.Errorf("%s must be a non-negative number of meters", key)
+			}
+			*target = &distance
+		}
+	}
+	return query, nil
+}
+
+// parseWithin reads one of $box, $polygon, $centerSphere or $geometry
+func parseWithin(operand interface{}) (*Query, error) {
+	obj, ok := asMap(operand)
+	if !ok || len(obj) != 1 {
+		return nil, fmt.Errorf("$geoWithin requires exactly one of $box, $polygon, $centerSphere or $geometry")
+	}
+
+	query := &Query{Operator: OpGeoWithin}
+	for shape, value := range obj {
+		switch shape {
+		case "$box":
+			corners, ok := value.([]interface{})
+			if !ok || len(corners) != 2 {
+				return nil, fmt.Errorf("$box requires [[minLng, minLat], [maxLng, maxLat]]")
+			}
+			min, err := parsePoint(corners[0])
+			if err != nil {
+				return nil, err
+			}
+			max, err := parsePoint(corners[1])
+			if err != nil {
+				return nil, err
+			}
+			if min.Lng > max.Lng || min.Lat > max.Lat {
+				return nil, fmt.Errorf("$box corners must be bottom-left then top-right")
+			}
+			query.Shape = Shape{Type: TypePolygon, Ring: []Point{
+				min, {Lng: max.Lng, Lat: min.Lat}, max, {Lng: min.Lng, Lat: max.Lat}, min,
+			}}
+		case "$polygon":
+			ring, err := parseRing(value, true)
+			if err != nil {
+				return nil, err
+			}
+			query.Shape = Shape{Type: TypePolygon, Ring: ring}
+		case "$centerSphere":
+			args, ok := value.([]interface{})
+			if !ok || len(args) != 2 {
+				return nil, fmt.Errorf("$centerSphere requires [[lng, lat], radiusInRadians]")
+			}
+			center, err := parsePoint(args[0])
+			if err != nil {
+				return nil, err
+			}
+			radians, ok := toFloat(args[1])
+			if !ok || radians <= 0 || radians > math.Pi {
+				return nil, fmt.Errorf("$centerSphere radius must be between 0 and pi radians")
+			}
+			query.Shape = Shape{Type: TypePoint, Point: center}
+			query.Radius = radians * EarthRadiusMeters
+		case "$geometry":
+			geometry, err := ParseGeometry(value)
+			if err != nil {
+				return nil, err
+			}
+			if geometry.Type != TypePolygon {
+				return nil, fmt.Errorf("$geoWithin $geometry must be a Polygon")
+			}
+			query.Shape = geometry
+		default:
+			return nil, fmt.Errorf("unsupported $geoWithin shape '%s'", shape)
+		}
+	}
+	return query, nil
+}
+
+// parseIntersects reads {"$geometry": Point|Polygon}
+func parseIntersects(operand interface{}) (*Query, error) {
+	obj, ok := asMap(operand)
+	if !ok {
+		return nil, fmt.Errorf("$geoIntersects requires an object with $geometry")
+	}
+	shape, err := ParseGeometry(obj["$geometry"])
+	if err != nil {
+		return nil, err
+	}
+	return &Query{Operator: OpGeoIntersects, Shape: shape}, nil
+}
+
+// parseRing reads a linear ring, closing it when allowed
+func parseRing(value interface{}, autoClose bool) ([]Point, error) {
+	coords, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("polygon ring must be an array of positions")
+	}
+	ring := make([]Point, 0, len(coords)+1)
+	for _, c := range coords {
+		point, err := parsePoint(c)
+		if err != nil {
+			return nil, err
+		}
+		ring = append(ring, point)
+	}
+	if len(ring) > 0 && ring[0] != ring[len(ring)-1] {
+		if !autoClose {
+			return nil, fmt.Errorf("polygon ring must be closed")
+		}
+		ring = append(ring, ring[0])
+	}
+	if len(ring) < 4 {
+		return nil, fmt.Errorf("polygon ring needs at least three distinct positions")
+	}
+	return ring, nil
+}
+
+// parsePoint reads a [lng, lat] position
+func parsePoint(value interface{}) (Point, error) {
+	coords, ok := value.([]interface{})
+	if !ok || len(coords) < 2 {
+		return Point{}, fmt.Errorf("position must be [longitude, latitude]")
+	}
+	lng, okLng := toFloat(coords[0])
+	lat, okLat := toFloat(coords[1])
+	if !okLng || !okLat {
+		return Point{}, fmt.Errorf("position must contain numbers")
+	}
+	if lng < -180 || lng > 180 || lat < -90 || lat > 90 {
+		return Point{}, fmt.Errorf("position [%v, %v] is out of range", lng, lat)
+	}
+	return Point{Lng: lng, Lat: lat}, nil
+}
+
+// asMap accepts both plain maps and bson.M, which share the same underlying type
+func asMap(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, true
+	case primitive.M:
+		return map[string]interface{}(v), true
+	}
+	return nil, false
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -4,12 +4,17 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/madhouselabs/anybase/internal/database/types"
+	"github.com/madhouselabs/anybase/internal/tenant"
+	"github.com/madhouselabs/anybase/pkg/models"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// SystemRoles defines the fixed system roles
+// SystemRoles defines the built-in roles. They are stored in the roles collection on
+// startup; admin always keeps full access and neither can be deleted.
 var SystemRoles = map[string][]string{
 	"admin": {
 		"*:*:*", // Full access to everything
@@ -22,9 +27,19 @@ var SystemRoles = map[string][]string{
 }
 
 type RBACService interface {
-	// User role assignment (simplified - just strings now)
+	// User role assignment
 	GetUserRole(ctx context.Context, userID primitive.ObjectID) (string, error)
-	SetUserRole(ctx context.Context, userID primitive.ObjectID, role string) error
+	GetUserRoles(ctx context.Context, userID primitive.ObjectID) ([]string, error)
+	SetUserRole(ctx context.Context, actorID, userID primitive.ObjectID, role string) error
+	SetUserRoles(ctx context.Context, actorID, userID primitive.ObjectID, roles []string) error
+
+	// Role management
+	EnsureSystemRoles(ctx context.Context) error
+	ListRoles(ctx context.Context) ([]*models.Role, error)
+	GetRole(ctx context.Context, name string) (*models.Role, error)
+	CreateRole(ctx context.Context, actorID primitive.ObjectID, role *models.Role) error
+	UpdateRole(ctx context.Context, actorID primitive.ObjectID, name string, update *models.RoleUpdate) (*models.Role, error)
+	DeleteRole(ctx context.Context, actorID primitive.ObjectID, name string) error
 	
 	// Authorization checks
 	HasPermission(ctx context.Context, userID primitive.ObjectID, resource, action string) (bool, error)
@@ -38,20 +53,69 @@ type RBACService interface {
 type rbacService struct {
 	db types.DB
 	usersCollection types.Collection
+	rolesCollection types.Collection
+	membersCollection types.Collection
+
+	// Role definitions per organization slug; the default namespace is ""
+	rolesMu sync.RWMutex
+	roles   map[string]*roleSet
+}
+
+type roleSet struct {
+	roles  map[string]*models.Role
+	loaded time.Time
 }
 
 func NewRBACService(db types.DB) RBACService {
 	return &rbacService{
 		db: db,
 		usersCollection: db.Collection("users"),
+		rolesCollection: db.Collection("roles"),
+		membersCollection: db.Collection("org_members"),
+		roles: map[string]*roleSet{},
 	}
 }
 
-// GetUserRole gets the role of a user
+// AccessKeyFromContext returns the access key that authenticated the request, if any.
+// A request made with an access key is limited to the key's own permission patterns.
+func AccessKeyFromContext(ctx context.Context) (*models.AccessKey, bool) {
+	key, ok := ctx.Value("access_key").(*models.AccessKey)
+	return key, ok && key != nil
+}
+
+// GetUserRole gets the primary role of a user
 func (s *rbacService) GetUserRole(ctx context.Context, userID primitive.ObjectID) (string, error) {
-	// Skip if access key is already validated
-	if validated, ok := ctx.Value("access_key_validated").(bool); ok && validated {
-		return "admin", nil // Return admin role for validated access keys
+	roles, err := s.GetUserRoles(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if len(roles) == 0 {
+		return "", nil
+	}
+	return roles[0], nil
+}
+
+// GetUserRoles gets every role a user holds, primary role first
+func (s *rbacService) GetUserRoles(ctx context.Context, userID primitive.ObjectID) ([]string, error) {
+	// Access keys carry permissions, not roles
+	if _, ok := AccessKeyFromContext(ctx); ok {
+		return []string{}, nil
+	}
+
+	// Within an organization only the roles of the membership count
+	if org, ok := tenant.FromContext(ctx); ok {
+		var member map[string]interface{}
+		err := s.membersCollection.FindOne(ctx, map[string]interface{}{
+			"org_id":  org.ID.Hex(),
+			"user_id": userID.Hex(),
+		}, &member)
+		if err != nil {
+			if err == types.ErrNoDocuments {
+				return []string{}, nil
+			}
+			return nil, fmt.Errorf("failed to get member roles: %w", err)
+		}
+		return rolesFromDocument(member), nil
 	}
 	
 	var user map[string]interface{}
@@ -64,78 +128,70 @@ func (s *rbacService) GetUserRole(ctx context.Context, userID primitive.ObjectID
 	err := s.usersCollection.FindOne(ctx, filter, &user)
 	if err != nil {
 		if err == types.ErrNoDocuments {
-			return "", fmt.Errorf("user not found")
+			return nil, fmt.Errorf("user not found")
 		}
-		return "", fmt.Errorf("failed to get user role: %w", err)
+		return nil, fmt.Errorf("failed to get user role: %w", err)
 	}
 
-	// Extract role from the user map
-	role, ok := user["role"].(string)
-	if !ok || role == "" {
-		return "developer", nil
+	roles := rolesFromDocument(user)
+	if len(roles) == 0 {
+		return []string{"developer"}, nil
 	}
 
-	return role, nil
+	return roles, nil
 }
 
-// SetUserRole sets the role of a user (admin or developer only)
-func (s *rbacService) SetUserRole(ctx context.Context, userID primitive.ObjectID, role string) error {
-	// Validate role
-	if role != "admin" && role != "developer" {
-		return fmt.Errorf("invalid role: must be 'admin' or 'developer'")
-	}
-
-	filter := map[string]interface{}{
-		"_id": userID.Hex(),
-	}
-
-	update := map[string]interface{}{
-		"$set": map[string]interface{}{"role": role},
-	}
-
-	result, err := s.usersCollection.UpdateOne(ctx, filter, update)
-	if err != nil {
-		return fmt.Errorf("failed to set user role: %w", err)
-	}
-
-	if result.MatchedCount == 0 {
-		return fmt.Errorf("user not found")
-	}
-
-	return nil
+// SetUserRole makes role the only role of a user
+func (s *rbacService) SetUserRole(ctx context.Context, actorID, userID primitive.ObjectID, role string) error {
+	return s.SetUserRoles(ctx, actorID, userID, []string{role})
 }
 
 // HasRole checks if a user has a specific role
 func (s *rbacService) HasRole(ctx context.Context, userID primitive.ObjectID, role string) (bool, error) {
-	// Skip if access key is already validated
-	if validated, ok := ctx.Value("access_key_validated").(bool); ok && validated {
-		return true, nil // Access keys have all roles
-	}
-	
-	userRole, err := s.GetUserRole(ctx, userID)
+	roles, err := s.GetUserRoles(ctx, userID)
 	if err != nil {
 		return false, err
 	}
 
-	return userRole == role, nil
+	for _, r := range roles {
+		if r == role {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
-// GetEffectivePermissions gets all permissions for a user based on their role
+// GetEffectivePermissions merges the permissions of every role a user holds
 func (s *rbacService) GetEffectivePermissions(ctx context.Context, userID primitive.ObjectID) ([]string, error) {
-	// Skip if access key is already validated
-	if validated, ok := ctx.Value("access_key_validated").(bool); ok && validated {
-		return []string{"*:*:*"}, nil // Return full permissions for validated access keys
+	// An access key grants exactly the patterns it was issued with
+	if key, ok := AccessKeyFromContext(ctx); ok {
+		return append([]string{}, key.Permissions...), nil
 	}
 	
-	role, err := s.GetUserRole(ctx, userID)
+	userRoles, err := s.GetUserRoles(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	permissions, ok := SystemRoles[role]
-	if !ok {
-		// Default to no permissions if role not found
-		return []string{}, nil
+	roles, err := s.loadRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Roles that no longer exist grant nothing
+	permissions := []string{}
+	seen := map[string]bool{}
+	for _, name := range userRoles {
+		role, ok := roles[name]
+		if !ok {
+			continue
+		}
+		for _, p := range role.Permissions {
+			if !seen[p] {
+				seen[p] = true
+				permissions = append(permissions, p)
+			}
+		}
 	}
 
 	return permissions, nil
@@ -143,11 +199,6 @@ func (s *rbacService) GetEffectivePermissions(ctx context.Context, userID primit
 
 // HasPermission checks if a user has a specific permission
 func (s *rbacService) HasPermission(ctx context.Context, userID primitive.ObjectID, resource, action string) (bool, error) {
-	// Skip permission checks if access key is already validated
-	if validated, ok := ctx.Value("access_key_validated").(bool); ok && validated {
-		return true, nil
-	}
-	
 	// Construct the permission string
 	var permissionName string
 	if strings.Count(resource, ":") == 1 {
@@ -164,37 +215,11 @@ func (s *rbacService) HasPermission(ctx context.Context, userID primitive.Object
 		return false, err
 	}
 
-	// Check if user has the specific permission
+	// Check if any granted pattern covers the permission
 	for _, p := range permissions {
-		// Exact match
-		if p == permissionName {
+		if MatchPermission(p, permissionName) {
 			return true, nil
 		}
-		
-		// Super admin wildcard
-		if p == "*:*:*" {
-			return true, nil
-		}
-		
-		// Pattern matching for dynamic permissions
-		if strings.Contains(p, "*") {
-			parts := strings.Split(p, ":")
-			targetParts := strings.Split(permissionName, ":")
-			
-			// Handle both 2-part and 3-part permissions
-			if len(parts) == len(targetParts) {
-				match := true
-				for i, part := range parts {
-					if part != "*" && part != targetParts[i] {
-						match = false
-						break
-					}
-				}
-				if match {
-					return true, nil
-				}
-			}
-		}
 	}
 
 	return false, nil
@@ -0,0 +1,443 @@
+package governance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/madhouselabs/anybase/internal/database/types"
+	"github.com/madhouselabs/anybase/internal/tenant"
+	"github.com/madhouselabs/anybase/pkg/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrRoleNotFound = errors.New("role not found")
+	ErrRoleExists   = errors.New("role already exists")
+	ErrRoleInUse    = errors.New("role is assigned to users")
+	ErrSystemRole   = errors.New("system role cannot be changed")
+)
+
+// roleCacheTTL bounds how long role definitions are served from memory, so changes made
+// by other instances are picked up
+const roleCacheTTL = 30 * time.Second
+
+var (
+	roleNamePattern          = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,62}$`)
+	permissionSegmentPattern = regexp.MustCompile(`^[A-Za-z0-9_.*-]+$`)
+)
+
+// ValidatePermission checks that a permission is a type:name:action pattern
+func ValidatePermission(permission string) error {
+	parts := strings.Split(permission, ":")
+	if len(parts) != 3 {
+		return fmt.Errorf("invalid permission '%s': expected type:name:action", permission)
+	}
+	for _, part := range parts {
+		if !permissionSegmentPattern.MatchString(part) {
+			return fmt.Errorf("invalid permission '%s': segments may only contain letters, digits, '_', '-', '.' and '*'", permission)
+		}
+		if _, err := path.Match(part, ""); err != nil {
+			return fmt.Errorf("invalid permission '%s': %w", permission, err)
+		}
+	}
+	return nil
+}
+
+// MatchPermission reports whether a granted pattern covers a required permission. Segments
+// match exactly or by glob, so "collection:team_a_*:*" covers every team_a_ collection.
+func MatchPermission(pattern, required string) bool {
+	if pattern == "*:*:*" || pattern == required {
+		return true
+	}
+	parts := strings.Split(pattern, ":")
+	targetParts := strings.Split(required, ":")
+	if len(parts) != len(targetParts) {
+		return false
+	}
+	for i, part := range parts {
+		if part == "*" || part == targetParts[i] {
+			continue
+		}
+		if matched, err := path.Match(part, targetParts[i]); err != nil || !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// EnsureSystemRoles stores the built-in roles the first time the server starts
+func (s *rbacService) EnsureSystemRoles(ctx context.Context) error {
+	for name, permissions := range SystemRoles {
+		count, err := s.rolesCollection.CountDocuments(ctx, map[string]interface{}{"name": name})
+		if err != nil {
+			return fmt.Errorf("failed to check role %s: %w", name, err)
+		}
+		if count > 0 {
+			continue
+		}
+		now := time.Now().UTC()
+		role := &models.Role{
+			ID:          primitive.NewObjectID(),
+			Name:        name,
+			Description: "Built-in " + name + " role",
+			Permissions: permissions,
+			System:      true,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if _, err := s.rolesCollection.InsertOne(ctx, roleDocument(role)); err != nil {
+			return fmt.Errorf("failed to create role %s: %w", name, err)
+		}
+	}
+	s.invalidateRoles(ctx)
+	return nil
+}
+
+// ListRoles returns every role, sorted by name
+func (s *rbacService) ListRoles(ctx context.Context) ([]*models.Role, error) {
+	roles, err := s.loadRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*models.Role, 0, len(roles))
+	for _, role := range roles {
+		list = append(list, role)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// GetRole returns a role by name
+func (s *rbacService) GetRole(ctx context.Context, name string) (*models.Role, error) {
+	roles, err := s.loadRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	role, ok := roles[name]
+	if !ok {
+		return nil, ErrRoleNotFound
+	}
+	return role, nil
+}
+
+// CreateRole stores a new custom role
+func (s *rbacService) CreateRole(ctx context.Context, actorID primitive.ObjectID, role *models.Role) error {
+	if !roleNamePattern.MatchString(role.Name) {
+		return fmt.Errorf("invalid role name '%s': use lowercase letters, digits, '_' and '-'", role.Name)
+	}
+	if err := validatePermissions(role.Permissions); err != nil {
+		return err
+	}
+	if _, err := s.GetRole(ctx, role.Name); err == nil {
+		return ErrRoleExists
+	}
+
+	now := time.Now().UTC()
+	role.ID = primitive.NewObjectID()
+	role.System = false
+	role.CreatedBy = actorID
+	role.CreatedAt = now
+	role.UpdatedAt = now
+
+	if _, err := s.rolesCollection.InsertOne(ctx, roleDocument(role)); err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return ErrRoleExists
+		}
+		return fmt.Errorf("failed to create role: %w", err)
+	}
+	s.invalidateRoles(ctx)
+
+	s.audit(ctx, actorID, "role.create", "role", role.Name, map[string]interface{}{
+		"permissions": role.Permissions,
+	})
+	return nil
+}
+
+// UpdateRole changes the description or permissions of a role. The admin role is fixed.
+func (s *rbacService) UpdateRole(ctx context.Context, actorID primitive.ObjectID, name string, update *models.RoleUpdate) (*models.Role, error) {
+	role, err := s.GetRole(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if name == "admin" {
+		return nil, ErrSystemRole
+	}
+
+	set := map[string]interface{}{"updated_at": time.Now().UTC()}
+	details := map[string]interface{}{}
+	if update.Description != nil {
+		set["description"] = *update.Description
+	}
+	if update.Permissions != nil {
+		if err := validatePermissions(update.Permissions); err != nil {
+			return nil, err
+		}
+		set["permissions"] = update.Permissions
+		details["before"] = role.Permissions
+		details["after"] = update.Permissions
+	}
+
+	if _, err := s.rolesCollection.UpdateOne(ctx, map[string]interface{}{"name": name}, map[string]interface{}{"$set": set}); err != nil {
+		return nil, fmt.Errorf("failed to update role: %w", err)
+	}
+	s.invalidateRoles(ctx)
+
+	s.audit(ctx, actorID, "role.update", "role", name, details)
+	return s.GetRole(ctx, name)
+}
+
+// DeleteRole removes a custom role that no user holds
+func (s *rbacService) DeleteRole(ctx context.Context, actorID primitive.ObjectID, name string) error {
+	role, err := s.GetRole(ctx, name)
+	if err != nil {
+		return err
+	}
+	if role.System {
+		return ErrSystemRole
+	}
+
+	var holders int64
+	if org, ok := tenant.FromContext(ctx); ok {
+		holders, err = s.membersCollection.CountDocuments(ctx, map[string]interface{}{
+			"org_id": org.ID.Hex(),
+			"roles":  []string{name},
+		})
+	} else {
+		holders, err = s.usersCollection.CountDocuments(ctx, map[string]interface{}{
+			"$or": []interface{}{
+				map[string]interface{}{"role": name},
+				map[string]interface{}{"roles": []string{name}},
+			},
+			"deleted_at": nil,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check role holders: %w", err)
+	}
+	if holders > 0 {
+		return fmt.Errorf("%w: %d user(s)", ErrRoleInUse, holders)
+	}
+
+	if _, err := s.rolesCollection.DeleteOne(ctx, map[string]interface{}{"name": name}); err != nil {
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+	s.invalidateRoles(ctx)
+
+	s.audit(ctx, actorID, "role.delete", "role", name, map[string]interface{}{
+		"permissions": role.Permissions,
+	})
+	return nil
+}
+
+// SetUserRoles replaces the roles a user holds; the first becomes the primary role
+func (s *rbacService) SetUserRoles(ctx context.Context, actorID, userID primitive.ObjectID, roles []string) error {
+	roles = uniqueRoles(roles)
+	if len(roles) == 0 {
+		return fmt.Errorf("a user must hold at least one role")
+	}
+	for _, role := range roles {
+		if _, err := s.GetRole(ctx, role); err != nil {
+			if errors.Is(err, ErrRoleNotFound) {
+				return fmt.Errorf("invalid role: %s does not exist", role)
+			}
+			return err
+		}
+	}
+
+	previous, err := s.GetUserRoles(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	// Within an organization the membership holds the roles
+	var result *types.UpdateResult
+	if org, ok := tenant.FromContext(ctx); ok {
+		result, err = s.membersCollection.UpdateOne(ctx, map[string]interface{}{
+			"org_id":  org.ID.Hex(),
+			"user_id": userID.Hex(),
+		}, map[string]interface{}{
+			"$set": map[string]interface{}{
+				"roles":      roles,
+				"updated_at": time.Now().UTC(),
+			},
+		})
+	} else {
+		result, err = s.usersCollection.UpdateOne(ctx, map[string]interface{}{"_id": userID.Hex()}, map[string]interface{}{
+			"$set": map[string]interface{}{
+				"role":       roles[0],
+				"roles":      roles,
+				"updated_at": time.Now().UTC(),
+			},
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set user roles: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		if _, ok := tenant.FromContext(ctx); ok {
+			return fmt.Errorf("user is not a member of this organization")
+		}
+		return fmt.Errorf("user not found")
+	}
+
+	s.audit(ctx, actorID, "user.roles.update", "user", userID.Hex(), map[string]interface{}{
+		"before": previous,
+		"after":  roles,
+	})
+	return nil
+}
+
+// loadRoles returns the role definitions of the current organization by name, from memory
+// while the cache is fresh. The built-in roles are always available, even before they are stored.
+func (s *rbacService) loadRoles(ctx context.Context) (map[string]*models.Role, error) {
+	namespace := roleNamespace(ctx)
+	s.rolesMu.RLock()
+	if cached, ok := s.roles[namespace]; ok && time.Since(cached.loaded) < roleCacheTTL {
+		s.rolesMu.RUnlock()
+		return cached.roles, nil
+	}
+	s.rolesMu.RUnlock()
+
+	roles := map[string]*models.Role{}
+	for name, permissions := range SystemRoles {
+		roles[name] = &models.Role{Name: name, Permissions: permissions, System: true}
+	}
+
+	cursor, err := s.rolesCollection.Find(ctx, map[string]interface{}{}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load roles: %w", err)
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var doc map[string]interface{}
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+		if role := decodeRole(doc); role != nil {
+			roles[role.Name] = role
+		}
+	}
+	// The admin role always grants everything, whatever is stored
+	if admin, ok := roles["admin"]; ok {
+		admin.Permissions = SystemRoles["admin"]
+		admin.System = true
+	}
+
+	s.rolesMu.Lock()
+	s.roles[namespace] = &roleSet{roles: roles, loaded: time.Now()}
+	s.rolesMu.Unlock()
+	return roles, nil
+}
+
+func (s *rbacService) invalidateRoles(ctx context.Context) {
+	s.rolesMu.Lock()
+	delete(s.roles, roleNamespace(ctx))
+	s.rolesMu.Unlock()
+}
+
+// roleNamespace keys the role cache by organization
+func roleNamespace(ctx context.Context) string {
+	if org, ok := tenant.FromContext(ctx); ok {
+		return org.Slug
+	}
+	return ""
+}
+
+// audit records a change to roles or role assignments; failures do not block the change
+func (s *rbacService) audit(ctx context.Context, actorID primitive.ObjectID, action, resource, resourceID string, details map[string]interface{}) {
+	s.db.Collection("audit_logs").InsertOne(ctx, map[string]interface{}{
+		"_id":         primitive.NewObjectID().Hex(),
+		"user_id":     actorID.Hex(),
+		"action":      action,
+		"resource":    resource,
+		"resource_id": resourceID,
+		"details":     details,
+		"status":      "success",
+		"created_at":  time.Now().UTC(),
+	})
+}
+
+func roleDocument(role *models.Role) map[string]interface{} {
+	doc := map[string]interface{}{
+		"_id":         role.ID.Hex(),
+		"name":        role.Name,
+		"description": role.Description,
+		"permissions": role.Permissions,
+		"system":      role.System,
+		"created_at":  role.CreatedAt,
+		"updated_at":  role.UpdatedAt,
+	}
+	if !role.CreatedBy.IsZero() {
+		doc["created_by"] = role.CreatedBy.Hex()
+	}
+	return doc
+}
+
+// decodeRole converts a stored role document, whose _id is kept as a hex string
+func decodeRole(doc map[string]interface{}) *models.Role {
+	id, _ := doc["_id"].(string)
+	delete(doc, "_id")
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil
+	}
+	var role models.Role
+	if err := json.Unmarshal(raw, &role); err != nil || role.Name == "" {
+		return nil
+	}
+	if objID, err := primitive.ObjectIDFromHex(id); err == nil {
+		role.ID = objID
+	}
+	if role.Permissions == nil {
+		role.Permissions = []string{}
+	}
+	return &role
+}
+
+func validatePermissions(permissions []string) error {
+	for _, p := range permissions {
+		if err := ValidatePermission(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func uniqueRoles(roles []string) []string {
+	var result []string
+	seen := map[string]bool{}
+	for _, role := range roles {
+		role = strings.TrimSpace(role)
+		if role != "" && !seen[role] {
+			seen[role] = true
+			result = append(result, role)
+		}
+	}
+	return result
+}
+
+// rolesFromDocument reads the primary role and additional roles of a stored user
+func rolesFromDocument(user map[string]interface{}) []string {
+	var roles []string
+	if role, ok := user["role"].(string); ok {
+		roles = append(roles, role)
+	}
+	switch list := user["roles"].(type) {
+	case []interface{}:
+		for _, r := range list {
+			if role, ok := r.(string); ok {
+				roles = append(roles, role)
+			}
+		}
+	case []string:
+		roles = append(roles, list...)
+	}
+	return uniqueRoles(roles)
+}
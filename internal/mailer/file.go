@@ -0,0 +1,51 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileMailer writes each message to an .eml file, for development and tests
+type FileMailer struct {
+	dir string
+}
+
+func NewFileMailer(dir string) *FileMailer {
+	return &FileMailer{dir: dir}
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg *Message) error {
+	body, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create mail directory: %w", err)
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), randomID())
+	path := filepath.Join(m.dir, name)
+	if err := os.WriteFile(path, body, 0o600); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	log.Printf("Mail to %s written to %s", strings.Join(msg.To, ", "), path)
+	return nil
+}
+
+// LogMailer prints messages to the server log instead of sending them. Emailed links carry
+// secrets, so it is only suitable for development.
+type LogMailer struct{}
+
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg *Message) error {
+	log.Printf("Mail to %s: %s\n%s", strings.Join(msg.To, ", "), msg.Subject, msg.Text)
+	return nil
+}
@@ -0,0 +1,70 @@
+// Package mailer delivers transactional email. Drivers implement Mailer; Sender renders the
+// named templates, built in or overridden from a directory, and hands the result to a driver.
+package mailer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/madhouselabs/anybase/internal/config"
+)
+
+// Message is a rendered email with a plain text body and an optional HTML alternative
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers messages
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// New returns the mailer selected by the configured driver
+func New(cfg *config.MailConfig) (Mailer, error) {
+	switch cfg.Driver {
+	case "smtp":
+		if cfg.SMTP.Host == "" {
+			return nil, fmt.Errorf("mail.smtp.host is required for the smtp driver")
+		}
+		return NewSMTPMailer(cfg.SMTP), nil
+	case "file":
+		return NewFileMailer(cfg.OutputDir), nil
+	case "log", "":
+		return NewLogMailer(), nil
+	default:
+		return nil, fmt.Errorf("unsupported mail driver: %s", cfg.Driver)
+	}
+}
+
+// Sender renders templates into messages and sends them from a fixed address
+type Sender struct {
+	mailer    Mailer
+	templates *Templates
+	from      string
+}
+
+func NewSender(mailer Mailer, templates *Templates, from string) *Sender {
+	return &Sender{
+		mailer:    mailer,
+		templates: templates,
+		from:      from,
+	}
+}
+
+// SendTemplate renders the named template with data and sends it to a single recipient
+func (s *Sender) SendTemplate(ctx context.Context, to, name string, data map[string]interface{}) error {
+	msg, err := s.templates.Render(name, data)
+	if err != nil {
+		return err
+	}
+	msg.From = s.from
+	msg.To = []string{to}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to send %s email: %w", name, err)
+	}
+	return nil
+}
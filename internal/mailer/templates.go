@@ -0,0 +1,121 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Built-in template names
+const (
+	TemplateVerifyEmail   = "verify_email"
+	TemplatePasswordReset = "password_reset"
+	TemplatePasswordless  = "passwordless_login"
+)
+
+//go:embed templates/*.tmpl
+var builtinTemplates embed.FS
+
+// Templates renders emails. Each template is made of <name>.subject.tmpl, <name>.txt.tmpl
+// and an optional <name>.html.tmpl; any of these files placed in the override directory
+// replaces the built-in one.
+type Templates struct {
+	overrideDir string
+}
+
+// LoadTemplates returns the templates, checking that the override directory exists when one is set
+func LoadTemplates(overrideDir string) (*Templates, error) {
+	if overrideDir != "" {
+		info, err := os.Stat(overrideDir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mail templates directory: %w", err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("invalid mail templates directory: %s is not a directory", overrideDir)
+		}
+	}
+	return &Templates{overrideDir: overrideDir}, nil
+}
+
+// Render renders the named template into a message without sender or recipients
+func (t *Templates) Render(name string, data map[string]interface{}) (*Message, error) {
+	subject, err := t.renderText(name+".subject.tmpl", data)
+	if err != nil {
+		return nil, err
+	}
+	text, err := t.renderText(name+".txt.tmpl", data)
+	if err != nil {
+		return nil, err
+	}
+	html, err := t.renderHTML(name+".html.tmpl", data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		Subject: strings.TrimSpace(subject),
+		Text:    text,
+		HTML:    html,
+	}, nil
+}
+
+func (t *Templates) renderText(file string, data map[string]interface{}) (string, error) {
+	source, err := t.source(file)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := texttemplate.New(file).Parse(source)
+	if err != nil {
+		return "", fmt.Errorf("invalid mail template %s: %w", file, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render mail template %s: %w", file, err)
+	}
+	return buf.String(), nil
+}
+
+// renderHTML renders an HTML body with escaping; a missing HTML template leaves the body empty
+func (t *Templates) renderHTML(file string, data map[string]interface{}) (string, error) {
+	source, err := t.source(file)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	tmpl, err := htmltemplate.New(file).Parse(source)
+	if err != nil {
+		return "", fmt.Errorf("invalid mail template %s: %w", file, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render mail template %s: %w", file, err)
+	}
+	return buf.String(), nil
+}
+
+// source returns the override of a template file if there is one, else the built-in file
+func (t *Templates) source(file string) (string, error) {
+	if t.overrideDir != "" {
+		content, err := os.ReadFile(filepath.Join(t.overrideDir, file))
+		if err == nil {
+			return string(content), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("failed to read mail template %s: %w", file, err)
+		}
+	}
+	content, err := builtinTemplates.ReadFile("templates/" + file)
+	if err != nil {
+		return "", fmt.Errorf("mail template %s: %w", file, fs.ErrNotExist)
+	}
+	return string(content), nil
+}
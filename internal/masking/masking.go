@@ -0,0 +1,105 @@
+// Package masking provides the strategies used to partially reveal field values to callers
+// whose field rules only allow a masked view.
+package masking
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Func renders a value for a caller who may not see it in full. Nil values are passed
+// through without calling the strategy.
+type Func func(value interface{}) interface{}
+
+// Default is the strategy used when a rule does not name one
+const Default = "redact"
+
+const maskChar = "*"
+
+var (
+	mu         sync.RWMutex
+	strategies = map[string]Func{
+		// redact replaces the whole value
+		"redact": func(value interface{}) interface{} {
+			return "****"
+		},
+		// last4 keeps the final four characters, as for card or account numbers
+		"last4": func(value interface{}) interface{} {
+			s := []rune(toString(value))
+			if len(s) <= 4 {
+				return strings.Repeat(maskChar, len(s))
+			}
+			return strings.Repeat(maskChar, len(s)-4) + string(s[len(s)-4:])
+		},
+		// email keeps the first character of the local part and the domain
+		"email": func(value interface{}) interface{} {
+			s := toString(value)
+			at := strings.LastIndex(s, "@")
+			if at < 1 {
+				return "****"
+			}
+			return string([]rune(s)[:1]) + "***" + s[at:]
+		},
+		// null hides the value but keeps the field present
+		"null": func(value interface{}) interface{} {
+			return nil
+		},
+	}
+)
+
+// Register adds or replaces a masking strategy. It is meant to be called during start-up,
+// before collections referencing the strategy are read.
+func Register(name string, fn Func) {
+	mu.Lock()
+	defer mu.Unlock()
+	strategies[name] = fn
+}
+
+// Lookup returns the named strategy; an empty name selects Default
+func Lookup(name string) (Func, bool) {
+	if name == "" {
+		name = Default
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	fn, ok := strategies[name]
+	return fn, ok
+}
+
+// Names lists the registered strategies
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Apply masks value with the named strategy. Unknown strategies fall back to Default so a
+// misconfigured rule never reveals the value.
+func Apply(name string, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	fn, ok := Lookup(name)
+	if !ok {
+		fn, _ = Lookup(Default)
+	}
+	return fn(value)
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", value)
+}
@@ -0,0 +1,62 @@
+package mfa
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+)
+
+// RecoveryCodeCount is the number of recovery codes issued at a time
+const RecoveryCodeCount = 10
+
+// recoveryAlphabet leaves out characters that are easily confused when copied by hand
+const recoveryAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// GenerateRecoveryCodes returns a fresh set of single-use recovery codes, formatted as
+// two groups of five characters
+func GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		chars, err := randomChars(10)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate recovery codes: %w", err)
+		}
+		codes[i] = chars[:5] + "-" + chars[5:]
+	}
+	return codes, nil
+}
+
+// randomChars returns n characters drawn uniformly from the recovery alphabet
+func randomChars(n int) (string, error) {
+	// Bytes at or above limit are discarded so that every character is equally likely
+	limit := byte(256 - 256%len(recoveryAlphabet))
+	out := make([]byte, 0, n)
+	buf := make([]byte, n)
+	for len(out) < n {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if b < limit && len(out) < n {
+				out = append(out, recoveryAlphabet[int(b)%len(recoveryAlphabet)])
+			}
+		}
+	}
+	return string(out), nil
+}
+
+// NormalizeRecoveryCode puts a code typed by a user into its issued form, so that case,
+// spaces and a missing dash don't matter
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer(" ", "", "-", "").Replace(code)
+	if len(code) != 10 {
+		return code
+	}
+	return code[:5] + "-" + code[5:]
+}
+
+// IsRecoveryCode reports whether the input looks like a recovery code rather than a TOTP code
+func IsRecoveryCode(code string) bool {
+	return len(strings.NewReplacer(" ", "", "-", "").Replace(code)) > Digits
+}
@@ -1,21 +1,45 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/madhouselabs/anybase/internal/accesskey"
+	"github.com/madhouselabs/anybase/internal/governance"
+	"github.com/madhouselabs/anybase/internal/organization"
+	"github.com/madhouselabs/anybase/internal/tenant"
+	"github.com/madhouselabs/anybase/pkg/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/time/rate"
 )
 
 // AccessKeyAuthMiddleware handles authentication via access keys
 type AccessKeyAuthMiddleware struct {
-	repo accesskey.Repository
+	repo    accesskey.Repository
+	orgRepo organization.Repository
+
+	// Per-key rate limiters, rebuilt when a key's limit changes
+	limiters map[primitive.ObjectID]*keyLimiter
+	mu       sync.Mutex
+}
+
+type keyLimiter struct {
+	limiter   *rate.Limiter
+	perMinute int
 }
 
 // NewAccessKeyAuthMiddleware creates a new access key auth middleware
-func NewAccessKeyAuthMiddleware(repo accesskey.Repository) *AccessKeyAuthMiddleware {
-	return &AccessKeyAuthMiddleware{repo: repo}
+func NewAccessKeyAuthMiddleware(repo accesskey.Repository, orgRepo organization.Repository) *AccessKeyAuthMiddleware {
+	return &AccessKeyAuthMiddleware{
+		repo:     repo,
+		orgRepo:  orgRepo,
+		limiters: make(map[primitive.ObjectID]*keyLimiter),
+	}
 }
 
 // Authenticate validates access keys from Authorization header
@@ -56,12 +80,57 @@ func (m *AccessKeyAuthMiddleware) Authenticate() gin.HandlerFunc {
 			return
 		}
 
+		// Enforce the key's restrictions
+		clientIP := c.ClientIP()
+		if !accesskey.AllowsIP(ak, clientIP) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "access key is not allowed from this IP address"})
+			c.Abort()
+			return
+		}
+		if !accesskey.AllowsOrigin(ak, requestOrigin(c)) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "access key is not allowed from this origin"})
+			c.Abort()
+			return
+		}
+		if ak.RateLimit > 0 && !m.allow(ak) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "access key rate limit exceeded"})
+			c.Abort()
+			return
+		}
+
+		// A key issued in an organization acts only in that organization
+		ctx := c.Request.Context()
+		if !ak.OrgID.IsZero() {
+			org, err := m.orgRepo.GetByID(ctx, ak.OrgID)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired access key"})
+				c.Abort()
+				return
+			}
+			c.Set("org_id", org.ID.Hex())
+			ctx = tenant.WithOrg(ctx, org)
+		}
+
+		// Record usage without holding up the request
+		endpoint := c.Request.Method + " " + c.Request.URL.Path
+		go func(id primitive.ObjectID) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			m.repo.RecordUsage(ctx, id, clientIP, endpoint)
+		}(ak.ID)
+
 		// Set context values for downstream handlers
 		c.Set("auth_type", "access_key")
 		c.Set("access_key_id", ak.ID.Hex())
 		c.Set("permissions", ak.Permissions)
 		c.Set("authenticated", true)
 
+		// A key acts on its own permissions, never a user's. Handlers that require a caller
+		// ID receive the nil ID, and services check the key's patterns from the request context.
+		c.Set("userID", primitive.NilObjectID.Hex())
+		c.Set("user_id", primitive.NilObjectID.Hex())
+		c.Request = c.Request.WithContext(context.WithValue(ctx, "access_key", ak))
+
 		c.Next()
 	}
 }
@@ -88,7 +157,7 @@ func (m *AccessKeyAuthMiddleware) RequirePermission(resource, action string) gin
 		// Check for exact match or wildcard permissions
 		hasPermission := false
 		for _, perm := range permissions {
-			if matchPermission(perm, requiredPerm) {
+			if governance.MatchPermission(perm, requiredPerm) {
 				hasPermission = true
 				break
 			}
@@ -104,33 +173,29 @@ func (m *AccessKeyAuthMiddleware) RequirePermission(resource, action string) gin
 	}
 }
 
-// matchPermission checks if a permission pattern matches the required permission
-func matchPermission(pattern, required string) bool {
-	// Exact match
-	if pattern == required {
-		return true
-	}
-
-	// Super admin wildcard
-	if pattern == "*:*:*" {
-		return true
+// allow applies the key's per-minute rate limit
+func (m *AccessKeyAuthMiddleware) allow(ak *models.AccessKey) bool {
+	m.mu.Lock()
+	entry, ok := m.limiters[ak.ID]
+	if !ok || entry.perMinute != ak.RateLimit {
+		entry = &keyLimiter{
+			limiter:   rate.NewLimiter(rate.Limit(float64(ak.RateLimit)/60), ak.RateLimit),
+			perMinute: ak.RateLimit,
+		}
+		m.limiters[ak.ID] = entry
 	}
+	m.mu.Unlock()
+	return entry.limiter.Allow()
+}
 
-	// Split into parts for pattern matching
-	patternParts := strings.Split(pattern, ":")
-	requiredParts := strings.Split(required, ":")
-
-	// Must have same number of parts
-	if len(patternParts) != len(requiredParts) {
-		return false
+// requestOrigin returns the browser origin of the request from the Origin header, falling
+// back to the Referer
+func requestOrigin(c *gin.Context) string {
+	if origin := c.GetHeader("Origin"); origin != "" && origin != "null" {
+		return origin
 	}
-
-	// Check each part
-	for i, pp := range patternParts {
-		if pp != "*" && pp != requiredParts[i] {
-			return false
-		}
+	if referer, err := url.Parse(c.GetHeader("Referer")); err == nil && referer.Host != "" {
+		return referer.Scheme + "://" + referer.Host
 	}
-
-	return true
-}
\ No newline at end of file
+	return ""
+}
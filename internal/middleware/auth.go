@@ -6,67 +6,183 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/madhouselabs/anybase/internal/auth"
-	"github.com/madhouselabs/anybase/internal/config"
 	"github.com/madhouselabs/anybase/internal/governance"
+	"github.com/madhouselabs/anybase/internal/organization"
+	"github.com/madhouselabs/anybase/internal/session"
+	"github.com/madhouselabs/anybase/internal/tenant"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type AuthMiddleware struct {
 	tokenService auth.TokenService
 	rbacService  governance.RBACService
+	orgRepo      organization.Repository
+	sessionRepo  session.Repository
 }
 
-func NewAuthMiddleware(config *config.AuthConfig, rbacService governance.RBACService) *AuthMiddleware {
+func NewAuthMiddleware(tokenService auth.TokenService, rbacService governance.RBACService, orgRepo organization.Repository, sessionRepo session.Repository) *AuthMiddleware {
 	return &AuthMiddleware{
-		tokenService: auth.NewTokenService(config),
+		tokenService: tokenService,
 		rbacService:  rbacService,
+		orgRepo:      orgRepo,
+		sessionRepo:  sessionRepo,
 	}
 }
 
 // RequireAuth validates JWT token and sets user context
 func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Check if already authenticated by access key
-		if authenticated, exists := c.Get("authenticated"); exists && authenticated.(bool) {
-			c.Next()
+		if !m.authenticate(c) {
 			return
 		}
+		c.Next()
+	}
+}
 
-		token := m.extractToken(c)
-		if token == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization token required"})
-			c.Abort()
-			return
+// authenticate validates the JWT token and sets the user context without running the rest
+// of the chain, so guards can check roles before the handler runs. It aborts the request and
+// returns false when the caller is not authenticated.
+func (m *AuthMiddleware) authenticate(c *gin.Context) bool {
+	// Check if already authenticated by access key
+	if authenticated, exists := c.Get("authenticated"); exists && authenticated.(bool) {
+		return true
+	}
+
+	token := m.extractToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization token required"})
+		c.Abort()
+		return false
+	}
+
+	// Skip if it's an access key (handled by access key middleware)
+	if strings.HasPrefix(token, "ak_") {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token format"})
+		c.Abort()
+		return false
+	}
+
+	claims, err := m.tokenService.ValidateToken(token, auth.AccessToken)
+	if err != nil {
+		if err == auth.ErrExpiredToken {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has expired"})
+		} else {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 		}
+		c.Abort()
+		return false
+	}
+
+	// Tokens stop working as soon as their session is revoked
+	if err := m.checkSession(c, claims); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has expired or been revoked"})
+		c.Abort()
+		return false
+	}
 
-		// Skip if it's an access key (handled by access key middleware)
-		if strings.HasPrefix(token, "ak_") {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token format"})
+	// Organization tokens only work while the user is still a member
+	roles := claims.Roles
+	if claims.OrgID != "" {
+		if roles, err = m.bindOrganization(c, claims); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 			c.Abort()
-			return
+			return false
 		}
+	}
 
-		claims, err := m.tokenService.ValidateToken(token, auth.AccessToken)
-		if err != nil {
-			if err == auth.ErrExpiredToken {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has expired"})
-			} else {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			}
+	// Set user context
+	c.Set("user", claims) // Set the full claims object for handlers that expect it
+	c.Set("userID", claims.UserID)
+	c.Set("user_id", claims.UserID) // For consistency with existing code
+	c.Set("email", claims.Email)
+	c.Set("roles", roles)
+	c.Set("auth_type", "jwt")
+	c.Set("permissions", claims.Permissions)
+	setImpersonator(c, claims)
+	return true
+}
+
+// setImpersonator records the admin behind an impersonation token, for the audit log and
+// for the guards on sensitive endpoints
+func setImpersonator(c *gin.Context, claims *auth.Claims) {
+	if !claims.Impersonated() {
+		return
+	}
+	c.Set("impersonator_id", claims.Actor.UserID)
+	c.Set("impersonator_email", claims.Actor.Email)
+}
+
+// DenyImpersonation refuses requests made with an impersonation token. It guards actions
+// only the user themselves may take, such as changing their password, MFA or access keys.
+func (m *AuthMiddleware) DenyImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("impersonator_id") != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not available while impersonating a user"})
 			c.Abort()
 			return
 		}
+		c.Next()
+	}
+}
 
-		// Set user context
-		c.Set("user", claims) // Set the full claims object for handlers that expect it
-		c.Set("userID", claims.UserID)
-		c.Set("user_id", claims.UserID) // For consistency with existing code
-		c.Set("email", claims.Email)
-		c.Set("roles", claims.Roles)
-		c.Set("auth_type", "jwt")
-		c.Set("permissions", claims.Permissions)
+// checkSession verifies that the token's session is still active and records its ID
+func (m *AuthMiddleware) checkSession(c *gin.Context, claims *auth.Claims) error {
+	sessionID, err := primitive.ObjectIDFromHex(claims.SessionID)
+	if err != nil {
+		return session.ErrSessionNotFound
+	}
+	userID, err := primitive.ObjectIDFromHex(claims.UserID)
+	if err != nil {
+		return session.ErrSessionNotFound
+	}
+	if err := m.sessionRepo.Validate(c.Request.Context(), sessionID, userID); err != nil {
+		return err
+	}
+	c.Set("session_id", claims.SessionID)
+	return nil
+}
 
-		c.Next()
+// bindOrganization scopes the request to the organization of the token and returns the
+// roles the user currently holds there
+func (m *AuthMiddleware) bindOrganization(c *gin.Context, claims *auth.Claims) ([]string, error) {
+	orgID, err := primitive.ObjectIDFromHex(claims.OrgID)
+	if err != nil {
+		return nil, organization.ErrOrganizationNotFound
+	}
+	userID, err := primitive.ObjectIDFromHex(claims.UserID)
+	if err != nil {
+		return nil, organization.ErrNotMember
+	}
+
+	ctx := c.Request.Context()
+	org, err := m.orgRepo.GetByID(ctx, orgID)
+	if err != nil {
+		return nil, organization.ErrOrganizationNotFound
+	}
+	member, err := m.orgRepo.GetMember(ctx, org.ID, userID)
+	if err != nil {
+		return nil, organization.ErrNotMember
+	}
+
+	c.Set("org_id", org.ID.Hex())
+	c.Request = c.Request.WithContext(tenant.WithOrg(ctx, org))
+	return member.Roles, nil
+}
+
+// RequirePlatformRole is RequireRole for platform-wide endpoints, such as user accounts and
+// system settings, which tokens scoped to an organization cannot reach
+func (m *AuthMiddleware) RequirePlatformRole(roles ...string) gin.HandlerFunc {
+	requireRole := m.RequireRole(roles...)
+	return func(c *gin.Context) {
+		requireRole(c)
+		if c.IsAborted() {
+			return
+		}
+		if _, ok := tenant.FromContext(c.Request.Context()); ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Organization tokens cannot access platform endpoints"})
+			c.Abort()
+			return
+		}
 	}
 }
 
@@ -74,8 +190,7 @@ func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 func (m *AuthMiddleware) RequireRole(roles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// First ensure user is authenticated
-		m.RequireAuth()(c)
-		if c.IsAborted() {
+		if !m.authenticate(c) {
 			return
 		}
 
@@ -124,8 +239,7 @@ func (m *AuthMiddleware) RequireRole(roles ...string) gin.HandlerFunc {
 func (m *AuthMiddleware) RequirePermission(resource, action string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// First ensure user is authenticated
-		m.RequireAuth()(c)
-		if c.IsAborted() {
+		if !m.authenticate(c) {
 			return
 		}
 
@@ -163,18 +277,40 @@ func (m *AuthMiddleware) RequirePermission(resource, action string) gin.HandlerF
 // OptionalAuth validates JWT token if present but doesn't require it
 func (m *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// Access keys authenticated earlier in the chain take precedence
+		if authenticated, exists := c.Get("authenticated"); exists && authenticated.(bool) {
+			c.Next()
+			return
+		}
+
+		// Callers without a valid token are served as anonymous
+		c.Set("auth_type", "anonymous")
+
 		token := m.extractToken(c)
-		if token == "" {
+		if token == "" || strings.HasPrefix(token, "ak_") {
 			c.Next()
 			return
 		}
 
 		claims, err := m.tokenService.ValidateToken(token, auth.AccessToken)
 		if err == nil {
+			err = m.checkSession(c, claims)
+		}
+		roles := []string{}
+		if err == nil && claims.OrgID != "" {
+			roles, err = m.bindOrganization(c, claims)
+		} else if err == nil {
+			roles = claims.Roles
+		}
+		if err == nil {
+			c.Set("user", claims)
 			c.Set("userID", claims.UserID)
+			c.Set("user_id", claims.UserID)
 			c.Set("email", claims.Email)
-			c.Set("roles", claims.Roles)
+			c.Set("roles", roles)
+			c.Set("auth_type", "jwt")
 			c.Set("permissions", claims.Permissions)
+			setImpersonator(c, claims)
 		}
 
 		c.Next()
@@ -194,4 +330,4 @@ func (m *AuthMiddleware) extractToken(c *gin.Context) string {
 
 	// Check query parameter as fallback
 	return c.Query("token")
-}
\ No newline at end of file
+}
@@ -0,0 +1,205 @@
+// Package oidctest provides a mock OpenID Connect provider for tests. It serves discovery,
+// a JWKS, the token and userinfo endpoints, and enforces PKCE and single-use codes the way
+// a real provider does.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const keyID = "test-key"
+
+// Issuer is a running mock provider. Its URL is the issuer identifier.
+type Issuer struct {
+	*httptest.Server
+	ClientID string
+
+	// Claims are added to every ID token, on top of iss, aud, sub, iat, exp and nonce
+	Claims map[string]interface{}
+	// UserInfo is returned by the userinfo endpoint; its sub is filled in if missing
+	UserInfo map[string]interface{}
+	// Forge signs ID tokens with a key that is not in the JWKS, under the published key ID
+	Forge bool
+
+	mu     sync.Mutex
+	key    *rsa.PrivateKey
+	grants map[string]grant
+}
+
+// grant is what the issuer remembers about an authorization code
+type grant struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	nonce       string
+}
+
+// NewIssuer starts a mock provider for a client; close it when done
+func NewIssuer(clientID string) *Issuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(fmt.Sprintf("oidctest: failed to generate key: %v", err))
+	}
+	i := &Issuer{
+		ClientID: clientID,
+		Claims:   map[string]interface{}{"sub": "subject-1"},
+		key:      key,
+		grants:   map[string]grant{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", i.discovery)
+	mux.HandleFunc("/jwks", i.jwks)
+	mux.HandleFunc("/token", i.token)
+	mux.HandleFunc("/userinfo", i.userinfo)
+	i.Server = httptest.NewServer(mux)
+	return i
+}
+
+// Authorize stands in for the user signing in at the provider: it reads the request from
+// an authorization URL and returns the code and state the provider would redirect back with
+func (i *Issuer) Authorize(authURL string) (code, state string, err error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	q := u.Query()
+	if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		return "", "", fmt.Errorf("oidctest: not an authorization code request with PKCE: %s", authURL)
+	}
+
+	code = randomString()
+	i.mu.Lock()
+	i.grants[code] = grant{
+		clientID:    q.Get("client_id"),
+		redirectURI: q.Get("redirect_uri"),
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+	}
+	i.mu.Unlock()
+	return code, q.Get("state"), nil
+}
+
+func (i *Issuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                 i.URL,
+		"authorization_endpoint": i.URL + "/authorize",
+		"token_endpoint":         i.URL + "/token",
+		"userinfo_endpoint":      i.URL + "/userinfo",
+		"jwks_uri":               i.URL + "/jwks",
+	})
+}
+
+func (i *Issuer) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := i.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]interface{}{{
+			"kty": "RSA",
+			"kid": keyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func (i *Issuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	clientID := r.PostForm.Get("client_id")
+	if user, _, ok := r.BasicAuth(); ok {
+		clientID, _ = url.QueryUnescape(user)
+	}
+
+	// Codes work once, whatever the outcome
+	i.mu.Lock()
+	g, ok := i.grants[r.PostForm.Get("code")]
+	delete(i.grants, r.PostForm.Get("code"))
+	i.mu.Unlock()
+	if !ok || g.clientID != clientID || g.redirectURI != r.PostForm.Get("redirect_uri") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	idToken, err := i.idToken(g.nonce)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "access-" + randomString(),
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func (i *Issuer) userinfo(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	info := map[string]interface{}{"sub": i.Claims["sub"]}
+	for k, v := range i.UserInfo {
+		info[k] = v
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (i *Issuer) idToken(nonce string) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":   i.URL,
+		"aud":   i.ClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"nonce": nonce,
+	}
+	for k, v := range i.Claims {
+		claims[k] = v
+	}
+
+	key := i.key
+	if i.Forge {
+		forged, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return "", err
+		}
+		key = forged
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	return token.SignedString(key)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,310 @@
+// Package oidc signs users in through external OpenID Connect identity providers, using
+// the authorization code flow with PKCE.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/madhouselabs/anybase/internal/config"
+)
+
+var (
+	ErrProviderNotFound = errors.New("identity provider not found")
+	ErrInvalidIDToken   = errors.New("invalid id token")
+)
+
+// Identity is the user an identity provider vouched for
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+	Claims        map[string]interface{} // Every claim of the ID token, merged with userinfo
+}
+
+// discovery is the part of the provider metadata the flow needs
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider talks to one identity provider. Its metadata is discovered on first use and kept.
+type Provider struct {
+	cfg    config.OIDCProviderConfig
+	client *http.Client
+
+	mu       sync.Mutex
+	metadata *discovery
+	keys     *keySet
+}
+
+func NewProvider(cfg config.OIDCProviderConfig, client *http.Client) *Provider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &Provider{cfg: cfg, client: client}
+}
+
+// Config returns the provider's configuration
+func (p *Provider) Config() config.OIDCProviderConfig {
+	return p.cfg
+}
+
+// AuthCodeURL returns the provider URL the user is sent to for signing in
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	scopes := p.cfg.Scopes
+	if !containsString(scopes, "openid") {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.cfg.ClientID)
+	params.Set("redirect_uri", p.cfg.RedirectURL)
+	params.Set("scope", strings.Join(scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", CodeChallenge(verifier))
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the identity in the verified ID
+// token. Claims missing from the token, such as the email with some providers, are filled
+// in from the userinfo endpoint.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("code_verifier", verifier)
+	if p.cfg.ClientSecret == "" {
+		// Public clients only identify themselves and rely on PKCE
+		form.Set("client_id", p.cfg.ClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		// client_secret_basic, which providers must support
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.doJSON(req, &token)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	if status != http.StatusOK || token.Error != "" {
+		return nil, fmt.Errorf("token request rejected (%d): %s %s", status, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("%w: token response has no id_token", ErrInvalidIDToken)
+	}
+
+	claims, err := p.verify(ctx, token.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := claims["email"]; !ok && metadata.UserinfoEndpoint != "" && token.AccessToken != "" {
+		if err := p.mergeUserinfo(ctx, metadata.UserinfoEndpoint, token.AccessToken, claims); err != nil {
+			return nil, err
+		}
+	}
+
+	return identityFromClaims(claims), nil
+}
+
+// verify checks the ID token's signature, issuer, audience, expiry and nonce
+func (p *Provider) verify(ctx context.Context, raw, nonce string) (jwt.MapClaims, error) {
+	keys, err := p.keySet(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return keys.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(p.cfg.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	// A token issued to several clients must name this one as the authorized party
+	if aud, ok := claims["aud"].([]interface{}); ok && len(aud) > 1 {
+		if azp, _ := claims["azp"].(string); azp != p.cfg.ClientID {
+			return nil, fmt.Errorf("%w: token is not authorized for this client", ErrInvalidIDToken)
+		}
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+	return claims, nil
+}
+
+// mergeUserinfo adds the claims from the userinfo endpoint that the ID token lacks
+func (p *Provider) mergeUserinfo(ctx context.Context, endpoint, accessToken string, claims jwt.MapClaims) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	var info map[string]interface{}
+	status, err := p.doJSON(req, &info)
+	if err != nil {
+		return fmt.Errorf("userinfo request failed: %w", err)
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("userinfo request rejected (%d)", status)
+	}
+	// The userinfo subject must be the ID token's, or the response is about someone else
+	if info["sub"] != claims["sub"] {
+		return fmt.Errorf("%w: userinfo subject mismatch", ErrInvalidIDToken)
+	}
+	for key, value := range info {
+		if _, ok := claims[key]; !ok {
+			claims[key] = value
+		}
+	}
+	return nil
+}
+
+// discover fetches the provider metadata once
+func (p *Provider) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	endpoint := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	var metadata discovery
+	status, err := p.doJSON(req, &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery failed: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc discovery failed with status %d", status)
+	}
+	if metadata.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("oidc discovery returned issuer %q, expected %q", metadata.Issuer, p.cfg.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc discovery document for %s is incomplete", p.cfg.Issuer)
+	}
+
+	p.metadata = &metadata
+	return p.metadata, nil
+}
+
+func (p *Provider) keySet(ctx context.Context) (*keySet, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.keys == nil {
+		p.keys = newKeySet(metadata.JWKSURI, p.client)
+	}
+	return p.keys, nil
+}
+
+// doJSON sends the request and decodes a JSON response body, whatever the status
+func (p *Provider) doJSON(req *http.Request, out interface{}) (int, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, out); err != nil && resp.StatusCode == http.StatusOK {
+			return resp.StatusCode, fmt.Errorf("invalid response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+func identityFromClaims(claims map[string]interface{}) *Identity {
+	identity := &Identity{Claims: claims}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.GivenName, _ = claims["given_name"].(string)
+	identity.FamilyName, _ = claims["family_name"].(string)
+	// Some providers send the flag as a string
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+	return identity
+}
+
+func containsString(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/madhouselabs/anybase/internal/config"
+	"github.com/madhouselabs/anybase/internal/oidc"
+	"github.com/madhouselabs/anybase/internal/oidc/oidctest"
+)
+
+const clientID = "anybase-test"
+
+func newProvider(iss *oidctest.Issuer) *oidc.Provider {
+	return oidc.NewProvider(config.OIDCProviderConfig{
+		Name:        "test",
+		Issuer:      iss.URL,
+		ClientID:    clientID,
+		RedirectURL: "http://localhost/callback",
+	}, iss.Client())
+}
+
+// signIn runs the authorization request against the mock issuer and returns the code and
+// the verifier it was requested with
+func signIn(t *testing.T, iss *oidctest.Issuer, p *oidc.Provider, nonce string) (string, string) {
+	t.Helper()
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := p.AuthCodeURL(context.Background(), "state-1", nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	if !strings.HasPrefix(authURL, iss.URL+"/authorize?") {
+		t.Fatalf("authorization URL %q does not use the discovered endpoint", authURL)
+	}
+	code, state, err := iss.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != "state-1" {
+		t.Fatalf("state %q was not passed through", state)
+	}
+	return code, verifier
+}
+
+func TestExchange(t *testing.T) {
+	iss := oidctest.NewIssuer(clientID)
+	defer iss.Close()
+	iss.Claims = map[string]interface{}{
+		"sub":            "subject-1",
+		"email":          "ada@example.com",
+		"email_verified": true,
+		"given_name":     "Ada",
+	}
+	p := newProvider(iss)
+
+	code, verifier := signIn(t, iss, p, "nonce-1")
+	identity, err := p.Exchange(context.Background(), code, verifier, "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if identity.Subject != "subject-1" || identity.Email != "ada@example.com" || !identity.EmailVerified || identity.GivenName != "Ada" {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+}
+
+func TestExchangeRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(iss *oidctest.Issuer)
+		exchange func(t *testing.T, p *oidc.Provider, code, verifier string) error
+		idToken  bool // The failure is in the ID token rather than the token request
+	}{
+		{
+			name: "wrong PKCE verifier",
+			exchange: func(t *testing.T, p *oidc.Provider, code, verifier string) error {
+				other, _ := oidc.NewVerifier()
+				_, err := p.Exchange(context.Background(), code, other, "nonce-1")
+				return err
+			},
+		},
+		{
+			name: "reused code",
+			exchange: func(t *testing.T, p *oidc.Provider, code, verifier string) error {
+				if _, err := p.Exchange(context.Background(), code, verifier, "nonce-1"); err != nil {
+					t.Fatalf("first exchange: %v", err)
+				}
+				_, err := p.Exchange(context.Background(), code, verifier, "nonce-1")
+				return err
+			},
+		},
+		{
+			name: "nonce mismatch",
+			exchange: func(t *testing.T, p *oidc.Provider, code, verifier string) error {
+				_, err := p.Exchange(context.Background(), code, verifier, "another-nonce")
+				return err
+			},
+			idToken: true,
+		},
+		{
+			name:  "signature not from the JWKS",
+			setup: func(iss *oidctest.Issuer) { iss.Forge = true },
+			exchange: func(t *testing.T, p *oidc.Provider, code, verifier string) error {
+				_, err := p.Exchange(context.Background(), code, verifier, "nonce-1")
+				return err
+			},
+			idToken: true,
+		},
+		{
+			name:  "token for another client",
+			setup: func(iss *oidctest.Issuer) { iss.Claims["aud"] = "someone-else" },
+			exchange: func(t *testing.T, p *oidc.Provider, code, verifier string) error {
+				_, err := p.Exchange(context.Background(), code, verifier, "nonce-1")
+				return err
+			},
+			idToken: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iss := oidctest.NewIssuer(clientID)
+			defer iss.Close()
+			if tt.setup != nil {
+				tt.setup(iss)
+			}
+			p := newProvider(iss)
+
+			code, verifier := signIn(t, iss, p, "nonce-1")
+			err := tt.exchange(t, p, code, verifier)
+			if err == nil {
+				t.Fatal("expected the exchange to fail")
+			}
+			if tt.idToken != errors.Is(err, oidc.ErrInvalidIDToken) {
+				t.Fatalf("unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestExchangeFillsClaimsFromUserinfo(t *testing.T) {
+	iss := oidctest.NewIssuer(clientID)
+	defer iss.Close()
+	iss.UserInfo = map[string]interface{}{"email": "grace@example.com", "email_verified": "true"}
+	p := newProvider(iss)
+
+	code, verifier := signIn(t, iss, p, "nonce-1")
+	identity, err := p.Exchange(context.Background(), code, verifier, "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if identity.Email != "grace@example.com" || !identity.EmailVerified {
+		t.Fatalf("userinfo claims were not merged: %+v", identity)
+	}
+
+	// A userinfo response about another subject is refused
+	iss.UserInfo["sub"] = "someone-else"
+	code, verifier = signIn(t, iss, p, "nonce-2")
+	if _, err := p.Exchange(context.Background(), code, verifier, "nonce-2"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Fatalf("expected a subject mismatch, got %v", err)
+	}
+}
+
+func TestDiscoveryChecksIssuer(t *testing.T) {
+	iss := oidctest.NewIssuer(clientID)
+	defer iss.Close()
+
+	// The discovery document names the issuer without the trailing slash
+	p := oidc.NewProvider(config.OIDCProviderConfig{
+		Issuer:      iss.URL + "/",
+		ClientID:    clientID,
+		RedirectURL: "http://localhost/callback",
+	}, iss.Client())
+	verifier, _ := oidc.NewVerifier()
+	if _, err := p.AuthCodeURL(context.Background(), "state", "nonce", verifier); err == nil {
+		t.Fatal("expected discovery to reject the issuer")
+	}
+}
@@ -0,0 +1,64 @@
+package oidc
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/madhouselabs/anybase/internal/config"
+)
+
+// namePattern keeps provider names safe to use in URLs
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Registry holds the configured identity providers by name
+type Registry struct {
+	providers map[string]*Provider
+}
+
+// NewRegistry checks the provider configuration and sets up a client for each provider.
+// A nil client gets a default with a timeout.
+func NewRegistry(cfgs []config.OIDCProviderConfig, client *http.Client) (*Registry, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	providers := make(map[string]*Provider, len(cfgs))
+	for _, cfg := range cfgs {
+		if !namePattern.MatchString(cfg.Name) {
+			return nil, fmt.Errorf("invalid oidc provider name %q", cfg.Name)
+		}
+		if _, exists := providers[cfg.Name]; exists {
+			return nil, fmt.Errorf("duplicate oidc provider %q", cfg.Name)
+		}
+		if cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+			return nil, fmt.Errorf("oidc provider %q needs an issuer, client_id and redirect_url", cfg.Name)
+		}
+		providers[cfg.Name] = NewProvider(cfg, client)
+	}
+
+	return &Registry{providers: providers}, nil
+}
+
+// Get returns the provider with the given name
+func (r *Registry) Get(name string) (*Provider, error) {
+	provider, ok := r.providers[name]
+	if !ok {
+		return nil, ErrProviderNotFound
+	}
+	return provider, nil
+}
+
+// List returns the providers sorted by name
+func (r *Registry) List() []*Provider {
+	providers := make([]*Provider, 0, len(r.providers))
+	for _, provider := range r.providers {
+		providers = append(providers, provider)
+	}
+	sort.Slice(providers, func(i, j int) bool {
+		return providers[i].cfg.Name < providers[j].cfg.Name
+	})
+	return providers
+}
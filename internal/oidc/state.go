@@ -0,0 +1,99 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	types "github.com/madhouselabs/anybase/internal/database/types"
+)
+
+// StateExpiration is how long a user has to sign in at the provider
+const StateExpiration = 10 * time.Minute
+
+var ErrStateNotFound = errors.New("sign-in request not found or expired")
+
+// LoginState is what the server remembers about a sign-in between sending the user to the
+// provider and the callback. The state parameter is the key, and only its hash is stored.
+type LoginState struct {
+	Provider     string    `json:"provider"`
+	Nonce        string    `json:"nonce"`
+	CodeVerifier string    `json:"code_verifier"`
+	Org          string    `json:"org,omitempty"` // Organization slug to sign in to
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// StateStore keeps pending sign-ins
+type StateStore interface {
+	// Create stores the login state and returns the state parameter for the provider
+	Create(ctx context.Context, state *LoginState) (string, error)
+	// Consume returns the login state and deletes it, so a callback is handled only once
+	Consume(ctx context.Context, state string) (*LoginState, error)
+}
+
+type stateStore struct {
+	collection types.Collection
+}
+
+func NewStateStore(db types.DB) StateStore {
+	return &stateStore{collection: db.Collection("oidc_states")}
+}
+
+func (s *stateStore) Create(ctx context.Context, st *LoginState) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate state: %w", err)
+	}
+	state := hex.EncodeToString(b)
+
+	st.ExpiresAt = time.Now().UTC().Add(StateExpiration)
+	if _, err := s.collection.InsertOne(ctx, map[string]interface{}{
+		"_id":           hashState(state),
+		"provider":      st.Provider,
+		"nonce":         st.Nonce,
+		"code_verifier": st.CodeVerifier,
+		"org":           st.Org,
+		"expires_at":    st.ExpiresAt,
+	}); err != nil {
+		return "", fmt.Errorf("failed to store login state: %w", err)
+	}
+	return state, nil
+}
+
+func (s *stateStore) Consume(ctx context.Context, state string) (*LoginState, error) {
+	if state == "" {
+		return nil, ErrStateNotFound
+	}
+	filter := map[string]interface{}{"_id": hashState(state)}
+
+	var st LoginState
+	if err := s.collection.FindOne(ctx, filter, &st); err != nil {
+		if errors.Is(err, types.ErrNoDocuments) {
+			return nil, ErrStateNotFound
+		}
+		return nil, fmt.Errorf("failed to get login state: %w", err)
+	}
+
+	// Whoever deletes the state owns the callback
+	result, err := s.collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete login state: %w", err)
+	}
+	if result.DeletedCount == 0 {
+		return nil, ErrStateNotFound
+	}
+
+	if time.Now().After(st.ExpiresAt) {
+		return nil, ErrStateNotFound
+	}
+	return &st, nil
+}
+
+func hashState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,232 @@
+package organization
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	types "github.com/madhouselabs/anybase/internal/database/types"
+	"github.com/madhouselabs/anybase/pkg/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrOrganizationNotFound = errors.New("organization not found")
+	ErrOrganizationExists   = errors.New("organization already exists")
+	ErrNotMember            = errors.New("user is not a member of this organization")
+	ErrMemberExists         = errors.New("user is already a member of this organization")
+	ErrNoOrganization       = errors.New("no organization selected")
+)
+
+// Repository stores organizations and their members. Both live in shared tables, outside
+// every organization's namespace.
+type Repository interface {
+	Create(ctx context.Context, org *models.Organization) error
+	GetByID(ctx context.Context, id primitive.ObjectID) (*models.Organization, error)
+	GetBySlug(ctx context.Context, slug string) (*models.Organization, error)
+	List(ctx context.Context) ([]*models.Organization, error)
+
+	AddMember(ctx context.Context, member *models.OrgMember) error
+	GetMember(ctx context.Context, orgID, userID primitive.ObjectID) (*models.OrgMember, error)
+	ListMembers(ctx context.Context, orgID primitive.ObjectID) ([]*models.OrgMember, error)
+	ListMemberships(ctx context.Context, userID primitive.ObjectID) ([]*models.OrgMember, error)
+	RemoveMember(ctx context.Context, orgID, userID primitive.ObjectID) error
+}
+
+type repository struct {
+	organizations types.Collection
+	members       types.Collection
+}
+
+func NewRepository(db types.DB) Repository {
+	return &repository{
+		organizations: db.Collection("organizations"),
+		members:       db.Collection("org_members"),
+	}
+}
+
+func (r *repository) Create(ctx context.Context, org *models.Organization) error {
+	if _, err := r.GetBySlug(ctx, org.Slug); err == nil {
+		return ErrOrganizationExists
+	} else if !errors.Is(err, ErrOrganizationNotFound) {
+		return err
+	}
+
+	now := time.Now().UTC()
+	org.ID = primitive.NewObjectID()
+	org.CreatedAt = now
+	org.UpdatedAt = now
+
+	doc := map[string]interface{}{
+		"_id":         org.ID.Hex(),
+		"name":        org.Name,
+		"slug":        org.Slug,
+		"description": org.Description,
+		"created_at":  org.CreatedAt,
+		"updated_at":  org.UpdatedAt,
+	}
+	if !org.CreatedBy.IsZero() {
+		doc["created_by"] = org.CreatedBy.Hex()
+	}
+	if _, err := r.organizations.InsertOne(ctx, doc); err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return ErrOrganizationExists
+		}
+		return fmt.Errorf("failed to create organization: %w", err)
+	}
+	return nil
+}
+
+func (r *repository) GetByID(ctx context.Context, id primitive.ObjectID) (*models.Organization, error) {
+	return r.findOrganization(ctx, map[string]interface{}{"_id": id.Hex()})
+}
+
+func (r *repository) GetBySlug(ctx context.Context, slug string) (*models.Organization, error) {
+	return r.findOrganization(ctx, map[string]interface{}{"slug": slug})
+}
+
+func (r *repository) findOrganization(ctx context.Context, filter map[string]interface{}) (*models.Organization, error) {
+	var doc map[string]interface{}
+	if err := r.organizations.FindOne(ctx, filter, &doc); err != nil {
+		if errors.Is(err, types.ErrNoDocuments) {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	org := &models.Organization{}
+	if !decodeDocument(doc, &org.ID, org) {
+		return nil, fmt.Errorf("failed to decode organization")
+	}
+	return org, nil
+}
+
+// List returns every organization, sorted by slug
+func (r *repository) List(ctx context.Context) ([]*models.Organization, error) {
+	cursor, err := r.organizations.Find(ctx, map[string]interface{}{}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	orgs := []*models.Organization{}
+	for cursor.Next(ctx) {
+		var doc map[string]interface{}
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+		org := &models.Organization{}
+		if decodeDocument(doc, &org.ID, org) {
+			orgs = append(orgs, org)
+		}
+	}
+	sort.Slice(orgs, func(i, j int) bool { return orgs[i].Slug < orgs[j].Slug })
+	return orgs, nil
+}
+
+func (r *repository) AddMember(ctx context.Context, member *models.OrgMember) error {
+	if _, err := r.GetMember(ctx, member.OrgID, member.UserID); err == nil {
+		return ErrMemberExists
+	} else if !errors.Is(err, ErrNotMember) {
+		return err
+	}
+
+	now := time.Now().UTC()
+	member.ID = primitive.NewObjectID()
+	member.CreatedAt = now
+	member.UpdatedAt = now
+
+	if _, err := r.members.InsertOne(ctx, map[string]interface{}{
+		"_id":        member.ID.Hex(),
+		"org_id":     member.OrgID.Hex(),
+		"user_id":    member.UserID.Hex(),
+		"roles":      member.Roles,
+		"created_at": member.CreatedAt,
+		"updated_at": member.UpdatedAt,
+	}); err != nil {
+		return fmt.Errorf("failed to add member: %w", err)
+	}
+	return nil
+}
+
+func (r *repository) GetMember(ctx context.Context, orgID, userID primitive.ObjectID) (*models.OrgMember, error) {
+	var doc map[string]interface{}
+	err := r.members.FindOne(ctx, map[string]interface{}{
+		"org_id":  orgID.Hex(),
+		"user_id": userID.Hex(),
+	}, &doc)
+	if err != nil {
+		if errors.Is(err, types.ErrNoDocuments) {
+			return nil, ErrNotMember
+		}
+		return nil, fmt.Errorf("failed to get member: %w", err)
+	}
+	member := &models.OrgMember{}
+	if !decodeDocument(doc, &member.ID, member) {
+		return nil, fmt.Errorf("failed to decode member")
+	}
+	return member, nil
+}
+
+func (r *repository) ListMembers(ctx context.Context, orgID primitive.ObjectID) ([]*models.OrgMember, error) {
+	return r.findMembers(ctx, map[string]interface{}{"org_id": orgID.Hex()})
+}
+
+func (r *repository) ListMemberships(ctx context.Context, userID primitive.ObjectID) ([]*models.OrgMember, error) {
+	return r.findMembers(ctx, map[string]interface{}{"user_id": userID.Hex()})
+}
+
+func (r *repository) findMembers(ctx context.Context, filter map[string]interface{}) ([]*models.OrgMember, error) {
+	cursor, err := r.members.Find(ctx, filter, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list members: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	members := []*models.OrgMember{}
+	for cursor.Next(ctx) {
+		var doc map[string]interface{}
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+		member := &models.OrgMember{}
+		if decodeDocument(doc, &member.ID, member) {
+			members = append(members, member)
+		}
+	}
+	return members, nil
+}
+
+func (r *repository) RemoveMember(ctx context.Context, orgID, userID primitive.ObjectID) error {
+	result, err := r.members.DeleteOne(ctx, map[string]interface{}{
+		"org_id":  orgID.Hex(),
+		"user_id": userID.Hex(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotMember
+	}
+	return nil
+}
+
+// decodeDocument converts a stored document, whose _id is kept as a hex string, into out
+func decodeDocument(doc map[string]interface{}, id *primitive.ObjectID, out interface{}) bool {
+	hexID, _ := doc["_id"].(string)
+	delete(doc, "_id")
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return false
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return false
+	}
+	if objID, err := primitive.ObjectIDFromHex(hexID); err == nil {
+		*id = objID
+	}
+	return true
+}
@@ -0,0 +1,196 @@
+package organization
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	types "github.com/madhouselabs/anybase/internal/database/types"
+	"github.com/madhouselabs/anybase/internal/governance"
+	"github.com/madhouselabs/anybase/internal/tenant"
+	"github.com/madhouselabs/anybase/pkg/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Service manages organizations and their members. Member operations always act on the
+// organization bound to the context, so a caller can only reach the organization its token
+// or access key was issued for.
+type Service interface {
+	CreateOrganization(ctx context.Context, actorID primitive.ObjectID, req *models.CreateOrganizationRequest) (*models.Organization, error)
+	ListOrganizations(ctx context.Context) ([]*models.Organization, error)
+	ListUserOrganizations(ctx context.Context, userID primitive.ObjectID) ([]*models.Organization, error)
+
+	ListMembers(ctx context.Context) ([]*models.OrgMember, error)
+	AddMember(ctx context.Context, actorID, userID primitive.ObjectID, roles []string) (*models.OrgMember, error)
+	SetMemberRoles(ctx context.Context, actorID, userID primitive.ObjectID, roles []string) error
+	RemoveMember(ctx context.Context, actorID, userID primitive.ObjectID) error
+}
+
+// tenantProvisioner is implemented by storage adapters that keep per-organization tables
+type tenantProvisioner interface {
+	EnsureTenantTables(ctx context.Context) error
+}
+
+type service struct {
+	db          types.DB
+	repo        Repository
+	rbacService governance.RBACService
+}
+
+func NewService(db types.DB, repo Repository, rbacService governance.RBACService) Service {
+	return &service{
+		db:          db,
+		repo:        repo,
+		rbacService: rbacService,
+	}
+}
+
+// CreateOrganization creates an organization with its own namespace and makes the creator
+// its first admin
+func (s *service) CreateOrganization(ctx context.Context, actorID primitive.ObjectID, req *models.CreateOrganizationRequest) (*models.Organization, error) {
+	slug := strings.TrimSpace(req.Slug)
+	if err := tenant.ValidateSlug(slug); err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("organization name is required")
+	}
+
+	org := &models.Organization{
+		Name:        name,
+		Slug:        slug,
+		Description: req.Description,
+		CreatedBy:   actorID,
+	}
+	if err := s.repo.Create(ctx, org); err != nil {
+		return nil, err
+	}
+
+	// Everything from here on is stored in the new organization's namespace
+	orgCtx := tenant.WithOrg(ctx, org)
+	if provisioner, ok := s.db.(tenantProvisioner); ok {
+		if err := provisioner.EnsureTenantTables(orgCtx); err != nil {
+			return nil, fmt.Errorf("failed to provision organization: %w", err)
+		}
+	}
+	if err := s.rbacService.EnsureSystemRoles(orgCtx); err != nil {
+		return nil, fmt.Errorf("failed to create organization roles: %w", err)
+	}
+	if err := s.repo.AddMember(ctx, &models.OrgMember{
+		OrgID:  org.ID,
+		UserID: actorID,
+		Roles:  []string{"admin"},
+	}); err != nil {
+		return nil, err
+	}
+
+	s.audit(orgCtx, actorID, "organization.create", "organization", org.ID.Hex(), map[string]interface{}{
+		"slug": org.Slug,
+	})
+	return org, nil
+}
+
+// ListOrganizations returns every organization
+func (s *service) ListOrganizations(ctx context.Context) ([]*models.Organization, error) {
+	return s.repo.List(ctx)
+}
+
+// ListUserOrganizations returns the organizations a user belongs to
+func (s *service) ListUserOrganizations(ctx context.Context, userID primitive.ObjectID) ([]*models.Organization, error) {
+	memberships, err := s.repo.ListMemberships(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	orgs := []*models.Organization{}
+	for _, membership := range memberships {
+		org, err := s.repo.GetByID(ctx, membership.OrgID)
+		if err != nil {
+			if errors.Is(err, ErrOrganizationNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		orgs = append(orgs, org)
+	}
+	return orgs, nil
+}
+
+// ListMembers returns the members of the current organization
+func (s *service) ListMembers(ctx context.Context) ([]*models.OrgMember, error) {
+	org, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, ErrNoOrganization
+	}
+	return s.repo.ListMembers(ctx, org.ID)
+}
+
+// AddMember adds a user to the current organization with roles defined in it
+func (s *service) AddMember(ctx context.Context, actorID, userID primitive.ObjectID, roles []string) (*models.OrgMember, error) {
+	org, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, ErrNoOrganization
+	}
+	if len(roles) == 0 {
+		return nil, fmt.Errorf("a member must hold at least one role")
+	}
+	for _, role := range roles {
+		if _, err := s.rbacService.GetRole(ctx, role); err != nil {
+			if errors.Is(err, governance.ErrRoleNotFound) {
+				return nil, fmt.Errorf("invalid role: %s does not exist", role)
+			}
+			return nil, err
+		}
+	}
+
+	member := &models.OrgMember{OrgID: org.ID, UserID: userID, Roles: roles}
+	if err := s.repo.AddMember(ctx, member); err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, actorID, "organization.member.add", "user", userID.Hex(), map[string]interface{}{
+		"roles": roles,
+	})
+	return member, nil
+}
+
+// SetMemberRoles replaces the roles a member holds in the current organization
+func (s *service) SetMemberRoles(ctx context.Context, actorID, userID primitive.ObjectID, roles []string) error {
+	if _, ok := tenant.FromContext(ctx); !ok {
+		return ErrNoOrganization
+	}
+	return s.rbacService.SetUserRoles(ctx, actorID, userID, roles)
+}
+
+// RemoveMember removes a user from the current organization
+func (s *service) RemoveMember(ctx context.Context, actorID, userID primitive.ObjectID) error {
+	org, ok := tenant.FromContext(ctx)
+	if !ok {
+		return ErrNoOrganization
+	}
+	if actorID == userID {
+		return fmt.Errorf("you cannot remove yourself from the organization")
+	}
+	if err := s.repo.RemoveMember(ctx, org.ID, userID); err != nil {
+		return err
+	}
+
+	s.audit(ctx, actorID, "organization.member.remove", "user", userID.Hex(), nil)
+	return nil
+}
+
+// audit records a change in the organization's audit log; failures do not block the change
+func (s *service) audit(ctx context.Context, actorID primitive.ObjectID, action, resource, resourceID string, details map[string]interface{}) {
+	s.db.Collection("audit_logs").InsertOne(ctx, map[string]interface{}{
+		"_id":         primitive.NewObjectID().Hex(),
+		"user_id":     actorID.Hex(),
+		"action":      action,
+		"resource":    resource,
+		"resource_id": resourceID,
+		"details":     details,
+		"status":      "success",
+		"created_at":  time.Now().UTC(),
+	})
+}
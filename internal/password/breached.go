@@ -0,0 +1,81 @@
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+//go:embed breached.txt
+var embeddedBreached string
+
+var (
+	breachedMu sync.RWMutex
+	breached   = mustParseBreached(embeddedBreached)
+)
+
+// IsBreached reports whether the password is on the breached-password list. Matching
+// ignores case, and also catches a listed password with digits and symbols tacked on,
+// like "Password123!".
+func IsBreached(password string) bool {
+	pw := strings.ToLower(password)
+	base := strings.TrimRightFunc(pw, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	breachedMu.RLock()
+	defer breachedMu.RUnlock()
+	if _, ok := breached[pw]; ok {
+		return true
+	}
+	_, ok := breached[base]
+	return ok && base != ""
+}
+
+// LoadBreachedList adds the passwords in a file, one per line, to the built-in list. Lines
+// starting with # are ignored.
+func LoadBreachedList(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open breached password list: %w", err)
+	}
+	defer f.Close()
+
+	words, err := parseBreached(f)
+	if err != nil {
+		return fmt.Errorf("failed to read breached password list: %w", err)
+	}
+
+	breachedMu.Lock()
+	defer breachedMu.Unlock()
+	for word := range words {
+		breached[word] = struct{}{}
+	}
+	return nil
+}
+
+func mustParseBreached(list string) map[string]struct{} {
+	words, err := parseBreached(strings.NewReader(list))
+	if err != nil {
+		panic(err)
+	}
+	return words
+}
+
+func parseBreached(r io.Reader) (map[string]struct{}, error) {
+	words := map[string]struct{}{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words[strings.ToLower(line)] = struct{}{}
+	}
+	return words, scanner.Err()
+}
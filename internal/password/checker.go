@@ -0,0 +1,127 @@
+package password
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/madhouselabs/anybase/internal/settings"
+	"github.com/madhouselabs/anybase/pkg/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MaxHistory is how many earlier password hashes are kept per user, enough for the
+// strictest tier
+const MaxHistory = 5
+
+// Checker applies the password policy chosen in the system settings
+type Checker struct {
+	settings  settings.Service
+	minLength int
+}
+
+// NewChecker returns a checker for the policy in the system settings. minLength raises
+// the tier's minimum length when it is higher.
+func NewChecker(settingsService settings.Service, minLength int) *Checker {
+	return &Checker{settings: settingsService, minLength: minLength}
+}
+
+// Policy returns the policy currently in force
+func (c *Checker) Policy(ctx context.Context) (*Policy, error) {
+	systemSettings, err := c.settings.GetSystemSettings(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load system settings: %w", err)
+	}
+	policy := ForTier(systemSettings.PasswordPolicy)
+	if c.minLength > policy.MinLength {
+		policy.MinLength = c.minLength
+	}
+	if systemSettings.PasswordMaxAge > 0 {
+		policy.MaxAgeDays = systemSettings.PasswordMaxAge
+	}
+	return policy, nil
+}
+
+// Check returns a *PolicyError listing every requirement the new password fails for the
+// user. For an existing user it also refuses the current and recent passwords.
+func (c *Checker) Check(ctx context.Context, password string, u *models.User) error {
+	policy, err := c.Policy(ctx)
+	if err != nil {
+		return err
+	}
+
+	violations := policy.Validate(password, u.Email)
+	if policy.HistorySize > 0 && Reused(password, recentHashes(u, policy.HistorySize)) {
+		violations = append(violations, Violation{
+			Code:    CodeReused,
+			Message: fmt.Sprintf("must not be one of your last %d passwords", policy.HistorySize),
+		})
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// Expired reports whether the user's password is older than the maximum age. Accounts
+// without a password, such as those signing in through an identity provider, never expire.
+func (c *Checker) Expired(ctx context.Context, u *models.User) (bool, error) {
+	if u.Password == "" {
+		return false, nil
+	}
+	policy, err := c.Policy(ctx)
+	if err != nil {
+		return false, err
+	}
+	if policy.MaxAgeDays <= 0 {
+		return false, nil
+	}
+
+	// Passwords set before the change date was tracked count from account creation
+	changedAt := u.CreatedAt
+	if u.PasswordChangedAt != nil {
+		changedAt = *u.PasswordChangedAt
+	}
+	return time.Since(changedAt) > time.Duration(policy.MaxAgeDays)*24*time.Hour, nil
+}
+
+// Reused reports whether the password matches any of the bcrypt hashes
+func Reused(password string, hashes []string) bool {
+	for _, hash := range hashes {
+		if hash != "" && bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// History returns the password history to store once the user's current password is
+// replaced: the current hash followed by the earlier ones, newest first
+func History(u *models.User) []string {
+	history := []string{}
+	if u.Password != "" {
+		history = append(history, u.Password)
+	}
+	history = append(history, u.PasswordHistory...)
+	if len(history) > MaxHistory {
+		history = history[:MaxHistory]
+	}
+	return history
+}
+
+// recentHashes returns the current password hash and enough earlier ones to cover size
+// passwords in all
+func recentHashes(u *models.User, size int) []string {
+	hashes := []string{}
+	if u.Password != "" {
+		hashes = append(hashes, u.Password)
+	}
+	for _, hash := range u.PasswordHistory {
+		if len(hashes) >= size {
+			break
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes
+}
@@ -0,0 +1,165 @@
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Policy tiers, as stored in the system settings
+const (
+	TierBasic    = "basic"
+	TierModerate = "moderate"
+	TierStrong   = "strong"
+)
+
+// MaxLength is the longest password accepted; bcrypt ignores anything past 72 bytes
+const MaxLength = 72
+
+// Violation codes
+const (
+	CodeTooShort     = "too_short"
+	CodeTooLong      = "too_long"
+	CodeCharClasses  = "char_classes"
+	CodeSimilarEmail = "similar_to_email"
+	CodeBreached     = "breached"
+	CodeReused       = "reused"
+)
+
+// Policy lists the requirements a new password must meet
+type Policy struct {
+	Tier           string `json:"tier"`
+	MinLength      int    `json:"min_length"`
+	MaxLength      int    `json:"max_length"`
+	MinCharClasses int    `json:"min_char_classes"` // Of lowercase, uppercase, digits and symbols
+	CheckBreached  bool   `json:"check_breached"`
+	HistorySize    int    `json:"history_size"` // Recent passwords, including the current one, that can't be reused
+	MaxAgeDays     int    `json:"max_age_days"` // 0 when passwords don't expire
+}
+
+// ForTier returns the policy for a tier. Unknown tiers get the moderate policy, which is
+// also the default in the system settings.
+func ForTier(tier string) *Policy {
+	switch tier {
+	case TierBasic:
+		return &Policy{Tier: TierBasic, MinLength: 8, MaxLength: MaxLength, MinCharClasses: 1}
+	case TierStrong:
+		return &Policy{Tier: TierStrong, MinLength: 12, MaxLength: MaxLength, MinCharClasses: 4, CheckBreached: true, HistorySize: 5}
+	default:
+		return &Policy{Tier: TierModerate, MinLength: 10, MaxLength: MaxLength, MinCharClasses: 3, CheckBreached: true, HistorySize: 3}
+	}
+}
+
+// Violation is one requirement a password failed
+type Violation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PolicyError carries every requirement a password failed, so they can be shown together
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return "password does not meet the policy: " + strings.Join(messages, "; ")
+}
+
+// Validate checks the parts of the policy that need only the password and the account's
+// email. Reuse of earlier passwords is checked separately, against their hashes.
+func (p *Policy) Validate(password, email string) []Violation {
+	violations := []Violation{}
+
+	length := len([]rune(password))
+	if length < p.MinLength {
+		violations = append(violations, Violation{
+			Code:    CodeTooShort,
+			Message: fmt.Sprintf("must be at least %d characters", p.MinLength),
+		})
+	}
+	if len(password) > p.MaxLength {
+		violations = append(violations, Violation{
+			Code:    CodeTooLong,
+			Message: fmt.Sprintf("must be at most %d bytes", p.MaxLength),
+		})
+	}
+
+	if classes := charClasses(password); classes < p.MinCharClasses {
+		message := fmt.Sprintf("must use at least %d of lowercase letters, uppercase letters, digits and symbols", p.MinCharClasses)
+		if p.MinCharClasses == 4 {
+			message = "must use lowercase letters, uppercase letters, digits and symbols"
+		}
+		violations = append(violations, Violation{Code: CodeCharClasses, Message: message})
+	}
+
+	if similarToEmail(password, email) {
+		violations = append(violations, Violation{
+			Code:    CodeSimilarEmail,
+			Message: "must not contain or resemble your email address",
+		})
+	}
+
+	if p.CheckBreached && IsBreached(password) {
+		violations = append(violations, Violation{
+			Code:    CodeBreached,
+			Message: "is too common and appears in lists of breached passwords",
+		})
+	}
+
+	return violations
+}
+
+func charClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			classes++
+		}
+	}
+	return classes
+}
+
+// similarToEmail reports whether the password contains the email's local part, or is
+// the local part with a few characters added to either end
+func similarToEmail(password, email string) bool {
+	email = strings.ToLower(strings.TrimSpace(email))
+	local, _, _ := strings.Cut(email, "@")
+	local, _, _ = strings.Cut(local, "+")
+	local = lettersAndDigits(local)
+	if len(local) < 3 {
+		return false
+	}
+
+	pw := lettersAndDigits(strings.ToLower(password))
+	if pw == "" {
+		return false
+	}
+	return strings.Contains(pw, local) || (len(pw) >= 3 && strings.Contains(local, pw))
+}
+
+// lettersAndDigits drops separators, so "john.smith" and "John_Smith1" compare alike
+func lettersAndDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, s)
+}
@@ -0,0 +1,158 @@
+package serviceaccount
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	types "github.com/madhouselabs/anybase/internal/database/types"
+	"github.com/madhouselabs/anybase/pkg/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrCredentialNotFound = errors.New("credential not found")
+
+// CredentialRepository stores service account credentials
+type CredentialRepository interface {
+	Create(ctx context.Context, cred *models.ServiceAccountCredential) error
+	GetByClientID(ctx context.Context, clientID string) (*models.ServiceAccountCredential, error)
+	// List returns the service account's credentials, newest first
+	List(ctx context.Context, serviceAccountID primitive.ObjectID) ([]*models.ServiceAccountCredential, error)
+	Revoke(ctx context.Context, serviceAccountID, id primitive.ObjectID) (*models.ServiceAccountCredential, error)
+	RecordUse(ctx context.Context, id primitive.ObjectID) error
+}
+
+type credentialRepository struct {
+	collection types.Collection
+}
+
+func NewCredentialRepository(db types.DB) CredentialRepository {
+	return &credentialRepository{collection: db.Collection("service_account_credentials")}
+}
+
+func (r *credentialRepository) Create(ctx context.Context, cred *models.ServiceAccountCredential) error {
+	cred.ID = primitive.NewObjectID()
+	cred.CreatedAt = time.Now().UTC()
+
+	if _, err := r.collection.InsertOne(ctx, map[string]interface{}{
+		"_id":                cred.ID.Hex(),
+		"service_account_id": cred.ServiceAccountID.Hex(),
+		"name":               cred.Name,
+		"client_id":          cred.ClientID,
+		"secret_hash":        cred.SecretHash,
+		"session_id":         cred.SessionID.Hex(),
+		"created_by":         cred.CreatedBy.Hex(),
+		"created_at":         cred.CreatedAt,
+		"expires_at":         cred.ExpiresAt,
+	}); err != nil {
+		return fmt.Errorf("failed to create credential: %w", err)
+	}
+	return nil
+}
+
+func (r *credentialRepository) GetByClientID(ctx context.Context, clientID string) (*models.ServiceAccountCredential, error) {
+	return r.findOne(ctx, map[string]interface{}{"client_id": clientID})
+}
+
+func (r *credentialRepository) List(ctx context.Context, serviceAccountID primitive.ObjectID) ([]*models.ServiceAccountCredential, error) {
+	cursor, err := r.collection.Find(ctx, map[string]interface{}{
+		"service_account_id": serviceAccountID.Hex(),
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list credentials: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	creds := []*models.ServiceAccountCredential{}
+	for cursor.Next(ctx) {
+		var doc map[string]interface{}
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+		cred, err := decodeCredential(doc)
+		if err != nil {
+			continue
+		}
+		creds = append(creds, cred)
+	}
+	sort.Slice(creds, func(i, j int) bool { return creds[i].CreatedAt.After(creds[j].CreatedAt) })
+	return creds, nil
+}
+
+func (r *credentialRepository) Revoke(ctx context.Context, serviceAccountID, id primitive.ObjectID) (*models.ServiceAccountCredential, error) {
+	filter := map[string]interface{}{
+		"_id":                id.Hex(),
+		"service_account_id": serviceAccountID.Hex(),
+	}
+	cred, err := r.findOne(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if cred.RevokedAt != nil {
+		return cred, nil
+	}
+
+	now := time.Now().UTC()
+	if _, err := r.collection.UpdateOne(ctx, filter, map[string]interface{}{
+		"$set": map[string]interface{}{"revoked_at": now},
+	}); err != nil {
+		return nil, fmt.Errorf("failed to revoke credential: %w", err)
+	}
+	cred.RevokedAt = &now
+	return cred, nil
+}
+
+func (r *credentialRepository) RecordUse(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(ctx, map[string]interface{}{"_id": id.Hex()}, map[string]interface{}{
+		"$set": map[string]interface{}{"last_used_at": time.Now().UTC()},
+	})
+	return err
+}
+
+func (r *credentialRepository) findOne(ctx context.Context, filter map[string]interface{}) (*models.ServiceAccountCredential, error) {
+	var doc map[string]interface{}
+	if err := r.collection.FindOne(ctx, filter, &doc); err != nil {
+		if errors.Is(err, types.ErrNoDocuments) {
+			return nil, ErrCredentialNotFound
+		}
+		return nil, fmt.Errorf("failed to get credential: %w", err)
+	}
+	return decodeCredential(doc)
+}
+
+// decodeCredential reads a stored credential. IDs are kept as hex strings and the secret
+// hash and session are hidden from JSON, so those are read directly.
+func decodeCredential(doc map[string]interface{}) (*models.ServiceAccountCredential, error) {
+	id, _ := doc["_id"].(string)
+	accountID, _ := doc["service_account_id"].(string)
+	sessionID, _ := doc["session_id"].(string)
+	createdBy, _ := doc["created_by"].(string)
+	secretHash, _ := doc["secret_hash"].(string)
+	for _, key := range []string{"_id", "service_account_id", "session_id", "created_by"} {
+		delete(doc, key)
+	}
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode credential: %w", err)
+	}
+	cred := &models.ServiceAccountCredential{}
+	if err := json.Unmarshal(raw, cred); err != nil {
+		return nil, fmt.Errorf("failed to decode credential: %w", err)
+	}
+	if cred.ID, err = primitive.ObjectIDFromHex(id); err != nil {
+		return nil, fmt.Errorf("failed to decode credential: invalid id")
+	}
+	if cred.ServiceAccountID, err = primitive.ObjectIDFromHex(accountID); err != nil {
+		return nil, fmt.Errorf("failed to decode credential: invalid service account id")
+	}
+	if cred.SessionID, err = primitive.ObjectIDFromHex(sessionID); err != nil {
+		return nil, fmt.Errorf("failed to decode credential: invalid session id")
+	}
+	cred.CreatedBy, _ = primitive.ObjectIDFromHex(createdBy)
+	cred.SecretHash = secretHash
+	return cred, nil
+}
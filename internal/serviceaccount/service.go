@@ -0,0 +1,261 @@
+// Package serviceaccount manages non-human accounts. A service account holds roles like a
+// user but cannot log in, use a password or enroll MFA; it obtains access tokens with
+// client credentials instead, and outlives whoever created it.
+package serviceaccount
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/madhouselabs/anybase/internal/governance"
+	"github.com/madhouselabs/anybase/internal/session"
+	"github.com/madhouselabs/anybase/internal/user"
+	"github.com/madhouselabs/anybase/pkg/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultCredentialLifetime applies when a credential is requested without an expiry
+const DefaultCredentialLifetime = 365 * 24 * time.Hour
+
+// MaxCredentialDays is the longest lifetime a credential can be issued with, ten years
+const MaxCredentialDays = 3650
+
+// Client IDs are sa_<hex> so they are recognizable in logs; secrets are random hex
+const (
+	clientIDPrefix = "sa_"
+	clientIDBytes  = 12
+	secretBytes    = 32
+)
+
+var (
+	ErrNotFound          = errors.New("service account not found")
+	ErrInvalidName       = errors.New("service account names use lowercase letters, digits and dashes, 3 to 63 characters")
+	ErrInvalidClient     = errors.New("invalid client credentials")
+	ErrInvalidExpiration = fmt.Errorf("expires_in_days must be between 0 and %d", MaxCredentialDays)
+)
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{2,62}$`)
+
+type Service interface {
+	Create(ctx context.Context, actorID primitive.ObjectID, req *models.CreateServiceAccountRequest) (*models.User, error)
+	List(ctx context.Context) ([]*models.User, error)
+	Get(ctx context.Context, id primitive.ObjectID) (*models.User, error)
+	// IssueCredential creates a credential and returns it with its client secret, which is
+	// not stored and cannot be shown again
+	IssueCredential(ctx context.Context, actorID, id primitive.ObjectID, req *models.CreateCredentialRequest) (*models.ServiceAccountCredential, string, error)
+	ListCredentials(ctx context.Context, id primitive.ObjectID) ([]*models.ServiceAccountCredential, error)
+	// RevokeCredential revokes the credential and ends the tokens issued with it
+	RevokeCredential(ctx context.Context, id, credentialID primitive.ObjectID) error
+	// Authenticate checks a client ID and secret and returns the account and credential
+	Authenticate(ctx context.Context, clientID, clientSecret string) (*models.User, *models.ServiceAccountCredential, error)
+}
+
+type service struct {
+	userRepo    user.Repository
+	sessionRepo session.Repository
+	credentials CredentialRepository
+	rbacService governance.RBACService
+}
+
+func NewService(userRepo user.Repository, sessionRepo session.Repository, credentials CredentialRepository, rbacService governance.RBACService) Service {
+	return &service{
+		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
+		credentials: credentials,
+		rbacService: rbacService,
+	}
+}
+
+// Create creates a service account. Its name becomes the local part of a reserved address,
+// which keeps names unique alongside user emails.
+func (s *service) Create(ctx context.Context, actorID primitive.ObjectID, req *models.CreateServiceAccountRequest) (*models.User, error) {
+	name := strings.TrimSpace(req.Name)
+	if !namePattern.MatchString(name) {
+		return nil, ErrInvalidName
+	}
+
+	roles := append([]string{req.Role}, req.Roles...)
+	for _, role := range roles {
+		if _, err := s.rbacService.GetRole(ctx, role); err != nil {
+			return nil, err
+		}
+	}
+
+	account := &models.User{
+		Email:         name + "@" + models.ServiceAccountEmailDomain,
+		FirstName:     name,
+		UserType:      models.UserTypeServiceAccount,
+		Role:          req.Role,
+		Roles:         roles,
+		EmailVerified: true,
+		Active:        true,
+		Metadata: map[string]interface{}{
+			"description": req.Description,
+			"created_by":  actorID.Hex(),
+		},
+	}
+	if err := s.userRepo.Create(ctx, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+func (s *service) List(ctx context.Context) ([]*models.User, error) {
+	accounts, err := s.userRepo.List(ctx, map[string]interface{}{
+		"user_type": string(models.UserTypeServiceAccount),
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accounts == nil {
+		accounts = []*models.User{}
+	}
+	return accounts, nil
+}
+
+func (s *service) Get(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	account, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if !account.IsServiceAccount() {
+		return nil, ErrNotFound
+	}
+	return account, nil
+}
+
+func (s *service) IssueCredential(ctx context.Context, actorID, id primitive.ObjectID, req *models.CreateCredentialRequest) (*models.ServiceAccountCredential, string, error) {
+	account, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > MaxCredentialDays {
+		return nil, "", ErrInvalidExpiration
+	}
+	lifetime := DefaultCredentialLifetime
+	if req.ExpiresInDays > 0 {
+		lifetime = time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	}
+
+	clientID, err := randomHex(clientIDBytes)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomHex(secretBytes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Tokens from this credential belong to its session and end with it
+	expiresAt := time.Now().UTC().Add(lifetime)
+	sess := &models.Session{
+		UserID:    account.ID,
+		UserAgent: "client credentials: " + clientIDPrefix + clientID,
+		ExpiresAt: expiresAt,
+	}
+	if err := s.sessionRepo.Create(ctx, sess); err != nil {
+		return nil, "", err
+	}
+
+	cred := &models.ServiceAccountCredential{
+		ServiceAccountID: account.ID,
+		Name:             req.Name,
+		ClientID:         clientIDPrefix + clientID,
+		SecretHash:       hashSecret(secret),
+		SessionID:        sess.ID,
+		CreatedBy:        actorID,
+		ExpiresAt:        expiresAt,
+	}
+	if err := s.credentials.Create(ctx, cred); err != nil {
+		s.sessionRepo.Revoke(ctx, sess.ID)
+		return nil, "", err
+	}
+	return cred, secret, nil
+}
+
+func (s *service) ListCredentials(ctx context.Context, id primitive.ObjectID) ([]*models.ServiceAccountCredential, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.credentials.List(ctx, id)
+}
+
+func (s *service) RevokeCredential(ctx context.Context, id, credentialID primitive.ObjectID) error {
+	cred, err := s.credentials.Revoke(ctx, id, credentialID)
+	if err != nil {
+		return err
+	}
+	if err := s.sessionRepo.Revoke(ctx, cred.SessionID); err != nil && !errors.Is(err, session.ErrSessionNotFound) {
+		return fmt.Errorf("failed to revoke credential session: %w", err)
+	}
+	return nil
+}
+
+// Authenticate reports every failure as ErrInvalidClient, so callers can't probe which
+// client IDs exist
+func (s *service) Authenticate(ctx context.Context, clientID, clientSecret string) (*models.User, *models.ServiceAccountCredential, error) {
+	if !strings.HasPrefix(clientID, clientIDPrefix) || clientSecret == "" {
+		return nil, nil, ErrInvalidClient
+	}
+	cred, err := s.credentials.GetByClientID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, ErrCredentialNotFound) {
+			return nil, nil, ErrInvalidClient
+		}
+		return nil, nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(cred.SecretHash), []byte(hashSecret(clientSecret))) != 1 {
+		return nil, nil, ErrInvalidClient
+	}
+	if !cred.Active() {
+		return nil, nil, ErrInvalidClient
+	}
+
+	account, err := s.Get(ctx, cred.ServiceAccountID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, nil, ErrInvalidClient
+		}
+		return nil, nil, err
+	}
+	if !account.Active {
+		return nil, nil, ErrInvalidClient
+	}
+	if err := s.sessionRepo.Validate(ctx, cred.SessionID, account.ID); err != nil {
+		if errors.Is(err, session.ErrSessionNotFound) || errors.Is(err, session.ErrSessionRevoked) {
+			return nil, nil, ErrInvalidClient
+		}
+		return nil, nil, err
+	}
+
+	if err := s.credentials.RecordUse(ctx, cred.ID); err != nil {
+		fmt.Printf("failed to record credential use: %v\n", err)
+	}
+	return account, cred, nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashSecret returns the stored form of a client secret. Secrets are random, so a plain
+// SHA-256 is enough.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
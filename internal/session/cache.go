@@ -0,0 +1,87 @@
+package session
+
+import (
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// activeCacheTTL bounds how long another instance may keep accepting a session after it
+// was revoked elsewhere; revocations on this instance take effect immediately
+const activeCacheTTL = 30 * time.Second
+
+// activeCacheSweepSize is the entry count above which expired entries are pruned on insert
+const activeCacheSweepSize = 4096
+
+// activeCache remembers sessions recently found active so that authenticating a request
+// does not cost a database round trip
+type activeCache struct {
+	mu      sync.Mutex
+	entries map[primitive.ObjectID]activeEntry
+}
+
+type activeEntry struct {
+	userID     primitive.ObjectID
+	validUntil time.Time
+}
+
+func newActiveCache() *activeCache {
+	return &activeCache{entries: make(map[primitive.ObjectID]activeEntry)}
+}
+
+// get reports whether the session is cached as active for the user
+func (c *activeCache) get(id, userID primitive.ObjectID) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[id]
+	if !ok {
+		return false
+	}
+	if time.Now().After(entry.validUntil) {
+		delete(c.entries, id)
+		return false
+	}
+	return entry.userID == userID
+}
+
+// put caches an active session until it expires, or the cache TTL if that is sooner
+func (c *activeCache) put(id, userID primitive.ObjectID, expiresAt time.Time) {
+	validUntil := time.Now().Add(activeCacheTTL)
+	if expiresAt.Before(validUntil) {
+		validUntil = expiresAt
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= activeCacheSweepSize {
+		now := time.Now()
+		for key, entry := range c.entries {
+			if now.After(entry.validUntil) {
+				delete(c.entries, key)
+			}
+		}
+	}
+	c.entries[id] = activeEntry{userID: userID, validUntil: validUntil}
+}
+
+// invalidate drops a single session
+func (c *activeCache) invalidate(id primitive.ObjectID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, id)
+}
+
+// invalidateUser drops every session of a user
+func (c *activeCache) invalidateUser(userID primitive.ObjectID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, entry := range c.entries {
+		if entry.userID == userID {
+			delete(c.entries, key)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+// Package session stores the server-side record of each login. Tokens carry the session ID
+// and are only accepted while the session is active, so revoking a session logs it out.
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	types "github.com/madhouselabs/anybase/internal/database/types"
+	"github.com/madhouselabs/anybase/pkg/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionRevoked  = errors.New("session has been revoked or has expired")
+	// ErrRefreshTokenReused means a refresh token was presented after it had been rotated
+	ErrRefreshTokenReused = errors.New("refresh token has already been used")
+)
+
+type Repository interface {
+	Create(ctx context.Context, session *models.Session) error
+	GetByID(ctx context.Context, id primitive.ObjectID) (*models.Session, error)
+	// Validate checks that the session belongs to the user and is still active
+	Validate(ctx context.Context, id, userID primitive.ObjectID) error
+	// ListActive returns the user's active sessions, newest first
+	ListActive(ctx context.Context, userID primitive.ObjectID) ([]*models.Session, error)
+	// Rotate replaces the session's current refresh token and extends the session. It fails
+	// with ErrRefreshTokenReused if previousID is no longer current.
+	Rotate(ctx context.Context, id primitive.ObjectID, previousID, nextID string, expiresAt time.Time) error
+	Revoke(ctx context.Context, id primitive.ObjectID) error
+	// RevokeAll revokes every active session of the user and returns how many there were
+	RevokeAll(ctx context.Context, userID primitive.ObjectID) (int64, error)
+}
+
+type repository struct {
+	collection types.Collection
+	cache      *activeCache
+}
+
+func NewRepository(db types.DB) Repository {
+	return &repository{
+		collection: db.Collection("sessions"),
+		cache:      newActiveCache(),
+	}
+}
+
+func (r *repository) Create(ctx context.Context, session *models.Session) error {
+	now := time.Now().UTC()
+	session.ID = primitive.NewObjectID()
+	session.CreatedAt = now
+	session.UpdatedAt = now
+
+	doc := map[string]interface{}{
+		"_id":              session.ID.Hex(),
+		"user_id":          session.UserID.Hex(),
+		"ip_address":       session.IPAddress,
+		"user_agent":       session.UserAgent,
+		"refresh_token_id": session.RefreshTokenID,
+		"expires_at":       session.ExpiresAt,
+		"created_at":       session.CreatedAt,
+		"updated_at":       session.UpdatedAt,
+	}
+	if session.ImpersonatorID != nil {
+		doc["impersonator_id"] = session.ImpersonatorID.Hex()
+	}
+	if _, err := r.collection.InsertOne(ctx, doc); err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+func (r *repository) GetByID(ctx context.Context, id primitive.ObjectID) (*models.Session, error) {
+	var doc map[string]interface{}
+	if err := r.collection.FindOne(ctx, map[string]interface{}{"_id": id.Hex()}, &doc); err != nil {
+		if errors.Is(err, types.ErrNoDocuments) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	return decodeSession(doc)
+}
+
+func (r *repository) Validate(ctx context.Context, id, userID primitive.ObjectID) error {
+	if r.cache.get(id, userID) {
+		return nil
+	}
+
+	session, err := r.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if session.UserID != userID {
+		return ErrSessionNotFound
+	}
+	if !session.Active() {
+		return ErrSessionRevoked
+	}
+
+	r.cache.put(id, userID, session.ExpiresAt)
+	return nil
+}
+
+func (r *repository) ListActive(ctx context.Context, userID primitive.ObjectID) ([]*models.Session, error) {
+	cursor, err := r.collection.Find(ctx, map[string]interface{}{
+		"user_id":    userID.Hex(),
+		"revoked_at": nil,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	sessions := []*models.Session{}
+	for cursor.Next(ctx) {
+		var doc map[string]interface{}
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+		session, err := decodeSession(doc)
+		if err != nil || !session.Active() {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.After(sessions[j].CreatedAt) })
+	return sessions, nil
+}
+
+func (r *repository) Rotate(ctx context.Context, id primitive.ObjectID, previousID, nextID string, expiresAt time.Time) error {
+	// The filter on the current token makes rotation a compare-and-swap, so two refreshes
+	// racing with the same token cannot both succeed
+	result, err := r.collection.UpdateOne(ctx, map[string]interface{}{
+		"_id":              id.Hex(),
+		"refresh_token_id": previousID,
+		"revoked_at":       nil,
+	}, map[string]interface{}{
+		"$set": map[string]interface{}{
+			"refresh_token_id": nextID,
+			"expires_at":       expiresAt,
+			"updated_at":       time.Now().UTC(),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrRefreshTokenReused
+	}
+	return nil
+}
+
+func (r *repository) Revoke(ctx context.Context, id primitive.ObjectID) error {
+	r.cache.invalidate(id)
+
+	now := time.Now().UTC()
+	result, err := r.collection.UpdateOne(ctx, map[string]interface{}{
+		"_id":        id.Hex(),
+		"revoked_at": nil,
+	}, map[string]interface{}{
+		"$set": map[string]interface{}{
+			"revoked_at": now,
+			"updated_at": now,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (r *repository) RevokeAll(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	r.cache.invalidateUser(userID)
+
+	now := time.Now().UTC()
+	result, err := r.collection.UpdateMany(ctx, map[string]interface{}{
+		"user_id":    userID.Hex(),
+		"revoked_at": nil,
+	}, map[string]interface{}{
+		"$set": map[string]interface{}{
+			"revoked_at": now,
+			"updated_at": now,
+		},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return result.ModifiedCount, nil
+}
+
+// decodeSession converts a stored document, whose ids are kept as hex strings, into a session
+func decodeSession(doc map[string]interface{}) (*models.Session, error) {
+	id, _ := doc["_id"].(string)
+	userID, _ := doc["user_id"].(string)
+	refreshTokenID, _ := doc["refresh_token_id"].(string) // Hidden from JSON, so read directly
+	delete(doc, "_id")
+	delete(doc, "user_id")
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode session: %w", err)
+	}
+	session := &models.Session{}
+	if err := json.Unmarshal(raw, session); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %w", err)
+	}
+	if session.ID, err = primitive.ObjectIDFromHex(id); err != nil {
+		return nil, fmt.Errorf("failed to decode session: invalid id")
+	}
+	if session.UserID, err = primitive.ObjectIDFromHex(userID); err != nil {
+		return nil, fmt.Errorf("failed to decode session: invalid user id")
+	}
+	session.RefreshTokenID = refreshTokenID
+	return session, nil
+}
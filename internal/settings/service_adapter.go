@@ -216,12 +216,21 @@ func (s *AdapterService) GetSystemSettings(ctx context.Context) (*models.SystemS
 		if pp, ok := data["password_policy"].(string); ok {
 			settings.PasswordPolicy = pp
 		}
+		if pma, ok := data["password_max_age"].(float64); ok {
+			settings.PasswordMaxAge = int(pma)
+		}
 		if mfa, ok := data["mfa_required"].(bool); ok {
 			settings.MFARequired = mfa
 		}
 		if ale, ok := data["audit_log_enabled"].(bool); ok {
 			settings.AuditLogEnabled = ale
 		}
+		if rev, ok := data["require_email_verification"].(bool); ok {
+			settings.RequireEmailVerification = rev
+		}
+		if pl, ok := data["passwordless_login"].(bool); ok {
+			settings.PasswordlessLogin = pl
+		}
 		if rl, ok := data["rate_limit"].(float64); ok {
 			settings.RateLimit = int(rl)
 		}
@@ -257,8 +266,11 @@ func (s *AdapterService) UpdateSystemSettings(ctx context.Context, userID primit
 		"encryption_enabled":   settings.EncryptionEnabled,
 		"session_timeout":      settings.SessionTimeout,
 		"password_policy":      settings.PasswordPolicy,
+		"password_max_age":     settings.PasswordMaxAge,
 		"mfa_required":         settings.MFARequired,
 		"audit_log_enabled":    settings.AuditLogEnabled,
+		"require_email_verification": settings.RequireEmailVerification,
+		"passwordless_login":   settings.PasswordlessLogin,
 		"rate_limit":           settings.RateLimit,
 		"burst_limit":          settings.BurstLimit,
 		"cors_enabled":         settings.CORSEnabled,
@@ -0,0 +1,157 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// Supported signing algorithms
+const (
+	RS256 = "RS256"
+	ES256 = "ES256"
+)
+
+const rsaKeyBits = 2048
+
+var ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+
+// Key is a signing key pair. Keys are published in the JWKS from CreatedAt, and sign
+// tokens from ActivatesAt until a newer key activates.
+type Key struct {
+	ID          string
+	Algorithm   string
+	PrivateKey  crypto.Signer
+	CreatedAt   time.Time
+	ActivatesAt time.Time
+}
+
+// Public returns the key's public half
+func (k *Key) Public() crypto.PublicKey {
+	return k.PrivateKey.Public()
+}
+
+// generateKey creates a key pair for the algorithm with a random key ID
+func generateKey(algorithm string) (*Key, error) {
+	var signer crypto.Signer
+	var err error
+	switch algorithm {
+	case RS256:
+		signer, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case ES256:
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate key id: %w", err)
+	}
+	return &Key{ID: hex.EncodeToString(id), Algorithm: algorithm, PrivateKey: signer}, nil
+}
+
+// JWK is a public key in JSON Web Key form (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWK returns the public key for publishing
+func (k *Key) JWK() JWK {
+	jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Algorithm}
+	switch pub := k.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		// Coordinates are padded to the curve size, as RFC 7518 requires
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+	}
+	return jwk
+}
+
+// sealer encrypts private keys at rest with AES-256-GCM, so a database dump alone can't
+// be used to mint tokens
+type sealer struct {
+	aead cipher.AEAD
+}
+
+func newSealer(secret string) (*sealer, error) {
+	if secret == "" {
+		return nil, errors.New("a secret is required to encrypt signing keys")
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &sealer{aead: aead}, nil
+}
+
+// seal encrypts the private key, binding the ciphertext to the key ID
+func (s *sealer) seal(k *Key) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.PrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode signing key: %w", err)
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := s.aead.Seal(nonce, nonce, der, []byte(k.ID))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts a private key sealed for the key ID
+func (s *sealer) open(id, sealed string) (crypto.Signer, error) {
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(raw) < s.aead.NonceSize() {
+		return nil, errors.New("malformed signing key")
+	}
+	nonce, ciphertext := raw[:s.aead.NonceSize()], raw[s.aead.NonceSize():]
+	der, err := s.aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return nil, errors.New("failed to decrypt signing key; was the key secret changed?")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("signing key is not a signer")
+	}
+	return signer, nil
+}
@@ -0,0 +1,299 @@
+package signing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	types "github.com/madhouselabs/anybase/internal/database/types"
+)
+
+const (
+	// PublishAhead is how long a new key is published before it signs anything, so
+	// services caching the key set have fetched it by the time tokens use it
+	PublishAhead = time.Hour
+
+	// syncInterval is how often keys are reloaded and checked for rotation
+	syncInterval = time.Minute
+
+	// reloadThrottle limits reloads caused by tokens naming an unknown key
+	reloadThrottle = 10 * time.Second
+)
+
+var ErrNoSigningKey = errors.New("no signing key available")
+
+// Config controls the keys a KeyStore creates and how long it keeps them
+type Config struct {
+	Algorithm string        // RS256 or ES256
+	Rotation  time.Duration // How long each key signs tokens before the next one takes over
+	Retention time.Duration // How long a key still verifies tokens after it stops signing
+	Secret    string        // Encrypts the private keys at rest
+}
+
+// KeyStore keeps the signing keys in the database, shared by every server instance, and
+// rotates them on schedule. Lookups are served from memory.
+type KeyStore struct {
+	cfg        Config
+	collection types.Collection
+	sealer     *sealer
+
+	mu       sync.RWMutex
+	keys     []*Key // Newest first
+	loadedAt time.Time
+}
+
+// storedKey is a key as kept in the database
+type storedKey struct {
+	ID          string    `json:"_id"`
+	Algorithm   string    `json:"algorithm"`
+	PrivateKey  string    `json:"private_key"` // Sealed PKCS #8
+	CreatedAt   time.Time `json:"created_at"`
+	ActivatesAt time.Time `json:"activates_at"`
+}
+
+func NewKeyStore(db types.DB, cfg Config) (*KeyStore, error) {
+	if cfg.Algorithm != RS256 && cfg.Algorithm != ES256 {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, cfg.Algorithm)
+	}
+	if cfg.Rotation <= PublishAhead {
+		return nil, fmt.Errorf("signing key rotation must be longer than %s", PublishAhead)
+	}
+	sealer, err := newSealer(cfg.Secret)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyStore{
+		cfg:        cfg,
+		collection: db.Collection("signing_keys"),
+		sealer:     sealer,
+	}, nil
+}
+
+// Start makes sure a signing key exists and then keeps the keys rotated in the
+// background until ctx is done
+func (s *KeyStore) Start(ctx context.Context) error {
+	if err := s.sync(ctx); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(syncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.sync(ctx); err != nil {
+					log.Printf("Failed to sync signing keys: %v", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// SigningKey returns the key new tokens are signed with
+func (s *KeyStore) SigningKey() (*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if key := activeKey(s.keys, time.Now()); key != nil {
+		return key, nil
+	}
+	return nil, ErrNoSigningKey
+}
+
+// VerificationKey returns the key with the given ID. A key another instance created
+// since the last sync is picked up by reloading.
+func (s *KeyStore) VerificationKey(id string) (*Key, bool) {
+	if key, ok := s.lookup(id); ok {
+		return key, true
+	}
+
+	s.mu.RLock()
+	stale := time.Since(s.loadedAt) >= reloadThrottle
+	s.mu.RUnlock()
+	if !stale {
+		return nil, false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	keys, err := s.load(ctx)
+	if err != nil {
+		log.Printf("Failed to reload signing keys: %v", err)
+		return nil, false
+	}
+	s.setKeys(keys)
+	return s.lookup(id)
+}
+
+// JWKS returns the public keys that verify tokens, including the next key to sign
+func (s *KeyStore) JWKS() []JWK {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	jwks := make([]JWK, 0, len(s.keys))
+	for _, key := range s.keys {
+		jwks = append(jwks, key.JWK())
+	}
+	return jwks
+}
+
+func (s *KeyStore) lookup(id string) (*Key, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, key := range s.keys {
+		if key.ID == id {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// sync reloads the keys, creates the next key when rotation is due and deletes keys no
+// token can still be signed with
+func (s *KeyStore) sync(ctx context.Context) error {
+	keys, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if s.rotationDue(keys, now) {
+		activatesAt := now.Add(PublishAhead)
+		if len(keys) == 0 {
+			// Nobody can hold an older key set yet
+			activatesAt = now
+		}
+		key, err := s.create(ctx, activatesAt)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		sortKeys(keys)
+	}
+
+	s.setKeys(s.prune(ctx, keys, now))
+	return nil
+}
+
+// rotationDue reports whether the next key should be created now. It is created
+// PublishAhead before the active key's time is up, or right away when the configured
+// algorithm changes.
+func (s *KeyStore) rotationDue(keys []*Key, now time.Time) bool {
+	for _, key := range keys {
+		if key.ActivatesAt.After(now) && key.Algorithm == s.cfg.Algorithm {
+			return false // The next key is already waiting
+		}
+	}
+	active := activeKey(keys, now)
+	if active == nil || active.Algorithm != s.cfg.Algorithm {
+		return true
+	}
+	return !now.Before(active.ActivatesAt.Add(s.cfg.Rotation - PublishAhead))
+}
+
+// prune deletes the keys that stopped signing longer ago than the retention period.
+// A key stops signing when the next newer key activates.
+func (s *KeyStore) prune(ctx context.Context, keys []*Key, now time.Time) []*Key {
+	kept := keys[:0:0]
+	for i, key := range keys {
+		if i > 0 {
+			successor := keys[i-1]
+			if !successor.ActivatesAt.After(now) && now.Sub(successor.ActivatesAt) > s.cfg.Retention {
+				if _, err := s.collection.DeleteOne(ctx, map[string]interface{}{"_id": key.ID}); err != nil {
+					log.Printf("Failed to delete retired signing key %s: %v", key.ID, err)
+				}
+				continue
+			}
+		}
+		kept = append(kept, key)
+	}
+	return kept
+}
+
+func (s *KeyStore) create(ctx context.Context, activatesAt time.Time) (*Key, error) {
+	key, err := generateKey(s.cfg.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	key.CreatedAt = time.Now().UTC()
+	key.ActivatesAt = activatesAt.UTC()
+
+	sealed, err := s.sealer.seal(key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.collection.InsertOne(ctx, map[string]interface{}{
+		"_id":          key.ID,
+		"algorithm":    key.Algorithm,
+		"private_key":  sealed,
+		"created_at":   key.CreatedAt,
+		"activates_at": key.ActivatesAt,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to store signing key: %w", err)
+	}
+	return key, nil
+}
+
+func (s *KeyStore) load(ctx context.Context) ([]*Key, error) {
+	cursor, err := s.collection.Find(ctx, map[string]interface{}{}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load signing keys: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	keys := []*Key{}
+	for cursor.Next(ctx) {
+		var stored storedKey
+		if err := cursor.Decode(&stored); err != nil {
+			continue
+		}
+		signer, err := s.sealer.open(stored.ID, stored.PrivateKey)
+		if err != nil {
+			log.Printf("Skipping signing key %s: %v", stored.ID, err)
+			continue
+		}
+		keys = append(keys, &Key{
+			ID:          stored.ID,
+			Algorithm:   stored.Algorithm,
+			PrivateKey:  signer,
+			CreatedAt:   stored.CreatedAt,
+			ActivatesAt: stored.ActivatesAt,
+		})
+	}
+	sortKeys(keys)
+	return keys, nil
+}
+
+func (s *KeyStore) setKeys(keys []*Key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+	s.loadedAt = time.Now()
+}
+
+// activeKey returns the most recently activated key. Instances that created keys at the
+// same time agree on the winner through the key ID.
+func activeKey(keys []*Key, now time.Time) *Key {
+	for _, key := range keys {
+		if !key.ActivatesAt.After(now) {
+			return key
+		}
+	}
+	return nil
+}
+
+// sortKeys orders keys newest first by activation
+func sortKeys(keys []*Key) {
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].ActivatesAt.Equal(keys[j].ActivatesAt) {
+			return keys[i].ActivatesAt.After(keys[j].ActivatesAt)
+		}
+		return keys[i].ID > keys[j].ID
+	})
+}
@@ -0,0 +1,89 @@
+// Package tenant binds a request to an organization and maps collection names to the
+// organization's storage namespace. Storage adapters resolve every table through Qualify,
+// so data written under one organization's context can only land in that organization's tables.
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/madhouselabs/anybase/pkg/models"
+)
+
+// contextKey is the request context key holding the current organization
+const contextKey = "organization"
+
+// slugPattern keeps slugs safe to embed in table names. Hyphens become underscores in
+// storage, so underscores and repeated hyphens are excluded: no slug can produce the "__"
+// separator of the table prefix.
+var slugPattern = regexp.MustCompile(`^[a-z][a-z0-9]*(-[a-z0-9]+)*$`)
+
+// maxSlugLength keeps prefixed table names within PostgreSQL's identifier limit for
+// typical collection names
+const maxSlugLength = 24
+
+// sharedCollections hold platform-wide data and are never prefixed. Everything else,
+// including every data_ table, belongs to the organization in the context.
+var sharedCollections = map[string]bool{
+	"users":                       true,
+	"sessions":                    true,
+	"settings":                    true,
+	"organizations":               true,
+	"org_members":                 true,
+	"access_keys":                 true,
+	"_collections":                true,
+	"user_identities":             true,
+	"oidc_states":                 true,
+	"signing_keys":                true,
+	"service_account_credentials": true,
+	"login_tokens":                true,
+}
+
+// WithOrg returns a context bound to the organization
+func WithOrg(ctx context.Context, org *models.Organization) context.Context {
+	return context.WithValue(ctx, contextKey, org)
+}
+
+// FromContext returns the organization the context is bound to, if any
+func FromContext(ctx context.Context) (*models.Organization, bool) {
+	org, ok := ctx.Value(contextKey).(*models.Organization)
+	return org, ok && org != nil
+}
+
+// ValidateSlug checks that an organization slug can be used as a storage namespace
+func ValidateSlug(slug string) error {
+	if len(slug) < 2 || len(slug) > maxSlugLength || !slugPattern.MatchString(slug) {
+		return fmt.Errorf("invalid organization slug '%s': use 2-%d lowercase letters, digits and single '-', starting with a letter", slug, maxSlugLength)
+	}
+	return nil
+}
+
+// Prefix returns the table prefix of an organization
+func Prefix(slug string) string {
+	return "org_" + slug + "__"
+}
+
+// IsShared reports whether a collection lives outside every organization namespace
+func IsShared(name string) bool {
+	return sharedCollections[name]
+}
+
+// Qualify returns the storage name of a collection for the organization in ctx. Without an
+// organization the default namespace is used and the name is returned unchanged.
+func Qualify(ctx context.Context, name string) string {
+	org, ok := FromContext(ctx)
+	if !ok || IsShared(name) {
+		return name
+	}
+	return Prefix(org.Slug) + name
+}
+
+// Detach returns a background context bound to the same organization as ctx, for work
+// that outlives the request
+func Detach(ctx context.Context) context.Context {
+	if org, ok := FromContext(ctx); ok {
+		return WithOrg(context.Background(), org)
+	}
+	return context.Background()
+}
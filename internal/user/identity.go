@@ -0,0 +1,68 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	types "github.com/madhouselabs/anybase/internal/database/types"
+	"github.com/madhouselabs/anybase/pkg/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrIdentityNotFound      = errors.New("identity not found")
+	ErrIdentityAlreadyLinked = errors.New("identity is already linked to a user")
+)
+
+// IdentityRepository stores the links between users and their external identities
+type IdentityRepository interface {
+	Get(ctx context.Context, provider, subject string) (*models.UserIdentity, error)
+	Create(ctx context.Context, identity *models.UserIdentity) error
+}
+
+type identityRepository struct {
+	collection types.Collection
+}
+
+func NewIdentityRepository(db types.DB) IdentityRepository {
+	return &identityRepository{
+		collection: db.Collection("user_identities"),
+	}
+}
+
+func (r *identityRepository) Get(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	if err := r.collection.FindOne(ctx, map[string]interface{}{
+		"provider": provider,
+		"subject":  subject,
+	}, &identity); err != nil {
+		if err.Error() == "no documents found" {
+			return nil, ErrIdentityNotFound
+		}
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+	return &identity, nil
+}
+
+func (r *identityRepository) Create(ctx context.Context, identity *models.UserIdentity) error {
+	identity.ID = primitive.NewObjectID()
+	identity.CreatedAt = time.Now().UTC()
+
+	if _, err := r.collection.InsertOne(ctx, map[string]interface{}{
+		"_id":        identity.ID.Hex(),
+		"user_id":    identity.UserID.Hex(),
+		"provider":   identity.Provider,
+		"subject":    identity.Subject,
+		"email":      identity.Email,
+		"created_at": identity.CreatedAt,
+	}); err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return ErrIdentityAlreadyLinked
+		}
+		return fmt.Errorf("failed to create identity: %w", err)
+	}
+	return nil
+}
@@ -17,15 +17,25 @@ var (
 	ErrUserNotFound     = errors.New("user not found")
 	ErrUserAlreadyExists = errors.New("user already exists")
 	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrMFAConflict = errors.New("mfa settings were changed concurrently")
 )
 
+// MFAState is the multi-factor state of a user, written as a whole by UpdateMFA
+type MFAState struct {
+	Enabled       bool
+	Secret        string
+	PendingSecret string
+	RecoveryCodes []string // SHA-256 of the unused recovery codes
+	LastStep      int64
+}
+
 type Repository interface {
 	Create(ctx context.Context, user *models.User) error
 	GetByID(ctx context.Context, id primitive.ObjectID) (*models.User, error)
 	GetByEmail(ctx context.Context, email string) (*models.User, error)
 	Update(ctx context.Context, id primitive.ObjectID, update *models.UserUpdate) error
 	UpdateRaw(ctx context.Context, id primitive.ObjectID, update interface{}) error
-	UpdatePassword(ctx context.Context, id primitive.ObjectID, hashedPassword string) error
+	UpdatePassword(ctx context.Context, id primitive.ObjectID, hashedPassword string, history []string) error
 	Delete(ctx context.Context, id primitive.ObjectID) error
 	SoftDelete(ctx context.Context, id primitive.ObjectID) error
 	List(ctx context.Context, filter interface{}, opts interface{}) ([]*models.User, error)
@@ -36,6 +46,10 @@ type Repository interface {
 	VerifyEmail(ctx context.Context, id primitive.ObjectID) error
 	SetPasswordResetToken(ctx context.Context, id primitive.ObjectID, token string, expiry time.Time) error
 	GetByPasswordResetToken(ctx context.Context, token string) (*models.User, error)
+	ConsumePasswordResetToken(ctx context.Context, id primitive.ObjectID, token string) error
+	SetEmailVerificationToken(ctx context.Context, id primitive.ObjectID, token string, expiry time.Time) error
+	GetByEmailVerificationToken(ctx context.Context, token string) (*models.User, error)
+	UpdateMFA(ctx context.Context, id primitive.ObjectID, revision int64, state *MFAState) error
 }
 
 type repository struct {
@@ -66,9 +80,10 @@ func (r *repository) Create(ctx context.Context, user *models.User) error {
 	user.CreatedAt = time.Now()
 	user.UpdatedAt = time.Now()
 	user.Active = true
-	user.EmailVerified = false
 	user.LoginAttempts = 0
-	user.UserType = models.UserTypeRegular
+	if user.UserType == "" {
+		user.UserType = models.UserTypeRegular
+	}
 
 	if user.Role == "" {
 		user.Role = "developer"
@@ -82,6 +97,7 @@ func (r *repository) Create(ctx context.Context, user *models.User) error {
 		"first_name": user.FirstName,
 		"last_name": user.LastName,
 		"role": user.Role,
+		"roles": user.AllRoles(),
 		"user_type": user.UserType,
 		"active": user.Active,
 		"email_verified": user.EmailVerified,
@@ -89,6 +105,18 @@ func (r *repository) Create(ctx context.Context, user *models.User) error {
 		"created_at": user.CreatedAt,
 		"updated_at": user.UpdatedAt,
 	}
+	if len(user.Metadata) > 0 {
+		doc["metadata"] = user.Metadata
+	}
+	if user.Password != "" {
+		changedAt := user.CreatedAt
+		user.PasswordChangedAt = &changedAt
+		doc["password_changed_at"] = changedAt
+	}
+	if user.EmailVerificationToken != "" {
+		doc["email_verification_token"] = user.EmailVerificationToken
+		doc["email_verification_expiry"] = user.EmailVerificationExpiry
+	}
 
 	insertedID, err := r.collection.InsertOne(ctx, doc)
 	if err != nil {
@@ -126,9 +154,10 @@ func (r *repository) GetByID(ctx context.Context, id primitive.ObjectID) (*model
 	return &user, nil
 }
 
+// GetByEmail finds a user by address, ignoring case: addresses are stored as typed at sign-up
 func (r *repository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	filter := map[string]interface{}{
-		"email": email,
+		"email": map[string]interface{}{types.OpEqualFold: email},
 		"deleted_at": nil,
 	}
 
@@ -180,34 +209,10 @@ func (r *repository) Update(ctx context.Context, id primitive.ObjectID, update *
 }
 
 func (r *repository) UpdateRaw(ctx context.Context, id primitive.ObjectID, update interface{}) error {
-	// For PostgreSQL, we need to handle the ID differently
-	var filter map[string]interface{}
-	
-	if r.db.Type() == "postgres" {
-		// Try to get the user first to get the PostgreSQL ID
-		user, err := r.GetByID(ctx, id)
-		if err != nil {
-			// If we can't find by ObjectID, this might be a new user
-			// Try to find by any criteria available
-			filter = map[string]interface{}{
-				"deleted_at": nil,
-			}
-		} else if user.Metadata != nil && user.Metadata["_postgres_id"] != nil {
-			// Use the PostgreSQL ID if available
-			filter = map[string]interface{}{
-				"_id": user.Metadata["_postgres_id"],
-				"deleted_at": nil,
-			}
-		} else {
-			filter = map[string]interface{}{
-				"deleted_at": nil,
-			}
-		}
-	} else {
-		filter = r.idFilter(id)
-		filter["deleted_at"] = nil
-	}
-	
+	// Users are stored under their hex ObjectID on every backend
+	filter := r.idFilter(id)
+	filter["deleted_at"] = nil
+
 	// Convert update to map if it's bson.M
 	var updateMap map[string]interface{}
 	if update == nil {
@@ -236,7 +241,9 @@ func (r *repository) UpdateRaw(ctx context.Context, id primitive.ObjectID, updat
 	return nil
 }
 
-func (r *repository) UpdatePassword(ctx context.Context, id primitive.ObjectID, hashedPassword string) error {
+// UpdatePassword replaces the password and records the hashes of earlier passwords, newest
+// first, so they can't be reused
+func (r *repository) UpdatePassword(ctx context.Context, id primitive.ObjectID, hashedPassword string, history []string) error {
 	filter := map[string]interface{}{
 		"_id": id,
 		"deleted_at": nil,
@@ -245,6 +252,8 @@ func (r *repository) UpdatePassword(ctx context.Context, id primitive.ObjectID,
 	update := map[string]interface{}{
 		"$set": map[string]interface{}{
 			"password":              hashedPassword,
+			"password_history":      history,
+			"password_changed_at":   time.Now(),
 			"password_reset_token":  "",
 			"password_reset_expiry": nil,
 			"updated_at":            time.Now(),
@@ -466,7 +475,6 @@ func (r *repository) SetPasswordResetToken(ctx context.Context, id primitive.Obj
 func (r *repository) GetByPasswordResetToken(ctx context.Context, token string) (*models.User, error) {
 	filter := map[string]interface{}{
 		"password_reset_token": token,
-		"password_reset_expiry": map[string]interface{}{"$gt": time.Now()},
 		"deleted_at":           nil,
 	}
 
@@ -479,4 +487,113 @@ func (r *repository) GetByPasswordResetToken(ctx context.Context, token string)
 	}
 
 	return &user, nil
-}
\ No newline at end of file
+}
+
+// ConsumePasswordResetToken clears the user's reset token if it is still the given one. Of
+// concurrent resets with the same token, only one gets past it; the others get ErrUserNotFound.
+func (r *repository) ConsumePasswordResetToken(ctx context.Context, id primitive.ObjectID, token string) error {
+	filter := r.idFilter(id)
+	filter["password_reset_token"] = token
+	update := map[string]interface{}{
+		"$set": map[string]interface{}{
+			"password_reset_token":  "",
+			"password_reset_expiry": nil,
+			"updated_at":            time.Now(),
+		},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("failed to clear password reset token: %w", err)
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *repository) SetEmailVerificationToken(ctx context.Context, id primitive.ObjectID, token string, expiry time.Time) error {
+	filter := r.idFilter(id)
+	update := map[string]interface{}{
+		"$set": map[string]interface{}{
+			"email_verification_token":  token,
+			"email_verification_expiry": expiry,
+			"updated_at":                time.Now(),
+		},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("failed to set email verification token: %w", err)
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *repository) GetByEmailVerificationToken(ctx context.Context, token string) (*models.User, error) {
+	filter := map[string]interface{}{
+		"email_verification_token": token,
+		"deleted_at":               nil,
+	}
+
+	var user models.User
+	if err := r.collection.FindOne(ctx, filter, &user); err != nil {
+		if err.Error() == "no documents found" {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user by verification token: %w", err)
+	}
+
+	return &user, nil
+}
+
+// UpdateMFA replaces the user's MFA state if it is still at the given revision, so that a
+// code or recovery code can only be spent once. It returns ErrMFAConflict when another
+// update got there first.
+func (r *repository) UpdateMFA(ctx context.Context, id primitive.ObjectID, revision int64, state *MFAState) error {
+	filter := r.idFilter(id)
+	if revision == 0 {
+		// Users created before MFA existed have no revision yet
+		filter["$or"] = []map[string]interface{}{
+			{"mfa_revision": nil},
+			{"mfa_revision": int64(0)},
+		}
+	} else {
+		filter["mfa_revision"] = revision
+	}
+
+	recoveryCodes := state.RecoveryCodes
+	if recoveryCodes == nil {
+		recoveryCodes = []string{}
+	}
+	update := map[string]interface{}{
+		"$set": map[string]interface{}{
+			"mfa_enabled":        state.Enabled,
+			"mfa_secret":         state.Secret,
+			"mfa_pending_secret": state.PendingSecret,
+			"mfa_recovery_codes": recoveryCodes,
+			"mfa_last_step":      state.LastStep,
+			"mfa_revision":       revision + 1,
+			"updated_at":         time.Now(),
+		},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("failed to update mfa settings: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		if _, err := r.GetByID(ctx, id); err != nil {
+			return err
+		}
+		return ErrMFAConflict
+	}
+
+	return nil
+}
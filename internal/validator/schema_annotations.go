@@ -0,0 +1,340 @@
+package validator
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/madhouselabs/anybase/pkg/models"
+)
+
+// ApplyDefaults fills properties missing from doc with their schema defaults.
+// Defaults are applied recursively inside nested objects and arrays of objects.
+func (v *SchemaValidator) ApplyDefaults(doc map[string]interface{}, schema *models.CollectionSchema) {
+	if schema == nil || doc == nil {
+		return
+	}
+	root := RootSchema(schema)
+	applyDefaults(doc, root, root)
+}
+
+// CheckReadOnly reports client-supplied values for readOnly properties.
+// When existing is given (updates), values identical to the stored ones are accepted.
+func (v *SchemaValidator) CheckReadOnly(doc, existing map[string]interface{}, schema *models.CollectionSchema) error {
+	if schema == nil || doc == nil {
+		return nil
+	}
+	root := RootSchema(schema)
+
+	var paths []string
+	v.filterProperties(doc, root, root, "", isReadOnly, func(path string) {
+		paths = append(paths, path)
+	})
+	sort.Strings(paths)
+
+	var errs ValidationErrors
+	for _, path := range paths {
+		if existing != nil {
+			if old, ok := lookupPointer(existing, path); ok {
+				if value, _ := lookupPointer(doc, path); jsonEqual(value, old) {
+					continue
+				}
+			}
+		}
+		errs = append(errs, ValidationError{Path: path, Keyword: "readOnly", Message: "is read-only"})
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// StripReadOnly returns a copy of doc without readOnly properties
+func (v *SchemaValidator) StripReadOnly(doc map[string]interface{}, schema *models.CollectionSchema) map[string]interface{} {
+	return v.stripProperties(doc, schema, isReadOnly)
+}
+
+// StripWriteOnly returns a copy of doc without writeOnly properties
+func (v *SchemaValidator) StripWriteOnly(doc map[string]interface{}, schema *models.CollectionSchema) map[string]interface{} {
+	return v.stripProperties(doc, schema, isWriteOnly)
+}
+
+// PreserveProtected carries readOnly values, and writeOnly values the client did not resend,
+// over from the stored document so a replacing update cannot drop them
+func (v *SchemaValidator) PreserveProtected(doc, existing map[string]interface{}, schema *models.CollectionSchema) {
+	if schema == nil || doc == nil || existing == nil {
+		return
+	}
+	root := RootSchema(schema)
+	preserveProtected(doc, existing, root, root)
+}
+
+// Resolve returns prop with its $ref resolved against root, keeping the annotations declared beside the reference
+func Resolve(prop, root *models.SchemaProperty) *models.SchemaProperty {
+	return effective(prop, root)
+}
+
+func isReadOnly(prop *models.SchemaProperty) bool  { return prop.ReadOnly || prop.Computed != "" }
+func isWriteOnly(prop *models.SchemaProperty) bool { return prop.WriteOnly }
+
+func (v *SchemaValidator) stripProperties(doc map[string]interface{}, schema *models.CollectionSchema, match func(*models.SchemaProperty) bool) map[string]interface{} {
+	if schema == nil || doc == nil {
+		return doc
+	}
+	root := RootSchema(schema)
+	stripped, _ := v.filterProperties(doc, root, root, "", match, nil).(map[string]interface{})
+	return stripped
+}
+
+// effective resolves $ref on prop, keeping annotations and structure declared next to the reference
+func effective(prop, root *models.SchemaProperty) *models.SchemaProperty {
+	if prop == nil || prop.Ref == "" {
+		return prop
+	}
+
+	target := prop
+	for i := 0; target != nil && target.Ref != "" && i < maxSchemaDepth; i++ {
+		target = resolveRef(root, target.Ref)
+	}
+	if target == nil {
+		return prop
+	}
+
+	merged := *target
+	merged.ReadOnly = merged.ReadOnly || prop.ReadOnly
+	merged.WriteOnly = merged.WriteOnly || prop.WriteOnly
+	if prop.Default != nil {
+		merged.Default = prop.Default
+	}
+	if prop.Computed != "" {
+		merged.Computed = prop.Computed
+		merged.ComputeOn = prop.ComputeOn
+	}
+	if prop.Reference != nil {
+		merged.Reference = prop.Reference
+	}
+	if prop.Properties != nil {
+		merged.Properties = prop.Properties
+	}
+	if prop.Items != nil {
+		merged.Items = prop.Items
+	}
+	return &merged
+}
+
+func applyDefaults(obj map[string]interface{}, prop, root *models.SchemaProperty) {
+	for name, sub := range prop.Properties {
+		sub = effective(sub, root)
+		if sub == nil {
+			continue
+		}
+		if _, ok := obj[name]; !ok && sub.Default != nil {
+			obj[name] = copyValue(sub.Default)
+		}
+		applyNestedDefaults(obj[name], sub, root)
+	}
+}
+
+func applyNestedDefaults(value interface{}, prop, root *models.SchemaProperty) {
+	switch val := value.(type) {
+	case map[string]interface{}:
+		applyDefaults(val, prop, root)
+	case []interface{}:
+		if items := effective(prop.Items, root); items != nil {
+			for _, item := range val {
+				applyNestedDefaults(item, items, root)
+			}
+		}
+	}
+}
+
+// filterProperties returns a copy of value without the properties whose schema matches,
+// calling removed with the JSON pointer of each dropped property. Properties are found
+// through properties, patternProperties and additionalProperties, array items through
+// prefixItems and items, and through every subschema of allOf, anyOf and oneOf. Which anyOf
+// or oneOf branch a value satisfies is not worked out here: an annotation in any of them
+// applies, so a field is withheld rather than leaked.
+func (v *SchemaValidator) filterProperties(value interface{}, prop, root *models.SchemaProperty, path string, match func(*models.SchemaProperty) bool, removed func(string)) interface{} {
+	schemas := applicableSchemas(prop, root)
+	if len(schemas) == 0 {
+		return value
+	}
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for key, item := range val {
+			subs := v.memberSchemas(schemas, key)
+			subPath := path + "/" + escapePointer(key)
+			if anyMatch(subs, root, match) {
+				if removed != nil {
+					removed(subPath)
+				}
+				continue
+			}
+			out[key] = v.filterProperties(item, combined(subs), root, subPath, match, removed)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			var subs []*models.SchemaProperty
+			for _, schema := range schemas {
+				if i < len(schema.PrefixItems) {
+					subs = append(subs, schema.PrefixItems[i])
+				} else if schema.Items != nil {
+					subs = append(subs, schema.Items)
+				}
+			}
+			out[i] = v.filterProperties(item, combined(subs), root, path+"/"+strconv.Itoa(i), match, removed)
+		}
+		return out
+	}
+	return value
+}
+
+// memberSchemas returns the subschemas that apply to the member key of an object
+// described by schemas
+func (v *SchemaValidator) memberSchemas(schemas []*models.SchemaProperty, key string) []*models.SchemaProperty {
+	var subs []*models.SchemaProperty
+	for _, schema := range schemas {
+		evaluated := false
+		if sub, ok := schema.Properties[key]; ok {
+			subs = append(subs, sub)
+			evaluated = true
+		}
+		for pattern, sub := range schema.PatternProperties {
+			if re, err := v.compilePattern(pattern); err == nil && re.MatchString(key) {
+				subs = append(subs, sub)
+				evaluated = true
+			}
+		}
+		if !evaluated {
+			if additional := v.additionalSchema(schema.AdditionalProperties); additional != nil {
+				subs = append(subs, additional)
+			}
+		}
+	}
+	return subs
+}
+
+// applicableSchemas returns prop and the subschemas it is composed of through allOf, anyOf
+// and oneOf, with $ref resolved. Each subschema is visited once, so recursive references
+// terminate.
+func applicableSchemas(prop, root *models.SchemaProperty) []*models.SchemaProperty {
+	var out []*models.SchemaProperty
+	seen := map[*models.SchemaProperty]bool{}
+	var walk func(p *models.SchemaProperty)
+	walk = func(p *models.SchemaProperty) {
+		if p == nil || seen[p] {
+			return
+		}
+		seen[p] = true
+		resolved := effective(p, root)
+		if resolved == nil {
+			return
+		}
+		out = append(out, resolved)
+		for _, group := range [][]*models.SchemaProperty{resolved.AllOf, resolved.AnyOf, resolved.OneOf} {
+			for _, sub := range group {
+				walk(sub)
+			}
+		}
+	}
+	walk(prop)
+	return out
+}
+
+// anyMatch reports whether match holds for any of subs or the subschemas they are composed of
+func anyMatch(subs []*models.SchemaProperty, root *models.SchemaProperty, match func(*models.SchemaProperty) bool) bool {
+	for _, schema := range applicableSchemas(combined(subs), root) {
+		if match(schema) {
+			return true
+		}
+	}
+	return false
+}
+
+// combined returns a schema that applies all of subs, or nil if there are none
+func combined(subs []*models.SchemaProperty) *models.SchemaProperty {
+	switch len(subs) {
+	case 0:
+		return nil
+	case 1:
+		return subs[0]
+	}
+	return &models.SchemaProperty{AllOf: subs}
+}
+
+func preserveProtected(obj, existing map[string]interface{}, prop, root *models.SchemaProperty) {
+	for name, sub := range prop.Properties {
+		sub = effective(sub, root)
+		if sub == nil {
+			continue
+		}
+		old, had := existing[name]
+		_, supplied := obj[name]
+
+		switch {
+		case isReadOnly(sub):
+			if had {
+				obj[name] = old
+			} else {
+				delete(obj, name)
+			}
+		case sub.WriteOnly && !supplied && had:
+			obj[name] = old
+		default:
+			newObj, ok := obj[name].(map[string]interface{})
+			oldObj, wasObj := old.(map[string]interface{})
+			if ok && wasObj {
+				preserveProtected(newObj, oldObj, sub, root)
+			}
+		}
+	}
+}
+
+// lookupPointer resolves a JSON pointer inside a decoded JSON document
+func lookupPointer(doc map[string]interface{}, pointer string) (interface{}, bool) {
+	var current interface{} = doc
+	for _, segment := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		segment = unescapePointer(segment)
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[segment]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			idx, err := strconv.Atoi(segment)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, false
+			}
+			current = node[idx]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// copyValue deep-copies decoded JSON so schema defaults are never shared with documents
+func copyValue(value interface{}) interface{} {
+	switch val := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[k] = copyValue(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = copyValue(item)
+		}
+		return out
+	}
+	return value
+}
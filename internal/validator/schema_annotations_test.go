@@ -0,0 +1,177 @@
+package validator_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/madhouselabs/anybase/internal/validator"
+	"github.com/madhouselabs/anybase/pkg/models"
+)
+
+func parseSchema(t *testing.T, src string) *models.CollectionSchema {
+	t.Helper()
+	var schema models.CollectionSchema
+	if err := json.Unmarshal([]byte(src), &schema); err != nil {
+		t.Fatalf("invalid schema: %v", err)
+	}
+	return &schema
+}
+
+func parseDoc(t *testing.T, src string) map[string]interface{} {
+	t.Helper()
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(src), &doc); err != nil {
+		t.Fatalf("invalid document: %v", err)
+	}
+	return doc
+}
+
+func TestStripWriteOnlyWalksSubschemas(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		doc    string
+		want   string
+	}{
+		{
+			name:   "properties",
+			schema: `{"type": "object", "properties": {"secret": {"type": "string", "writeOnly": true}}}`,
+			doc:    `{"secret": "s", "name": "n"}`,
+			want:   `{"name": "n"}`,
+		},
+		{
+			name: "allOf",
+			schema: `{"type": "object", "allOf": [
+				{"properties": {"secret": {"type": "string", "writeOnly": true}}}
+			]}`,
+			doc:  `{"secret": "s", "name": "n"}`,
+			want: `{"name": "n"}`,
+		},
+		{
+			name: "anyOf",
+			schema: `{"type": "object", "properties": {"card": {"anyOf": [
+				{"type": "object", "properties": {"number": {"writeOnly": true}}},
+				{"type": "object", "properties": {"iban": {"type": "string"}}}
+			]}}}`,
+			doc:  `{"card": {"number": "4111", "iban": "DE00"}}`,
+			want: `{"card": {"iban": "DE00"}}`,
+		},
+		{
+			name: "oneOf",
+			schema: `{"type": "object", "properties": {"auth": {"oneOf": [
+				{"type": "object", "properties": {"password": {"writeOnly": true}}, "required": ["password"]},
+				{"type": "object", "properties": {"token": {"writeOnly": true}}, "required": ["token"]}
+			]}}}`,
+			doc:  `{"auth": {"token": "t", "user": "u"}}`,
+			want: `{"auth": {"user": "u"}}`,
+		},
+		{
+			name: "patternProperties",
+			schema: `{"type": "object", "patternProperties": {
+				"^secret_": {"type": "string", "writeOnly": true}
+			}}`,
+			doc:  `{"secret_a": "s", "public": "p"}`,
+			want: `{"public": "p"}`,
+		},
+		{
+			name: "additionalProperties",
+			schema: `{"type": "object", "properties": {"name": {"type": "string"}},
+				"additionalProperties": {"type": "string", "writeOnly": true}}`,
+			doc:  `{"name": "n", "extra": "x"}`,
+			want: `{"name": "n"}`,
+		},
+		{
+			name: "nested additionalProperties",
+			schema: `{"type": "object", "properties": {"keys": {"type": "object",
+				"additionalProperties": {"type": "object", "properties": {"private": {"writeOnly": true}}}
+			}}}`,
+			doc:  `{"keys": {"a": {"private": "k", "public": "p"}}}`,
+			want: `{"keys": {"a": {"public": "p"}}}`,
+		},
+		{
+			name: "prefixItems",
+			schema: `{"type": "object", "properties": {"pair": {"type": "array", "prefixItems": [
+				{"type": "object", "properties": {"pin": {"writeOnly": true}}}
+			], "items": {"type": "object", "properties": {"code": {"writeOnly": true}}}}}}`,
+			doc:  `{"pair": [{"pin": 1, "pos": 0}, {"pin": 2, "code": 3, "pos": 1}]}`,
+			want: `{"pair": [{"pos": 0}, {"pin": 2, "pos": 1}]}`,
+		},
+		{
+			name: "recursive $ref",
+			schema: `{"type": "object", "$defs": {"node": {"type": "object", "properties": {
+				"secret": {"writeOnly": true},
+				"child": {"$ref": "#/$defs/node"}
+			}, "allOf": [{"$ref": "#/$defs/node"}]}}, "properties": {"tree": {"$ref": "#/$defs/node"}}}`,
+			doc:  `{"tree": {"secret": 1, "child": {"secret": 2, "child": {"secret": 3, "v": 3}}}}`,
+			want: `{"tree": {"child": {"child": {"v": 3}}}}`,
+		},
+	}
+
+	v := validator.NewSchemaValidator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := v.StripWriteOnly(parseDoc(t, tt.doc), parseSchema(t, tt.schema))
+			if want := parseDoc(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckReadOnlyWalksSubschemas(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		doc    string
+		path   string
+	}{
+		{
+			name:   "allOf",
+			schema: `{"type": "object", "allOf": [{"properties": {"id": {"readOnly": true}}}]}`,
+			doc:    `{"id": "x"}`,
+			path:   "/id",
+		},
+		{
+			name:   "anyOf",
+			schema: `{"type": "object", "anyOf": [{"properties": {"id": {"readOnly": true}}}, {"required": ["name"]}]}`,
+			doc:    `{"id": "x"}`,
+			path:   "/id",
+		},
+		{
+			name:   "oneOf",
+			schema: `{"type": "object", "oneOf": [{"properties": {"id": {"readOnly": true}}}]}`,
+			doc:    `{"id": "x"}`,
+			path:   "/id",
+		},
+		{
+			name:   "patternProperties",
+			schema: `{"type": "object", "patternProperties": {"^sys_": {"readOnly": true}}}`,
+			doc:    `{"sys_owner": "x"}`,
+			path:   "/sys_owner",
+		},
+		{
+			name:   "additionalProperties",
+			schema: `{"type": "object", "properties": {"name": {}}, "additionalProperties": {"readOnly": true}}`,
+			doc:    `{"name": "n", "other": "x"}`,
+			path:   "/other",
+		},
+		{
+			name:   "prefixItems",
+			schema: `{"type": "object", "properties": {"row": {"prefixItems": [{"properties": {"id": {"readOnly": true}}}]}}}`,
+			doc:    `{"row": [{"id": 1}]}`,
+			path:   "/row/0/id",
+		},
+	}
+
+	v := validator.NewSchemaValidator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.CheckReadOnly(parseDoc(t, tt.doc), nil, parseSchema(t, tt.schema))
+			errs, ok := err.(validator.ValidationErrors)
+			if !ok || len(errs) != 1 || errs[0].Path != tt.path || errs[0].Keyword != "readOnly" {
+				t.Fatalf("expected a readOnly error at %s, got %v", tt.path, err)
+			}
+		})
+	}
+}
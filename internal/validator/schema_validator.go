@@ -3,93 +3,282 @@ package validator
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"net"
+	"reflect"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
+	"github.com/madhouselabs/anybase/internal/geo"
 	"github.com/madhouselabs/anybase/pkg/models"
 )
 
-// SchemaValidator validates documents against OpenAPI schema
-type SchemaValidator struct{}
+// maxSchemaDepth bounds subschema evaluation so that recursive $ref chains terminate
+const maxSchemaDepth = 64
+
+// maxSchemaSteps bounds the subschema evaluations in one validation run. Within the depth
+// limit, recursive $ref through anyOf or oneOf can still branch exponentially.
+const maxSchemaSteps = 1000000
+
+var (
+	emailRegex       = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+	urlRegex         = regexp.MustCompile(`^(https?|ftp)://[^\s/$.?#].[^\s]*$`)
+	uuidRegex        = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	hostnameLabel    = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+	jsonPointerRegex = regexp.MustCompile(`^(/([^/~]|~[01])*)*$`)
+)
+
+// ValidationError describes a single schema violation
+type ValidationError struct {
+	Path    string `json:"path"`    // JSON pointer to the offending value, empty for the document root
+	Keyword string `json:"keyword"` // Schema keyword that failed, e.g. "required" or "minLength"
+	Message string `json:"message"`
+}
+
+// Error implements the error interface
+func (e ValidationError) Error() string {
+	if e.Path == "" {
+		return "document " + e.Message
+	}
+	return fmt.Sprintf("field '%s' %s", e.Path, e.Message)
+}
+
+// ValidationErrors is the list of every violation found in a document
+type ValidationErrors []ValidationError
+
+// Error implements the error interface
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// SchemaValidator validates documents against JSON Schema (2020-12 subset)
+type SchemaValidator struct {
+	patterns sync.Map // Compiled pattern cache keyed by expression
+}
 
 // NewSchemaValidator creates a new schema validator
 func NewSchemaValidator() *SchemaValidator {
 	return &SchemaValidator{}
 }
 
-// ValidateDocument validates a document against a collection schema
+// validationState carries the root schema and the collected errors through one validation run
+type validationState struct {
+	root   *models.SchemaProperty
+	errors ValidationErrors
+	depth  int
+	budget *evaluationBudget // Shared with the probes of the run
+}
+
+// evaluationBudget counts the subschema evaluations of a validation run
+type evaluationBudget struct {
+	steps     int
+	exhausted bool
+}
+
+func newValidationState(root *models.SchemaProperty) *validationState {
+	return &validationState{root: root, budget: &evaluationBudget{}}
+}
+
+func (st *validationState) addError(path, keyword, format string, args ...interface{}) {
+	st.errors = append(st.errors, ValidationError{
+		Path:    path,
+		Keyword: keyword,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// child returns a scratch state used to probe a subschema without recording its errors
+func (st *validationState) child() *validationState {
+	return &validationState{root: st.root, depth: st.depth, budget: st.budget}
+}
+
+// ValidateDocument validates a document against a collection schema.
+// All violations are collected and returned as ValidationErrors.
 func (v *SchemaValidator) ValidateDocument(doc map[string]interface{}, schema *models.CollectionSchema) error {
 	if schema == nil {
 		return nil // No schema, no validation
 	}
 
-	// Check required fields
-	for _, required := range schema.Required {
-		if _, ok := doc[required]; !ok {
-			return fmt.Errorf("required field '%s' is missing", required)
-		}
-	}
-
-	// Validate each property
+	// Internal fields are managed by the system and never validated
+	userDoc := make(map[string]interface{}, len(doc))
 	for key, value := range doc {
-		// Skip internal fields
 		if strings.HasPrefix(key, "_") {
 			continue
 		}
+		userDoc[key] = value
+	}
 
-		prop, ok := schema.Properties[key]
-		if !ok {
-			// Check if additional properties are allowed
-			if schema.AdditionalProperties == false {
-				return fmt.Errorf("property '%s' is not allowed", key)
-			}
-			continue
+	root := RootSchema(schema)
+	st := newValidationState(root)
+	v.validateValue(userDoc, root, "", st)
+	if st.budget.exhausted {
+		st.addError("", "$ref", "is too complex to validate (recursive $ref?)")
+	}
+
+	if len(st.errors) > 0 {
+		return st.errors
+	}
+	return nil
+}
+
+// AcceptsNull reports whether a property, with $ref resolved against root, accepts a null value
+func (v *SchemaValidator) AcceptsNull(prop, root *models.SchemaProperty) bool {
+	st := newValidationState(root)
+	v.validateValue(nil, prop, "", st)
+	return len(st.errors) == 0 && !st.budget.exhausted
+}
+
+// RootSchema converts a collection schema into an equivalent object subschema
+func RootSchema(schema *models.CollectionSchema) *models.SchemaProperty {
+	root := &models.SchemaProperty{
+		Description:          schema.Description,
+		Properties:           schema.Properties,
+		Required:             schema.Required,
+		AdditionalProperties: schema.AdditionalProperties,
+		Defs:                 schema.Defs,
+		PatternProperties:    schema.PatternProperties,
+		DependentRequired:    schema.DependentRequired,
+		MinProperties:        schema.MinProperties,
+		MaxProperties:        schema.MaxProperties,
+		AllOf:                schema.AllOf,
+		AnyOf:                schema.AnyOf,
+		OneOf:                schema.OneOf,
+		Not:                  schema.Not,
+	}
+	if schema.Type != "" {
+		root.Type = schema.Type
+	}
+	return root
+}
+
+// validateValue validates a value against a schema property, recording every violation
+func (v *SchemaValidator) validateValue(value interface{}, prop *models.SchemaProperty, path string, st *validationState) {
+	if prop == nil {
+		return
+	}
+
+	st.depth++
+	defer func() { st.depth-- }()
+	if st.depth > maxSchemaDepth {
+		st.addError(path, "$ref", "exceeds maximum schema depth (recursive $ref?)")
+		return
+	}
+
+	// Once the budget is spent the run fails as a whole, so nothing more is evaluated
+	st.budget.steps++
+	if st.budget.steps > maxSchemaSteps {
+		st.budget.exhausted = true
+		return
+	}
+
+	if prop.Ref != "" {
+		target := resolveRef(st.root, prop.Ref)
+		if target == nil {
+			st.addError(path, "$ref", "references unknown schema '%s'", prop.Ref)
+		} else {
+			v.validateValue(value, target, path, st)
 		}
+	}
 
-		if err := v.validateValue(value, prop, key); err != nil {
-			return err
+	value = normalizeValue(value)
+
+	// Check type
+	types := v.getTypes(prop.Type)
+	if len(types) > 0 && !v.matchesAnyType(value, types) {
+		if value == nil {
+			st.addError(path, "type", "cannot be null")
+		} else if len(types) == 1 {
+			st.addError(path, "type", "must be %s", typeArticle(types[0]))
+		} else {
+			st.addError(path, "type", "must be one of types: %s", strings.Join(types, ", "))
 		}
+		return
 	}
 
-	return nil
-}
+	if prop.Const != nil {
+		var want interface{}
+		if err := json.Unmarshal(prop.Const, &want); err != nil || !jsonEqual(value, want) {
+			st.addError(path, "const", "must be equal to %s", prop.Const)
+		}
+	}
 
-// validateValue validates a value against a schema property
-func (v *SchemaValidator) validateValue(value interface{}, prop *models.SchemaProperty, path string) error {
-	if value == nil {
-		// Check if null is allowed
-		if prop.Type == "null" {
-			return nil
+	if len(prop.Enum) > 0 {
+		found := false
+		for _, e := range prop.Enum {
+			if jsonEqual(value, e) {
+				found = true
+				break
+			}
 		}
-		if arr, ok := prop.Type.([]interface{}); ok {
-			for _, t := range arr {
-				if t == "null" {
-					return nil
-				}
+		if !found {
+			st.addError(path, "enum", "must be one of: %v", prop.Enum)
+		}
+	}
+
+	switch val := value.(type) {
+	case string:
+		v.validateString(val, prop, path, st)
+	case float64:
+		v.validateNumber(val, prop, path, st)
+	case []interface{}:
+		v.validateArray(val, prop, path, st)
+	case map[string]interface{}:
+		v.validateObject(val, prop, path, st)
+	}
+
+	v.validateComposition(value, prop, path, st)
+}
+
+// validateComposition applies the allOf, anyOf, oneOf and not keywords
+func (v *SchemaValidator) validateComposition(value interface{}, prop *models.SchemaProperty, path string, st *validationState) {
+	for _, sub := range prop.AllOf {
+		v.validateValue(value, sub, path, st)
+	}
+
+	if len(prop.AnyOf) > 0 {
+		matched := false
+		for _, sub := range prop.AnyOf {
+			if v.matches(value, sub, path, st) {
+				matched = true
+				break
 			}
 		}
-		return fmt.Errorf("field '%s' cannot be null", path)
+		if !matched {
+			st.addError(path, "anyOf", "must match at least one schema in anyOf")
+		}
 	}
 
-	// Get the type(s)
-	types := v.getTypes(prop.Type)
-	
-	// Try to validate against any of the allowed types
-	var lastErr error
-	for _, t := range types {
-		if err := v.validateType(value, t, prop, path); err == nil {
-			return nil // Valid for this type
-		} else {
-			lastErr = err
+	if len(prop.OneOf) > 0 {
+		matches := 0
+		for _, sub := range prop.OneOf {
+			if v.matches(value, sub, path, st) {
+				matches++
+			}
+		}
+		if matches != 1 {
+			st.addError(path, "oneOf", "must match exactly one schema in oneOf (matched %d)", matches)
 		}
 	}
-	
-	if lastErr != nil {
-		return lastErr
+
+	if prop.Not != nil && v.matches(value, prop.Not, path, st) {
+		st.addError(path, "not", "must not match the schema in not")
 	}
+}
 
-	return fmt.Errorf("field '%s' does not match any allowed type", path)
+// matches reports whether value is valid against sub without recording errors
+func (v *SchemaValidator) matches(value interface{}, sub *models.SchemaProperty, path string, st *validationState) bool {
+	probe := st.child()
+	v.validateValue(value, sub, path, probe)
+	return len(probe.errors) == 0
 }
 
 // getTypes extracts types from type definition
@@ -105,232 +294,481 @@ func (v *SchemaValidator) getTypes(typeValue interface{}) []string {
 			}
 		}
 		return types
+	case []string:
+		return t
 	default:
 		return []string{}
 	}
 }
 
-// validateType validates value against a specific type
-func (v *SchemaValidator) validateType(value interface{}, dataType string, prop *models.SchemaProperty, path string) error {
-	switch dataType {
-	case "string":
-		str, ok := value.(string)
-		if !ok {
-			return fmt.Errorf("field '%s' must be a string", path)
-		}
-		return v.validateString(str, prop, path)
-		
-	case "number", "integer":
-		var num float64
-		switch n := value.(type) {
-		case float64:
-			num = n
-		case int:
-			num = float64(n)
-		case int64:
-			num = float64(n)
-		case int32:
-			num = float64(n)
-		default:
-			return fmt.Errorf("field '%s' must be a number", path)
-		}
-		
-		if dataType == "integer" {
-			if num != float64(int64(num)) {
-				return fmt.Errorf("field '%s' must be an integer", path)
+// matchesAnyType reports whether a normalized value is an instance of one of the given types
+func (v *SchemaValidator) matchesAnyType(value interface{}, types []string) bool {
+	for _, t := range types {
+		switch t {
+		case "null":
+			if value == nil {
+				return true
 			}
-		}
-		
-		return v.validateNumber(num, prop, path)
-		
-	case "boolean":
-		if _, ok := value.(bool); !ok {
-			return fmt.Errorf("field '%s' must be a boolean", path)
-		}
-		
-	case "array":
-		arr, ok := value.([]interface{})
-		if !ok {
-			// Try to convert from other slice types
-			if jsonBytes, err := json.Marshal(value); err == nil {
-				if err := json.Unmarshal(jsonBytes, &arr); err != nil {
-					return fmt.Errorf("field '%s' must be an array", path)
-				}
-			} else {
-				return fmt.Errorf("field '%s' must be an array", path)
+		case "string":
+			if _, ok := value.(string); ok {
+				return true
+			}
+		case "number":
+			if _, ok := value.(float64); ok {
+				return true
+			}
+		case "integer":
+			if num, ok := value.(float64); ok && num == math.Trunc(num) && !math.IsInf(num, 0) {
+				return true
+			}
+		case "boolean":
+			if _, ok := value.(bool); ok {
+				return true
+			}
+		case "array":
+			if _, ok := value.([]interface{}); ok {
+				return true
+			}
+		case "object":
+			if _, ok := value.(map[string]interface{}); ok {
+				return true
 			}
 		}
-		return v.validateArray(arr, prop, path)
-		
-	case "object":
-		obj, ok := value.(map[string]interface{})
-		if !ok {
-			return fmt.Errorf("field '%s' must be an object", path)
-		}
-		return v.validateObject(obj, prop, path)
 	}
-	
-	return nil
+	return false
 }
 
 // validateString validates string constraints
-func (v *SchemaValidator) validateString(str string, prop *models.SchemaProperty, path string) error {
-	// Check enum
-	if len(prop.Enum) > 0 {
-		found := false
-		for _, e := range prop.Enum {
-			if str == e {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return fmt.Errorf("field '%s' must be one of: %v", path, prop.Enum)
-		}
-	}
-
+func (v *SchemaValidator) validateString(str string, prop *models.SchemaProperty, path string, st *validationState) {
 	// Check pattern
 	if prop.Pattern != "" {
-		matched, err := regexp.MatchString(prop.Pattern, str)
+		re, err := v.compilePattern(prop.Pattern)
 		if err != nil {
-			return fmt.Errorf("invalid pattern for field '%s': %v", path, err)
-		}
-		if !matched {
-			return fmt.Errorf("field '%s' does not match pattern: %s", path, prop.Pattern)
+			st.addError(path, "pattern", "has an invalid pattern in schema: %v", err)
+		} else if !re.MatchString(str) {
+			st.addError(path, "pattern", "does not match pattern: %s", prop.Pattern)
 		}
 	}
 
-	// Check length constraints
-	if prop.MinLength != nil && len(str) < *prop.MinLength {
-		return fmt.Errorf("field '%s' is too short (minimum length: %d)", path, *prop.MinLength)
+	// Check length constraints (counted in characters, not bytes)
+	length := utf8.RuneCountInString(str)
+	if prop.MinLength != nil && length < *prop.MinLength {
+		st.addError(path, "minLength", "is too short (minimum length: %d)", *prop.MinLength)
 	}
-	if prop.MaxLength != nil && len(str) > *prop.MaxLength {
-		return fmt.Errorf("field '%s' is too long (maximum length: %d)", path, *prop.MaxLength)
+	if prop.MaxLength != nil && length > *prop.MaxLength {
+		st.addError(path, "maxLength", "is too long (maximum length: %d)", *prop.MaxLength)
 	}
 
 	// Check format
 	if prop.Format != "" {
-		if err := v.validateFormat(str, prop.Format, path); err != nil {
-			return err
+		if msg := v.validateFormat(str, prop.Format); msg != "" {
+			st.addError(path, "format", "%s", msg)
 		}
 	}
-
-	return nil
 }
 
 // validateNumber validates number constraints
-func (v *SchemaValidator) validateNumber(num float64, prop *models.SchemaProperty, path string) error {
-	// Check enum
-	if len(prop.Enum) > 0 {
-		found := false
-		for _, e := range prop.Enum {
-			if enumNum, ok := e.(float64); ok && num == enumNum {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return fmt.Errorf("field '%s' must be one of: %v", path, prop.Enum)
-		}
-	}
-
-	// Check range constraints
+func (v *SchemaValidator) validateNumber(num float64, prop *models.SchemaProperty, path string, st *validationState) {
 	if prop.Minimum != nil && num < *prop.Minimum {
-		return fmt.Errorf("field '%s' is too small (minimum: %f)", path, *prop.Minimum)
+		st.addError(path, "minimum", "is too small (minimum: %v)", *prop.Minimum)
 	}
 	if prop.Maximum != nil && num > *prop.Maximum {
-		return fmt.Errorf("field '%s' is too large (maximum: %f)", path, *prop.Maximum)
+		st.addError(path, "maximum", "is too large (maximum: %v)", *prop.Maximum)
+	}
+	if prop.ExclusiveMinimum != nil && num <= *prop.ExclusiveMinimum {
+		st.addError(path, "exclusiveMinimum", "must be greater than %v", *prop.ExclusiveMinimum)
+	}
+	if prop.ExclusiveMaximum != nil && num >= *prop.ExclusiveMaximum {
+		st.addError(path, "exclusiveMaximum", "must be less than %v", *prop.ExclusiveMaximum)
+	}
+	if prop.MultipleOf != nil && *prop.MultipleOf > 0 {
+		quotient := num / *prop.MultipleOf
+		if math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+			st.addError(path, "multipleOf", "must be a multiple of %v", *prop.MultipleOf)
+		}
 	}
-
-	return nil
 }
 
 // validateArray validates array constraints
-func (v *SchemaValidator) validateArray(arr []interface{}, prop *models.SchemaProperty, path string) error {
+func (v *SchemaValidator) validateArray(arr []interface{}, prop *models.SchemaProperty, path string, st *validationState) {
 	// Check length constraints
 	if prop.MinItems != nil && len(arr) < *prop.MinItems {
-		return fmt.Errorf("field '%s' has too few items (minimum: %d)", path, *prop.MinItems)
+		st.addError(path, "minItems", "has too few items (minimum: %d)", *prop.MinItems)
 	}
 	if prop.MaxItems != nil && len(arr) > *prop.MaxItems {
-		return fmt.Errorf("field '%s' has too many items (maximum: %d)", path, *prop.MaxItems)
+		st.addError(path, "maxItems", "has too many items (maximum: %d)", *prop.MaxItems)
 	}
 
 	// Check unique items
 	if prop.UniqueItems {
 		seen := make(map[string]bool)
 		for _, item := range arr {
-			key := fmt.Sprintf("%v", item)
+			key := canonicalJSON(item)
 			if seen[key] {
-				return fmt.Errorf("field '%s' must have unique items", path)
+				st.addError(path, "uniqueItems", "must have unique items")
+				break
 			}
 			seen[key] = true
 		}
 	}
 
-	// Validate each item
-	if prop.Items != nil {
+	// Validate positional items, then the remaining items
+	for i, item := range arr {
+		itemPath := path + "/" + strconv.Itoa(i)
+		if i < len(prop.PrefixItems) {
+			v.validateValue(item, prop.PrefixItems[i], itemPath, st)
+		} else if prop.Items != nil {
+			v.validateValue(item, prop.Items, itemPath, st)
+		}
+	}
+
+	// Check contains
+	if prop.Contains != nil {
+		count := 0
 		for i, item := range arr {
-			itemPath := fmt.Sprintf("%s[%d]", path, i)
-			if err := v.validateValue(item, prop.Items, itemPath); err != nil {
-				return err
+			if v.matches(item, prop.Contains, path+"/"+strconv.Itoa(i), st) {
+				count++
 			}
 		}
+		minContains := 1
+		if prop.MinContains != nil {
+			minContains = *prop.MinContains
+		}
+		if count < minContains {
+			st.addError(path, "contains", "must contain at least %d matching item(s)", minContains)
+		}
+		if prop.MaxContains != nil && count > *prop.MaxContains {
+			st.addError(path, "maxContains", "must contain at most %d matching item(s)", *prop.MaxContains)
+		}
 	}
-
-	return nil
 }
 
 // validateObject validates object constraints
-func (v *SchemaValidator) validateObject(obj map[string]interface{}, prop *models.SchemaProperty, path string) error {
+func (v *SchemaValidator) validateObject(obj map[string]interface{}, prop *models.SchemaProperty, path string, st *validationState) {
+	// GeoJSON formats apply to objects rather than strings
+	if msg := validateGeoFormat(obj, prop.Format); msg != "" {
+		st.addError(path, "format", "%s", msg)
+	}
+
 	// Check required fields
 	for _, required := range prop.Required {
 		if _, ok := obj[required]; !ok {
-			return fmt.Errorf("required field '%s.%s' is missing", path, required)
+			st.addError(path+"/"+escapePointer(required), "required", "is required")
 		}
 	}
 
-	// Validate properties
-	if prop.Properties != nil {
-		for key, value := range obj {
-			if subProp, ok := prop.Properties[key]; ok {
-				subPath := fmt.Sprintf("%s.%s", path, key)
-				if err := v.validateValue(value, subProp, subPath); err != nil {
-					return err
-				}
+	// Check property count
+	if prop.MinProperties != nil && len(obj) < *prop.MinProperties {
+		st.addError(path, "minProperties", "has too few properties (minimum: %d)", *prop.MinProperties)
+	}
+	if prop.MaxProperties != nil && len(obj) > *prop.MaxProperties {
+		st.addError(path, "maxProperties", "has too many properties (maximum: %d)", *prop.MaxProperties)
+	}
+
+	// Check dependent required fields
+	for key, deps := range prop.DependentRequired {
+		if _, ok := obj[key]; !ok {
+			continue
+		}
+		for _, dep := range deps {
+			if _, ok := obj[dep]; !ok {
+				st.addError(path+"/"+escapePointer(dep), "dependentRequired", "is required when '%s' is present", key)
+			}
+		}
+	}
+
+	additional := v.additionalSchema(prop.AdditionalProperties)
+
+	// Validate properties in a stable order so errors are reported deterministically
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := obj[key]
+		subPath := path + "/" + escapePointer(key)
+		evaluated := false
+
+		if subProp, ok := prop.Properties[key]; ok {
+			v.validateValue(value, subProp, subPath, st)
+			evaluated = true
+		}
+
+		for pattern, subProp := range prop.PatternProperties {
+			re, err := v.compilePattern(pattern)
+			if err != nil {
+				st.addError(subPath, "patternProperties", "has an invalid pattern in schema: %v", err)
+				continue
+			}
+			if re.MatchString(key) {
+				v.validateValue(value, subProp, subPath, st)
+				evaluated = true
 			}
 		}
+
+		if evaluated {
+			continue
+		}
+
+		// Check if additional properties are allowed
+		if prop.AdditionalProperties == false {
+			st.addError(subPath, "additionalProperties", "is not allowed")
+		} else if additional != nil {
+			v.validateValue(value, additional, subPath, st)
+		}
 	}
+}
 
+// additionalSchema returns the schema form of additionalProperties, if any
+func (v *SchemaValidator) additionalSchema(value interface{}) *models.SchemaProperty {
+	switch ap := value.(type) {
+	case *models.SchemaProperty:
+		return ap
+	case map[string]interface{}:
+		var prop models.SchemaProperty
+		if data, err := json.Marshal(ap); err == nil && json.Unmarshal(data, &prop) == nil {
+			return &prop
+		}
+	}
 	return nil
 }
 
-// validateFormat validates string format
-func (v *SchemaValidator) validateFormat(str string, format string, path string) error {
+// validateFormat validates string format and returns a message describing the failure
+func (v *SchemaValidator) validateFormat(str string, format string) string {
 	switch format {
 	case "date-time":
 		if _, err := time.Parse(time.RFC3339, str); err != nil {
-			return fmt.Errorf("field '%s' must be a valid date-time (RFC3339 format)", path)
+			return "must be a valid date-time (RFC3339 format)"
 		}
 	case "date":
 		if _, err := time.Parse("2006-01-02", str); err != nil {
-			return fmt.Errorf("field '%s' must be a valid date (YYYY-MM-DD format)", path)
+			return "must be a valid date (YYYY-MM-DD format)"
+		}
+	case "time":
+		if _, err := time.Parse("15:04:05Z07:00", str); err != nil {
+			return "must be a valid time (HH:MM:SS with offset, RFC3339 full-time)"
 		}
 	case "email":
-		emailRegex := `^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`
-		if matched, _ := regexp.MatchString(emailRegex, str); !matched {
-			return fmt.Errorf("field '%s' must be a valid email address", path)
+		if !emailRegex.MatchString(str) {
+			return "must be a valid email address"
+		}
+	case "hostname":
+		if !isHostname(str) {
+			return "must be a valid hostname"
+		}
+	case "ipv4":
+		if ip := net.ParseIP(str); ip == nil || ip.To4() == nil || strings.Contains(str, ":") {
+			return "must be a valid IPv4 address"
+		}
+	case "ipv6":
+		if ip := net.ParseIP(str); ip == nil || !strings.Contains(str, ":") {
+			return "must be a valid IPv6 address"
 		}
 	case "uri", "url":
-		urlRegex := `^(https?|ftp)://[^\s/$.?#].[^\s]*$`
-		if matched, _ := regexp.MatchString(urlRegex, str); !matched {
-			return fmt.Errorf("field '%s' must be a valid URL", path)
+		if !urlRegex.MatchString(str) {
+			return "must be a valid URL"
 		}
 	case "uuid":
-		uuidRegex := `^[0-9a-f]{8}-[0-9a-f]{4}-[1-5][0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`
-		if matched, _ := regexp.MatchString(uuidRegex, strings.ToLower(str)); !matched {
-			return fmt.Errorf("field '%s' must be a valid UUID", path)
+		if !uuidRegex.MatchString(strings.ToLower(str)) {
+			return "must be a valid UUID"
+		}
+	case "regex":
+		if _, err := regexp.Compile(str); err != nil {
+			return "must be a valid regular expression"
+		}
+	case "json-pointer":
+		if !jsonPointerRegex.MatchString(str) {
+			return "must be a valid JSON pointer"
 		}
 	}
 
-	return nil
-}
\ No newline at end of file
+	return ""
+}
+
+// validateGeoFormat checks the geojson-point, geojson-polygon and geojson formats
+func validateGeoFormat(obj map[string]interface{}, format string) string {
+	var want string
+	switch format {
+	case "geojson-point":
+		want = geo.TypePoint
+	case "geojson-polygon":
+		want = geo.TypePolygon
+	case "geojson":
+	default:
+		return ""
+	}
+
+	shape, err := geo.ParseGeometry(obj)
+	if err != nil {
+		return "must be a valid GeoJSON geometry: " + err.Error()
+	}
+	if want != "" && shape.Type != want {
+		return "must be a GeoJSON " + want
+	}
+	return ""
+}
+
+// compilePattern compiles a pattern once and caches it
+func (v *SchemaValidator) compilePattern(pattern string) (*regexp.Regexp, error) {
+	if cached, ok := v.patterns.Load(pattern); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	v.patterns.Store(pattern, re)
+	return re, nil
+}
+
+// resolveRef resolves a local JSON pointer reference ("#", "#/$defs/name", ...) against the root schema
+func resolveRef(root *models.SchemaProperty, ref string) *models.SchemaProperty {
+	if ref == "#" {
+		return root
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil // Only local references are supported
+	}
+
+	segments := strings.Split(strings.TrimPrefix(ref, "#/"), "/")
+	current := root
+	for i := 0; i < len(segments) && current != nil; i++ {
+		keyword := unescapePointer(segments[i])
+		switch keyword {
+		case "items":
+			current = current.Items
+			continue
+		case "not":
+			current = current.Not
+			continue
+		case "contains":
+			current = current.Contains
+			continue
+		}
+
+		// Remaining keywords take a name or index argument
+		i++
+		if i >= len(segments) {
+			return nil
+		}
+		arg := unescapePointer(segments[i])
+		switch keyword {
+		case "$defs", "definitions":
+			current = current.Defs[arg]
+		case "properties":
+			current = current.Properties[arg]
+		case "patternProperties":
+			current = current.PatternProperties[arg]
+		case "prefixItems", "allOf", "anyOf", "oneOf":
+			list := map[string][]*models.SchemaProperty{
+				"prefixItems": current.PrefixItems,
+				"allOf":       current.AllOf,
+				"anyOf":       current.AnyOf,
+				"oneOf":       current.OneOf,
+			}[keyword]
+			idx, err := strconv.Atoi(arg)
+			if err != nil || idx < 0 || idx >= len(list) {
+				return nil
+			}
+			current = list[idx]
+		default:
+			return nil
+		}
+	}
+	return current
+}
+
+// normalizeValue converts Go numeric and slice types into their JSON equivalents
+func normalizeValue(value interface{}) interface{} {
+	switch n := value.(type) {
+	case nil, string, bool, float64, []interface{}, map[string]interface{}:
+		return value
+	case int:
+		return float64(n)
+	case int8:
+		return float64(n)
+	case int16:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case uint:
+		return float64(n)
+	case uint8:
+		return float64(n)
+	case uint16:
+		return float64(n)
+	case uint32:
+		return float64(n)
+	case uint64:
+		return float64(n)
+	case float32:
+		return float64(n)
+	case json.Number:
+		if f, err := n.Float64(); err == nil {
+			return f
+		}
+		return n.String()
+	}
+
+	// Fall back to a JSON round trip for other slices, maps and structs
+	kind := reflect.TypeOf(value).Kind()
+	if kind == reflect.Slice || kind == reflect.Array || kind == reflect.Map || kind == reflect.Struct {
+		if data, err := json.Marshal(value); err == nil {
+			var out interface{}
+			if json.Unmarshal(data, &out) == nil {
+				return out
+			}
+		}
+	}
+	return value
+}
+
+// jsonEqual compares two values by their canonical JSON encoding
+func jsonEqual(a, b interface{}) bool {
+	return canonicalJSON(normalizeValue(a)) == canonicalJSON(normalizeValue(b))
+}
+
+// canonicalJSON encodes a value with sorted object keys
+func canonicalJSON(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
+
+// isHostname checks RFC 1123 hostname syntax
+func isHostname(str string) bool {
+	str = strings.TrimSuffix(str, ".")
+	if str == "" || len(str) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(str, ".") {
+		if !hostnameLabel.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
+
+// typeArticle renders a type name with its article for error messages
+func typeArticle(t string) string {
+	switch t {
+	case "array", "object", "integer":
+		return "an " + t
+	default:
+		return "a " + t
+	}
+}
+
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+func unescapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~1", "/"), "~0", "~")
+}
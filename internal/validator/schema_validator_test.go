@@ -0,0 +1,108 @@
+package validator_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/madhouselabs/anybase/internal/validator"
+)
+
+// validate checks a document against a schema and returns the failing keywords
+func validate(t *testing.T, schema, doc string) []string {
+	t.Helper()
+	err := validator.NewSchemaValidator().ValidateDocument(parseDoc(t, doc), parseSchema(t, schema))
+	if err == nil {
+		return nil
+	}
+	errs, ok := err.(validator.ValidationErrors)
+	if !ok {
+		t.Fatalf("unexpected error type %T: %v", err, err)
+	}
+	keywords := make([]string, len(errs))
+	for i, e := range errs {
+		keywords[i] = e.Keyword
+	}
+	return keywords
+}
+
+func TestValidateUUIDFormat(t *testing.T) {
+	schema := `{"type": "object", "properties": {"id": {"type": "string", "format": "uuid"}}}`
+	tests := []struct {
+		id    string
+		valid bool
+	}{
+		{id: "123e4567-e89b-12d3-a456-426614174000", valid: true},  // v1
+		{id: "9b2e1d3c-5f7a-4c8e-9d1b-2a3f4e5d6c7b", valid: true},  // v4
+		{id: "1ec9414c-232a-6b00-b3c8-9e6bdeced846", valid: true},  // v6
+		{id: "01890a5d-ac96-774b-bcce-b302099a8057", valid: true},  // v7
+		{id: "01890A5D-AC96-774B-BCCE-B302099A8057", valid: true},  // upper case
+		{id: "320c3d4d-cc00-875b-8ec9-32d5f69181c0", valid: true},  // v8
+		{id: "01890a5d-ac96-774b-7cce-b302099a8057", valid: false}, // NCS variant
+		{id: "01890a5dac96774bbcceb302099a8057", valid: false},
+		{id: "not-a-uuid", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			errs := validate(t, schema, `{"id": "`+tt.id+`"}`)
+			if valid := len(errs) == 0; valid != tt.valid {
+				t.Fatalf("valid = %v, want %v (errors %v)", valid, tt.valid, errs)
+			}
+		})
+	}
+}
+
+func TestValidateConst(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		doc   string
+		valid bool
+	}{
+		{name: "null matches null", value: `null`, doc: `{"v": null}`, valid: true},
+		{name: "null rejects a value", value: `null`, doc: `{"v": 0}`, valid: false},
+		{name: "null rejects a string", value: `null`, doc: `{"v": "null"}`, valid: false},
+		{name: "missing property is not checked", value: `null`, doc: `{}`, valid: true},
+		{name: "zero", value: `0`, doc: `{"v": 0}`, valid: true},
+		{name: "false rejects null", value: `false`, doc: `{"v": null}`, valid: false},
+		{name: "object ignores key order", value: `{"b": 1, "a": [true]}`, doc: `{"v": {"a": [true], "b": 1}}`, valid: true},
+		{name: "object compares values", value: `{"a": 1}`, doc: `{"v": {"a": 2}}`, valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := `{"type": "object", "properties": {"v": {"const": ` + tt.value + `}}}`
+			errs := validate(t, schema, tt.doc)
+			if valid := len(errs) == 0; valid != tt.valid {
+				t.Fatalf("valid = %v, want %v (errors %v)", valid, tt.valid, errs)
+			}
+		})
+	}
+}
+
+func TestValidateBoundsRecursiveComposition(t *testing.T) {
+	// Each level tries both branches, so without a bound this is 2^64 evaluations
+	schema := `{"type": "object", "$defs": {"loop": {"anyOf": [
+		{"$ref": "#/$defs/loop"},
+		{"$ref": "#/$defs/loop"}
+	]}}, "properties": {"v": {"$ref": "#/$defs/loop"}}}`
+
+	done := make(chan []string, 1)
+	go func() { done <- validate(t, schema, `{"v": 1}`) }()
+	select {
+	case errs := <-done:
+		if len(errs) == 0 {
+			t.Fatal("expected the document to be rejected")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("validation did not finish")
+	}
+
+	// Ordinary recursion stays well within the bound
+	tree := `{"type": "object", "$defs": {"node": {"type": "object", "properties": {
+		"children": {"type": "array", "items": {"anyOf": [{"$ref": "#/$defs/node"}, {"type": "null"}]}}
+	}}}, "properties": {"root": {"$ref": "#/$defs/node"}}}`
+	if errs := validate(t, tree, `{"root": {"children": [{"children": [null, {"children": []}]}, null]}}`); len(errs) != 0 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+}
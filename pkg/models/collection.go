@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -25,6 +26,18 @@ type VectorField struct {
 	AutoEmbed      bool     `bson:"auto_embed,omitempty" json:"auto_embed,omitempty"`           // Auto-generate on CRUD
 }
 
+// TextSearchConfig configures full-text search over selected document fields
+type TextSearchConfig struct {
+	Fields   []TextSearchField `bson:"fields" json:"fields"`
+	Language string            `bson:"language,omitempty" json:"language,omitempty"` // PostgreSQL text search configuration, defaults to "english"
+}
+
+// TextSearchField is a field included in full-text search
+type TextSearchField struct {
+	Name   string `bson:"name" json:"name"`                         // Field name; dots address nested fields
+	Weight string `bson:"weight,omitempty" json:"weight,omitempty"` // A (highest) to D (default)
+}
+
 // Collection represents a data collection in the system
 type Collection struct {
 	ID            primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
@@ -33,6 +46,7 @@ type Collection struct {
 	Schema        *CollectionSchema      `bson:"schema,omitempty" json:"schema,omitempty"`
 	Indexes       []CollectionIndex      `bson:"indexes,omitempty" json:"indexes,omitempty"`
 	VectorFields  []VectorField          `bson:"vector_fields,omitempty" json:"vector_fields,omitempty"`
+	TextSearch    *TextSearchConfig      `bson:"text_search,omitempty" json:"text_search,omitempty"`
 	Permissions   CollectionPermissions  `bson:"permissions" json:"permissions"`
 	Settings      CollectionSettings     `bson:"settings" json:"settings"`
 	Metadata      map[string]interface{} `bson:"metadata,omitempty" json:"metadata,omitempty"`
@@ -49,6 +63,17 @@ type CollectionSchema struct {
 	Required    []string                          `bson:"required,omitempty" json:"required,omitempty"`
 	AdditionalProperties interface{}              `bson:"additionalProperties,omitempty" json:"additionalProperties,omitempty"`
 	Description string                            `bson:"description,omitempty" json:"description,omitempty"`
+
+	// JSON Schema 2020-12 keywords applied to the document root
+	Defs              map[string]*SchemaProperty `bson:"$defs,omitempty" json:"$defs,omitempty"` // Reusable subschemas referenced via "#/$defs/<name>"
+	PatternProperties map[string]*SchemaProperty `bson:"patternProperties,omitempty" json:"patternProperties,omitempty"`
+	DependentRequired map[string][]string        `bson:"dependentRequired,omitempty" json:"dependentRequired,omitempty"`
+	MinProperties     *int                       `bson:"minProperties,omitempty" json:"minProperties,omitempty"`
+	MaxProperties     *int                       `bson:"maxProperties,omitempty" json:"maxProperties,omitempty"`
+	AllOf             []*SchemaProperty          `bson:"allOf,omitempty" json:"allOf,omitempty"`
+	AnyOf             []*SchemaProperty          `bson:"anyOf,omitempty" json:"anyOf,omitempty"`
+	OneOf             []*SchemaProperty          `bson:"oneOf,omitempty" json:"oneOf,omitempty"`
+	Not               *SchemaProperty            `bson:"not,omitempty" json:"not,omitempty"`
 }
 
 // SchemaProperty represents a property in OpenAPI schema format
@@ -72,8 +97,56 @@ type SchemaProperty struct {
 	ReadOnly    bool                              `bson:"readOnly,omitempty" json:"readOnly,omitempty"`
 	WriteOnly   bool                              `bson:"writeOnly,omitempty" json:"writeOnly,omitempty"`
 	Example     interface{}                       `bson:"example,omitempty" json:"example,omitempty"`
+
+	// JSON Schema 2020-12 keywords
+	Ref                  string                     `bson:"$ref,omitempty" json:"$ref,omitempty"`   // Local reference, e.g. "#/$defs/address"
+	Defs                 map[string]*SchemaProperty `bson:"$defs,omitempty" json:"$defs,omitempty"` // Reusable subschemas
+	Const                json.RawMessage            `bson:"const,omitempty" json:"const,omitempty"` // Kept raw so that "const": null is told apart from no const
+	AllOf                []*SchemaProperty          `bson:"allOf,omitempty" json:"allOf,omitempty"`
+	AnyOf                []*SchemaProperty          `bson:"anyOf,omitempty" json:"anyOf,omitempty"`
+	OneOf                []*SchemaProperty          `bson:"oneOf,omitempty" json:"oneOf,omitempty"`
+	Not                  *SchemaProperty            `bson:"not,omitempty" json:"not,omitempty"`
+	MultipleOf           *float64                   `bson:"multipleOf,omitempty" json:"multipleOf,omitempty"`
+	ExclusiveMinimum     *float64                   `bson:"exclusiveMinimum,omitempty" json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64                   `bson:"exclusiveMaximum,omitempty" json:"exclusiveMaximum,omitempty"`
+	PrefixItems          []*SchemaProperty          `bson:"prefixItems,omitempty" json:"prefixItems,omitempty"` // Positional item schemas for tuples
+	Contains             *SchemaProperty            `bson:"contains,omitempty" json:"contains,omitempty"`
+	MinContains          *int                       `bson:"minContains,omitempty" json:"minContains,omitempty"`
+	MaxContains          *int                       `bson:"maxContains,omitempty" json:"maxContains,omitempty"`
+	PatternProperties    map[string]*SchemaProperty `bson:"patternProperties,omitempty" json:"patternProperties,omitempty"`
+	AdditionalProperties interface{}                `bson:"additionalProperties,omitempty" json:"additionalProperties,omitempty"` // bool or schema
+	DependentRequired    map[string][]string        `bson:"dependentRequired,omitempty" json:"dependentRequired,omitempty"`
+	MinProperties        *int                       `bson:"minProperties,omitempty" json:"minProperties,omitempty"`
+	MaxProperties        *int                       `bson:"maxProperties,omitempty" json:"maxProperties,omitempty"`
+
+	// Server-computed values (top-level properties only); computed fields are read-only to clients
+	Computed  string `bson:"computed,omitempty" json:"computed,omitempty"`   // Expression, e.g. "first + ' ' + last" or "slugify(title)"
+	ComputeOn string `bson:"computeOn,omitempty" json:"computeOn,omitempty"` // "always" (default) or "insert"
+
+	// Reference to a document in another collection; on arrays, declare it on items
+	Reference *PropertyRef `bson:"ref,omitempty" json:"ref,omitempty"`
 }
 
+// PropertyRef points a property at documents in another collection
+type PropertyRef struct {
+	Collection string `bson:"collection" json:"collection"`
+	Field      string `bson:"field,omitempty" json:"field,omitempty"`       // Field matched in the target collection, defaults to "_id"
+	OnDelete   string `bson:"onDelete,omitempty" json:"onDelete,omitempty"` // restrict, cascade or setNull; unset leaves references dangling
+}
+
+// What happens to referencing documents when their target is deleted
+const (
+	OnDeleteRestrict = "restrict"
+	OnDeleteCascade  = "cascade"
+	OnDeleteSetNull  = "setNull"
+)
+
+// When computed fields are evaluated
+const (
+	ComputeOnAlways = "always"
+	ComputeOnInsert = "insert"
+)
+
 // CollectionIndex represents an index on the collection
 type CollectionIndex struct {
 	Name   string                 `bson:"name" json:"name"`
@@ -88,8 +161,28 @@ type CollectionPermissions struct {
 	Write  PermissionRule `bson:"write" json:"write"`
 	Update PermissionRule `bson:"update" json:"update"`
 	Delete PermissionRule `bson:"delete" json:"delete"`
+	Fields []FieldRule    `bson:"fields,omitempty" json:"fields,omitempty"` // Field-level restrictions on top of the rules above
+}
+
+// FieldRule restricts one field for the callers it applies to. A rule without roles or
+// permissions applies to everyone except the exempt callers.
+type FieldRule struct {
+	Field             string   `bson:"field" json:"field"`                                               // Field name; dots address nested objects
+	Access            string   `bson:"access" json:"access"`                                             // hidden, masked or read_only
+	Mask              string   `bson:"mask,omitempty" json:"mask,omitempty"`                             // Masking strategy for masked fields, defaults to "redact"
+	Roles             []string `bson:"roles,omitempty" json:"roles,omitempty"`                           // Applies to callers holding any of these roles
+	Permissions       []string `bson:"permissions,omitempty" json:"permissions,omitempty"`               // or any of these permissions
+	ExceptRoles       []string `bson:"except_roles,omitempty" json:"except_roles,omitempty"`             // Callers holding any of these roles are exempt
+	ExceptPermissions []string `bson:"except_permissions,omitempty" json:"except_permissions,omitempty"` // as are callers holding any of these permissions
 }
 
+// Field access levels, from least to most restrictive. Hidden and masked fields are also read-only.
+const (
+	FieldAccessReadOnly = "read_only"
+	FieldAccessMasked   = "masked"
+	FieldAccessHidden   = "hidden"
+)
+
 // PermissionRule defines who can perform an action
 type PermissionRule struct {
 	Roles      []string               `bson:"roles" json:"roles"`           // Allowed roles
@@ -106,8 +199,15 @@ type CollectionSettings struct {
 	Encryption    bool `bson:"encryption" json:"encryption"`       // Enable field encryption
 	MaxDocuments  int  `bson:"max_documents" json:"max_documents"` // Max documents (0 = unlimited)
 	MaxSizeBytes  int  `bson:"max_size_bytes" json:"max_size_bytes"` // Max size in bytes
+	ReadOnlyPolicy string `bson:"read_only_policy,omitempty" json:"read_only_policy,omitempty"` // "reject" (default) or "strip" client writes to readOnly fields
 }
 
+// Read-only policies for client writes to readOnly schema fields
+const (
+	ReadOnlyPolicyReject = "reject"
+	ReadOnlyPolicyStrip  = "strip"
+)
+
 // View represents a filtered/transformed view of a collection
 type View struct {
 	ID          primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
@@ -152,10 +252,48 @@ type DataQuery struct {
 	Sort       map[string]int         `json:"sort,omitempty"`
 	Limit      int                    `json:"limit,omitempty"`
 	Skip       int                    `json:"skip,omitempty"`
+	Expand     []string               `json:"expand,omitempty"` // Reference paths to inline, e.g. "author" or "comments.user"
 	UserID     primitive.ObjectID     `json:"-"`
 	UserRoles  []string               `json:"-"`
 }
 
+// FacetRequest describes an aggregate computed over the documents matching a query
+type FacetRequest struct {
+	Type     string       `json:"type"`               // terms, range or date_histogram
+	Field    string       `json:"field"`              // Field name; dots address nested fields
+	Size     int          `json:"size,omitempty"`     // terms: maximum number of buckets
+	Ranges   []FacetRange `json:"ranges,omitempty"`   // range: bucket boundaries
+	Interval string       `json:"interval,omitempty"` // date_histogram: hour, day, week, month, quarter or year
+}
+
+// FacetRange is a numeric bucket; From is inclusive, To is exclusive, either may be open
+type FacetRange struct {
+	Key  string   `json:"key,omitempty"`
+	From *float64 `json:"from,omitempty"`
+	To   *float64 `json:"to,omitempty"`
+}
+
+// FacetBucket is one value of a facet with its document count
+type FacetBucket struct {
+	Key   interface{} `json:"key"`
+	Count int64       `json:"count"`
+	From  *float64    `json:"from,omitempty"`
+	To    *float64    `json:"to,omitempty"`
+}
+
+// FacetResult holds the buckets of one facet
+type FacetResult struct {
+	Type    string        `json:"type"`
+	Buckets []FacetBucket `json:"buckets"`
+}
+
+// Facet types
+const (
+	FacetTypeTerms         = "terms"
+	FacetTypeRange         = "range"
+	FacetTypeDateHistogram = "date_histogram"
+)
+
 // DataMutation represents a data change with governance
 type DataMutation struct {
 	Collection string                 `json:"collection"`
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Organization is an isolated tenant. Its collections, views, roles, access keys and AI
+// providers are stored under its own namespace.
+type Organization struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name        string             `bson:"name" json:"name"`
+	Slug        string             `bson:"slug" json:"slug"`
+	Description string             `bson:"description,omitempty" json:"description,omitempty"`
+	CreatedBy   primitive.ObjectID `bson:"created_by,omitempty" json:"created_by,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// OrgMember grants a user roles within one organization
+type OrgMember struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrgID     primitive.ObjectID `bson:"org_id" json:"org_id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Roles     []string           `bson:"roles" json:"roles"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// CreateOrganizationRequest is the body of an organization creation request
+type CreateOrganizationRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Slug        string `json:"slug" binding:"required"`
+	Description string `json:"description,omitempty"`
+}
+
+// OrgMemberRequest adds a user to an organization or changes their roles
+type OrgMemberRequest struct {
+	UserID string   `json:"user_id,omitempty"`
+	Email  string   `json:"email,omitempty"`
+	Roles  []string `json:"roles" binding:"required"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ServiceAccountEmailDomain is the domain of the addresses service accounts are stored
+// under. The .invalid TLD is reserved (RFC 2606), so no mailbox or identity provider can
+// ever claim one.
+const ServiceAccountEmailDomain = "service-accounts.invalid"
+
+// IsServiceAccountEmail reports whether the address belongs to the service account domain
+func IsServiceAccountEmail(email string) bool {
+	return strings.HasSuffix(strings.ToLower(strings.TrimSpace(email)), "@"+ServiceAccountEmailDomain)
+}
+
+// IsServiceAccount reports whether the user is a service account rather than a person
+func (u *User) IsServiceAccount() bool {
+	return u.UserType == UserTypeServiceAccount
+}
+
+// ServiceAccountCredential lets a service account obtain access tokens through the OAuth 2.0
+// client credentials grant. Each credential has its own session, so revoking the credential
+// ends every token issued with it.
+type ServiceAccountCredential struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ServiceAccountID primitive.ObjectID `bson:"service_account_id" json:"service_account_id"`
+	Name             string             `bson:"name,omitempty" json:"name,omitempty"`
+	ClientID         string             `bson:"client_id" json:"client_id"`
+	SecretHash       string             `bson:"secret_hash" json:"-"` // SHA-256 of the client secret
+	SessionID        primitive.ObjectID `bson:"session_id" json:"-"`
+	CreatedBy        primitive.ObjectID `bson:"created_by,omitempty" json:"created_by,omitempty"`
+	CreatedAt        time.Time          `bson:"created_at" json:"created_at"`
+	ExpiresAt        time.Time          `bson:"expires_at" json:"expires_at"`
+	LastUsedAt       *time.Time         `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+	RevokedAt        *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+}
+
+// Active reports whether the credential can still be exchanged for tokens
+func (c *ServiceAccountCredential) Active() bool {
+	return c.RevokedAt == nil && time.Now().Before(c.ExpiresAt)
+}
+
+// CreateServiceAccountRequest is the body of a service account creation request
+type CreateServiceAccountRequest struct {
+	Name        string   `json:"name" binding:"required"` // Lowercase letters, digits and dashes
+	Description string   `json:"description,omitempty"`
+	Role        string   `json:"role" binding:"required"`
+	Roles       []string `json:"roles,omitempty"`
+}
+
+// CreateCredentialRequest is the body of a service account credential request
+type CreateCredentialRequest struct {
+	Name          string `json:"name,omitempty"`
+	ExpiresInDays int    `json:"expires_in_days,omitempty"` // Defaults to a year, at most ten years
+}
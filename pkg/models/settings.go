@@ -36,8 +36,11 @@ type SystemSettings struct {
 	// Security Settings (admin only)
 	SessionTimeout    int    `bson:"session_timeout" json:"session_timeout"` // hours
 	PasswordPolicy    string `bson:"password_policy" json:"password_policy"` // basic, moderate, strong
+	PasswordMaxAge    int    `bson:"password_max_age" json:"password_max_age"` // days before a password must be changed, 0 for never
 	MFARequired       bool   `bson:"mfa_required" json:"mfa_required"`
 	AuditLogEnabled   bool   `bson:"audit_log_enabled" json:"audit_log_enabled"`
+	RequireEmailVerification bool `bson:"require_email_verification" json:"require_email_verification"` // Block login until the email is verified
+	PasswordlessLogin bool   `bson:"passwordless_login" json:"passwordless_login"` // Allow sign-in with an emailed link or code
 	
 	// API Settings (admin only)
 	RateLimit         int  `bson:"rate_limit" json:"rate_limit"`         // requests per minute
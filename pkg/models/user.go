@@ -19,25 +19,48 @@ type User struct {
 	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Email             string             `bson:"email" json:"email" validate:"required,email"`
 	Password          string             `bson:"password" json:"-"` // Never expose password in JSON
+	PasswordHistory   []string           `bson:"password_history,omitempty" json:"-"` // Hashes of previous passwords, newest first
+	PasswordChangedAt *time.Time         `bson:"password_changed_at,omitempty" json:"password_changed_at,omitempty"`
 	FirstName         string             `bson:"first_name,omitempty" json:"first_name,omitempty"`
 	LastName          string             `bson:"last_name,omitempty" json:"last_name,omitempty"`
 	Avatar            string             `bson:"avatar,omitempty" json:"avatar,omitempty"`
 	EmailVerified     bool               `bson:"email_verified" json:"email_verified"`
-	EmailVerificationToken string        `bson:"email_verification_token,omitempty" json:"-"`
-	PasswordResetToken    string         `bson:"password_reset_token,omitempty" json:"-"`
+	EmailVerificationToken string        `bson:"email_verification_token,omitempty" json:"-"` // SHA-256 of the emailed token
+	EmailVerificationExpiry *time.Time   `bson:"email_verification_expiry,omitempty" json:"-"`
+	PasswordResetToken    string         `bson:"password_reset_token,omitempty" json:"-"` // SHA-256 of the emailed token
 	PasswordResetExpiry   *time.Time     `bson:"password_reset_expiry,omitempty" json:"-"`
 	UserType          UserType           `bson:"user_type" json:"user_type"`
-	Role              string             `bson:"role" json:"role"` // Only "admin" or "developer" for regular users
+	Role              string             `bson:"role" json:"role"` // Primary role
+	Roles             []string           `bson:"roles,omitempty" json:"roles,omitempty"` // All roles held, including the primary one
 	Metadata          map[string]interface{} `bson:"metadata,omitempty" json:"metadata,omitempty"`
 	LastLogin         *time.Time         `bson:"last_login,omitempty" json:"last_login,omitempty"`
 	LoginAttempts     int                `bson:"login_attempts" json:"-"`
 	LockedUntil       *time.Time         `bson:"locked_until,omitempty" json:"-"`
+	MFAEnabled        bool               `bson:"mfa_enabled" json:"mfa_enabled"`
+	MFASecret         string             `bson:"mfa_secret,omitempty" json:"-"` // Base32 TOTP secret
+	MFAPendingSecret  string             `bson:"mfa_pending_secret,omitempty" json:"-"` // Secret being enrolled, until a code confirms it
+	MFARecoveryCodes  []string           `bson:"mfa_recovery_codes,omitempty" json:"-"` // SHA-256 of the unused recovery codes
+	MFALastStep       int64              `bson:"mfa_last_step" json:"-"` // Last accepted TOTP time step, so a code works once
+	MFARevision       int64              `bson:"mfa_revision" json:"-"` // Bumped on every MFA change to guard concurrent updates
 	Active            bool               `bson:"active" json:"active"`
 	CreatedAt         time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt         time.Time          `bson:"updated_at" json:"updated_at"`
 	DeletedAt         *time.Time         `bson:"deleted_at,omitempty" json:"-"`
 }
 
+// AllRoles returns every role the user holds, primary role first
+func (u *User) AllRoles() []string {
+	roles := []string{}
+	seen := map[string]bool{}
+	for _, role := range append([]string{u.Role}, u.Roles...) {
+		if role != "" && !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
 // UserRegistration represents the user registration request
 type UserRegistration struct {
 	Email     string `json:"email" validate:"required,email"`
@@ -50,6 +73,70 @@ type UserRegistration struct {
 type UserLogin struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required"`
+	Org      string `json:"org,omitempty"` // Organization slug to sign in to; empty for the platform
+
+	// Client details recorded on the session, filled in by the handler
+	IPAddress string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
+// PasswordlessRequest asks for an email with a sign-in link and a one-time code
+type PasswordlessRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Org   string `json:"org,omitempty"` // Organization slug to sign in to; empty for the platform
+}
+
+// PasswordlessLogin completes a passwordless sign-in with the token from the emailed link,
+// or with the email address and the code
+type PasswordlessLogin struct {
+	Token string `json:"token,omitempty"`
+	Email string `json:"email,omitempty"`
+	Code  string `json:"code,omitempty"`
+
+	// Client details recorded on the session, filled in by the handler
+	IPAddress string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
+// ImpersonationRequest is an admin's request to act as another user
+type ImpersonationRequest struct {
+	Reason string `json:"reason,omitempty"` // Recorded in the audit log, such as a support ticket
+	Org    string `json:"org,omitempty"`    // Organization slug to act in; empty for the platform
+
+	// Client details recorded on the session, filled in by the handler
+	IPAddress string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
+// UserIdentity links a user to their account at an external identity provider
+type UserIdentity struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Provider  string             `bson:"provider" json:"provider"`
+	Subject   string             `bson:"subject" json:"subject"` // The provider's stable ID for the user
+	Email     string             `bson:"email,omitempty" json:"email,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// OIDCCallback represents the redirect back from an identity provider
+type OIDCCallback struct {
+	Provider string
+	Code     string
+	State    string
+
+	// Client details recorded on the session, filled in by the handler
+	IPAddress string
+	UserAgent string
+}
+
+// MFAVerification represents the second step of a login that requires MFA
+type MFAVerification struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required"` // TOTP code or recovery code
+
+	// Client details recorded on the session, filled in by the handler
+	IPAddress string `json:"-"`
+	UserAgent string `json:"-"`
 }
 
 // UserUpdate represents the user update request
@@ -71,17 +158,31 @@ type PasswordUpdate struct {
 	NewPassword string `json:"new_password" validate:"required,min=8"`
 }
 
-// Session represents a user session
+// Session represents a user session. Every token issued at login carries the session ID,
+// so revoking the session invalidates its access and refresh tokens.
 type Session struct {
-	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID       primitive.ObjectID `bson:"user_id" json:"user_id"`
-	Token        string             `bson:"token" json:"token"`
-	RefreshToken string             `bson:"refresh_token" json:"refresh_token"`
-	IPAddress    string             `bson:"ip_address" json:"ip_address"`
-	UserAgent    string             `bson:"user_agent" json:"user_agent"`
-	ExpiresAt    time.Time          `bson:"expires_at" json:"expires_at"`
-	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	IPAddress string             `bson:"ip_address" json:"ip_address"`
+	UserAgent string             `bson:"user_agent" json:"user_agent"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+	RevokedAt *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+
+	// RefreshTokenID is the only refresh token of the session that may still be used. The
+	// session is the token family: presenting an earlier token revokes it.
+	RefreshTokenID string `bson:"refresh_token_id" json:"-"`
+
+	// ImpersonatorID is the admin who opened the session to act as the user
+	ImpersonatorID *primitive.ObjectID `bson:"impersonator_id,omitempty" json:"impersonator_id,omitempty"`
+
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+	Current   bool               `bson:"-" json:"current,omitempty"` // Set when listing the caller's own sessions
+}
+
+// Active reports whether the session can still authenticate requests
+func (s *Session) Active() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
 }
 
 
@@ -90,6 +191,8 @@ type AccessKey struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Name        string             `bson:"name" json:"name" validate:"required"`
 	Description string             `bson:"description,omitempty" json:"description"`
+	PublicID    string             `bson:"public_id,omitempty" json:"public_id,omitempty"` // Identifies the key in ak_<public_id>_<secret>
+	OrgID       primitive.ObjectID `bson:"org_id,omitempty" json:"org_id,omitempty"`       // Organization the key acts in; zero for the default namespace
 	Key         string             `bson:"-" json:"key,omitempty"` // Never stored, only returned on creation
 	KeyHash     string             `bson:"key_hash" json:"-"`       // Hashed version stored in DB
 	Permissions []string           `bson:"permissions" json:"permissions"` // Direct permissions using pattern type:name:action
@@ -99,6 +202,20 @@ type AccessKey struct {
 	Active      bool               `bson:"active" json:"active"`
 	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+
+	// Restrictions
+	AllowedCIDRs   []string `bson:"allowed_cidrs,omitempty" json:"allowed_cidrs,omitempty"`     // Empty allows any client IP
+	AllowedOrigins []string `bson:"allowed_origins,omitempty" json:"allowed_origins,omitempty"` // Browser origins, e.g. https://*.example.com
+	RateLimit      int      `bson:"rate_limit,omitempty" json:"rate_limit,omitempty"`           // Requests per minute, 0 = unlimited
+
+	// Rotation: the previous key keeps working until PreviousKeyExpiresAt
+	PreviousKeyHash      string     `bson:"previous_key_hash,omitempty" json:"-"`
+	PreviousKeyExpiresAt *time.Time `bson:"previous_key_expires_at,omitempty" json:"previous_key_expires_at,omitempty"`
+
+	// Usage
+	RequestCount int64  `bson:"request_count" json:"request_count"`
+	LastIP       string `bson:"last_ip,omitempty" json:"last_ip,omitempty"`
+	LastEndpoint string `bson:"last_endpoint,omitempty" json:"last_endpoint,omitempty"`
 }
 
 // AuditLog represents an audit log entry